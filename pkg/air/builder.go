@@ -81,7 +81,7 @@ func NewRecordBuilderWithRecord(allocator *memory.GoAllocator, record *Record, c
 
 	for fieldIdx := range record.fields {
 		fieldName := record.fields[fieldIdx].Name
-		fieldType := record.fields[fieldIdx].DataType()
+		fieldType := record.FieldType(fieldIdx)
 		fieldPath := builder.columns.CreateColumn(allocator, []int{fieldIdx}, fieldName, fieldType, config, &builder.dictIdGen)
 		builder.columns.UpdateColumn(fieldPath, record.fields[fieldIdx])
 		if fieldPath != nil {
@@ -18,6 +18,8 @@ import (
 	"github.com/apache/arrow/go/v9/arrow"
 	"github.com/apache/arrow/go/v9/arrow/array"
 	"github.com/apache/arrow/go/v9/arrow/memory"
+
+	"otel-arrow-adapter/pkg/air/rfield"
 )
 
 // BinaryColumn is a column of binary data.
@@ -26,6 +28,8 @@ type BinaryColumn struct {
 	name string
 	// Data of the column.
 	data []*[]byte
+	// Optional dictionary type of the column, the column is built as an Arrow dictionary when set.
+	dictionaryType *arrow.DictionaryType
 }
 
 // MakeBinaryColumn creates a new Binary column.
@@ -56,11 +60,34 @@ func (c *BinaryColumn) Name() string {
 	return c.name
 }
 
+// Type returns the type of the column.
+func (c *BinaryColumn) Type() arrow.DataType {
+	if c.dictionaryType != nil {
+		return c.dictionaryType
+	}
+	return arrow.BinaryTypes.Binary
+}
+
 // Push adds a new value to the column.
 func (c *BinaryColumn) Push(data *[]byte) {
 	c.data = append(c.data, data)
 }
 
+// PushFromValues adds the given values to the column.
+func (c *BinaryColumn) PushFromValues(_ *rfield.FieldPath, data []rfield.Value) {
+	for _, v := range data {
+		if _, ok := v.(*rfield.Null); ok {
+			c.Push(nil)
+			continue
+		}
+		bv, err := v.AsBinary()
+		if err != nil {
+			panic(err)
+		}
+		c.Push(&bv)
+	}
+}
+
 // Len returns the number of values in the column.
 func (c *BinaryColumn) Len() int {
 	return len(c.data)
@@ -75,3 +102,37 @@ func (c *BinaryColumn) Clear() {
 func (c *BinaryColumn) NewBinarySchemaField() *arrow.Field {
 	return &arrow.Field{Name: c.name, Type: arrow.BinaryTypes.Binary}
 }
+
+// NewArrowField creates a Binary (or a dictionary of Binary) schema field.
+func (c *BinaryColumn) NewArrowField() *arrow.Field {
+	return &arrow.Field{Name: c.name, Type: c.Type()}
+}
+
+// NewArray creates and initializes a new Arrow Array (Binary or dictionary of Binary) for the column.
+func (c *BinaryColumn) NewArray(allocator *memory.GoAllocator) arrow.Array {
+	_, arr, err := c.Build(allocator)
+	if err != nil {
+		panic(err)
+	}
+	return arr
+}
+
+// Build builds the Arrow field and the Arrow Array (Binary or dictionary of Binary) of the column.
+func (c *BinaryColumn) Build(allocator *memory.GoAllocator) (*arrow.Field, arrow.Array, error) {
+	if c.dictionaryType == nil {
+		return c.NewArrowField(), c.NewBinaryArray(allocator), nil
+	}
+
+	builder := array.NewDictionaryBuilder(allocator, c.dictionaryType).(*array.BinaryDictionaryBuilder)
+	defer builder.Release()
+	builder.Reserve(c.Len())
+	for _, v := range c.data {
+		if v == nil {
+			builder.AppendNull()
+		} else if err := builder.Append(*v); err != nil {
+			return nil, nil, err
+		}
+	}
+	c.Clear()
+	return c.NewArrowField(), builder.NewArray(), nil
+}
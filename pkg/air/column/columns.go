@@ -43,6 +43,7 @@ type Column interface {
 }
 
 type Columns struct {
+	NullColumns    []NullColumn
 	BooleanColumns []BoolColumn
 
 	I8Columns  []I8Column
@@ -93,6 +94,9 @@ func NewColumns(allocator *memory.GoAllocator, fieldType arrow.DataType, fieldPa
 // CreateColumn creates a column with a field based on its field type and field name.
 func (c *Columns) CreateColumn(allocator *memory.GoAllocator, path []int, fieldName string, fieldType arrow.DataType, config *config.Config, dictIdGen *dictionary.DictIdGenerator) *rfield.FieldPath {
 	switch t := fieldType.(type) {
+	case *arrow.NullType:
+		c.NullColumns = append(c.NullColumns, MakeNullColumn(fieldName))
+		return rfield.NewFieldPath(len(c.NullColumns) - 1)
 	case *arrow.BooleanType:
 		c.BooleanColumns = append(c.BooleanColumns, MakeBoolColumn(fieldName))
 		return rfield.NewFieldPath(len(c.BooleanColumns) - 1)
@@ -133,9 +137,25 @@ func (c *Columns) CreateColumn(allocator *memory.GoAllocator, path []int, fieldN
 	case *arrow.BinaryType:
 		c.BinaryColumns = append(c.BinaryColumns, MakeBinaryColumn(fieldName))
 		return rfield.NewFieldPath(len(c.BinaryColumns) - 1)
+	case *arrow.DictionaryType:
+		// Dictionary columns are requested explicitly (see `Record.DictionaryField`).
+		switch t.ValueType.(type) {
+		case *arrow.StringType:
+			stringColumn := NewStringColumn(fieldName, &config.Dictionaries.StringColumns, path, dictIdGen.NextId())
+			stringColumn.dictionaryType = t
+			c.StringColumns = append(c.StringColumns, *stringColumn)
+			return rfield.NewFieldPath(len(c.StringColumns) - 1)
+		case *arrow.BinaryType:
+			binaryColumn := MakeBinaryColumn(fieldName)
+			binaryColumn.dictionaryType = t
+			c.BinaryColumns = append(c.BinaryColumns, binaryColumn)
+			return rfield.NewFieldPath(len(c.BinaryColumns) - 1)
+		default:
+			panic("unsupported dictionary value type")
+		}
 	case *arrow.ListType:
 		etype := t.Elem()
-		listColumn, fieldPaths := MakeListColumn(allocator, path, etype, config, dictIdGen)
+		listColumn, fieldPaths := MakeListColumn(allocator, path, fieldName, etype, config, dictIdGen)
 		c.ListColumns = append(c.ListColumns, listColumn)
 		if fieldPaths == nil {
 			return rfield.NewFieldPath(len(c.ListColumns) - 1)
@@ -143,13 +163,10 @@ func (c *Columns) CreateColumn(allocator *memory.GoAllocator, path []int, fieldN
 			return rfield.NewFieldPathWithChildren(len(c.ListColumns)-1, fieldPaths)
 		}
 	case *arrow.StructType:
+		// Empty structs are represented by struct columns without children.
 		columns, fieldPaths := NewColumns(allocator, fieldType, path, config, dictIdGen)
-		if !columns.IsEmpty() {
-			c.StructColumns = append(c.StructColumns, NewStructColumn(fieldName, fieldType, columns))
-			return rfield.NewFieldPathWithChildren(len(c.StructColumns)-1, fieldPaths)
-		} else {
-			return nil
-		}
+		c.StructColumns = append(c.StructColumns, NewStructColumn(fieldName, fieldType, columns))
+		return rfield.NewFieldPathWithChildren(len(c.StructColumns)-1, fieldPaths)
 	default:
 		panic("unsupported field type")
 	}
@@ -157,6 +174,8 @@ func (c *Columns) CreateColumn(allocator *memory.GoAllocator, path []int, fieldN
 
 func (c *Columns) UpdateColumn(fieldPath *rfield.FieldPath, field *rfield.Field) {
 	switch t := field.Value.(type) {
+	case *rfield.Null:
+		c.pushNull(fieldPath, t.DataType())
 	case *rfield.I8:
 		c.I8Columns[fieldPath.Current].Push(&t.Value)
 		c.length = c.I8Columns[fieldPath.Current].Len()
@@ -197,12 +216,137 @@ func (c *Columns) UpdateColumn(fieldPath *rfield.FieldPath, field *rfield.Field)
 		c.BooleanColumns[fieldPath.Current].Push(&t.Value)
 		c.length = c.BooleanColumns[fieldPath.Current].Len()
 	case *rfield.List:
-		c.ListColumns[fieldPath.Current].Push(fieldPath, t.Values)
+		values := t.Values
+		if values == nil {
+			// A List value is never null, a nil slice is an empty list.
+			values = []rfield.Value{}
+		}
+		c.ListColumns[fieldPath.Current].Push(fieldPath, values)
 		c.length = c.ListColumns[fieldPath.Current].Len()
 	case *rfield.Struct:
-		for fieldPos := range field.Value.(*rfield.Struct).Fields {
-			c.StructColumns[fieldPath.Current].Push(fieldPath.Children[fieldPos], t.Fields[fieldPos])
+		c.StructColumns[fieldPath.Current].PushStruct(fieldPath, t)
+		c.length = c.StructColumns[fieldPath.Current].Len()
+	default:
+		panic("unsupported field type")
+	}
+}
+
+// pushNull adds a null entry to the column of the given type (see `rfield.Null`).
+func (c *Columns) pushNull(fieldPath *rfield.FieldPath, dataType arrow.DataType) {
+	switch dataType.(type) {
+	case *arrow.NullType:
+		c.NullColumns[fieldPath.Current].Push()
+		c.length = c.NullColumns[fieldPath.Current].Len()
+	case *arrow.BooleanType:
+		c.BooleanColumns[fieldPath.Current].Push(nil)
+		c.length = c.BooleanColumns[fieldPath.Current].Len()
+	case *arrow.Int8Type:
+		c.I8Columns[fieldPath.Current].Push(nil)
+		c.length = c.I8Columns[fieldPath.Current].Len()
+	case *arrow.Int16Type:
+		c.I16Columns[fieldPath.Current].Push(nil)
+		c.length = c.I16Columns[fieldPath.Current].Len()
+	case *arrow.Int32Type:
+		c.I32Columns[fieldPath.Current].Push(nil)
+		c.length = c.I32Columns[fieldPath.Current].Len()
+	case *arrow.Int64Type:
+		c.I64Columns[fieldPath.Current].Push(nil)
+		c.length = c.I64Columns[fieldPath.Current].Len()
+	case *arrow.Uint8Type:
+		c.U8Columns[fieldPath.Current].Push(nil)
+		c.length = c.U8Columns[fieldPath.Current].Len()
+	case *arrow.Uint16Type:
+		c.U16Columns[fieldPath.Current].Push(nil)
+		c.length = c.U16Columns[fieldPath.Current].Len()
+	case *arrow.Uint32Type:
+		c.U32Columns[fieldPath.Current].Push(nil)
+		c.length = c.U32Columns[fieldPath.Current].Len()
+	case *arrow.Uint64Type:
+		c.U64Columns[fieldPath.Current].Push(nil)
+		c.length = c.U64Columns[fieldPath.Current].Len()
+	case *arrow.Float32Type:
+		c.F32Columns[fieldPath.Current].Push(nil)
+		c.length = c.F32Columns[fieldPath.Current].Len()
+	case *arrow.Float64Type:
+		c.F64Columns[fieldPath.Current].Push(nil)
+		c.length = c.F64Columns[fieldPath.Current].Len()
+	case *arrow.StringType:
+		c.StringColumns[fieldPath.Current].Push(nil)
+		c.length = c.StringColumns[fieldPath.Current].Len()
+	case *arrow.BinaryType:
+		c.BinaryColumns[fieldPath.Current].Push(nil)
+		c.length = c.BinaryColumns[fieldPath.Current].Len()
+	case *arrow.ListType:
+		c.ListColumns[fieldPath.Current].Push(fieldPath, nil)
+		c.length = c.ListColumns[fieldPath.Current].Len()
+	case *arrow.StructType:
+		c.StructColumns[fieldPath.Current].PushFromValues(fieldPath, []rfield.Value{&rfield.Null{}})
+		c.length = c.StructColumns[fieldPath.Current].Len()
+	default:
+		panic("unsupported null type")
+	}
+}
+
+// PushValue adds a value to the column of the given type, the value is converted to this type if needed (e.g. the items
+// of a list coerced to a common type, see `StructColumn.PushFromValues`). Null values become null entries.
+func (c *Columns) PushValue(fieldPath *rfield.FieldPath, dataType arrow.DataType, value rfield.Value) {
+	values := []rfield.Value{value}
+	switch dataType.(type) {
+	case *arrow.NullType:
+		c.NullColumns[fieldPath.Current].PushFromValues(fieldPath, values)
+		c.length = c.NullColumns[fieldPath.Current].Len()
+	case *arrow.BooleanType:
+		c.BooleanColumns[fieldPath.Current].PushFromValues(fieldPath, values)
+		c.length = c.BooleanColumns[fieldPath.Current].Len()
+	case *arrow.Int8Type:
+		c.I8Columns[fieldPath.Current].PushFromValues(fieldPath, values)
+		c.length = c.I8Columns[fieldPath.Current].Len()
+	case *arrow.Int16Type:
+		c.I16Columns[fieldPath.Current].PushFromValues(fieldPath, values)
+		c.length = c.I16Columns[fieldPath.Current].Len()
+	case *arrow.Int32Type:
+		c.I32Columns[fieldPath.Current].PushFromValues(fieldPath, values)
+		c.length = c.I32Columns[fieldPath.Current].Len()
+	case *arrow.Int64Type:
+		c.I64Columns[fieldPath.Current].PushFromValues(fieldPath, values)
+		c.length = c.I64Columns[fieldPath.Current].Len()
+	case *arrow.Uint8Type:
+		c.U8Columns[fieldPath.Current].PushFromValues(fieldPath, values)
+		c.length = c.U8Columns[fieldPath.Current].Len()
+	case *arrow.Uint16Type:
+		c.U16Columns[fieldPath.Current].PushFromValues(fieldPath, values)
+		c.length = c.U16Columns[fieldPath.Current].Len()
+	case *arrow.Uint32Type:
+		c.U32Columns[fieldPath.Current].PushFromValues(fieldPath, values)
+		c.length = c.U32Columns[fieldPath.Current].Len()
+	case *arrow.Uint64Type:
+		c.U64Columns[fieldPath.Current].PushFromValues(fieldPath, values)
+		c.length = c.U64Columns[fieldPath.Current].Len()
+	case *arrow.Float32Type:
+		c.F32Columns[fieldPath.Current].PushFromValues(fieldPath, values)
+		c.length = c.F32Columns[fieldPath.Current].Len()
+	case *arrow.Float64Type:
+		c.F64Columns[fieldPath.Current].PushFromValues(fieldPath, values)
+		c.length = c.F64Columns[fieldPath.Current].Len()
+	case *arrow.StringType:
+		c.StringColumns[fieldPath.Current].PushFromValues(fieldPath, values)
+		c.length = c.StringColumns[fieldPath.Current].Len()
+	case *arrow.BinaryType:
+		c.BinaryColumns[fieldPath.Current].PushFromValues(fieldPath, values)
+		c.length = c.BinaryColumns[fieldPath.Current].Len()
+	case *arrow.ListType:
+		// A null value is a null list, a List value is never null (see UpdateColumn).
+		var items []rfield.Value
+		if list, ok := value.(*rfield.List); ok {
+			items = list.Values
+			if items == nil {
+				items = []rfield.Value{}
+			}
 		}
+		c.ListColumns[fieldPath.Current].Push(fieldPath, items)
+		c.length = c.ListColumns[fieldPath.Current].Len()
+	case *arrow.StructType:
+		c.StructColumns[fieldPath.Current].PushFromValues(fieldPath, values)
 		c.length = c.StructColumns[fieldPath.Current].Len()
 	default:
 		panic("unsupported field type")
@@ -214,6 +358,11 @@ func (c *Columns) Build(allocator *memory.GoAllocator) ([]*arrow.Field, []arrow.
 	fields := make([]*arrow.Field, 0, columnCount)
 	arrays := make([]arrow.Array, 0, columnCount)
 
+	for i := range c.NullColumns {
+		col := &c.NullColumns[i]
+		fields = append(fields, col.NewArrowField())
+		arrays = append(arrays, col.NewArray(allocator))
+	}
 	for i := range c.BooleanColumns {
 		col := &c.BooleanColumns[i]
 		fields = append(fields, col.NewArrowField())
@@ -270,16 +419,20 @@ func (c *Columns) Build(allocator *memory.GoAllocator) ([]*arrow.Field, []arrow.
 		arrays = append(arrays, col.NewArray(allocator))
 	}
 	for i := range c.StringColumns {
-		col := &c.StringColumns[i]
-		// ToDo implement dictionary builder when that makes sense
-		fields = append(fields, col.NewStringSchemaField())
-		arrays = append(arrays, col.NewStringArray(allocator))
+		field, arr, err := c.StringColumns[i].Build(allocator)
+		if err != nil {
+			return nil, nil, err
+		}
+		fields = append(fields, field)
+		arrays = append(arrays, arr)
 	}
 	for i := range c.BinaryColumns {
-		col := &c.BinaryColumns[i]
-		// ToDo implement dictionary builder when that makes sense
-		fields = append(fields, col.NewBinarySchemaField())
-		arrays = append(arrays, col.NewBinaryArray(allocator))
+		field, arr, err := c.BinaryColumns[i].Build(allocator)
+		if err != nil {
+			return nil, nil, err
+		}
+		fields = append(fields, field)
+		arrays = append(arrays, arr)
 	}
 	for i := range c.StructColumns {
 		col := c.StructColumns[i]
@@ -294,6 +447,7 @@ func (c *Columns) Build(allocator *memory.GoAllocator) ([]*arrow.Field, []arrow.
 		col := c.ListColumns[i]
 		listField := col.NewArrowField()
 		listArray := col.NewArray(allocator)
+		listField.Type = listArray.DataType()
 		fields = append(fields, listField)
 		arrays = append(arrays, listArray)
 	}
@@ -305,7 +459,7 @@ func (c *Columns) ColumnCount() int {
 	return len(c.I8Columns) + len(c.I16Columns) + len(c.I32Columns) + len(c.I64Columns) +
 		len(c.U8Columns) + len(c.U16Columns) + len(c.U32Columns) + len(c.U64Columns) +
 		len(c.F32Columns) + len(c.F64Columns) +
-		len(c.BooleanColumns) +
+		len(c.NullColumns) + len(c.BooleanColumns) +
 		len(c.StringColumns) +
 		len(c.BinaryColumns) +
 		len(c.ListColumns) +
@@ -317,6 +471,9 @@ func (c *Columns) Len() int {
 }
 
 func (c *Columns) Clear() {
+	for i := range c.NullColumns {
+		c.NullColumns[i].Clear()
+	}
 	for i := range c.BooleanColumns {
 		c.BooleanColumns[i].Clear()
 	}
@@ -366,13 +523,13 @@ func (c *Columns) Clear() {
 }
 
 func (c *Columns) IsEmpty() bool {
-	return len(c.I8Columns) == 0 && len(c.I16Columns) == 0 && len(c.I32Columns) == 0 && len(c.I64Columns) == 0 && len(c.U8Columns) == 0 && len(c.U16Columns) == 0 && len(c.U32Columns) == 0 && len(c.U64Columns) == 0 && len(c.F32Columns) == 0 && len(c.F64Columns) == 0 && len(c.BooleanColumns) == 0 && len(c.StringColumns) == 0 && len(c.BinaryColumns) == 0 && len(c.ListColumns) == 0 && len(c.StructColumns) == 0
+	return len(c.I8Columns) == 0 && len(c.I16Columns) == 0 && len(c.I32Columns) == 0 && len(c.I64Columns) == 0 && len(c.U8Columns) == 0 && len(c.U16Columns) == 0 && len(c.U32Columns) == 0 && len(c.U64Columns) == 0 && len(c.F32Columns) == 0 && len(c.F64Columns) == 0 && len(c.NullColumns) == 0 && len(c.BooleanColumns) == 0 && len(c.StringColumns) == 0 && len(c.BinaryColumns) == 0 && len(c.ListColumns) == 0 && len(c.StructColumns) == 0
 }
 
 func (c *Columns) Metadata() []*ColumnMetadata {
 	metadata := make([]*ColumnMetadata, 0, len(c.I8Columns)+len(c.I16Columns)+len(c.I32Columns)+len(c.I64Columns)+
 		len(c.U8Columns)+len(c.U16Columns)+len(c.U32Columns)+len(c.U64Columns)+len(c.F32Columns)+len(c.F64Columns)+
-		len(c.BooleanColumns)+len(c.StringColumns)+len(c.BinaryColumns)+len(c.ListColumns)+len(c.StructColumns))
+		len(c.NullColumns)+len(c.BooleanColumns)+len(c.StringColumns)+len(c.BinaryColumns)+len(c.ListColumns)+len(c.StructColumns))
 
 	for _, i8Column := range c.I8Columns {
 		metadata = append(metadata, &ColumnMetadata{
@@ -444,6 +601,13 @@ func (c *Columns) Metadata() []*ColumnMetadata {
 			Len:  f64Column.Len(),
 		})
 	}
+	for _, nullColumn := range c.NullColumns {
+		metadata = append(metadata, &ColumnMetadata{
+			Name: nullColumn.Name(),
+			Type: arrow.Null,
+			Len:  nullColumn.Len(),
+		})
+	}
 	for _, booleanColumn := range c.BooleanColumns {
 		metadata = append(metadata, &ColumnMetadata{
 			Name: booleanColumn.Name(),
@@ -47,10 +47,13 @@ type ListColumnBase struct {
 	values     Column
 }
 
-func MakeListColumn(allocator *memory.GoAllocator, fieldPath []int, etype arrow.DataType, config *config.Config, dictIdGen *dictionary.DictIdGenerator) (ListColumn, []*rfield.FieldPath) {
+func MakeListColumn(allocator *memory.GoAllocator, fieldPath []int, fieldName string, etype arrow.DataType, config *config.Config, dictIdGen *dictionary.DictIdGenerator) (ListColumn, []*rfield.FieldPath) {
 	var values Column
 	fieldPaths := []*rfield.FieldPath(nil)
-	switch etype.(type) {
+	switch t := etype.(type) {
+	case *arrow.NullType:
+		col := MakeNullColumn(etype.Name())
+		values = &col
 	case *arrow.BooleanType:
 		col := MakeBoolColumn(etype.Name())
 		values = &col
@@ -84,17 +87,33 @@ func MakeListColumn(allocator *memory.GoAllocator, fieldPath []int, etype arrow.
 	case *arrow.Float64Type:
 		col := MakeF64Column(etype.Name())
 		values = &col
+	case *arrow.StringType:
+		values = &stringListValues{NewStringColumn(etype.Name(), &config.Dictionaries.StringColumns, fieldPath, dictIdGen.NextId())}
+	case *arrow.BinaryType:
+		col := MakeBinaryColumn(etype.Name())
+		values = &col
 	case *arrow.StructType:
 		columns, fps := NewColumns(allocator, etype, fieldPath, config, dictIdGen)
 		fieldPaths = fps
 		values = NewStructColumn(etype.Name(), etype, columns)
-
-	// List of list of not yet supported
-
+	case *arrow.ListType:
+		listColumn, fps := MakeListColumn(allocator, fieldPath, etype.Name(), t.Elem(), config, dictIdGen)
+		fieldPaths = fps
+		values = listColumn
 	default:
 		panic("ListColumn: unsupported data type")
 	}
-	return NewListColumnBase(allocator, etype.Name(), etype, values), fieldPaths
+	return NewListColumnBase(allocator, fieldName, etype, values), fieldPaths
+}
+
+// stringListValues adapts a StringColumn to the Column interface to store the items of a list of strings.
+type stringListValues struct {
+	*StringColumn
+}
+
+// Name returns the name of the column.
+func (c *stringListValues) Name() string {
+	return *c.StringColumn.Name()
 }
 
 func NewListColumnBase(allocator *memory.GoAllocator, name string, dataType arrow.DataType, values Column) *ListColumnBase {
@@ -128,8 +147,16 @@ func (c *ListColumnBase) Len() int {
 	return c.length
 }
 
-func (c *ListColumnBase) PushFromValues(_ *rfield.FieldPath, _ []rfield.Value) {
-	panic("not implemented")
+// PushFromValues adds the given values (the items of a list of lists) to the column, the values that are not lists are
+// pushed as null lists.
+func (c *ListColumnBase) PushFromValues(fieldPath *rfield.FieldPath, data []rfield.Value) {
+	for _, value := range data {
+		if list, ok := value.(*rfield.List); ok {
+			c.Push(fieldPath, list.Values)
+		} else {
+			c.Push(fieldPath, nil)
+		}
+	}
 }
 
 func (c *ListColumnBase) appendNextOffset(offset int32) {
@@ -252,8 +279,10 @@ func (c *ListColumnBase) NewArray(allocator *memory.GoAllocator) arrow.Array {
 		offsets = arr.Data().Buffers()[1]
 	}
 
+	// The type of the items is taken from the values array, the fields of a struct array are ordered by column type
+	// and not by name as in `etype`.
 	data := array.NewData(
-		arrow.ListOf(c.Type()), c.Len(),
+		arrow.ListOf(values.DataType()), c.Len(),
 		[]*memory.Buffer{
 			c.nullBitmap,
 			offsets,
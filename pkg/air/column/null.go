@@ -0,0 +1,80 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package column
+
+import (
+	"github.com/apache/arrow/go/v9/arrow"
+	"github.com/apache/arrow/go/v9/arrow/array"
+	"github.com/apache/arrow/go/v9/arrow/memory"
+
+	"otel-arrow-adapter/pkg/air/rfield"
+)
+
+// NullColumn is a column of null values (only the number of values is stored).
+type NullColumn struct {
+	// name of the column.
+	name string
+	// number of null values in the column.
+	length int
+}
+
+// MakeNullColumn creates a new null column.
+func MakeNullColumn(name string) NullColumn {
+	return NullColumn{
+		name: name,
+	}
+}
+
+// Name returns the name of the column.
+func (c *NullColumn) Name() string {
+	return c.name
+}
+
+// Type returns the type of the column.
+func (c *NullColumn) Type() arrow.DataType {
+	return arrow.Null
+}
+
+// Push adds a new null value to the column.
+func (c *NullColumn) Push() {
+	c.length++
+}
+
+// PushFromValues adds the given values to the column.
+func (c *NullColumn) PushFromValues(_ *rfield.FieldPath, data []rfield.Value) {
+	c.length += len(data)
+}
+
+// Len returns the number of values in the column.
+func (c *NullColumn) Len() int {
+	return c.length
+}
+
+// Clear resets the column to its initial state.
+func (c *NullColumn) Clear() {
+	c.length = 0
+}
+
+// NewArrowField creates a Null schema field.
+func (c *NullColumn) NewArrowField() *arrow.Field {
+	return &arrow.Field{Name: c.name, Type: arrow.Null, Nullable: true}
+}
+
+// NewArray creates and initializes a new Arrow Array for the column.
+func (c *NullColumn) NewArray(_ *memory.GoAllocator) arrow.Array {
+	arr := array.NewNull(c.length)
+	c.Clear()
+	return arr
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/apache/arrow/go/v9/arrow/memory"
 
 	"otel-arrow-adapter/pkg/air/config"
+	"otel-arrow-adapter/pkg/air/rfield"
 	"otel-arrow-adapter/pkg/air/stats"
 )
 
@@ -41,6 +42,8 @@ type StringColumn struct {
 	totalValueLength int
 	// Total number of rows in the column.
 	totalRowCount int
+	// Optional dictionary type of the column, the column is built as an Arrow dictionary when set.
+	dictionaryType *arrow.DictionaryType
 }
 
 // NewStringColumn creates a new StringColumn.
@@ -62,6 +65,14 @@ func (c *StringColumn) Name() *string {
 	return &c.name
 }
 
+// Type returns the type of the column.
+func (c *StringColumn) Type() arrow.DataType {
+	if c.dictionaryType != nil {
+		return c.dictionaryType
+	}
+	return arrow.BinaryTypes.String
+}
+
 // Push adds a new value to the column.
 func (c *StringColumn) Push(value *string) {
 	// Maintains a dictionary of unique values
@@ -83,6 +94,17 @@ func (c *StringColumn) Push(value *string) {
 	c.data = append(c.data, value)
 }
 
+// PushFromValues adds the given values to the column.
+func (c *StringColumn) PushFromValues(_ *rfield.FieldPath, data []rfield.Value) {
+	for _, v := range data {
+		sv, err := v.AsString()
+		if err != nil {
+			panic(err)
+		}
+		c.Push(sv)
+	}
+}
+
 // DictionaryStats returns the DictionaryStats of the column.
 func (c *StringColumn) DictionaryStats() *stats.DictionaryStats {
 	if c.dictionary != nil {
@@ -128,6 +150,40 @@ func (c *StringColumn) NewStringSchemaField() *arrow.Field {
 	return &arrow.Field{Name: c.name, Type: arrow.BinaryTypes.String}
 }
 
+// NewArrowField creates a String (or a dictionary of String) schema field.
+func (c *StringColumn) NewArrowField() *arrow.Field {
+	return &arrow.Field{Name: c.name, Type: c.Type()}
+}
+
+// NewArray creates and initializes a new Arrow Array (String or dictionary of String) for the column.
+func (c *StringColumn) NewArray(allocator *memory.GoAllocator) arrow.Array {
+	_, arr, err := c.Build(allocator)
+	if err != nil {
+		panic(err)
+	}
+	return arr
+}
+
+// Build builds the Arrow field and the Arrow Array (String or dictionary of String) of the column.
+func (c *StringColumn) Build(allocator *memory.GoAllocator) (*arrow.Field, arrow.Array, error) {
+	if c.dictionaryType == nil {
+		return c.NewArrowField(), c.NewStringArray(allocator), nil
+	}
+
+	builder := array.NewDictionaryBuilder(allocator, c.dictionaryType).(*array.BinaryDictionaryBuilder)
+	defer builder.Release()
+	builder.Reserve(c.Len())
+	for _, v := range c.data {
+		if v == nil {
+			builder.AppendNull()
+		} else if err := builder.AppendString(*v); err != nil {
+			return nil, nil, err
+		}
+	}
+	c.Clear()
+	return c.NewArrowField(), builder.NewArray(), nil
+}
+
 // NewStringArray creates and initializes a new Arrow Array for the column.
 func (c *StringColumn) NewStringArray(allocator *memory.GoAllocator) arrow.Array {
 	builder := array.NewStringBuilder(allocator)
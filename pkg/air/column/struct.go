@@ -17,6 +17,7 @@ package column
 import (
 	"github.com/apache/arrow/go/v9/arrow"
 	"github.com/apache/arrow/go/v9/arrow/array"
+	"github.com/apache/arrow/go/v9/arrow/bitutil"
 	"github.com/apache/arrow/go/v9/arrow/memory"
 
 	"otel-arrow-adapter/pkg/air/rfield"
@@ -28,6 +29,11 @@ type StructColumn struct {
 	name       string
 	structType arrow.DataType
 	columns    *Columns
+	// Number of structs pushed in the column (the column can be an empty struct without children).
+	length int
+	// Validity of the structs pushed in the column (the null values typed as a struct are not valid).
+	validity []bool
+	nulls    int
 }
 
 // NewStructColumn creates a new Struct column.
@@ -39,11 +45,20 @@ func NewStructColumn(name string, structType arrow.DataType, columns *Columns) *
 	}
 }
 
-// Push pushes the value to the column.
+// Push pushes the value of a single field of the struct to the column (see PushStruct to push an entire struct).
 func (c *StructColumn) Push(fieldPath *rfield.FieldPath, field *rfield.Field) {
 	c.columns.UpdateColumn(fieldPath, field)
 }
 
+// PushStruct pushes all the fields of a struct to the column.
+func (c *StructColumn) PushStruct(fieldPath *rfield.FieldPath, value *rfield.Struct) {
+	for i, field := range value.Fields {
+		c.Push(fieldPath.ChildPath(i), field)
+	}
+	c.length++
+	c.validity = append(c.validity, true)
+}
+
 // Name returns the name of the column.
 func (c *StructColumn) Name() string {
 	return c.name
@@ -51,20 +66,45 @@ func (c *StructColumn) Name() string {
 
 // Len returns the number of elements in the column.
 func (c *StructColumn) Len() int {
-	return c.columns.Len()
+	return c.length
 }
 
 // Clear resets the column to its initial state.
 func (c *StructColumn) Clear() {
 	c.columns.Clear()
+	c.length = 0
+	c.validity = c.validity[:0]
+	c.nulls = 0
 }
 
-// PushFromValues adds the given values to the column.
+// PushFromValues adds the given values (the items of a list) to the column. The items of a list don't necessarily share
+// the same fields, the column type is the union of the item types (see `rfield.CoerceDataType`). The fields are matched
+// by name and the fields missing from an item (or all the fields of a null item) are pushed as nulls.
 func (c *StructColumn) PushFromValues(fieldPath *rfield.FieldPath, data []rfield.Value) {
+	fields := c.structType.(*arrow.StructType).Fields()
 	for _, value := range data {
-		for i, field := range value.(*rfield.Struct).Fields {
-			c.Push(fieldPath.ChildPath(i), field)
+		var itemFields []*rfield.Field
+		item, valid := value.(*rfield.Struct)
+		if valid {
+			itemFields = item.Fields
+		} else {
+			c.nulls++
 		}
+
+		// The fields of the column type and the fields of a normalized struct are both sorted by name.
+		j := 0
+		for i, field := range fields {
+			for j < len(itemFields) && itemFields[j].Name < field.Name {
+				j++
+			}
+			var fieldValue rfield.Value = &rfield.Null{}
+			if j < len(itemFields) && itemFields[j].Name == field.Name {
+				fieldValue = itemFields[j].Value
+			}
+			c.columns.PushValue(fieldPath.ChildPath(i), field.Type, fieldValue)
+		}
+		c.length++
+		c.validity = append(c.validity, valid)
 	}
 }
 
@@ -91,7 +131,11 @@ func (c *StructColumn) NewArray(allocator *memory.GoAllocator) arrow.Array {
 		defer fieldArray.Release()
 		children[i] = fieldArray.Data()
 	}
-	data := array.NewData(arrow.StructOf(fields...), children[0].Len(), []*memory.Buffer{nil, nil}, children, 0, 0)
+	nullBitmap := c.newNullBitmap(allocator)
+	if nullBitmap != nil {
+		defer nullBitmap.Release()
+	}
+	data := array.NewData(arrow.StructOf(fields...), c.length, []*memory.Buffer{nullBitmap, nil}, children, c.nulls, 0)
 	defer data.Release()
 	structArray := array.NewStructData(data)
 
@@ -121,7 +165,11 @@ func (c *StructColumn) Build(allocator *memory.GoAllocator) (*arrow.Field, arrow
 		defer fieldArray.Release()
 		children[i] = fieldArray.Data()
 	}
-	data := array.NewData(arrow.StructOf(fields...), children[0].Len(), []*memory.Buffer{nil, nil}, children, 0, 0)
+	nullBitmap := c.newNullBitmap(allocator)
+	if nullBitmap != nil {
+		defer nullBitmap.Release()
+	}
+	data := array.NewData(arrow.StructOf(fields...), c.length, []*memory.Buffer{nullBitmap, nil}, children, c.nulls, 0)
 	defer data.Release()
 	structArray := array.NewStructData(data)
 
@@ -130,6 +178,22 @@ func (c *StructColumn) Build(allocator *memory.GoAllocator) (*arrow.Field, arrow
 	return &structField, structArray, nil
 }
 
+// newNullBitmap returns the validity bitmap of the column, or nil if all the structs are valid.
+func (c *StructColumn) newNullBitmap(allocator *memory.GoAllocator) *memory.Buffer {
+	if c.nulls == 0 {
+		return nil
+	}
+	nullBitmap := memory.NewResizableBuffer(allocator)
+	nullBitmap.Resize(bitutil.CeilByte(c.length) / 8)
+	memory.Set(nullBitmap.Buf(), 0)
+	for i, valid := range c.validity {
+		if valid {
+			bitutil.SetBit(nullBitmap.Bytes(), i)
+		}
+	}
+	return nullBitmap
+}
+
 // DictionaryStats returns the dictionary statistics of the column.
 func (c *StructColumn) DictionaryStats() []*stats.DictionaryStats {
 	return c.columns.DictionaryStats()
@@ -19,31 +19,31 @@ import "math"
 // Config defines configuration for RecordRepository.
 type Config struct {
 	// Configuration for the dictionaries
-	Dictionaries DictionariesConfig
+	Dictionaries DictionariesConfig `json:"dictionaries" yaml:"dictionaries"`
 }
 
 // DictionariesConfig defines configuration for binary and string dictionaries.
 type DictionariesConfig struct {
 	// Dictionary options for binary columns
-	BinaryColumns DictionaryConfig
+	BinaryColumns DictionaryConfig `json:"binary_columns" yaml:"binary_columns"`
 
 	// Dictionary options for string columns
-	StringColumns DictionaryConfig
+	StringColumns DictionaryConfig `json:"string_columns" yaml:"string_columns"`
 }
 
 // DictionaryConfig defines configuration for a dictionary.
 type DictionaryConfig struct {
 	// The creation of a dictionary will be performed only on columns with more than `min_row_count` elements.
-	MinRowCount int
+	MinRowCount int `json:"min_row_count" yaml:"min_row_count"`
 
 	// The creation of a dictionary will be performed only on columns with a cardinality lower than `max_card`.
-	MaxCard int
+	MaxCard int `json:"max_card" yaml:"max_card"`
 
 	// The creation of a dictionary will only be performed on columns with a ratio `card` / `size` <= `max_card_ratio`.
-	MaxCardRatio float64
+	MaxCardRatio float64 `json:"max_card_ratio" yaml:"max_card_ratio"`
 
 	// Maximum number of sorted dictionaries (based on cardinality/total_size and avg_data_length).
-	MaxSortedDictionaries int
+	MaxSortedDictionaries int `json:"max_sorted_dictionaries" yaml:"max_sorted_dictionaries"`
 }
 
 func NewDefaultConfig() *Config {
@@ -0,0 +1,143 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the serialization format of a configuration.
+type Format int
+
+const (
+	FormatJSON Format = iota
+	FormatYAML
+)
+
+// FormatFromPath returns the configuration format associated with the extension of a file path (.json, .yaml or .yml).
+func FormatFromPath(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	default:
+		return 0, fmt.Errorf("config: unsupported file extension %q (expected .json, .yaml or .yml)", filepath.Ext(path))
+	}
+}
+
+// Validator is a configuration checking its own consistency (see `LoadInto`).
+type Validator interface {
+	Validate() error
+}
+
+// Load parses and validates a Config. The settings not defined in the data keep the values of `NewDefaultConfig`.
+func Load(data []byte, format Format) (*Config, error) {
+	config := NewDefaultConfig()
+	if err := LoadInto(data, format, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// LoadFile parses and validates the Config stored in a JSON or YAML file (see `FormatFromPath`).
+func LoadFile(path string) (*Config, error) {
+	config := NewDefaultConfig()
+	if err := LoadFileInto(path, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// LoadInto decodes (see `Decode`) and validates a configuration. The settings not defined in the data keep the values
+// of `config`, usually initialized with the default configuration.
+func LoadInto(data []byte, format Format, config Validator) error {
+	if err := Decode(data, format, config); err != nil {
+		return err
+	}
+	return config.Validate()
+}
+
+// LoadFileInto decodes and validates the configuration stored in a JSON or YAML file (see `FormatFromPath` and
+// `LoadInto`).
+func LoadFileInto(path string, config Validator) error {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := LoadInto(data, format, config); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Decode strictly decodes JSON or YAML data into `v` (unknown fields are reported as errors). An empty document leaves
+// `v` unchanged.
+func Decode(data []byte, format Format, v interface{}) error {
+	switch format {
+	case FormatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(v); err != nil && err != io.EOF {
+			return fmt.Errorf("config: invalid json: %w", err)
+		}
+	case FormatYAML:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(v); err != nil && err != io.EOF {
+			return fmt.Errorf("config: invalid yaml: %w", err)
+		}
+	default:
+		return fmt.Errorf("config: unknown format %d", format)
+	}
+	return nil
+}
+
+// Validate checks the consistency of the configuration.
+func (c *Config) Validate() error {
+	if err := c.Dictionaries.BinaryColumns.validate("dictionaries.binary_columns"); err != nil {
+		return err
+	}
+	return c.Dictionaries.StringColumns.validate("dictionaries.string_columns")
+}
+
+func (d *DictionaryConfig) validate(path string) error {
+	if d.MinRowCount < 0 {
+		return fmt.Errorf("config: %s.min_row_count must be >= 0 (got %d)", path, d.MinRowCount)
+	}
+	if d.MaxCard < 0 {
+		return fmt.Errorf("config: %s.max_card must be >= 0 (got %d)", path, d.MaxCard)
+	}
+	// The negated form also rejects NaN.
+	if !(d.MaxCardRatio >= 0 && d.MaxCardRatio <= 1) {
+		return fmt.Errorf("config: %s.max_card_ratio must be in [0,1] (got %v)", path, d.MaxCardRatio)
+	}
+	if d.MaxSortedDictionaries < 0 {
+		return fmt.Errorf("config: %s.max_sorted_dictionaries must be >= 0 (got %d)", path, d.MaxSortedDictionaries)
+	}
+	return nil
+}
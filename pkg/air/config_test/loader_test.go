@@ -0,0 +1,112 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	config2 "otel-arrow-adapter/pkg/air/config"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	yamlDoc := `
+dictionaries:
+  binary_columns:
+    min_row_count: 20
+    max_card: 100
+    max_card_ratio: 0.2
+  string_columns:
+    max_card_ratio: 0.3
+    max_sorted_dictionaries: 2
+`
+	jsonDoc := `{
+  "dictionaries": {
+    "binary_columns": {"min_row_count": 20, "max_card": 100, "max_card_ratio": 0.2},
+    "string_columns": {"max_card_ratio": 0.3, "max_sorted_dictionaries": 2}
+  }
+}`
+
+	for format, doc := range map[config2.Format]string{config2.FormatYAML: yamlDoc, config2.FormatJSON: jsonDoc} {
+		config, err := config2.Load([]byte(doc), format)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		binary := config.Dictionaries.BinaryColumns
+		if binary.MinRowCount != 20 || binary.MaxCard != 100 || binary.MaxCardRatio != 0.2 || binary.MaxSortedDictionaries != 0 {
+			t.Errorf("Unexpected binary columns config: %+v", binary)
+		}
+		// Settings not defined in the document keep their default values.
+		str := config.Dictionaries.StringColumns
+		if str.MinRowCount != 10 || str.MaxCard != 255 || str.MaxCardRatio != 0.3 || str.MaxSortedDictionaries != 2 {
+			t.Errorf("Unexpected string columns config: %+v", str)
+		}
+	}
+
+	config, err := config2.Load(nil, config2.FormatYAML)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *config != *config2.NewDefaultConfig() {
+		t.Errorf("Expected the default config, got %+v", config)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+
+	docs := []string{
+		`dictionaries: {string_columns: {max_card_ratio: 1.5}}`,
+		`dictionaries: {string_columns: {max_card_ratio: -0.1}}`,
+		`dictionaries: {binary_columns: {min_row_count: -1}}`,
+		`dictionaries: {binary_columns: {max_card: -1}}`,
+		`dictionaries: {string_columns: {max_sorted_dictionaries: -1}}`,
+		`dictionaries: {string_columns: {unknown: 1}}`,
+		`dictionaries: {string_columns: {min_row_count: "ten"}}`,
+	}
+	for _, doc := range docs {
+		if _, err := config2.Load([]byte(doc), config2.FormatYAML); err == nil {
+			t.Errorf("Expected an error for %s", doc)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "air.yml")
+	if err := os.WriteFile(path, []byte("dictionaries: {string_columns: {max_card: 10}}"), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	config, err := config2.LoadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.Dictionaries.StringColumns.MaxCard != 10 {
+		t.Errorf("Expected max_card 10, got %d", config.Dictionaries.StringColumns.MaxCard)
+	}
+
+	if _, err := config2.LoadFile(filepath.Join(dir, "air.toml")); err == nil {
+		t.Errorf("Expected an unsupported extension error")
+	}
+	if _, err := config2.LoadFile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("Expected a missing file error")
+	}
+}
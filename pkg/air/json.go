@@ -0,0 +1,304 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package air
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/apache/arrow/go/v9/arrow"
+
+	"otel-arrow-adapter/pkg/air/rfield"
+)
+
+// JsonNumberType defines how JSON numbers are converted into AIR values.
+type JsonNumberType int
+
+const (
+	// JsonNumberAuto converts integral numbers into I64 values (U64 when they don't fit into an int64) and all the
+	// other numbers into F64 values. Lists mixing number types are converted into lists of F64.
+	JsonNumberAuto JsonNumberType = iota
+	// JsonNumberF64 converts all numbers into F64 values.
+	JsonNumberF64
+	// JsonNumberString keeps the literal representation of the numbers as String values.
+	JsonNumberString
+)
+
+// JsonConfig defines the configuration of the JSON to Record converter.
+type JsonConfig struct {
+	// Conversion rule for JSON numbers.
+	NumberType JsonNumberType
+
+	// Maximum nesting depth of objects and arrays (the top-level object has a depth of 1). A zero value means no limit.
+	MaxDepth int
+}
+
+// NewDefaultJsonConfig returns a configuration inferring the number types and limiting the nesting depth to 32.
+func NewDefaultJsonConfig() *JsonConfig {
+	return &JsonConfig{
+		NumberType: JsonNumberAuto,
+		MaxDepth:   32,
+	}
+}
+
+// JsonToRecord converts a JSON object into a Record.
+//
+// Null values are converted into untyped nulls (typed by the `RecordRepository` with the data type previously seen at
+// the same path), empty objects into empty structs and empty arrays into empty lists. The items of an array are
+// coerced to a common type (see `JsonValueToValue`).
+func JsonToRecord(data []byte, config *JsonConfig) (*Record, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	// `More` doesn't report a trailing closing delimiter, only the end of the input is accepted.
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("json: unexpected data after the top-level value")
+	}
+	return jsonDocToRecord(doc, config)
+}
+
+// JsonStreamToRecords converts a stream of JSON objects (e.g. newline delimited JSON) into a list of Records.
+func JsonStreamToRecords(reader io.Reader, config *JsonConfig) ([]*Record, error) {
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	var records []*Record
+	for {
+		var doc interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		record, err := jsonDocToRecord(doc, config)
+		if err != nil {
+			return nil, fmt.Errorf("json document #%d: %w", len(records), err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func jsonDocToRecord(doc interface{}, config *JsonConfig) (*Record, error) {
+	object, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("json: top-level value must be an object, got %T", doc)
+	}
+
+	record := NewRecord()
+	for name, value := range object {
+		v, err := JsonValueToValue(value, config, 1)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", name, err)
+		}
+		record.GenericField(name, v)
+	}
+	return record, nil
+}
+
+// JsonValueToValue converts a value decoded by `encoding/json` into an AIR value. The depth parameter is the nesting
+// depth of the value's parent.
+//
+// The items of an array are coerced to a common type: the objects contain the union of their fields, the nested arrays
+// are coerced item-wise, the nulls take the type of the other items and the numbers of different types are converted
+// into F64 values. An `rfield.ErrIncompatibleTypes` error is returned for the arrays mixing other types (e.g. strings and
+// numbers, or objects and scalars) as they can't be converted without loss.
+func JsonValueToValue(value interface{}, config *JsonConfig, depth int) (rfield.Value, error) {
+	switch v := value.(type) {
+	case nil:
+		return &rfield.Null{}, nil
+	case bool:
+		return &rfield.Bool{Value: v}, nil
+	case string:
+		return &rfield.String{Value: v}, nil
+	case json.Number:
+		return jsonNumberToValue(v, config.NumberType)
+	case float64:
+		// Only returned by decoders not configured with `UseNumber`.
+		return jsonNumberToValue(json.Number(strconv.FormatFloat(v, 'g', -1, 64)), config.NumberType)
+	case map[string]interface{}:
+		if err := checkJsonDepth(config, depth+1); err != nil {
+			return nil, err
+		}
+		fields := make([]*rfield.Field, 0, len(v))
+		for name, item := range v {
+			fieldValue, err := JsonValueToValue(item, config, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", name, err)
+			}
+			fields = append(fields, rfield.NewField(name, fieldValue))
+		}
+		return &rfield.Struct{Fields: fields}, nil
+	case []interface{}:
+		if err := checkJsonDepth(config, depth+1); err != nil {
+			return nil, err
+		}
+		values := make([]rfield.Value, 0, len(v))
+		for i, item := range v {
+			itemValue, err := JsonValueToValue(item, config, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+			values = append(values, itemValue)
+		}
+		values, err := coerceJsonList(values)
+		if err != nil {
+			return nil, err
+		}
+		return &rfield.List{Values: values}, nil
+	default:
+		return nil, fmt.Errorf("unsupported json value type %T", value)
+	}
+}
+
+func checkJsonDepth(config *JsonConfig, depth int) error {
+	if config.MaxDepth > 0 && depth > config.MaxDepth {
+		return fmt.Errorf("max nesting depth (%d) exceeded", config.MaxDepth)
+	}
+	return nil
+}
+
+func jsonNumberToValue(number json.Number, numberType JsonNumberType) (rfield.Value, error) {
+	switch numberType {
+	case JsonNumberString:
+		return &rfield.String{Value: number.String()}, nil
+	case JsonNumberF64:
+		f64, err := number.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return &rfield.F64{Value: f64}, nil
+	case JsonNumberAuto:
+		if i64, err := number.Int64(); err == nil {
+			return &rfield.I64{Value: i64}, nil
+		}
+		if u64, err := strconv.ParseUint(number.String(), 10, 64); err == nil {
+			return &rfield.U64{Value: u64}, nil
+		}
+		f64, err := number.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return &rfield.F64{Value: f64}, nil
+	default:
+		return nil, fmt.Errorf("unknown json number type %d", numberType)
+	}
+}
+
+// coerceJsonList makes the items of a list homogeneous (see `JsonValueToValue`).
+func coerceJsonList(values []rfield.Value) ([]rfield.Value, error) {
+	var dataType arrow.DataType = arrow.Null
+	for _, value := range values {
+		var err error
+		dataType, err = coerceJsonTypes(dataType, value.DataType())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	coerced := make([]rfield.Value, len(values))
+	for i, value := range values {
+		coerced[i] = jsonValueToType(value, dataType)
+	}
+	return coerced, nil
+}
+
+// coerceJsonTypes returns the common type of two JSON value types, or an error if the conversion to a common type is
+// lossy.
+func coerceJsonTypes(dataType1 arrow.DataType, dataType2 arrow.DataType) (arrow.DataType, error) {
+	switch {
+	case dataType1.ID() == arrow.NULL:
+		return dataType2, nil
+	case dataType2.ID() == arrow.NULL || arrow.TypeEqual(dataType1, dataType2):
+		return dataType1, nil
+	case dataType1.ID() == arrow.STRUCT && dataType2.ID() == arrow.STRUCT:
+		fields := make(map[string]arrow.DataType)
+		for _, structType := range []arrow.DataType{dataType1, dataType2} {
+			for _, field := range structType.(*arrow.StructType).Fields() {
+				fieldType, found := fields[field.Name]
+				if !found {
+					fieldType = arrow.Null
+				}
+				fieldType, err := coerceJsonTypes(fieldType, field.Type)
+				if err != nil {
+					return nil, fmt.Errorf("field %q: %w", field.Name, err)
+				}
+				fields[field.Name] = fieldType
+			}
+		}
+		structFields := make([]arrow.Field, 0, len(fields))
+		for name, fieldType := range fields {
+			structFields = append(structFields, arrow.Field{Name: name, Type: fieldType, Nullable: true})
+		}
+		sort.Slice(structFields, func(i, j int) bool { return structFields[i].Name < structFields[j].Name })
+		return arrow.StructOf(structFields...), nil
+	case dataType1.ID() == arrow.LIST && dataType2.ID() == arrow.LIST:
+		itemType, err := coerceJsonTypes(dataType1.(*arrow.ListType).Elem(), dataType2.(*arrow.ListType).Elem())
+		if err != nil {
+			return nil, err
+		}
+		return arrow.ListOf(itemType), nil
+	case isJsonNumberType(dataType1) && isJsonNumberType(dataType2):
+		return arrow.PrimitiveTypes.Float64, nil
+	default:
+		return nil, fmt.Errorf("%w: list mixing %s and %s values", rfield.ErrIncompatibleTypes, rfield.DataTypeSignature(dataType1), rfield.DataTypeSignature(dataType2))
+	}
+}
+
+func isJsonNumberType(dataType arrow.DataType) bool {
+	switch dataType.ID() {
+	case arrow.INT64, arrow.UINT64, arrow.FLOAT64:
+		return true
+	default:
+		return false
+	}
+}
+
+// jsonValueToType converts the numbers of a value (at any depth) into F64 values where the coerced type is F64.
+func jsonValueToType(value rfield.Value, dataType arrow.DataType) rfield.Value {
+	switch v := value.(type) {
+	case *rfield.I64:
+		if dataType.ID() == arrow.FLOAT64 {
+			return &rfield.F64{Value: float64(v.Value)}
+		}
+	case *rfield.U64:
+		if dataType.ID() == arrow.FLOAT64 {
+			return &rfield.F64{Value: float64(v.Value)}
+		}
+	case *rfield.Struct:
+		structType := dataType.(*arrow.StructType)
+		for _, field := range v.Fields {
+			fieldType, _ := structType.FieldByName(field.Name)
+			field.Value = jsonValueToType(field.Value, fieldType.Type)
+		}
+	case *rfield.List:
+		itemType := dataType.(*arrow.ListType).Elem()
+		for i, item := range v.Values {
+			v.Values[i] = jsonValueToType(item, itemType)
+		}
+		// The element type is inferred again from the converted items.
+		v.SetEType(nil)
+	}
+	return value
+}
@@ -0,0 +1,353 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquet
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/apache/arrow/go/v9/arrow"
+	"github.com/apache/arrow/go/v9/arrow/array"
+	"github.com/apache/arrow/go/v9/arrow/memory"
+	pq "github.com/apache/arrow/go/v9/parquet"
+	"github.com/apache/arrow/go/v9/parquet/compress"
+	"github.com/apache/arrow/go/v9/parquet/pqarrow"
+)
+
+// SCHEMA_ID_METADATA_KEY is the key of the Parquet file metadata containing the AIR schema id of the records.
+const SCHEMA_ID_METADATA_KEY = "air.schema_id"
+
+// DictionaryMode defines how Parquet dictionary encoding is applied to the columns.
+type DictionaryMode int
+
+const (
+	// DictionaryPassThrough enables Parquet dictionary encoding only for the Arrow dictionary columns.
+	DictionaryPassThrough DictionaryMode = iota
+	// DictionaryAll enables Parquet dictionary encoding for all the columns.
+	DictionaryAll
+	// DictionaryNone disables Parquet dictionary encoding.
+	DictionaryNone
+)
+
+// Config defines the configuration of the Parquet Writer.
+type Config struct {
+	// Maximum number of rows per row group.
+	MaxRowGroupLen int64
+
+	// Compression codec used for all the columns.
+	Compression compress.Compression
+
+	// Dictionary encoding mode.
+	Dictionary DictionaryMode
+
+	// Prefix of the generated file names.
+	FilePrefix string
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		MaxRowGroupLen: 64 * 1024,
+		Compression:    compress.Codecs.Zstd,
+		Dictionary:     DictionaryPassThrough,
+		FilePrefix:     "records",
+	}
+}
+
+// Writer writes the Arrow records produced by a RecordRepository into Parquet files, one file per schema id.
+//
+// Arrow dictionary columns are not supported by the Parquet Arrow writer, they are decoded before being written and
+// flagged as Parquet dictionary-encoded columns (see DictionaryPassThrough).
+type Writer struct {
+	dir       string
+	config    *Config
+	allocator *memory.GoAllocator
+
+	// A map of SchemaId to schemaWriter.
+	writers map[string]*schemaWriter
+	closed  bool
+}
+
+type schemaWriter struct {
+	path   string
+	writer *pqarrow.FileWriter
+	rows   int64
+}
+
+// FileInfo describes a Parquet file created by the Writer.
+type FileInfo struct {
+	SchemaId string
+	Path     string
+	Rows     int64
+}
+
+func NewWriter(dir string, config *Config) (*Writer, error) {
+	if config.MaxRowGroupLen <= 0 {
+		return nil, fmt.Errorf("parquet: MaxRowGroupLen must be greater than 0 (got %d)", config.MaxRowGroupLen)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		dir:       dir,
+		config:    config,
+		allocator: memory.NewGoAllocator(),
+		writers:   make(map[string]*schemaWriter),
+	}, nil
+}
+
+// Write writes the records returned by `RecordRepository.Build`.
+func (w *Writer) Write(records map[string]arrow.Record) error {
+	// Sorted to get a deterministic order of file creation.
+	schemaIds := make([]string, 0, len(records))
+	for schemaId := range records {
+		schemaIds = append(schemaIds, schemaId)
+	}
+	sort.Strings(schemaIds)
+
+	for _, schemaId := range schemaIds {
+		if err := w.WriteRecord(schemaId, records[schemaId]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteRecord writes a record into the Parquet file associated with the schema id.
+func (w *Writer) WriteRecord(schemaId string, record arrow.Record) error {
+	if w.closed {
+		return fmt.Errorf("parquet: writer closed")
+	}
+	if record == nil {
+		return nil
+	}
+
+	record, dictPaths, err := decodeDictionaries(w.allocator, record)
+	if err != nil {
+		return fmt.Errorf("parquet: schema %q: %w", schemaId, err)
+	}
+	defer record.Release()
+
+	sw, ok := w.writers[schemaId]
+	if !ok {
+		sw, err = w.newSchemaWriter(schemaId, record.Schema(), dictPaths)
+		if err != nil {
+			return err
+		}
+		w.writers[schemaId] = sw
+	}
+
+	if err := sw.writer.Write(record); err != nil {
+		return fmt.Errorf("parquet: schema %q: %w", schemaId, err)
+	}
+	sw.rows += record.NumRows()
+	return nil
+}
+
+// Files returns the files created by the Writer, sorted by path. The files are complete only once the Writer is closed.
+func (w *Writer) Files() []FileInfo {
+	files := make([]FileInfo, 0, len(w.writers))
+	for schemaId, sw := range w.writers {
+		files = append(files, FileInfo{SchemaId: schemaId, Path: sw.path, Rows: sw.rows})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// Close flushes and closes all the Parquet files.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	var firstErr error
+	for _, sw := range w.writers {
+		// The Parquet FileWriter closes the underlying file.
+		if err := sw.writer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (w *Writer) newSchemaWriter(schemaId string, schema *arrow.Schema, dictPaths []string) (*schemaWriter, error) {
+	path := filepath.Join(w.dir, fmt.Sprintf("%s-%s.parquet", w.config.FilePrefix, schemaIdHash(schemaId)))
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	props := []pq.WriterProperty{
+		pq.WithMaxRowGroupLength(w.config.MaxRowGroupLen),
+		pq.WithCompression(w.config.Compression),
+		pq.WithAllocator(w.allocator),
+	}
+	switch w.config.Dictionary {
+	case DictionaryAll:
+		props = append(props, pq.WithDictionaryDefault(true))
+	case DictionaryNone:
+		props = append(props, pq.WithDictionaryDefault(false))
+	case DictionaryPassThrough:
+		props = append(props, pq.WithDictionaryDefault(false))
+		for _, dictPath := range dictPaths {
+			props = append(props, pq.WithDictionaryFor(dictPath, true))
+		}
+	default:
+		_ = file.Close()
+		return nil, fmt.Errorf("parquet: unknown dictionary mode %d", w.config.Dictionary)
+	}
+
+	// The schema id is added to the metadata of the records.
+	keys := []string{SCHEMA_ID_METADATA_KEY}
+	values := []string{schemaId}
+	for i, key := range schema.Metadata().Keys() {
+		if key != SCHEMA_ID_METADATA_KEY {
+			keys = append(keys, key)
+			values = append(values, schema.Metadata().Values()[i])
+		}
+	}
+	metadata := arrow.NewMetadata(keys, values)
+	schema = arrow.NewSchema(schema.Fields(), &metadata)
+
+	writer, err := pqarrow.NewFileWriter(schema, file, pq.NewWriterProperties(props...), pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema()))
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &schemaWriter{path: path, writer: writer}, nil
+}
+
+// schemaIdHash returns a file name friendly representation of a schema id.
+func schemaIdHash(schemaId string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(schemaId))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// decodeDictionaries replaces the Arrow dictionary columns (top-level and nested in structs or lists) by their decoded
+// values and returns the Parquet column paths of these columns. The schema and field metadata are preserved.
+func decodeDictionaries(allocator *memory.GoAllocator, record arrow.Record) (arrow.Record, []string, error) {
+	var dictPaths []string
+	fields := make([]arrow.Field, record.NumCols())
+	cols := make([]arrow.Array, record.NumCols())
+
+	defer func() {
+		for _, col := range cols {
+			if col != nil {
+				col.Release()
+			}
+		}
+	}()
+
+	for i, field := range record.Schema().Fields() {
+		col, err := decodeArray(allocator, record.Column(i), field.Name, &dictPaths)
+		if err != nil {
+			return nil, nil, err
+		}
+		fields[i] = arrow.Field{Name: field.Name, Type: col.DataType(), Nullable: field.Nullable, Metadata: field.Metadata}
+		cols[i] = col
+	}
+
+	metadata := record.Schema().Metadata()
+	return array.NewRecord(arrow.NewSchema(fields, &metadata), cols, record.NumRows()), dictPaths, nil
+}
+
+// decodeArray returns a new reference to the array or to its decoded version if the array contains dictionaries.
+func decodeArray(allocator *memory.GoAllocator, arr arrow.Array, path string, dictPaths *[]string) (arrow.Array, error) {
+	switch t := arr.(type) {
+	case *array.Dictionary:
+		*dictPaths = append(*dictPaths, path)
+		return decodeDictionary(allocator, t)
+	case *array.Struct:
+		structType := t.DataType().(*arrow.StructType)
+		fields := make([]arrow.Field, t.NumField())
+		children := make([]arrow.ArrayData, t.NumField())
+		decoded := false
+		for i := 0; i < t.NumField(); i++ {
+			field := structType.Field(i)
+			child, err := decodeArray(allocator, t.Field(i), path+"."+field.Name, dictPaths)
+			if err != nil {
+				return nil, err
+			}
+			defer child.Release()
+			if child != t.Field(i) {
+				decoded = true
+			}
+			fields[i] = arrow.Field{Name: field.Name, Type: child.DataType(), Nullable: field.Nullable, Metadata: field.Metadata}
+			children[i] = child.Data()
+		}
+		if !decoded {
+			arr.Retain()
+			return arr, nil
+		}
+		data := array.NewData(arrow.StructOf(fields...), t.Len(), t.Data().Buffers(), children, t.NullN(), t.Data().Offset())
+		defer data.Release()
+		return array.NewStructData(data), nil
+	case *array.List:
+		// Parquet lists are three-level structures, the values are stored under `<name>.list.element`.
+		values, err := decodeArray(allocator, t.ListValues(), path+".list.element", dictPaths)
+		if err != nil {
+			return nil, err
+		}
+		defer values.Release()
+		if values == t.ListValues() {
+			arr.Retain()
+			return arr, nil
+		}
+		elem := t.DataType().(*arrow.ListType).ElemField()
+		listType := arrow.ListOfField(arrow.Field{Name: elem.Name, Type: values.DataType(), Nullable: elem.Nullable, Metadata: elem.Metadata})
+		data := array.NewData(listType, t.Len(), t.Data().Buffers(), []arrow.ArrayData{values.Data()}, t.NullN(), t.Data().Offset())
+		defer data.Release()
+		return array.NewListData(data), nil
+	default:
+		arr.Retain()
+		return arr, nil
+	}
+}
+
+func decodeDictionary(allocator *memory.GoAllocator, dict *array.Dictionary) (arrow.Array, error) {
+	switch values := dict.Dictionary().(type) {
+	case *array.String:
+		builder := array.NewStringBuilder(allocator)
+		defer builder.Release()
+		builder.Reserve(dict.Len())
+		for i := 0; i < dict.Len(); i++ {
+			if dict.IsNull(i) {
+				builder.AppendNull()
+			} else {
+				builder.Append(values.Value(dict.GetValueIndex(i)))
+			}
+		}
+		return builder.NewArray(), nil
+	case *array.Binary:
+		builder := array.NewBinaryBuilder(allocator, arrow.BinaryTypes.Binary)
+		defer builder.Release()
+		builder.Reserve(dict.Len())
+		for i := 0; i < dict.Len(); i++ {
+			if dict.IsNull(i) {
+				builder.AppendNull()
+			} else {
+				builder.Append(values.Value(dict.GetValueIndex(i)))
+			}
+		}
+		return builder.NewArray(), nil
+	default:
+		return nil, fmt.Errorf("dictionary of %v not supported", values.DataType())
+	}
+}
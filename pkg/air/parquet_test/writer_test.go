@@ -0,0 +1,426 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquet_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/apache/arrow/go/v9/arrow"
+	"github.com/apache/arrow/go/v9/arrow/array"
+	"github.com/apache/arrow/go/v9/arrow/memory"
+	"github.com/apache/arrow/go/v9/parquet/compress"
+	"github.com/apache/arrow/go/v9/parquet/file"
+	"github.com/apache/arrow/go/v9/parquet/pqarrow"
+
+	"otel-arrow-adapter/pkg/air"
+	config2 "otel-arrow-adapter/pkg/air/config"
+	"otel-arrow-adapter/pkg/air/parquet"
+	"otel-arrow-adapter/pkg/air/rfield"
+	"otel-arrow-adapter/pkg/datagen"
+	"otel-arrow-adapter/pkg/otel/trace"
+)
+
+func TestWriter(t *testing.T) {
+	t.Parallel()
+
+	config := parquet.NewDefaultConfig()
+	config.MaxRowGroupLen = 30
+	config.Compression = compress.Codecs.Snappy
+	writer, err := parquet.NewWriter(t.TempDir(), config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	records := buildRecords(t, 100)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if err := writer.Write(records); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Second batch appended to the same files.
+	if err := writer.Write(buildRecords(t, 100)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	files := writer.Files()
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(files))
+	}
+	for _, info := range files {
+		if info.Rows != 100 {
+			t.Errorf("Expected 100 rows, got %d", info.Rows)
+		}
+
+		reader, err := file.OpenParquetFile(info.Path, false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if reader.NumRows() != 100 {
+			t.Errorf("Expected 100 rows, got %d", reader.NumRows())
+		}
+		// 2 batches of 50 rows split in row groups of at most 30 rows.
+		if reader.NumRowGroups() != 4 {
+			t.Errorf("Expected 4 row groups, got %d", reader.NumRowGroups())
+		}
+		schemaId := reader.MetaData().KeyValueMetadata().FindValue(parquet.SCHEMA_ID_METADATA_KEY)
+		if schemaId == nil || *schemaId != info.SchemaId {
+			t.Errorf("Expected schema id %q in the file metadata, got %v", info.SchemaId, schemaId)
+		}
+		rowGroup := reader.MetaData().RowGroup(0)
+		for i := 0; i < rowGroup.NumColumns(); i++ {
+			column, err := rowGroup.ColumnChunk(i)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if column.Compression() != compress.Codecs.Snappy {
+				t.Errorf("Expected snappy compression, got %v", column.Compression())
+			}
+		}
+		if err := reader.Close(); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func TestWriterDictionaryModes(t *testing.T) {
+	t.Parallel()
+
+	// Column "a" is an Arrow dictionary, column "ts" is not.
+	expected := map[parquet.DictionaryMode]map[string]bool{
+		parquet.DictionaryPassThrough: {"a": true, "ts": false},
+		parquet.DictionaryAll:         {"a": true, "ts": true},
+		parquet.DictionaryNone:        {"a": false, "ts": false},
+	}
+
+	for mode, columns := range expected {
+		config := parquet.NewDefaultConfig()
+		config.Dictionary = mode
+		writer, err := parquet.NewWriter(t.TempDir(), config)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		record := buildDictionaryRecord(100)
+		if err := writer.WriteRecord("a:Str,ts:I64", record); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		record.Release()
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		files := writer.Files()
+		if len(files) != 1 {
+			t.Fatalf("Expected 1 file, got %d", len(files))
+		}
+		reader, err := file.OpenParquetFile(files[0].Path, false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		rowGroup := reader.MetaData().RowGroup(0)
+		for i := 0; i < rowGroup.NumColumns(); i++ {
+			column, err := rowGroup.ColumnChunk(i)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			name := column.PathInSchema().String()
+			if column.HasDictionaryPage() != columns[name] {
+				t.Errorf("Mode %d, column %s: expected dictionary page %v, got %v", mode, name, columns[name], column.HasDictionaryPage())
+			}
+		}
+
+		table, err := pqarrow.ReadTable(context.Background(), mustOpen(t, files[0].Path), nil, pqarrow.ArrowReadProperties{}, memory.NewGoAllocator())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if table.NumRows() != 100 {
+			t.Errorf("Expected 100 rows, got %d", table.NumRows())
+		}
+		if table.Schema().Field(0).Type.ID() != arrow.STRING {
+			t.Errorf("Expected column a to be decoded as a string column, got %v", table.Schema().Field(0).Type)
+		}
+		// The schema and field metadata are preserved by the dictionary decoding.
+		metadata := reader.MetaData().KeyValueMetadata()
+		if value := metadata.FindValue("test.key"); value == nil || *value != "test.value" {
+			t.Errorf("Mode %d: expected the schema metadata to be preserved, got %v", mode, metadata)
+		}
+		if value := metadata.FindValue(parquet.SCHEMA_ID_METADATA_KEY); value == nil || *value != "a:Str,ts:I64" {
+			t.Errorf("Mode %d: expected the schema id in the metadata, got %v", mode, metadata)
+		}
+		if idx := table.Schema().Field(1).Metadata.FindKey("derived"); idx < 0 {
+			t.Errorf("Mode %d: expected the field metadata to be preserved, got %v", mode, table.Schema().Field(1).Metadata)
+		}
+		table.Release()
+		_ = reader.Close()
+	}
+}
+
+// TestWriterTraceRecords writes the records produced from generated traces. Span events and links are lists of
+// structs.
+func TestWriterTraceRecords(t *testing.T) {
+	t.Parallel()
+
+	rr := air.NewRecordRepository(config2.NewDefaultConfig())
+	generator := datagen.NewTraceGenerator(datagen.DefaultResourceAttributes(), datagen.DefaultInstrumentationScope())
+	records, _, err := trace.OtlpTraceToArrowRecords(rr, generator.Generate(100, 100))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	writer, err := parquet.NewWriter(t.TempDir(), parquet.NewDefaultConfig())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rows := int64(0)
+	for i, record := range records {
+		// The schema of the record must describe its columns.
+		for j, field := range record.Schema().Fields() {
+			if !arrow.TypeEqual(field.Type, record.Column(j).DataType()) {
+				t.Errorf("Column %s: expected type %v, got %v", field.Name, field.Type, record.Column(j).DataType())
+			}
+		}
+		rows += record.NumRows()
+		if err := writer.WriteRecord(fmt.Sprintf("trace_%d", i), record); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		record.Release()
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	readRows := int64(0)
+	for _, info := range writer.Files() {
+		table, err := pqarrow.ReadTable(context.Background(), mustOpen(t, info.Path), nil, pqarrow.ArrowReadProperties{}, memory.NewGoAllocator())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		readRows += table.NumRows()
+		table.Release()
+	}
+	if readRows != rows {
+		t.Errorf("Expected %d rows, got %d", rows, readRows)
+	}
+}
+
+func TestWriterListDictionary(t *testing.T) {
+	t.Parallel()
+
+	writer, err := parquet.NewWriter(t.TempDir(), parquet.NewDefaultConfig())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	record := buildListDictionaryRecord(100)
+	if err := writer.WriteRecord("l:[{a:Str,b:I64}]", record); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	record.Release()
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	files := writer.Files()
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 file, got %d", len(files))
+	}
+	reader, err := file.OpenParquetFile(files[0].Path, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]bool{"l.list.element.a": true, "l.list.element.b": false}
+	rowGroup := reader.MetaData().RowGroup(0)
+	for i := 0; i < rowGroup.NumColumns(); i++ {
+		column, err := rowGroup.ColumnChunk(i)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		name := column.PathInSchema().String()
+		if column.HasDictionaryPage() != expected[name] {
+			t.Errorf("Column %s: expected dictionary page %v, got %v", name, expected[name], column.HasDictionaryPage())
+		}
+	}
+	_ = reader.Close()
+
+	table, err := pqarrow.ReadTable(context.Background(), mustOpen(t, files[0].Path), nil, pqarrow.ArrowReadProperties{}, memory.NewGoAllocator())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer table.Release()
+	if table.NumRows() != 100 {
+		t.Errorf("Expected 100 rows, got %d", table.NumRows())
+	}
+	listType, ok := table.Schema().Field(0).Type.(*arrow.ListType)
+	if !ok {
+		t.Fatalf("Expected a list column, got %v", table.Schema().Field(0).Type)
+	}
+	if elemType := listType.Elem().(*arrow.StructType).Field(0).Type; elemType.ID() != arrow.STRING {
+		t.Errorf("Expected field a to be decoded as a string column, got %v", elemType)
+	}
+}
+
+func TestWriterInvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	config := parquet.NewDefaultConfig()
+	config.MaxRowGroupLen = 0
+	if _, err := parquet.NewWriter(t.TempDir(), config); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+// buildRecords builds 2 Arrow records (one per schema) of `count`/2 rows each.
+func buildRecords(t *testing.T, count int) map[string]arrow.Record {
+	t.Helper()
+
+	rr := air.NewRecordRepository(&config2.Config{
+		Dictionaries: config2.DictionariesConfig{
+			StringColumns: config2.DictionaryConfig{
+				MinRowCount:           10,
+				MaxCard:               math.MaxUint8,
+				MaxCardRatio:          0.5,
+				MaxSortedDictionaries: 5,
+			},
+		},
+	})
+	for i := 0; i < count; i++ {
+		record := air.NewRecord()
+		record.I64Field("ts", int64(i))
+		record.StringField("a", fmt.Sprintf("a_%d", i%5))
+		if i%2 == 1 {
+			record.StructField("b", rfield.Struct{Fields: []*rfield.Field{
+				rfield.NewStringField("c", fmt.Sprintf("c_%d", i%3)),
+				rfield.NewF64Field("d", float64(i)),
+			}})
+		}
+		rr.AddRecord(record)
+	}
+	records, err := rr.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return records
+}
+
+// buildDictionaryRecord builds an Arrow record with a dictionary column "a" (5 distinct values) and an int64 column
+// "ts".
+func buildDictionaryRecord(count int) arrow.Record {
+	allocator := memory.NewGoAllocator()
+
+	dictBuilder := array.NewStringBuilder(allocator)
+	defer dictBuilder.Release()
+	for i := 0; i < 5; i++ {
+		dictBuilder.Append(fmt.Sprintf("a_%d", i))
+	}
+	dict := dictBuilder.NewArray()
+	defer dict.Release()
+
+	indicesBuilder := array.NewInt32Builder(allocator)
+	defer indicesBuilder.Release()
+	tsBuilder := array.NewInt64Builder(allocator)
+	defer tsBuilder.Release()
+	for i := 0; i < count; i++ {
+		indicesBuilder.Append(int32(i % 5))
+		tsBuilder.Append(int64(i))
+	}
+	indices := indicesBuilder.NewArray()
+	defer indices.Release()
+	ts := tsBuilder.NewArray()
+	defer ts.Release()
+
+	dictType := &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.String}
+	a := array.NewDictionaryArray(dictType, indices, dict)
+	defer a.Release()
+
+	metadata := arrow.NewMetadata([]string{"test.key"}, []string{"test.value"})
+	fieldMetadata := arrow.NewMetadata([]string{"derived"}, []string{"true"})
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "a", Type: dictType},
+		{Name: "ts", Type: arrow.PrimitiveTypes.Int64, Metadata: fieldMetadata},
+	}, &metadata)
+	return array.NewRecord(schema, []arrow.Array{a, ts}, int64(count))
+}
+
+// buildListDictionaryRecord builds an Arrow record with a list column "l" of `count` lists of 2 structs. The structs
+// contain a dictionary field "a" and an int64 field "b".
+func buildListDictionaryRecord(count int) arrow.Record {
+	allocator := memory.NewGoAllocator()
+
+	dictBuilder := array.NewStringBuilder(allocator)
+	defer dictBuilder.Release()
+	for i := 0; i < 5; i++ {
+		dictBuilder.Append(fmt.Sprintf("a_%d", i))
+	}
+	dict := dictBuilder.NewArray()
+	defer dict.Release()
+
+	indicesBuilder := array.NewInt32Builder(allocator)
+	defer indicesBuilder.Release()
+	bBuilder := array.NewInt64Builder(allocator)
+	defer bBuilder.Release()
+	offsetsBuilder := array.NewInt32Builder(allocator)
+	defer offsetsBuilder.Release()
+	for i := 0; i < count; i++ {
+		offsetsBuilder.Append(int32(2 * i))
+		for j := 0; j < 2; j++ {
+			indicesBuilder.Append(int32((i + j) % 5))
+			bBuilder.Append(int64(i))
+		}
+	}
+	offsetsBuilder.Append(int32(2 * count))
+	indices := indicesBuilder.NewArray()
+	defer indices.Release()
+	b := bBuilder.NewArray()
+	defer b.Release()
+	offsets := offsetsBuilder.NewArray()
+	defer offsets.Release()
+
+	dictType := &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.String}
+	a := array.NewDictionaryArray(dictType, indices, dict)
+	defer a.Release()
+
+	structType := arrow.StructOf(
+		arrow.Field{Name: "a", Type: dictType},
+		arrow.Field{Name: "b", Type: arrow.PrimitiveTypes.Int64},
+	)
+	structData := array.NewData(structType, 2*count, []*memory.Buffer{nil}, []arrow.ArrayData{a.Data(), b.Data()}, 0, 0)
+	defer structData.Release()
+	listType := arrow.ListOf(structType)
+	listData := array.NewData(listType, count, []*memory.Buffer{nil, offsets.Data().Buffers()[1]}, []arrow.ArrayData{structData}, 0, 0)
+	defer listData.Release()
+	l := array.NewListData(listData)
+	defer l.Release()
+
+	schema := arrow.NewSchema([]arrow.Field{{Name: "l", Type: listType}}, nil)
+	return array.NewRecord(schema, []arrow.Array{l}, int64(count))
+}
+
+func mustOpen(t *testing.T, path string) *os.File {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+	return f
+}
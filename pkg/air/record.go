@@ -18,6 +18,8 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/apache/arrow/go/v9/arrow"
+
 	"otel-arrow-adapter/pkg/air/rfield"
 )
 
@@ -42,6 +44,9 @@ func (r *Records) Swap(i, j int) { r.records[i], r.records[j] = r.records[j], r.
 // Record is a collection of fields (scalar our composite fields).
 type Record struct {
 	fields []*rfield.Field
+
+	// Optional set of the top-level fields built as Arrow dictionary columns (see `DictionaryField`).
+	dictionaries map[string]bool
 }
 
 func NewRecord() *Record {
@@ -67,15 +72,51 @@ func (r Record) SchemaId() string {
 		if i > 0 {
 			sig.WriteByte(',')
 		}
-		f.WriteSignature(&sig)
+		if dataType := r.FieldType(i); dataType.ID() == arrow.DICTIONARY {
+			sig.WriteString(f.Name)
+			sig.WriteString(":")
+			sig.WriteString(rfield.DataTypeSignature(dataType))
+		} else {
+			f.WriteSignature(&sig)
+		}
 	}
 	return sig.String()
 }
 
+// DictionaryField marks the top-level field `name` as an Arrow dictionary column (with int32 indices). The mark only
+// applies to string and binary fields, it is ignored for the other types.
+func (r *Record) DictionaryField(name string) {
+	if r.dictionaries == nil {
+		r.dictionaries = make(map[string]bool)
+	}
+	r.dictionaries[name] = true
+}
+
+// FieldType returns the data type of the column built for the field at position `idx`.
+func (r *Record) FieldType(idx int) arrow.DataType {
+	field := r.fields[idx]
+	dataType := field.DataType()
+	if r.dictionaries[field.Name] && (dataType.ID() == arrow.STRING || dataType.ID() == arrow.BINARY) {
+		return &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: dataType}
+	}
+	return dataType
+}
+
+// Check returns an error if the record can't be added to a record repository, i.e. if it contains several fields with
+// the same name or values that can't be coerced into a single data type (see `rfield.CheckValue`).
+func (r *Record) Check() error {
+	return rfield.CheckValue(&rfield.Struct{Fields: r.fields})
+}
+
 func (r *Record) FieldCount() int {
 	return len(r.fields)
 }
 
+// Fields returns the fields of the record.
+func (r *Record) Fields() []*rfield.Field {
+	return r.fields
+}
+
 func (r *Record) AddField(f *rfield.Field) {
 	r.fields = append(r.fields, f)
 }
@@ -84,6 +125,10 @@ func (r *Record) GenericField(name string, value rfield.Value) {
 	r.fields = append(r.fields, rfield.NewField(name, value))
 }
 
+func (r *Record) NullField(name string) {
+	r.fields = append(r.fields, rfield.NewNullField(name))
+}
+
 func (r *Record) BoolField(name string, value bool) {
 	r.fields = append(r.fields, rfield.NewBoolField(name, value))
 }
@@ -163,6 +208,19 @@ func (r *Record) Compare(other *Record, sortBy [][]int) int {
 			panic("compare: invalid path")
 		}
 
+		// The null values (see `rfield.Null`) are sorted before the other values.
+		_, isNull := v.(*rfield.Null)
+		_, otherIsNull := otherV.(*rfield.Null)
+		if isNull || otherIsNull {
+			if isNull && otherIsNull {
+				continue
+			}
+			if isNull {
+				return -1
+			}
+			return 1
+		}
+
 		if cmp := v.Compare(otherV); cmp != 0 {
 			// Not equals
 			return cmp
@@ -0,0 +1,406 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package air
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"otel-arrow-adapter/pkg/air/rfield"
+)
+
+// AIR_TAG is the name of the struct tag used by the RecordEncoder.
+//
+// The tag value is a comma separated list starting with the field name followed by options, e.g. `air:"name,dict"`.
+// An empty name keeps the Go field name and the name "-" skips the field. Supported options:
+//   - omitempty: the field is omitted when it contains a zero value.
+//   - dict: the column of the field is an Arrow dictionary (only for string and []byte fields, see
+//     `Record.DictionaryField`). The option is ignored for the fields of nested structs.
+const AIR_TAG = "air"
+
+// RecordEncoder converts Go structs into Records.
+//
+// Supported Go types are bool, signed and unsigned integers, floats, strings, []byte, time.Time (converted into unix
+// nanoseconds), structs, maps with string keys, slices and arrays (except slices of slices), pointers and interfaces
+// to these types. Nil pointers, nil interfaces, empty slices and empty maps are omitted.
+//
+// The encoding plan of each struct type is computed once and cached, so a RecordEncoder should be reused across calls.
+// A RecordEncoder is safe for concurrent use.
+type RecordEncoder struct {
+	mu    sync.RWMutex
+	plans map[reflect.Type]*structPlan
+}
+
+// structPlan is the cached encoding plan of a struct type.
+type structPlan struct {
+	fields []*fieldPlan
+}
+
+// fieldPlan is the encoding plan of a struct field.
+type fieldPlan struct {
+	index      []int
+	name       string
+	omitEmpty  bool
+	dictionary bool
+	encode     encoderFunc
+}
+
+// encoderFunc converts a reflected value into an AIR value. A nil value means that the value must be omitted.
+type encoderFunc func(value reflect.Value) (rfield.Value, error)
+
+var timeType = reflect.TypeOf(time.Time{})
+var defaultEncoder = NewRecordEncoder()
+
+// NewRecordEncoder creates a RecordEncoder with an empty plan cache.
+func NewRecordEncoder() *RecordEncoder {
+	return &RecordEncoder{
+		plans: make(map[reflect.Type]*structPlan),
+	}
+}
+
+// StructToRecord converts a struct (or a pointer to a struct) into a Record with a shared RecordEncoder.
+func StructToRecord(value interface{}) (*Record, error) {
+	return defaultEncoder.Encode(value)
+}
+
+// Encode converts a struct (or a pointer to a struct) into a Record.
+func (e *RecordEncoder) Encode(value interface{}) (*Record, error) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, fmt.Errorf("air: cannot encode a nil pointer")
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("air: cannot encode %v, a struct is expected", v.Type())
+	}
+
+	plan, err := e.structPlan(v.Type())
+	if err != nil {
+		return nil, err
+	}
+	fields, err := e.encodeStruct(v)
+	if err != nil {
+		return nil, err
+	}
+	record := NewRecordFromFields(fields)
+	for _, fp := range plan.fields {
+		if fp.dictionary {
+			record.DictionaryField(fp.name)
+		}
+	}
+	return record, nil
+}
+
+func (e *RecordEncoder) encodeStruct(v reflect.Value) ([]*rfield.Field, error) {
+	plan, err := e.structPlan(v.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]*rfield.Field, 0, len(plan.fields))
+	for _, fp := range plan.fields {
+		fv, ok := fieldByIndex(v, fp.index)
+		if !ok {
+			// Nil embedded struct pointer.
+			continue
+		}
+		if fp.omitEmpty && fv.IsZero() {
+			continue
+		}
+		value, err := fp.encode(fv)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", fp.name, err)
+		}
+		if value != nil {
+			fields = append(fields, rfield.NewField(fp.name, value))
+		}
+	}
+	return fields, nil
+}
+
+// structPlan returns the cached plan of a struct type or builds it.
+func (e *RecordEncoder) structPlan(t reflect.Type) (*structPlan, error) {
+	e.mu.RLock()
+	plan, ok := e.plans[t]
+	e.mu.RUnlock()
+	if ok {
+		return plan, nil
+	}
+
+	plan, err := e.buildStructPlan(t)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.plans[t] = plan
+	e.mu.Unlock()
+	return plan, nil
+}
+
+func (e *RecordEncoder) buildStructPlan(t reflect.Type) (*structPlan, error) {
+	plan := &structPlan{}
+	names := make(map[string]bool)
+	if err := e.addStructFields(plan, t, nil, names); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (e *RecordEncoder) addStructFields(plan *structPlan, t reflect.Type, index []int, names map[string]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get(AIR_TAG)
+		if tag == "-" {
+			continue
+		}
+		name, options := parseTag(tag)
+
+		fieldIndex := make([]int, len(index), len(index)+1)
+		copy(fieldIndex, index)
+		fieldIndex = append(fieldIndex, i)
+
+		// Embedded structs without explicit name are flattened.
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := e.addStructFields(plan, ft, fieldIndex, names); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		if names[name] {
+			return fmt.Errorf("air: duplicate field name %q in %v", name, t)
+		}
+		names[name] = true
+
+		encode, err := e.typeEncoder(sf.Type)
+		if err != nil {
+			return fmt.Errorf("air: field %q of %v: %w", sf.Name, t, err)
+		}
+
+		fp := &fieldPlan{
+			index:  fieldIndex,
+			name:   name,
+			encode: encode,
+		}
+		for _, option := range options {
+			switch option {
+			case "omitempty":
+				fp.omitEmpty = true
+			case "dict":
+				if !isStringOrBinary(sf.Type) {
+					return fmt.Errorf("air: field %q of %v: dict option only applies to string and []byte fields", sf.Name, t)
+				}
+				fp.dictionary = true
+			default:
+				return fmt.Errorf("air: field %q of %v: unknown tag option %q", sf.Name, t, option)
+			}
+		}
+		plan.fields = append(plan.fields, fp)
+	}
+	return nil
+}
+
+// typeEncoder returns the encoder function of a Go type.
+func (e *RecordEncoder) typeEncoder(t reflect.Type) (encoderFunc, error) {
+	if t == timeType {
+		return func(v reflect.Value) (rfield.Value, error) {
+			return &rfield.U64{Value: uint64(v.Interface().(time.Time).UnixNano())}, nil
+		}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(v reflect.Value) (rfield.Value, error) { return &rfield.Bool{Value: v.Bool()}, nil }, nil
+	case reflect.Int8:
+		return func(v reflect.Value) (rfield.Value, error) { return &rfield.I8{Value: int8(v.Int())}, nil }, nil
+	case reflect.Int16:
+		return func(v reflect.Value) (rfield.Value, error) { return &rfield.I16{Value: int16(v.Int())}, nil }, nil
+	case reflect.Int32:
+		return func(v reflect.Value) (rfield.Value, error) { return &rfield.I32{Value: int32(v.Int())}, nil }, nil
+	case reflect.Int, reflect.Int64:
+		return func(v reflect.Value) (rfield.Value, error) { return &rfield.I64{Value: v.Int()}, nil }, nil
+	case reflect.Uint8:
+		return func(v reflect.Value) (rfield.Value, error) { return &rfield.U8{Value: uint8(v.Uint())}, nil }, nil
+	case reflect.Uint16:
+		return func(v reflect.Value) (rfield.Value, error) { return &rfield.U16{Value: uint16(v.Uint())}, nil }, nil
+	case reflect.Uint32:
+		return func(v reflect.Value) (rfield.Value, error) { return &rfield.U32{Value: uint32(v.Uint())}, nil }, nil
+	case reflect.Uint, reflect.Uint64:
+		return func(v reflect.Value) (rfield.Value, error) { return &rfield.U64{Value: v.Uint()}, nil }, nil
+	case reflect.Float32:
+		return func(v reflect.Value) (rfield.Value, error) { return &rfield.F32{Value: float32(v.Float())}, nil }, nil
+	case reflect.Float64:
+		return func(v reflect.Value) (rfield.Value, error) { return &rfield.F64{Value: v.Float()}, nil }, nil
+	case reflect.String:
+		return func(v reflect.Value) (rfield.Value, error) { return &rfield.String{Value: v.String()}, nil }, nil
+	case reflect.Struct:
+		// The plan of the nested struct is resolved lazily to support recursive types.
+		return func(v reflect.Value) (rfield.Value, error) {
+			fields, err := e.encodeStruct(v)
+			if err != nil {
+				return nil, err
+			}
+			if len(fields) == 0 {
+				return nil, nil
+			}
+			return &rfield.Struct{Fields: fields}, nil
+		}, nil
+	case reflect.Ptr:
+		elemEncoder, err := e.typeEncoder(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) (rfield.Value, error) {
+			if v.IsNil() {
+				return nil, nil
+			}
+			return elemEncoder(v.Elem())
+		}, nil
+	case reflect.Interface:
+		return func(v reflect.Value) (rfield.Value, error) {
+			if v.IsNil() {
+				return nil, nil
+			}
+			elem := v.Elem()
+			elemEncoder, err := e.typeEncoder(elem.Type())
+			if err != nil {
+				return nil, err
+			}
+			return elemEncoder(elem)
+		}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return func(v reflect.Value) (rfield.Value, error) {
+				if v.Kind() == reflect.Slice && v.IsNil() {
+					return nil, nil
+				}
+				data := make([]byte, v.Len())
+				reflect.Copy(reflect.ValueOf(data), v)
+				return &rfield.Binary{Value: data}, nil
+			}, nil
+		}
+		if kind := indirectType(t.Elem()).Kind(); (kind == reflect.Slice || kind == reflect.Array) && indirectType(t.Elem()).Elem().Kind() != reflect.Uint8 {
+			return nil, fmt.Errorf("list of list not supported (%v)", t)
+		}
+		elemEncoder, err := e.typeEncoder(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) (rfield.Value, error) {
+			values := make([]rfield.Value, 0, v.Len())
+			for i := 0; i < v.Len(); i++ {
+				item, err := elemEncoder(v.Index(i))
+				if err != nil {
+					return nil, fmt.Errorf("item %d: %w", i, err)
+				}
+				if item != nil {
+					values = append(values, item)
+				}
+			}
+			if len(values) == 0 {
+				return nil, nil
+			}
+			return &rfield.List{Values: values}, nil
+		}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map key type %v not supported (only string keys)", t.Key())
+		}
+		elemEncoder, err := e.typeEncoder(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) (rfield.Value, error) {
+			fields := make([]*rfield.Field, 0, v.Len())
+			iter := v.MapRange()
+			for iter.Next() {
+				key := iter.Key().String()
+				item, err := elemEncoder(iter.Value())
+				if err != nil {
+					return nil, fmt.Errorf("key %q: %w", key, err)
+				}
+				if item != nil {
+					fields = append(fields, rfield.NewField(key, item))
+				}
+			}
+			if len(fields) == 0 {
+				return nil, nil
+			}
+			return &rfield.Struct{Fields: fields}, nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("type %v not supported", t)
+	}
+}
+
+func parseTag(tag string) (string, []string) {
+	if tag == "" {
+		return "", nil
+	}
+	parts := strings.Split(tag, ",")
+	var options []string
+	for _, option := range parts[1:] {
+		option = strings.TrimSpace(option)
+		if option != "" {
+			options = append(options, option)
+		}
+	}
+	return strings.TrimSpace(parts[0]), options
+}
+
+func isStringOrBinary(t reflect.Type) bool {
+	t = indirectType(t)
+	return t.Kind() == reflect.String || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8)
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// fieldByIndex returns the nested field corresponding to index. The boolean is false when a nil embedded struct
+// pointer is traversed.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
@@ -15,10 +15,13 @@
 package air
 
 import (
+	"fmt"
+
 	"github.com/apache/arrow/go/v9/arrow"
 	"github.com/apache/arrow/go/v9/arrow/memory"
 
 	config2 "otel-arrow-adapter/pkg/air/config"
+	"otel-arrow-adapter/pkg/air/rfield"
 )
 
 type RecordRepository struct {
@@ -27,20 +30,39 @@ type RecordRepository struct {
 	// A map of SchemaId to RecordBuilder.
 	builders map[string]*RecordBuilder
 
+	// A map of field path (e.g. `attributes.tags`) to the last data type seen at this path (the items of a list are
+	// located at the path `<list path>[]`). Used to type the null values and the empty lists, and avoid the creation
+	// of a new schema for every null value or empty list. Only the paths seen since the previous build are kept in
+	// fieldTypes, the paths of the batch before are kept in prevFieldTypes, so the paths that are not seen anymore are
+	// forgotten and the maps don't grow without bound.
+	fieldTypes     map[string]arrow.DataType
+	prevFieldTypes map[string]arrow.DataType
+
 	// ToDo check if release is called properly
 	allocator *memory.GoAllocator
 }
 
 func NewRecordRepository(config *config2.Config) *RecordRepository {
 	return &RecordRepository{
-		config:    config,
-		builders:  make(map[string]*RecordBuilder),
-		allocator: memory.NewGoAllocator(),
+		config:         config,
+		builders:       make(map[string]*RecordBuilder),
+		fieldTypes:     make(map[string]arrow.DataType),
+		prevFieldTypes: make(map[string]arrow.DataType),
+		allocator:      memory.NewGoAllocator(),
 	}
 }
 
-func (rr *RecordRepository) AddRecord(record *Record) {
+// AddRecord adds a record to the repository. An error is returned, and the record is ignored, if the record can't be
+// stored in columns (see `Record.Check`).
+// The null values and the empty lists of the record are typed with the data type last seen at the same path in the
+// current or the previous batch. Otherwise they stay untyped (see `rfield.Null`).
+func (rr *RecordRepository) AddRecord(record *Record) error {
+	if err := record.Check(); err != nil {
+		return fmt.Errorf("air: %w", err)
+	}
 	record.Normalize()
+	rr.resolveTypes("", record.fields)
+
 	schemaId := record.SchemaId()
 
 	if rb, ok := rr.builders[schemaId]; ok {
@@ -48,6 +70,65 @@ func (rr *RecordRepository) AddRecord(record *Record) {
 	} else {
 		rr.builders[schemaId] = NewRecordBuilderWithRecord(rr.allocator, record, rr.config)
 	}
+	return nil
+}
+
+// resolveTypes types the null values and the empty lists with the data type previously seen at the same path, and
+// records the data type of the other values.
+func (rr *RecordRepository) resolveTypes(prefix string, fields []*rfield.Field) {
+	for _, field := range fields {
+		path := field.Name
+		if prefix != "" {
+			path = prefix + "." + field.Name
+		}
+		rr.resolveValueType(path, field.Value)
+	}
+}
+
+func (rr *RecordRepository) resolveValueType(path string, value rfield.Value) {
+	switch v := value.(type) {
+	case *rfield.Null:
+		if v.DataType().ID() == arrow.NULL {
+			if dataType := rr.fieldType(path); dataType != nil {
+				v.SetDataType(dataType)
+			}
+		}
+		if v.DataType().ID() != arrow.NULL {
+			rr.fieldTypes[path] = v.DataType()
+		}
+	case *rfield.Struct:
+		rr.resolveTypes(path, v.Fields)
+		rr.fieldTypes[path] = v.DataType()
+	case *rfield.List:
+		if len(v.Values) > 0 {
+			// Two passes so the items can be typed by the following items of the list.
+			itemPath := path + "[]"
+			for _, item := range v.Values {
+				rr.resolveValueType(itemPath, item)
+			}
+			for _, item := range v.Values {
+				rr.resolveValueType(itemPath, item)
+			}
+			// The element type is inferred again from the resolved items.
+			v.SetEType(nil)
+		}
+		if v.EType().ID() != arrow.NULL {
+			rr.fieldTypes[path] = v.DataType()
+		} else if listType, ok := rr.fieldType(path).(*arrow.ListType); ok {
+			v.SetEType(listType.Elem())
+			rr.fieldTypes[path] = listType
+		}
+	default:
+		rr.fieldTypes[path] = value.DataType()
+	}
+}
+
+// fieldType returns the data type last seen at the given path in the current or the previous batch, or nil.
+func (rr *RecordRepository) fieldType(path string) arrow.DataType {
+	if dataType, ok := rr.fieldTypes[path]; ok {
+		return dataType
+	}
+	return rr.prevFieldTypes[path]
 }
 
 // RecordBuilderCount returns the number of non-empty RecordBuilder in the repository.
@@ -61,6 +142,8 @@ func (rr *RecordRepository) RecordBuilderCount() int {
 	return count
 }
 
+// Build builds the records of the non-empty RecordBuilders. The data types of the paths seen neither in this batch nor
+// in the previous one are forgotten.
 func (rr *RecordRepository) Build() (map[string]arrow.Record, error) {
 	recordBatches := make(map[string]arrow.Record)
 
@@ -74,6 +157,9 @@ func (rr *RecordRepository) Build() (map[string]arrow.Record, error) {
 		}
 	}
 
+	rr.prevFieldTypes = rr.fieldTypes
+	rr.fieldTypes = make(map[string]arrow.DataType)
+
 	return recordBatches, nil
 }
 
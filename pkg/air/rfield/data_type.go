@@ -15,6 +15,8 @@
 package rfield
 
 import (
+	"errors"
+	"fmt"
 	"sort"
 	"strings"
 
@@ -34,6 +36,11 @@ const F32_SIG = "F32"
 const F64_SIG = "F64"
 const BINARY_SIG = "Bin"
 const STRING_SIG = "Str"
+const NULL_SIG = "Nul"
+const DICTIONARY_SIG = "Dic"
+
+// ErrIncompatibleTypes is returned for the values that can't be coerced into a single data type (see `CheckValue`).
+var ErrIncompatibleTypes = errors.New("incompatible data types")
 
 type NameTypes []*NameType
 
@@ -78,8 +85,12 @@ func DataTypeSignature(dataType arrow.DataType) string {
 		return STRING_SIG
 	case arrow.BINARY:
 		return BINARY_SIG
+	case arrow.NULL:
+		return NULL_SIG
 	case arrow.LIST:
 		return "[" + DataTypeSignature(dataType.(*arrow.ListType).Elem()) + "]"
+	case arrow.DICTIONARY:
+		return DICTIONARY_SIG + "<" + DataTypeSignature(dataType.(*arrow.DictionaryType).ValueType) + ">"
 	case arrow.STRUCT:
 		var fields []*NameType
 		structDataType := dataType.(*arrow.StructType)
@@ -96,10 +107,10 @@ func DataTypeSignature(dataType arrow.DataType) string {
 		}
 		return "{" + strings.Join(fieldSigs, ",") + "}"
 	case arrow.DATE32, arrow.DATE64, arrow.DECIMAL128, arrow.DECIMAL256, arrow.DENSE_UNION, arrow.SPARSE_UNION,
-		arrow.INTERVAL, arrow.TIME32, arrow.TIME64, arrow.DICTIONARY, arrow.FIXED_SIZE_LIST, arrow.MAP,
+		arrow.INTERVAL, arrow.TIME32, arrow.TIME64, arrow.FIXED_SIZE_LIST, arrow.MAP,
 		arrow.FIXED_SIZE_BINARY, arrow.INTERVAL_DAY_TIME, arrow.INTERVAL_MONTHS, arrow.INTERVAL_MONTH_DAY_NANO,
 		arrow.DURATION, arrow.EXTENSION, arrow.FLOAT16, arrow.LARGE_LIST, arrow.LARGE_STRING, arrow.LARGE_BINARY,
-		arrow.NULL, arrow.TIMESTAMP:
+		arrow.TIMESTAMP:
 		fallthrough
 	default:
 		panic("unknown data type '" + dataType.ID().String() + "'")
@@ -109,8 +120,13 @@ func DataTypeSignature(dataType arrow.DataType) string {
 // CoerceDataType coerces an heterogeneous set of [`DataType`] into a single one. Rules:
 // * `Int64` and `Float64` are `Float64`
 // * Lists and scalars are coerced to a list of a compatible scalar
-// * Structs contain the union of all fields
-// * All other types are coerced to `Utf8`.
+// * Structs contain the union of all fields (sorted by name like the fields of a normalized struct), the types of the
+// fields sharing the same name are coerced recursively
+// * Lists of different item types are lists of the coerced item type
+// * Nulls take the type of the other values
+// * All other types are coerced to `Utf8` (or to `Binary` when mixed with binary values), the scalar values being
+// converted into their string representation (see `Value.AsString` and `Value.AsBinary`). Structs and lists can't be
+// mixed with scalars or with each other (see `CheckValue`).
 func CoerceDataType(dataTypes *[]arrow.DataType) arrow.DataType {
 	dataType := (*dataTypes)[0]
 
@@ -142,11 +158,12 @@ func CoerceDataType(dataTypes *[]arrow.DataType) arrow.DataType {
 				Metadata: arrow.Metadata{},
 			})
 		}
+		sort.Slice(structFields, func(i, j int) bool { return structFields[i].Name < structFields[j].Name })
 		return arrow.StructOf(structFields...)
 	} else {
 		areAllEqual := true
 		for _, otherDataType := range *dataTypes {
-			if dataType.ID() != otherDataType.ID() {
+			if !arrow.TypeEqual(dataType, otherDataType) {
 				areAllEqual = false
 				break
 			}
@@ -163,7 +180,89 @@ func CoerceDataType(dataTypes *[]arrow.DataType) arrow.DataType {
 	}
 }
 
+// CheckValue returns an ErrIncompatibleTypes error if the value can't be stored in a column of its own data type, i.e.
+// if a list mixes structs, lists and scalars (at any depth) or if a struct contains several fields with the same name.
+func CheckValue(value Value) error {
+	if err := checkFieldNames(value); err != nil {
+		return err
+	}
+	return checkDataType(value, value.DataType())
+}
+
+func checkFieldNames(value Value) error {
+	switch v := value.(type) {
+	case *Struct:
+		names := make(map[string]bool, len(v.Fields))
+		for _, field := range v.Fields {
+			if names[field.Name] {
+				return fmt.Errorf("%w: duplicate field %q", ErrIncompatibleTypes, field.Name)
+			}
+			names[field.Name] = true
+			if err := checkFieldNames(field.Value); err != nil {
+				return fmt.Errorf("%s: %w", field.Name, err)
+			}
+		}
+	case *List:
+		for _, item := range v.Values {
+			if err := checkFieldNames(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkDataType checks that the value (or its items and fields) can be stored in a column of the given (coerced) data
+// type.
+func checkDataType(value Value, dataType arrow.DataType) error {
+	switch v := value.(type) {
+	case *Null:
+		return nil
+	case *Struct:
+		structType, ok := dataType.(*arrow.StructType)
+		if !ok {
+			return fmt.Errorf("%w: struct mixed with %s values", ErrIncompatibleTypes, DataTypeSignature(dataType))
+		}
+		for _, field := range v.Fields {
+			fieldType, _ := structType.FieldByName(field.Name)
+			if err := checkDataType(field.Value, fieldType.Type); err != nil {
+				return fmt.Errorf("%s: %w", field.Name, err)
+			}
+		}
+	case *List:
+		listType, ok := dataType.(*arrow.ListType)
+		if !ok {
+			return fmt.Errorf("%w: list mixed with %s values", ErrIncompatibleTypes, DataTypeSignature(dataType))
+		}
+		for _, item := range v.Values {
+			if err := checkDataType(item, listType.Elem()); err != nil {
+				return err
+			}
+		}
+	default:
+		if dataType.ID() == arrow.STRUCT || dataType.ID() == arrow.LIST {
+			return fmt.Errorf("%w: %s value mixed with %s values", ErrIncompatibleTypes, DataTypeSignature(value.DataType()), DataTypeSignature(dataType))
+		}
+	}
+	return nil
+}
+
 func CoerceDataTypes(dataType1 arrow.DataType, dataType2 arrow.DataType) arrow.DataType {
+	// Nulls take the type of the other side, structs are merged and lists are coerced item-wise.
+	switch {
+	case dataType1.ID() == arrow.NULL:
+		return dataType2
+	case dataType2.ID() == arrow.NULL:
+		return dataType1
+	case dataType1.ID() == arrow.STRUCT && dataType2.ID() == arrow.STRUCT:
+		return CoerceDataType(&[]arrow.DataType{dataType1, dataType2})
+	case dataType1.ID() == arrow.LIST && dataType2.ID() == arrow.LIST:
+		return arrow.ListOf(CoerceDataTypes(dataType1.(*arrow.ListType).Elem(), dataType2.(*arrow.ListType).Elem()))
+	case dataType2.ID() == arrow.BINARY && dataType1.ID() != arrow.STRUCT && dataType1.ID() != arrow.LIST:
+		// Symmetric to the Binary case below, so the coercion doesn't depend on the order of the data types.
+		return arrow.BinaryTypes.Binary
+	}
+
 	//exhaustive:ignore
 	switch dataType1.ID() {
 	case arrow.PrimitiveTypes.Uint8.ID():
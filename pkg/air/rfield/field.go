@@ -42,6 +42,13 @@ func NewField(name string, value Value) *Field {
 	}
 }
 
+func NewNullField(name string) *Field {
+	return &Field{
+		Name:  name,
+		Value: &Null{},
+	}
+}
+
 func NewBoolField(name string, value bool) *Field {
 	return &Field{
 		Name: name,
@@ -194,6 +201,8 @@ func (f *Field) WriteSignature(sig *strings.Builder) {
 	sig.WriteString(f.Name)
 	sig.WriteString(":")
 	switch v := f.Value.(type) {
+	case *Null:
+		sig.WriteString(DataTypeSignature(v.DataType()))
 	case *Bool:
 		sig.WriteString(BOOL_SIG)
 	case *I8:
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"fmt"
 	"sort"
+	"strconv"
 
 	"github.com/apache/arrow/go/v9/arrow"
 )
@@ -42,12 +43,70 @@ type Value interface {
 
 	AsF32() (*float32, error)
 	AsF64() (*float64, error)
+
+	AsString() (*string, error)
+	AsBinary() ([]byte, error)
 }
 
 type CommonValue struct{}
 
 func (cv *CommonValue) Normalize() {}
 
+// Null is a value without content (e.g. an unset attribute value). A Null value is untyped (arrow.Null) unless a data
+// type is set with `SetDataType`, it is then stored as a null entry of a column of this type. All the AsXXX methods
+// return a nil pointer and no error, so a Null value can be pushed as a null entry in a column of any type.
+type Null struct {
+	CommonValue
+	dataType arrow.DataType
+}
+
+// NewTypedNull creates a Null value of the given data type (a scalar, list or struct type).
+func NewTypedNull(dataType arrow.DataType) *Null {
+	return &Null{dataType: dataType}
+}
+
+func (v *Null) DataType() arrow.DataType {
+	if v.dataType != nil {
+		return v.dataType
+	}
+	return arrow.Null
+}
+
+// SetDataType sets the data type of the Null value (a scalar, list or struct type).
+func (v *Null) SetDataType(dataType arrow.DataType) {
+	v.dataType = dataType
+}
+func (v *Null) ValueByPath(path []int) Value {
+	if path == nil || len(path) == 0 {
+		return v
+	}
+	return nil
+}
+
+// Compare sorts the Null values before the other values.
+func (v *Null) Compare(other Value) int {
+	if other == nil {
+		panic("invalid comparison")
+	}
+	if _, ok := other.(*Null); ok {
+		return 0
+	}
+	return -1
+}
+func (v *Null) AsBool() (*bool, error)     { return nil, nil }
+func (v *Null) AsU8() (*uint8, error)      { return nil, nil }
+func (v *Null) AsU16() (*uint16, error)    { return nil, nil }
+func (v *Null) AsU32() (*uint32, error)    { return nil, nil }
+func (v *Null) AsU64() (*uint64, error)    { return nil, nil }
+func (v *Null) AsI8() (*int8, error)       { return nil, nil }
+func (v *Null) AsI16() (*int16, error)     { return nil, nil }
+func (v *Null) AsI32() (*int32, error)     { return nil, nil }
+func (v *Null) AsI64() (*int64, error)     { return nil, nil }
+func (v *Null) AsF32() (*float32, error)   { return nil, nil }
+func (v *Null) AsF64() (*float64, error)   { return nil, nil }
+func (v *Null) AsString() (*string, error) { return nil, nil }
+func (v *Null) AsBinary() ([]byte, error)  { return nil, nil }
+
 type Bool struct {
 	CommonValue
 	Value bool
@@ -139,6 +198,14 @@ func (v *Bool) AsF32() (*float32, error) {
 func (v *Bool) AsF64() (*float64, error) {
 	return nil, fmt.Errorf("cannot convert bool to float64")
 }
+func (v *Bool) AsString() (*string, error) {
+	value := strconv.FormatBool(v.Value)
+	return &value, nil
+}
+func (v *Bool) AsBinary() ([]byte, error) {
+	value, _ := v.AsString()
+	return []byte(*value), nil
+}
 
 type I8 struct {
 	CommonValue
@@ -201,6 +268,14 @@ func (v *I8) AsF32() (*float32, error) {
 func (v *I8) AsF64() (*float64, error) {
 	return nil, fmt.Errorf("cannot convert signed integer to float64")
 }
+func (v *I8) AsString() (*string, error) {
+	value := strconv.FormatInt(int64(v.Value), 10)
+	return &value, nil
+}
+func (v *I8) AsBinary() ([]byte, error) {
+	value, _ := v.AsString()
+	return []byte(*value), nil
+}
 
 type I16 struct {
 	CommonValue
@@ -262,6 +337,14 @@ func (v *I16) AsF32() (*float32, error) {
 func (v *I16) AsF64() (*float64, error) {
 	return nil, fmt.Errorf("cannot convert signed integer to float64")
 }
+func (v *I16) AsString() (*string, error) {
+	value := strconv.FormatInt(int64(v.Value), 10)
+	return &value, nil
+}
+func (v *I16) AsBinary() ([]byte, error) {
+	value, _ := v.AsString()
+	return []byte(*value), nil
+}
 
 type I32 struct {
 	CommonValue
@@ -322,6 +405,14 @@ func (v *I32) AsF32() (*float32, error) {
 func (v *I32) AsF64() (*float64, error) {
 	return nil, fmt.Errorf("cannot convert signed integer to float64")
 }
+func (v *I32) AsString() (*string, error) {
+	value := strconv.FormatInt(int64(v.Value), 10)
+	return &value, nil
+}
+func (v *I32) AsBinary() ([]byte, error) {
+	value, _ := v.AsString()
+	return []byte(*value), nil
+}
 
 type I64 struct {
 	CommonValue
@@ -381,6 +472,14 @@ func (v *I64) AsF32() (*float32, error) {
 func (v *I64) AsF64() (*float64, error) {
 	return nil, fmt.Errorf("cannot convert signed integer to float64")
 }
+func (v *I64) AsString() (*string, error) {
+	value := strconv.FormatInt(v.Value, 10)
+	return &value, nil
+}
+func (v *I64) AsBinary() ([]byte, error) {
+	value, _ := v.AsString()
+	return []byte(*value), nil
+}
 
 type U8 struct {
 	CommonValue
@@ -446,6 +545,14 @@ func (v *U8) AsF32() (*float32, error) {
 func (v *U8) AsF64() (*float64, error) {
 	return nil, fmt.Errorf("cannot convert unsigned integer to float64")
 }
+func (v *U8) AsString() (*string, error) {
+	value := strconv.FormatUint(uint64(v.Value), 10)
+	return &value, nil
+}
+func (v *U8) AsBinary() ([]byte, error) {
+	value, _ := v.AsString()
+	return []byte(*value), nil
+}
 
 type U16 struct {
 	CommonValue
@@ -509,6 +616,14 @@ func (v *U16) AsF32() (*float32, error) {
 func (v *U16) AsF64() (*float64, error) {
 	return nil, fmt.Errorf("cannot convert unsigned integer to float64")
 }
+func (v *U16) AsString() (*string, error) {
+	value := strconv.FormatUint(uint64(v.Value), 10)
+	return &value, nil
+}
+func (v *U16) AsBinary() ([]byte, error) {
+	value, _ := v.AsString()
+	return []byte(*value), nil
+}
 
 type U32 struct {
 	CommonValue
@@ -571,6 +686,14 @@ func (v *U32) AsF32() (*float32, error) {
 func (v *U32) AsF64() (*float64, error) {
 	return nil, fmt.Errorf("cannot convert unsigned integer to float64")
 }
+func (v *U32) AsString() (*string, error) {
+	value := strconv.FormatUint(uint64(v.Value), 10)
+	return &value, nil
+}
+func (v *U32) AsBinary() ([]byte, error) {
+	value, _ := v.AsString()
+	return []byte(*value), nil
+}
 
 type U64 struct {
 	CommonValue
@@ -631,6 +754,14 @@ func (v *U64) AsF32() (*float32, error) {
 func (v *U64) AsF64() (*float64, error) {
 	return nil, fmt.Errorf("cannot convert unsigned integer to float64")
 }
+func (v *U64) AsString() (*string, error) {
+	value := strconv.FormatUint(v.Value, 10)
+	return &value, nil
+}
+func (v *U64) AsBinary() ([]byte, error) {
+	value, _ := v.AsString()
+	return []byte(*value), nil
+}
 
 type F32 struct {
 	CommonValue
@@ -691,6 +822,14 @@ func (v *F32) AsF64() (*float64, error) {
 	value := float64(v.Value)
 	return &value, nil
 }
+func (v *F32) AsString() (*string, error) {
+	value := strconv.FormatFloat(float64(v.Value), 'g', -1, 32)
+	return &value, nil
+}
+func (v *F32) AsBinary() ([]byte, error) {
+	value, _ := v.AsString()
+	return []byte(*value), nil
+}
 
 type F64 struct {
 	CommonValue
@@ -750,6 +889,14 @@ func (v *F64) AsF32() (*float32, error) {
 func (v *F64) AsF64() (*float64, error) {
 	return &v.Value, nil
 }
+func (v *F64) AsString() (*string, error) {
+	value := strconv.FormatFloat(v.Value, 'g', -1, 64)
+	return &value, nil
+}
+func (v *F64) AsBinary() ([]byte, error) {
+	value, _ := v.AsString()
+	return []byte(*value), nil
+}
 
 type String struct {
 	CommonValue
@@ -809,6 +956,13 @@ func (v *String) AsF32() (*float32, error) {
 func (v *String) AsF64() (*float64, error) {
 	return nil, fmt.Errorf("cannot convert string to float64")
 }
+func (v *String) AsString() (*string, error) {
+	return &v.Value, nil
+}
+func (v *String) AsBinary() ([]byte, error) {
+	value, _ := v.AsString()
+	return []byte(*value), nil
+}
 
 type Binary struct {
 	CommonValue
@@ -862,6 +1016,13 @@ func (v *Binary) AsF32() (*float32, error) {
 func (v *Binary) AsF64() (*float64, error) {
 	return nil, fmt.Errorf("cannot convert binary to float64")
 }
+func (v *Binary) AsString() (*string, error) {
+	value := string(v.Value)
+	return &value, nil
+}
+func (v *Binary) AsBinary() ([]byte, error) {
+	return v.Value, nil
+}
 
 type Struct struct {
 	Fields []*Field
@@ -925,16 +1086,29 @@ func (v *Struct) AsF32() (*float32, error) {
 func (v *Struct) AsF64() (*float64, error) {
 	return nil, fmt.Errorf("cannot convert struct to float64")
 }
+func (v *Struct) AsString() (*string, error) {
+	return nil, fmt.Errorf("cannot convert struct to string")
+}
+func (v *Struct) AsBinary() ([]byte, error) {
+	return nil, fmt.Errorf("cannot convert struct to binary")
+}
 
 type List struct {
 	etype  arrow.DataType
 	Values []Value
 }
 
+// NewTypedList creates a list with an explicit element type (e.g. to represent an empty list of strings).
+func NewTypedList(etype arrow.DataType, values []Value) *List {
+	return &List{etype: etype, Values: values}
+}
+
 func (v *List) DataType() arrow.DataType {
 	return arrow.ListOf(v.EType())
 }
 
+// EType returns the data type of the list's elements. The element type of an empty list created without explicit type
+// is arrow.Null.
 func (v *List) EType() arrow.DataType {
 	if v.etype == nil {
 		v.etype = listDataType(v.Values)
@@ -942,6 +1116,11 @@ func (v *List) EType() arrow.DataType {
 	return v.etype
 }
 
+// SetEType sets the data type of the list's elements.
+func (v *List) SetEType(etype arrow.DataType) {
+	v.etype = etype
+}
+
 func listDataType(values []Value) arrow.DataType {
 	dataTypeSet := map[string]arrow.DataType{}
 
@@ -1012,5 +1191,11 @@ func (v *List) AsF32() (*float32, error) {
 func (v *List) AsF64() (*float64, error) {
 	return nil, fmt.Errorf("cannot convert list to float64")
 }
+func (v *List) AsString() (*string, error) {
+	return nil, fmt.Errorf("cannot convert list to string")
+}
+func (v *List) AsBinary() ([]byte, error) {
+	return nil, fmt.Errorf("cannot convert list to binary")
+}
 
 // ToDo what about list mixing struct, uint, string, ... items?
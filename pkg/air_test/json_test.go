@@ -0,0 +1,214 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package air_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v9/arrow/array"
+
+	"otel-arrow-adapter/pkg/air"
+	config2 "otel-arrow-adapter/pkg/air/config"
+	"otel-arrow-adapter/pkg/air/rfield"
+)
+
+func TestJsonToRecord(t *testing.T) {
+	t.Parallel()
+
+	doc := `{
+		"ts": 1,
+		"big": 18446744073709551615,
+		"ratio": 0.5,
+		"name": "test",
+		"ok": true,
+		"missing": null,
+		"empty": {},
+		"tags": ["a", "b"],
+		"values": [1, 2.5, null],
+		"none": [],
+		"http": {"method": "GET", "status": 200, "headers": [{"k": "a", "v": "b"}]}
+	}`
+
+	record, err := air.JsonToRecord([]byte(doc), air.NewDefaultJsonConfig())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	record.Normalize()
+
+	expectedSchemaId := "big:U64,empty:{},http:{headers:[{k:Str,v:Str}],method:Str,status:I64},missing:Nul,name:Str,none:[Nul],ok:Bol,ratio:F64,tags:[Str],ts:I64,values:[F64]"
+	if record.SchemaId() != expectedSchemaId {
+		t.Errorf("Expected: %s\nGot: %s", expectedSchemaId, record.SchemaId())
+	}
+
+	rr := air.NewRecordRepository(config2.NewDefaultConfig())
+	if err := rr.AddRecord(record); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	records, err := rr.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("Expected 1 record, got %d", len(records))
+	}
+	for _, r := range records {
+		if r.NumRows() != 1 {
+			t.Errorf("Expected 1 row, got %d", r.NumRows())
+		}
+		if r.NumCols() != 11 {
+			t.Errorf("Expected 11 columns, got %d", r.NumCols())
+		}
+	}
+}
+
+func TestJsonNumberTypes(t *testing.T) {
+	t.Parallel()
+
+	doc := []byte(`{"a": 1, "b": 1.5}`)
+
+	config := air.NewDefaultJsonConfig()
+	config.NumberType = air.JsonNumberF64
+	record, err := air.JsonToRecord(doc, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	record.Normalize()
+	if record.SchemaId() != "a:F64,b:F64" {
+		t.Errorf("Expected: a:F64,b:F64\nGot: %s", record.SchemaId())
+	}
+
+	config.NumberType = air.JsonNumberString
+	record, err = air.JsonToRecord(doc, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	record.Normalize()
+	if record.SchemaId() != "a:Str,b:Str" {
+		t.Errorf("Expected: a:Str,b:Str\nGot: %s", record.SchemaId())
+	}
+}
+
+func TestJsonMaxDepth(t *testing.T) {
+	t.Parallel()
+
+	config := air.NewDefaultJsonConfig()
+	config.MaxDepth = 2
+
+	if _, err := air.JsonToRecord([]byte(`{"a": {"b": 1}}`), config); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := air.JsonToRecord([]byte(`{"a": {"b": [1]}}`), config); err == nil {
+		t.Errorf("Expected a max depth error")
+	}
+}
+
+func TestJsonErrors(t *testing.T) {
+	t.Parallel()
+
+	config := air.NewDefaultJsonConfig()
+	docs := []string{
+		`[1, 2]`,
+		`{"a": 1} {"b": 2}`,
+		`{"a": 1}}`,
+	}
+	for _, doc := range docs {
+		if _, err := air.JsonToRecord([]byte(doc), config); err == nil {
+			t.Errorf("Expected an error for %s", doc)
+		}
+	}
+
+	// The lists mixing types that can't be converted without loss.
+	docs = []string{
+		`{"a": [1, "a", false]}`,
+		`{"a": [{"b": 1}, 1]}`,
+		`{"a": [[1], 1]}`,
+		`{"a": [[1], ["a"]]}`,
+		`{"a": [{"b": 1}, {"b": "c"}]}`,
+	}
+	for _, doc := range docs {
+		if _, err := air.JsonToRecord([]byte(doc), config); !errors.Is(err, rfield.ErrIncompatibleTypes) {
+			t.Errorf("Expected an ErrIncompatibleTypes error for %s, got %v", doc, err)
+		}
+	}
+}
+
+func TestJsonListCoercion(t *testing.T) {
+	t.Parallel()
+
+	doc := `{
+		"objects": [{"a": 1, "b": {"c": "x"}}, {"a": 1.5, "d": [true]}, {}, null],
+		"lists": [[1], [2.5, null], []],
+		"numbers": [1, 18446744073709551615]
+	}`
+
+	record, err := air.JsonToRecord([]byte(doc), air.NewDefaultJsonConfig())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	record.Normalize()
+
+	expectedSchemaId := "lists:[[F64]],numbers:[F64],objects:[{a:F64,b:{c:Str},d:[Bol]}]"
+	if record.SchemaId() != expectedSchemaId {
+		t.Errorf("Expected: %s\nGot: %s", expectedSchemaId, record.SchemaId())
+	}
+
+	rr := air.NewRecordRepository(config2.NewDefaultConfig())
+	if err := rr.AddRecord(record); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	records, err := rr.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	r, ok := records[expectedSchemaId]
+	if !ok {
+		t.Fatalf("Expected a record with the schema id %s", expectedSchemaId)
+	}
+	defer r.Release()
+	objects := r.Column(r.Schema().FieldIndices("objects")[0]).(*array.List).ListValues().(*array.Struct)
+	if objects.Len() != 4 || objects.NullN() != 1 {
+		t.Errorf("Expected 4 objects with 1 null, got %d (nulls: %d)", objects.Len(), objects.NullN())
+	}
+	a := objects.Field(0).(*array.Float64)
+	if a.Value(0) != 1 || a.Value(1) != 1.5 || a.NullN() != 2 {
+		t.Errorf("Unexpected values %v", a)
+	}
+}
+
+func TestJsonStreamToRecords(t *testing.T) {
+	t.Parallel()
+
+	stream := `{"ts": 1, "name": "a"}
+{"ts": 2, "name": "b"}
+{"ts": 3, "value": 1.5}
+`
+	records, err := air.JsonStreamToRecords(strings.NewReader(stream), air.NewDefaultJsonConfig())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+
+	rr := air.NewRecordRepository(config2.NewDefaultConfig())
+	for _, record := range records {
+		rr.AddRecord(record)
+	}
+	if rr.RecordBuilderCount() != 2 {
+		t.Errorf("Expected 2 RecordBuilders, got %d", rr.RecordBuilderCount())
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package air_test
+
+import (
+	"testing"
+
+	"github.com/apache/arrow/go/v9/arrow"
+	"github.com/apache/arrow/go/v9/arrow/array"
+
+	"otel-arrow-adapter/pkg/air"
+	config2 "otel-arrow-adapter/pkg/air/config"
+	"otel-arrow-adapter/pkg/air/rfield"
+)
+
+func TestNullAndEmptyValues(t *testing.T) {
+	t.Parallel()
+
+	rr := air.NewRecordRepository(config2.NewDefaultConfig())
+
+	// The first record defines the element type of the list `attrs.tags`.
+	record := air.NewRecord()
+	record.I64Field("ts", 1)
+	record.NullField("unset")
+	record.StructField("empty", rfield.Struct{})
+	record.StructField("attrs", rfield.Struct{Fields: []*rfield.Field{
+		rfield.NewListField("tags", rfield.List{Values: []rfield.Value{&rfield.String{Value: "a"}, &rfield.Null{}}}),
+	}})
+	rr.AddRecord(record)
+
+	// The following records contain empty lists and must not create new schemas.
+	for i := 2; i <= 3; i++ {
+		record := air.NewRecord()
+		record.I64Field("ts", int64(i))
+		record.NullField("unset")
+		record.StructField("empty", rfield.Struct{})
+		record.StructField("attrs", rfield.Struct{Fields: []*rfield.Field{
+			rfield.NewListField("tags", rfield.List{}),
+		}})
+		rr.AddRecord(record)
+	}
+
+	if rr.RecordBuilderCount() != 1 {
+		t.Fatalf("Expected 1 RecordBuilder, got %d", rr.RecordBuilderCount())
+	}
+
+	records, err := rr.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for schemaId, r := range records {
+		if schemaId != "attrs:{tags:[Str]},empty:{},ts:I64,unset:Nul" {
+			t.Errorf("Unexpected schema id %s", schemaId)
+		}
+		if r.NumRows() != 3 {
+			t.Errorf("Expected 3 rows, got %d", r.NumRows())
+		}
+		for i, field := range r.Schema().Fields() {
+			switch field.Name {
+			case "unset":
+				if field.Type.ID() != arrow.NULL || r.Column(i).NullN() != 3 {
+					t.Errorf("Expected a null column with 3 nulls, got %v", field.Type)
+				}
+			case "empty":
+				if field.Type.ID() != arrow.STRUCT || r.Column(i).Len() != 3 {
+					t.Errorf("Expected an empty struct column of 3 rows, got %v", field.Type)
+				}
+			case "attrs":
+				if name := field.Type.(*arrow.StructType).Field(0).Name; name != "tags" {
+					t.Errorf("Expected list column tags, got %s", name)
+				}
+				tags := r.Column(i).(*array.Struct).Field(0).(*array.List)
+				if tags.Len() != 3 || tags.NullN() != 0 {
+					t.Errorf("Expected 3 non-null lists, got %d (nulls: %d)", tags.Len(), tags.NullN())
+				}
+				// [a, null], [], []
+				offsets := tags.Offsets()
+				if offsets[1] != 2 || offsets[2] != 2 || offsets[3] != 2 {
+					t.Errorf("Unexpected list offsets %v", offsets)
+				}
+				if tags.ListValues().NullN() != 1 {
+					t.Errorf("Expected 1 null list item, got %d", tags.ListValues().NullN())
+				}
+			}
+		}
+	}
+}
+
+func TestUntypedEmptyList(t *testing.T) {
+	t.Parallel()
+
+	rr := air.NewRecordRepository(config2.NewDefaultConfig())
+
+	// No element type has been seen before, the list is a list of nulls.
+	record := air.NewRecord()
+	record.ListField("tags", rfield.List{})
+	rr.AddRecord(record)
+
+	// Explicitly typed empty list.
+	record = air.NewRecord()
+	record.GenericField("values", rfield.NewTypedList(arrow.PrimitiveTypes.Int64, nil))
+	rr.AddRecord(record)
+
+	records, err := rr.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	for _, schemaId := range []string{"tags:[Nul]", "values:[I64]"} {
+		r, ok := records[schemaId]
+		if !ok {
+			t.Errorf("Expected a record with the schema id %s", schemaId)
+			continue
+		}
+		if r.NumRows() != 1 {
+			t.Errorf("Expected 1 row, got %d", r.NumRows())
+		}
+	}
+}
+
+func TestNullValuesDontForkSchemas(t *testing.T) {
+	t.Parallel()
+
+	rr := air.NewRecordRepository(config2.NewDefaultConfig())
+
+	// The null values and the empty lists are typed with the data types seen before at the same path.
+	record := air.NewRecord()
+	record.StringField("x", "a")
+	record.ListField("tags", rfield.List{Values: []rfield.Value{&rfield.String{Value: "a"}}})
+	record.ListField("items", rfield.List{Values: []rfield.Value{
+		&rfield.Struct{Fields: []*rfield.Field{rfield.NewNullField("name")}},
+		&rfield.Struct{Fields: []*rfield.Field{rfield.NewStringField("name", "a")}},
+	}})
+	record.StructField("attrs", rfield.Struct{Fields: []*rfield.Field{rfield.NewI64Field("a", 1)}})
+	if err := rr.AddRecord(record); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		record = air.NewRecord()
+		record.NullField("x")
+		record.ListField("tags", rfield.List{})
+		record.ListField("items", rfield.List{})
+		record.NullField("attrs")
+		if err := rr.AddRecord(record); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	records, err := rr.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(records))
+	}
+	r, ok := records["attrs:{a:I64},items:[{name:Str}],tags:[Str],x:Str"]
+	if !ok {
+		for schemaId := range records {
+			t.Fatalf("Unexpected schema id %s", schemaId)
+		}
+	}
+	defer r.Release()
+	if r.NumRows() != 3 {
+		t.Errorf("Expected 3 rows, got %d", r.NumRows())
+	}
+	for i, field := range r.Schema().Fields() {
+		switch field.Name {
+		case "x":
+			// The rows keep the order of the records.
+			x := r.Column(i).(*array.String)
+			if x.NullN() != 2 || x.IsNull(0) || x.Value(0) != "a" {
+				t.Errorf("Expected a non-null first value followed by 2 null values, got %v", x)
+			}
+		case "attrs":
+			attrs := r.Column(i).(*array.Struct)
+			if attrs.NullN() != 2 || attrs.Field(0).NullN() != 2 {
+				t.Errorf("Expected 2 null structs, got %d", attrs.NullN())
+			}
+		}
+	}
+}
+
+func TestNullValuesBeforeTypedValues(t *testing.T) {
+	t.Parallel()
+
+	rr := air.NewRecordRepository(config2.NewDefaultConfig())
+
+	// A null value seen before any typed value at the same path stays untyped, the records are not reordered.
+	record := air.NewRecord()
+	record.NullField("x")
+	if err := rr.AddRecord(record); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	record = air.NewRecord()
+	record.StringField("x", "a")
+	if err := rr.AddRecord(record); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	records, err := rr.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+
+	// The data types are forgotten after a batch without the path.
+	for i, expectedSchemaId := range []string{"x:Str", "x:Nul"} {
+		if i > 0 {
+			if _, err := rr.Build(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		record = air.NewRecord()
+		record.NullField("x")
+		if err := rr.AddRecord(record); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		records, err := rr.Build()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for schemaId, r := range records {
+			if r.NumRows() > 0 && schemaId != expectedSchemaId {
+				t.Errorf("Expected the schema id %s, got %s", expectedSchemaId, schemaId)
+			}
+		}
+	}
+}
+
+func TestListOfStructsWithDifferentFields(t *testing.T) {
+	t.Parallel()
+
+	rr := air.NewRecordRepository(config2.NewDefaultConfig())
+
+	// The items share the union of their fields, the missing fields and the null items are null.
+	record := air.NewRecord()
+	record.ListField("items", rfield.List{Values: []rfield.Value{
+		&rfield.Struct{Fields: []*rfield.Field{
+			rfield.NewI64Field("a", 1),
+			rfield.NewBinaryField("id", []byte{1}),
+			rfield.NewStructField("attrs", rfield.Struct{Fields: []*rfield.Field{rfield.NewStringField("x", "x")}}),
+		}},
+		&rfield.Struct{Fields: []*rfield.Field{
+			rfield.NewF64Field("b", 2),
+			rfield.NewStructField("attrs", rfield.Struct{Fields: []*rfield.Field{rfield.NewBoolField("y", true)}}),
+		}},
+		&rfield.Struct{},
+		&rfield.Null{},
+	}})
+	rr.AddRecord(record)
+
+	records, err := rr.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	r, ok := records["items:[{a:I64,attrs:{x:Str,y:Bol},b:F64,id:Bin}]"]
+	if !ok {
+		for schemaId := range records {
+			t.Fatalf("Unexpected schema id %s", schemaId)
+		}
+	}
+	items := r.Column(0).(*array.List).ListValues().(*array.Struct)
+	if items.Len() != 4 || items.NullN() != 1 {
+		t.Fatalf("Expected 4 list items with 1 null, got %d (nulls: %d)", items.Len(), items.NullN())
+	}
+	for i, field := range items.DataType().(*arrow.StructType).Fields() {
+		column := items.Field(i)
+		switch field.Name {
+		case "a", "b", "id":
+			if column.NullN() != 3 {
+				t.Errorf("Expected 3 nulls in %s, got %d", field.Name, column.NullN())
+			}
+		case "attrs":
+			attrs := column.(*array.Struct)
+			if attrs.NullN() != 2 {
+				t.Errorf("Expected 2 null attribute structs, got %d", attrs.NullN())
+			}
+			if attrs.Field(0).NullN() != 3 || attrs.Field(1).NullN() != 3 {
+				t.Errorf("Expected 3 nulls per attribute, got %d and %d", attrs.Field(0).NullN(), attrs.Field(1).NullN())
+			}
+		}
+	}
+}
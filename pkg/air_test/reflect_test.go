@@ -0,0 +1,215 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package air_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v9/arrow"
+	"github.com/apache/arrow/go/v9/arrow/array"
+	"github.com/google/go-cmp/cmp"
+
+	"otel-arrow-adapter/pkg/air"
+	config2 "otel-arrow-adapter/pkg/air/config"
+	"otel-arrow-adapter/pkg/air/rfield"
+)
+
+type HttpRequest struct {
+	Method  string            `air:"method,dict"`
+	Path    string            `air:"path"`
+	Status  uint16            `air:"status"`
+	Headers map[string]string `air:"headers"`
+}
+
+type Event struct {
+	Common
+	Timestamp time.Time     `air:"time_unix_nano"`
+	Duration  time.Duration `air:"duration"`
+	Service   string        `air:"service,dict"`
+	Payload   []byte        `air:"payload,omitempty"`
+	Tags      []string      `air:"tags"`
+	Request   *HttpRequest  `air:"request"`
+	Spans     []Span        `air:"spans"`
+	Ignored   string        `air:"-"`
+	internal  string
+}
+
+type Common struct {
+	Host string `air:"host"`
+}
+
+type Span struct {
+	Name  string  `air:"name"`
+	Ratio float64 `air:"ratio"`
+}
+
+func TestStructToRecord(t *testing.T) {
+	t.Parallel()
+
+	event := Event{
+		Common:    Common{Host: "host1"},
+		Timestamp: time.Unix(0, 10),
+		Duration:  time.Second,
+		Service:   "svc",
+		Tags:      []string{"a", "b"},
+		Request: &HttpRequest{
+			Method:  "GET",
+			Path:    "/",
+			Status:  200,
+			Headers: map[string]string{"accept": "*/*"},
+		},
+		Spans:    []Span{{Name: "s1", Ratio: 0.5}, {Name: "s2", Ratio: 1}},
+		Ignored:  "ignored",
+		internal: "internal",
+	}
+
+	record, err := air.StructToRecord(&event)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := air.NewRecord()
+	expected.StringField("host", "host1")
+	expected.U64Field("time_unix_nano", 10)
+	expected.I64Field("duration", int64(time.Second))
+	expected.StringField("service", "svc")
+	expected.DictionaryField("service")
+	expected.ListField("tags", rfield.List{Values: []rfield.Value{
+		&rfield.String{Value: "a"},
+		&rfield.String{Value: "b"},
+	}})
+	expected.StructField("request", rfield.Struct{Fields: []*rfield.Field{
+		rfield.NewStringField("method", "GET"),
+		rfield.NewStringField("path", "/"),
+		rfield.NewU16Field("status", 200),
+		rfield.NewStructField("headers", rfield.Struct{Fields: []*rfield.Field{
+			rfield.NewStringField("accept", "*/*"),
+		}}),
+	}})
+	expected.ListField("spans", rfield.List{Values: []rfield.Value{
+		&rfield.Struct{Fields: []*rfield.Field{rfield.NewStringField("name", "s1"), rfield.NewF64Field("ratio", 0.5)}},
+		&rfield.Struct{Fields: []*rfield.Field{rfield.NewStringField("name", "s2"), rfield.NewF64Field("ratio", 1)}},
+	}})
+
+	if !cmp.Equal(record, expected, cmp.AllowUnexported(air.Record{}, rfield.Struct{}, rfield.List{})) {
+		t.Errorf("Expected: %+v\nGot: %+v", expected, record)
+	}
+
+	rr := air.NewRecordRepository(config2.NewDefaultConfig())
+	for i := 0; i < 10; i++ {
+		record, err := air.StructToRecord(&event)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		rr.AddRecord(record)
+	}
+	records, err := rr.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("Expected 1 record, got %d", len(records))
+	}
+	for _, r := range records {
+		if r.NumRows() != 10 {
+			t.Errorf("Expected 10 rows, got %d", r.NumRows())
+		}
+	}
+}
+
+func TestRecordEncoderDictionary(t *testing.T) {
+	t.Parallel()
+
+	rr := air.NewRecordRepository(config2.NewDefaultConfig())
+	for i := 0; i < 10; i++ {
+		record, err := air.StructToRecord(&Event{
+			Service: fmt.Sprintf("svc_%d", i%2),
+			Request: &HttpRequest{Method: "GET"},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		rr.AddRecord(record)
+	}
+	records, err := rr.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(records))
+	}
+
+	for schemaId, record := range records {
+		if !strings.Contains(schemaId, "service:Dic<Str>") {
+			t.Errorf("Expected a dictionary field in the schema id, got %s", schemaId)
+		}
+		indices := record.Schema().FieldIndices("service")
+		if len(indices) != 1 {
+			t.Fatalf("Expected a service column, got %v", record.Schema())
+		}
+		service, ok := record.Column(indices[0]).(*array.Dictionary)
+		if !ok {
+			t.Fatalf("Expected a dictionary column, got %v", record.Column(indices[0]).DataType())
+		}
+		if service.Dictionary().Len() != 2 {
+			t.Errorf("Expected 2 dictionary entries, got %d", service.Dictionary().Len())
+		}
+		values := service.Dictionary().(*array.String)
+		for i := 0; i < service.Len(); i++ {
+			if value := values.Value(service.GetValueIndex(i)); value != fmt.Sprintf("svc_%d", i%2) {
+				t.Errorf("Expected svc_%d, got %s", i%2, value)
+			}
+		}
+
+		// The option is ignored for the fields of nested structs.
+		indices = record.Schema().FieldIndices("request")
+		request := record.Column(indices[0]).DataType().(*arrow.StructType)
+		if method, ok := request.FieldByName("method"); !ok || method.Type.ID() != arrow.STRING {
+			t.Errorf("Expected a string column, got %v", request)
+		}
+		record.Release()
+	}
+}
+
+func TestRecordEncoderErrors(t *testing.T) {
+	t.Parallel()
+
+	type InvalidDict struct {
+		Count int `air:"count,dict"`
+	}
+	type UnknownOption struct {
+		Count int `air:"count,unknown"`
+	}
+	type UnsupportedType struct {
+		Ch chan int
+	}
+	type ListOfList struct {
+		Values [][]int
+	}
+	type DuplicateName struct {
+		A string `air:"x"`
+		B string `air:"x"`
+	}
+
+	encoder := air.NewRecordEncoder()
+	for _, value := range []interface{}{InvalidDict{}, UnknownOption{}, UnsupportedType{}, ListOfList{}, DuplicateName{}, 1, (*Event)(nil)} {
+		if _, err := encoder.Encode(value); err == nil {
+			t.Errorf("Expected an error for %T", value)
+		}
+	}
+}
@@ -15,6 +15,7 @@
 package air_test
 
 import (
+	"errors"
 	"math"
 	"math/rand"
 	"testing"
@@ -25,6 +26,7 @@ import (
 
 	"otel-arrow-adapter/pkg/air"
 	config2 "otel-arrow-adapter/pkg/air/config"
+	"otel-arrow-adapter/pkg/air/rfield"
 )
 
 func TestAddRecord(t *testing.T) {
@@ -81,6 +83,98 @@ func TestAddRecord(t *testing.T) {
 	//spew.Dump(rr.Metadata())
 }
 
+func TestAddRecordIncompatibleTypes(t *testing.T) {
+	t.Parallel()
+
+	invalidValues := map[string]rfield.Value{
+		"struct and scalar": &rfield.List{Values: []rfield.Value{
+			&rfield.Struct{Fields: []*rfield.Field{rfield.NewI64Field("a", 1)}},
+			&rfield.I64{Value: 1},
+		}},
+		"list and scalar": &rfield.List{Values: []rfield.Value{
+			&rfield.List{Values: []rfield.Value{&rfield.I64{Value: 1}}},
+			&rfield.String{Value: "a"},
+		}},
+		"nested lists": &rfield.List{Values: []rfield.Value{
+			&rfield.List{Values: []rfield.Value{&rfield.I64{Value: 1}}},
+			&rfield.List{Values: []rfield.Value{&rfield.Struct{}}},
+		}},
+		"duplicate fields": &rfield.Struct{Fields: []*rfield.Field{
+			rfield.NewI64Field("a", 1),
+			rfield.NewStringField("a", "a"),
+		}},
+	}
+
+	rr := air.NewRecordRepository(config2.NewDefaultConfig())
+	for name, value := range invalidValues {
+		record := air.NewRecord()
+		record.I64Field("ts", 1)
+		record.GenericField("value", value)
+		if err := rr.AddRecord(record); !errors.Is(err, rfield.ErrIncompatibleTypes) {
+			t.Errorf("%s: expected an ErrIncompatibleTypes error, got %v", name, err)
+		}
+	}
+	if rr.RecordBuilderCount() != 0 {
+		t.Errorf("Expected the invalid records to be ignored, got %d RecordBuilders", rr.RecordBuilderCount())
+	}
+}
+
+func TestAddRecordCoercedLists(t *testing.T) {
+	t.Parallel()
+
+	rr := air.NewRecordRepository(config2.NewDefaultConfig())
+	record := air.NewRecord()
+	// The scalars of different types are coerced to strings.
+	record.ListField("scalars", rfield.List{Values: []rfield.Value{
+		&rfield.I64{Value: 1},
+		&rfield.String{Value: "a"},
+		&rfield.Bool{Value: true},
+		&rfield.F64{Value: 1.5},
+	}})
+	// Mixed with binary values, they are coerced to binary.
+	record.ListField("bytes", rfield.List{Values: []rfield.Value{
+		&rfield.String{Value: "a"},
+		&rfield.Binary{Value: []byte("b")},
+		&rfield.U8{Value: 1},
+	}})
+	record.ListField("lists", rfield.List{Values: []rfield.Value{
+		&rfield.List{Values: []rfield.Value{&rfield.I64{Value: 1}, &rfield.I64{Value: 2}}},
+		&rfield.List{Values: []rfield.Value{&rfield.I64{Value: 3}}},
+	}})
+	if err := rr.AddRecord(record); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	records, err := rr.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	r, ok := records["bytes:[Bin],lists:[[I64]],scalars:[Str]"]
+	if !ok {
+		for schemaId := range records {
+			t.Fatalf("Unexpected schema id %s", schemaId)
+		}
+	}
+	defer r.Release()
+
+	scalars := r.Column(r.Schema().FieldIndices("scalars")[0]).(*array.List).ListValues().(*array.String)
+	for i, expected := range []string{"1", "a", "true", "1.5"} {
+		if scalars.Value(i) != expected {
+			t.Errorf("Expected %q, got %q", expected, scalars.Value(i))
+		}
+	}
+	bytes := r.Column(r.Schema().FieldIndices("bytes")[0]).(*array.List).ListValues().(*array.Binary)
+	for i, expected := range []string{"a", "b", "1"} {
+		if string(bytes.Value(i)) != expected {
+			t.Errorf("Expected %q, got %q", expected, bytes.Value(i))
+		}
+	}
+	lists := r.Column(r.Schema().FieldIndices("lists")[0]).(*array.List).ListValues().(*array.List)
+	if lists.Len() != 2 || lists.ListValues().Len() != 3 {
+		t.Errorf("Expected 2 lists of 3 values, got %d lists of %d values", lists.Len(), lists.ListValues().Len())
+	}
+}
+
 func TestOptimize(t *testing.T) {
 	t.Parallel()
 
@@ -45,7 +45,7 @@ func (s *MetricsProfileable) PrepareBatch(startAt, size int) {
 func (s *MetricsProfileable) CreateBatch(_, _ int) {
 	// Conversion of OTLP metrics to OTLP Arrow events
 	for _, m := range s.metrics {
-		records, err := metrics.OtlpMetricsToArrowRecords(s.rr, m, s.multivariateConfig)
+		records, _, err := metrics.OtlpMetricsToArrowRecords(s.rr, m, s.multivariateConfig)
 		if err != nil {
 			panic(err)
 		}
@@ -0,0 +1,437 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/apache/arrow/go/v9/arrow"
+	"github.com/apache/arrow/go/v9/arrow/array"
+	"google.golang.org/protobuf/proto"
+
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/resource/v1"
+	"otel-arrow-adapter/pkg/otel/constants"
+)
+
+// Helpers used to convert the Arrow records produced by the `OtlpXXXToArrowRecords` functions back to OTLP entities.
+//
+// The getters below return the zero value of the expected type when the column doesn't exist or when the value is
+// null (the OTLP to Arrow conversion omits the fields with a zero value).
+
+// Column returns the column of a record with the given name, or nil if the column doesn't exist.
+func Column(record arrow.Record, name string) arrow.Array {
+	indices := record.Schema().FieldIndices(name)
+	if len(indices) == 0 {
+		return nil
+	}
+	return record.Column(indices[0])
+}
+
+// StructField returns the field of a struct array with the given name, or nil if the field doesn't exist (or if the
+// array is not a struct array).
+func StructField(arr arrow.Array, name string) arrow.Array {
+	structArr, ok := arr.(*array.Struct)
+	if !ok {
+		return nil
+	}
+	idx, ok := structArr.DataType().(*arrow.StructType).FieldIdx(name)
+	if !ok {
+		return nil
+	}
+	return structArr.Field(idx)
+}
+
+// U64FromArray returns the value at position `row` of an unsigned integer array as an uint64.
+func U64FromArray(arr arrow.Array, row int) (uint64, error) {
+	if IsNull(arr, row) {
+		return 0, nil
+	}
+	switch a := arr.(type) {
+	case *array.Uint8:
+		return uint64(a.Value(row)), nil
+	case *array.Uint16:
+		return uint64(a.Value(row)), nil
+	case *array.Uint32:
+		return uint64(a.Value(row)), nil
+	case *array.Uint64:
+		return a.Value(row), nil
+	default:
+		return 0, fmt.Errorf("expected an unsigned integer array, got %s", arr.DataType())
+	}
+}
+
+// U32FromArray returns the value at position `row` of an unsigned integer array as an uint32.
+func U32FromArray(arr arrow.Array, row int) (uint32, error) {
+	value, err := U64FromArray(arr, row)
+	if err != nil {
+		return 0, err
+	}
+	if value > math.MaxUint32 {
+		return 0, fmt.Errorf("value %d overflows uint32", value)
+	}
+	return uint32(value), nil
+}
+
+// I64FromArray returns the value at position `row` of a signed integer array as an int64.
+func I64FromArray(arr arrow.Array, row int) (int64, error) {
+	if IsNull(arr, row) {
+		return 0, nil
+	}
+	switch a := arr.(type) {
+	case *array.Int8:
+		return int64(a.Value(row)), nil
+	case *array.Int16:
+		return int64(a.Value(row)), nil
+	case *array.Int32:
+		return int64(a.Value(row)), nil
+	case *array.Int64:
+		return a.Value(row), nil
+	default:
+		return 0, fmt.Errorf("expected a signed integer array, got %s", arr.DataType())
+	}
+}
+
+// I32FromArray returns the value at position `row` of a signed integer array as an int32.
+func I32FromArray(arr arrow.Array, row int) (int32, error) {
+	value, err := I64FromArray(arr, row)
+	if err != nil {
+		return 0, err
+	}
+	if value > math.MaxInt32 || value < math.MinInt32 {
+		return 0, fmt.Errorf("value %d overflows int32", value)
+	}
+	return int32(value), nil
+}
+
+// F64FromArray returns the value at position `row` of a floating point array as a float64.
+func F64FromArray(arr arrow.Array, row int) (float64, error) {
+	if IsNull(arr, row) {
+		return 0, nil
+	}
+	switch a := arr.(type) {
+	case *array.Float32:
+		return float64(a.Value(row)), nil
+	case *array.Float64:
+		return a.Value(row), nil
+	default:
+		return 0, fmt.Errorf("expected a float array, got %s", arr.DataType())
+	}
+}
+
+// BoolFromArray returns the value at position `row` of a boolean array.
+func BoolFromArray(arr arrow.Array, row int) (bool, error) {
+	if IsNull(arr, row) {
+		return false, nil
+	}
+	a, ok := arr.(*array.Boolean)
+	if !ok {
+		return false, fmt.Errorf("expected a boolean array, got %s", arr.DataType())
+	}
+	return a.Value(row), nil
+}
+
+// StringFromArray returns the value at position `row` of a string array (or a dictionary of strings).
+func StringFromArray(arr arrow.Array, row int) (string, error) {
+	if IsNull(arr, row) {
+		return "", nil
+	}
+	switch a := arr.(type) {
+	case *array.String:
+		return a.Value(row), nil
+	case *array.Dictionary:
+		return StringFromArray(a.Dictionary(), a.GetValueIndex(row))
+	default:
+		return "", fmt.Errorf("expected a string array, got %s", arr.DataType())
+	}
+}
+
+// BinaryFromArray returns the value at position `row` of a binary array (or a dictionary of binaries).
+func BinaryFromArray(arr arrow.Array, row int) ([]byte, error) {
+	if IsNull(arr, row) {
+		return nil, nil
+	}
+	switch a := arr.(type) {
+	case *array.Binary:
+		// The returned slice is a copy, the Arrow buffers can be released independently of the OTLP entities.
+		return append([]byte(nil), a.Value(row)...), nil
+	case *array.Dictionary:
+		return BinaryFromArray(a.Dictionary(), a.GetValueIndex(row))
+	default:
+		return nil, fmt.Errorf("expected a binary array, got %s", arr.DataType())
+	}
+}
+
+// ListValues returns the values of a list array and the range [start, end) of the items of the list at position `row`.
+// An empty range is returned for null lists or when the array doesn't exist.
+func ListValues(arr arrow.Array, row int) (values arrow.Array, start int, end int, err error) {
+	if IsNull(arr, row) {
+		return nil, 0, 0, nil
+	}
+	listArr, ok := arr.(*array.List)
+	if !ok {
+		return nil, 0, 0, fmt.Errorf("expected a list array, got %s", arr.DataType())
+	}
+	offsets := listArr.Offsets()
+	pos := row + listArr.Data().Offset()
+	return listArr.ListValues(), int(offsets[pos]), int(offsets[pos+1]), nil
+}
+
+// ArrowValueToOtlpAnyValue converts the value at position `row` of an Arrow array into an OTLP AnyValue. Null values
+// are converted into nil, lists into array values and structs into kvlist values.
+func ArrowValueToOtlpAnyValue(arr arrow.Array, row int) (*commonpb.AnyValue, error) {
+	if IsNull(arr, row) {
+		return nil, nil
+	}
+
+	switch a := arr.(type) {
+	case *array.Boolean:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: a.Value(row)}}, nil
+	case *array.Int8, *array.Int16, *array.Int32, *array.Int64:
+		v, err := I64FromArray(arr, row)
+		if err != nil {
+			return nil, err
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}, nil
+	case *array.Uint8, *array.Uint16, *array.Uint32, *array.Uint64:
+		v, err := U64FromArray(arr, row)
+		if err != nil {
+			return nil, err
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}, nil
+	case *array.Float32, *array.Float64:
+		v, err := F64FromArray(arr, row)
+		if err != nil {
+			return nil, err
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}, nil
+	case *array.String:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: a.Value(row)}}, nil
+	case *array.Binary:
+		v, err := BinaryFromArray(arr, row)
+		if err != nil {
+			return nil, err
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: v}}, nil
+	case *array.Dictionary:
+		return ArrowValueToOtlpAnyValue(a.Dictionary(), a.GetValueIndex(row))
+	case *array.List:
+		values, start, end, err := ListValues(a, row)
+		if err != nil {
+			return nil, err
+		}
+		// The lists of structs are lists of variants (see `OtlpAnyValueToValue`).
+		itemToOtlpAnyValue := ArrowValueToOtlpAnyValue
+		if _, ok := values.(*array.Struct); ok {
+			itemToOtlpAnyValue = VariantToOtlpAnyValue
+		}
+		items := make([]*commonpb.AnyValue, 0, end-start)
+		for i := start; i < end; i++ {
+			item, err := itemToOtlpAnyValue(values, i)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: items}}}, nil
+	case *array.Struct:
+		kvs, err := KeyValuesFromArray(a, row)
+		if err != nil {
+			return nil, err
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: kvs}}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported arrow data type %s", arr.DataType())
+	}
+}
+
+// KeyValuesFromArray converts the struct at position `row` of a struct array into a list of OTLP KeyValues (e.g.
+// attributes). The KeyValues are sorted by key (the order of the original attributes is not preserved).
+func KeyValuesFromArray(arr arrow.Array, row int) ([]*commonpb.KeyValue, error) {
+	if IsNull(arr, row) {
+		return nil, nil
+	}
+	structArr, ok := arr.(*array.Struct)
+	if !ok {
+		return nil, fmt.Errorf("expected a struct array, got %s", arr.DataType())
+	}
+
+	fields := structArr.DataType().(*arrow.StructType).Fields()
+	kvs := make([]*commonpb.KeyValue, 0, len(fields))
+	for i, field := range fields {
+		value, err := ArrowValueToOtlpAnyValue(structArr.Field(i), row)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", field.Name, err)
+		}
+		kvs = append(kvs, &commonpb.KeyValue{Key: field.Name, Value: value})
+	}
+	return kvs, nil
+}
+
+// VariantToOtlpAnyValue converts the variant at position `row` of a struct array into an OTLP AnyValue (see
+// `OtlpAnyValueToVariant`). A variant without any set field is converted into nil.
+func VariantToOtlpAnyValue(arr arrow.Array, row int) (*commonpb.AnyValue, error) {
+	if IsNull(arr, row) {
+		return nil, nil
+	}
+	structArr, ok := arr.(*array.Struct)
+	if !ok {
+		return nil, fmt.Errorf("expected a struct array, got %s", arr.DataType())
+	}
+
+	for i, field := range structArr.DataType().(*arrow.StructType).Fields() {
+		value := structArr.Field(i)
+		if IsNull(value, row) {
+			continue
+		}
+		switch field.Name {
+		case constants.VARIANT_KVLIST:
+			kvs, err := VariantKeyValuesFromArray(value, row)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field.Name, err)
+			}
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: kvs}}}, nil
+		case constants.VARIANT_BOOL, constants.VARIANT_INT, constants.VARIANT_DOUBLE, constants.VARIANT_STRING,
+			constants.VARIANT_BYTES, constants.VARIANT_ARRAY:
+			// The scalars have their own Arrow type and the items of the arrays are variants.
+			return ArrowValueToOtlpAnyValue(value, row)
+		default:
+			return nil, fmt.Errorf("unknown variant field %q", field.Name)
+		}
+	}
+	return nil, nil
+}
+
+// VariantKeyValuesFromArray converts the struct of variants at position `row` of a struct array into a list of OTLP
+// KeyValues (see `NewItemAttributes`). The null fields are the keys missing from this item and are skipped.
+func VariantKeyValuesFromArray(arr arrow.Array, row int) ([]*commonpb.KeyValue, error) {
+	if IsNull(arr, row) {
+		return nil, nil
+	}
+	structArr, ok := arr.(*array.Struct)
+	if !ok {
+		return nil, fmt.Errorf("expected a struct array, got %s", arr.DataType())
+	}
+
+	fields := structArr.DataType().(*arrow.StructType).Fields()
+	kvs := make([]*commonpb.KeyValue, 0, len(fields))
+	for i, field := range fields {
+		if IsNull(structArr.Field(i), row) {
+			continue
+		}
+		value, err := VariantToOtlpAnyValue(structArr.Field(i), row)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", field.Name, err)
+		}
+		kvs = append(kvs, &commonpb.KeyValue{Key: field.Name, Value: value})
+	}
+	return kvs, nil
+}
+
+// KeyValueListFromArray converts the key/value list at position `row` of a list array into a list of OTLP KeyValues
+// (see `NewKeyValueList`). The order of the KeyValues is preserved.
+func KeyValueListFromArray(arr arrow.Array, row int) ([]*commonpb.KeyValue, error) {
+	items, start, end, err := ListValues(arr, row)
+	if err != nil || start == end {
+		return nil, err
+	}
+
+	keys := StructField(items, constants.KEY)
+	values := StructField(items, constants.VALUE)
+	kvs := make([]*commonpb.KeyValue, 0, end-start)
+	for i := start; i < end; i++ {
+		key, err := StringFromArray(keys, i)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", constants.KEY, err)
+		}
+		value, err := VariantToOtlpAnyValue(values, i)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %q: %w", key, err)
+		}
+		kvs = append(kvs, &commonpb.KeyValue{Key: key, Value: value})
+	}
+	return kvs, nil
+}
+
+// ResourceFromRecord rebuilds the resource of the row `row` of a record.
+func ResourceFromRecord(record arrow.Record, row int) (*resourcepb.Resource, error) {
+	resourceArr := Column(record, constants.RESOURCE)
+	resource := &resourcepb.Resource{}
+	if resourceArr == nil {
+		return resource, nil
+	}
+
+	attributes, err := KeyValuesFromArray(StructField(resourceArr, constants.ATTRIBUTES), row)
+	if err != nil {
+		return nil, fmt.Errorf("resource attributes: %w", err)
+	}
+	resource.Attributes = attributes
+	resource.DroppedAttributesCount, err = U32FromArray(StructField(resourceArr, constants.DROPPED_ATTRIBUTES_COUNT), row)
+	if err != nil {
+		return nil, fmt.Errorf("resource dropped attributes count: %w", err)
+	}
+	return resource, nil
+}
+
+// ScopeFromRecord rebuilds the instrumentation scope of the row `row` of a record.
+func ScopeFromRecord(record arrow.Record, scopeKey string, row int) (*commonpb.InstrumentationScope, error) {
+	scopeArr := Column(record, scopeKey)
+	scope := &commonpb.InstrumentationScope{}
+	if scopeArr == nil {
+		return scope, nil
+	}
+
+	var err error
+	if scope.Name, err = StringFromArray(StructField(scopeArr, constants.NAME), row); err != nil {
+		return nil, fmt.Errorf("scope name: %w", err)
+	}
+	if scope.Version, err = StringFromArray(StructField(scopeArr, constants.VERSION), row); err != nil {
+		return nil, fmt.Errorf("scope version: %w", err)
+	}
+	if scope.Attributes, err = KeyValuesFromArray(StructField(scopeArr, constants.ATTRIBUTES), row); err != nil {
+		return nil, fmt.Errorf("scope attributes: %w", err)
+	}
+	if scope.DroppedAttributesCount, err = U32FromArray(StructField(scopeArr, constants.DROPPED_ATTRIBUTES_COUNT), row); err != nil {
+		return nil, fmt.Errorf("scope dropped attributes count: %w", err)
+	}
+	return scope, nil
+}
+
+// SchemaUrlFromRecord returns the schema URL stored in the resource or scope column `structKey` at position `row` (an
+// empty string if not defined).
+func SchemaUrlFromRecord(record arrow.Record, structKey string, row int) (string, error) {
+	schemaUrl, err := StringFromArray(StructField(Column(record, structKey), constants.SCHEMA_URL), row)
+	if err != nil {
+		return "", fmt.Errorf("%s schema url: %w", structKey, err)
+	}
+	return schemaUrl, nil
+}
+
+// IsNull returns true if the array doesn't exist or if the value at position `row` is null.
+func IsNull(arr arrow.Array, row int) bool {
+	// The validity bitmap of Null arrays is not allocated.
+	return arr == nil || arr.DataType().ID() == arrow.NULL || arr.IsNull(row)
+}
+
+// ProtoKey returns a deterministic binary representation of a message, used to regroup rows sharing the same resource
+// or scope.
+func ProtoKey(message proto.Message) (string, error) {
+	bytes, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
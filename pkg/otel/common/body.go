@@ -0,0 +1,673 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	"otel-arrow-adapter/pkg/air"
+	"otel-arrow-adapter/pkg/air/rfield"
+)
+
+// Structured bodies
+//
+// A string body containing a JSON object (e.g. `{"level":"info","msg":"started"}`) or, if enabled, a list of logfmt
+// pairs (e.g. `level=info msg="server started"`) can be stored as a struct instead of an opaque string. The format of
+// the parsed body is stored in the `body_format` column, so the string body can be restored by the Arrow to OTLP
+// converter.
+//
+// The restored body is the canonical form of the parsed body: compact JSON (resp. space separated logfmt pairs) with
+// the keys in their original order. The fields of the struct are sorted by name, so the key order is stored in the
+// `body_key_order` column when the keys of an object are not sorted. The key order lists the objects of the body in
+// document order, separated by `;`, each object being the comma separated positions of its keys in the sorted keys
+// (e.g. `1,0` for `{"msg":"started","level":"info"}`). The objects whose keys are sorted are empty and the trailing
+// empty objects are omitted.
+//
+// When enabled (`keep_original`), the original body is stored in the `body_original` column as well and restored
+// instead if it is not byte-identical to its canonical form (e.g. different spacing). The bodies whose values can't be
+// represented by the struct (e.g. JSON bodies with null values, duplicate keys or lists mixing types) are kept as
+// strings.
+
+const (
+	// BodyFormatJson is the `body_format` of the JSON bodies.
+	BodyFormatJson = "json"
+	// BodyFormatLogfmt is the `body_format` of the logfmt bodies.
+	BodyFormatLogfmt = "logfmt"
+)
+
+// BodyParserConfig defines the limits of the structured body parser. A zero limit means no limit.
+type BodyParserConfig struct {
+	// Maximum nesting depth of the JSON objects and arrays (the top-level object has a depth of 1).
+	MaxDepth int `json:"max_depth" yaml:"max_depth"`
+
+	// Maximum number of keys of a body (keys of the nested objects included).
+	MaxKeys int `json:"max_keys" yaml:"max_keys"`
+
+	// Parses the logfmt bodies as well. Disabled by default as any message made of `key=value` tokens would be parsed.
+	Logfmt bool `json:"logfmt" yaml:"logfmt"`
+
+	// Stores the original bodies that differ from their canonical form (e.g. different spacing) so they are restored
+	// byte for byte.
+	KeepOriginal bool `json:"keep_original" yaml:"keep_original"`
+}
+
+// ParsedBody is a string body parsed by `BodyParserConfig.ParseBody`.
+type ParsedBody struct {
+	Value  *rfield.Struct
+	Format string
+	// Original order of the keys (empty if all the keys are sorted).
+	KeyOrder string
+	// Original body if `KeepOriginal` is enabled and the body differs from its canonical form (empty otherwise).
+	Original string
+}
+
+func NewDefaultBodyParserConfig() *BodyParserConfig {
+	return &BodyParserConfig{
+		MaxDepth: 4,
+		MaxKeys:  64,
+	}
+}
+
+// Validate checks the consistency of the configuration.
+func (c *BodyParserConfig) Validate() error {
+	if c.MaxDepth < 0 {
+		return fmt.Errorf("max_depth must be positive (got %d)", c.MaxDepth)
+	}
+	if c.MaxKeys < 0 {
+		return fmt.Errorf("max_keys must be positive (got %d)", c.MaxKeys)
+	}
+	return nil
+}
+
+// ParseBody converts a JSON or logfmt string body into a struct. It returns nil if the body is not structured, exceeds
+// the limits of the configuration, or has values that can't be restored by `FormatBody`.
+func (c *BodyParserConfig) ParseBody(body string) *ParsedBody {
+	var value *rfield.Struct
+	var format, keyOrder, canonical string
+	var ok bool
+
+	trimmed := strings.TrimSpace(body)
+	switch {
+	case strings.HasPrefix(trimmed, "{"):
+		value, keyOrder, canonical, ok = c.parseJsonBody(trimmed)
+		format = BodyFormatJson
+	case c.Logfmt:
+		value, keyOrder, canonical, ok = c.parseLogfmtBody(trimmed)
+		format = BodyFormatLogfmt
+	}
+	if !ok {
+		return nil
+	}
+
+	parsed := &ParsedBody{Value: value, Format: format, KeyOrder: keyOrder}
+	if c.KeepOriginal && canonical != body {
+		parsed.Original = body
+	}
+	return parsed
+}
+
+// parseJsonBody returns the struct, the key order and the canonical form of a JSON body.
+func (c *BodyParserConfig) parseJsonBody(body string) (*rfield.Struct, string, string, bool) {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, "", "", false
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, "", "", false
+	}
+	object, ok := doc.(map[string]interface{})
+	if !ok || len(object) == 0 {
+		return nil, "", "", false
+	}
+	if keys, ok := jsonKeyCount(object); !ok || (c.MaxKeys > 0 && keys > c.MaxKeys) {
+		return nil, "", "", false
+	}
+
+	value, err := air.JsonValueToValue(object, &air.JsonConfig{NumberType: air.JsonNumberAuto, MaxDepth: c.MaxDepth}, 0)
+	if err != nil {
+		return nil, "", "", false
+	}
+	parsed, ok := value.(*rfield.Struct)
+	if !ok {
+		return nil, "", "", false
+	}
+	objectKeys, ok := jsonObjectKeys(body)
+	if !ok {
+		return nil, "", "", false
+	}
+	keyOrder := newKeyOrder(objectKeys)
+
+	// The null values can't be stored in the body and the numbers of some lists are coerced, so the body is only
+	// parsed if its values can be restored.
+	expected, err := marshalJson(doc, keyOrder)
+	if err != nil {
+		return nil, "", "", false
+	}
+	restored, err := rfieldToJson(parsed)
+	if err != nil {
+		return nil, "", "", false
+	}
+	canonical, err := marshalJson(restored, keyOrder)
+	if err != nil || canonical != expected {
+		return nil, "", "", false
+	}
+
+	// The body is stored like a kvlist body so the Arrow to OTLP converter can decode it (e.g. the lists of objects are
+	// lists of variants).
+	parsed, ok = OtlpAnyValueToValue(jsonToOtlpAnyValue(restored)).(*rfield.Struct)
+	if !ok {
+		return nil, "", "", false
+	}
+	return parsed, keyOrder, canonical, true
+}
+
+// parseLogfmtBody returns the struct, the key order and the canonical form of a logfmt body.
+func (c *BodyParserConfig) parseLogfmtBody(body string) (*rfield.Struct, string, string, bool) {
+	pairs, ok := parseLogfmt(body)
+	if !ok || len(pairs) == 0 || (c.MaxKeys > 0 && len(pairs) > c.MaxKeys) {
+		return nil, "", "", false
+	}
+
+	fields := make([]*rfield.Field, 0, len(pairs))
+	keys := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		fields = append(fields, rfield.NewStringField(pair.Key, pair.Value.GetStringValue()))
+		keys = append(keys, pair.Key)
+	}
+	keyOrder := newKeyOrder([][]string{keys})
+	canonical, err := formatLogfmt(&commonpb.KeyValueList{Values: pairs}, keyOrder)
+	if err != nil {
+		return nil, "", "", false
+	}
+	return &rfield.Struct{Fields: fields}, keyOrder, canonical, true
+}
+
+// FormatBody restores the string body of a log record from its parsed body, its `body_format` and its
+// `body_key_order`.
+func FormatBody(format string, body *commonpb.AnyValue, keyOrder string) (string, error) {
+	kvs := body.GetKvlistValue()
+	if kvs == nil {
+		return "", fmt.Errorf("%s body: expected a kvlist value, got %T", format, body.GetValue())
+	}
+
+	switch format {
+	case BodyFormatJson:
+		doc, err := kvlistToJson(kvs)
+		if err != nil {
+			return "", err
+		}
+		return marshalJson(doc, keyOrder)
+	case BodyFormatLogfmt:
+		return formatLogfmt(kvs, keyOrder)
+	default:
+		return "", fmt.Errorf("unknown body format %q", format)
+	}
+}
+
+// jsonObjectKeys returns the keys of the objects of a JSON body in document order (see `newKeyOrder`). It returns
+// false if an object has duplicate keys.
+func jsonObjectKeys(body string) ([][]string, bool) {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+
+	var objects [][]string
+	if err := readJsonObjectKeys(decoder, &objects); err != nil {
+		return nil, false
+	}
+	return objects, true
+}
+
+func readJsonObjectKeys(decoder *json.Decoder, objects *[][]string) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	switch token {
+	case json.Delim('{'):
+		object := len(*objects)
+		*objects = append(*objects, nil)
+		keys := make(map[string]bool)
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			key, ok := token.(string)
+			if !ok || keys[key] {
+				return fmt.Errorf("invalid or duplicate key %v", token)
+			}
+			keys[key] = true
+			(*objects)[object] = append((*objects)[object], key)
+			if err := readJsonObjectKeys(decoder, objects); err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+		return err
+	case json.Delim('['):
+		for decoder.More() {
+			if err := readJsonObjectKeys(decoder, objects); err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+		return err
+	default:
+		return nil
+	}
+}
+
+// newKeyOrder returns the key order of the objects of a body (see the `body_key_order` column), given the keys of each
+// object in document order.
+func newKeyOrder(objects [][]string) string {
+	orders := make([]string, len(objects))
+	count := 0
+	for i, keys := range objects {
+		if sort.StringsAreSorted(keys) {
+			continue
+		}
+		sorted := make([]string, len(keys))
+		copy(sorted, keys)
+		sort.Strings(sorted)
+		positions := make([]string, len(keys))
+		for j, key := range keys {
+			positions[j] = strconv.Itoa(sort.SearchStrings(sorted, key))
+		}
+		orders[i] = strings.Join(positions, ",")
+		count = i + 1
+	}
+	return strings.Join(orders[:count], ";")
+}
+
+// keyOrder restores the original order of the keys of the objects of a body, the objects being visited in document
+// order.
+type keyOrder struct {
+	objects [][]int
+	next    int
+}
+
+func parseKeyOrder(order string) (*keyOrder, error) {
+	ko := &keyOrder{}
+	if order == "" {
+		return ko, nil
+	}
+	for _, object := range strings.Split(order, ";") {
+		var positions []int
+		if object != "" {
+			for _, position := range strings.Split(object, ",") {
+				p, err := strconv.Atoi(position)
+				if err != nil {
+					return nil, fmt.Errorf("invalid key order %q: %w", order, err)
+				}
+				positions = append(positions, p)
+			}
+		}
+		ko.objects = append(ko.objects, positions)
+	}
+	return ko, nil
+}
+
+// keys returns the sorted keys of the next object in their original order.
+func (ko *keyOrder) keys(sorted []string) ([]string, error) {
+	if ko.next >= len(ko.objects) {
+		return sorted, nil
+	}
+	positions := ko.objects[ko.next]
+	ko.next++
+	if positions == nil {
+		return sorted, nil
+	}
+	if len(positions) != len(sorted) {
+		return nil, fmt.Errorf("invalid key order: %d positions for %d keys", len(positions), len(sorted))
+	}
+	keys := make([]string, len(sorted))
+	seen := make([]bool, len(sorted))
+	for i, position := range positions {
+		if position < 0 || position >= len(sorted) || seen[position] {
+			return nil, fmt.Errorf("invalid key order: position %d", position)
+		}
+		seen[position] = true
+		keys[i] = sorted[position]
+	}
+	return keys, nil
+}
+
+// end checks that the key order doesn't refer to more objects than the body contains.
+func (ko *keyOrder) end() error {
+	if ko.next < len(ko.objects) {
+		return fmt.Errorf("invalid key order: %d objects for %d objects in the body", len(ko.objects), ko.next)
+	}
+	return nil
+}
+
+// jsonKeyCount returns the number of keys of a JSON value (false if a key is empty).
+func jsonKeyCount(value interface{}) (int, bool) {
+	count := 0
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if key == "" {
+				return 0, false
+			}
+			keys, ok := jsonKeyCount(item)
+			if !ok {
+				return 0, false
+			}
+			count += 1 + keys
+		}
+	case []interface{}:
+		for _, item := range v {
+			keys, ok := jsonKeyCount(item)
+			if !ok {
+				return 0, false
+			}
+			count += keys
+		}
+	}
+	return count, true
+}
+
+// marshalJson returns the compact JSON representation of a value with the keys in the given order (see
+// `newKeyOrder`).
+func marshalJson(value interface{}, order string) (string, error) {
+	ko, err := parseKeyOrder(order)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := writeJson(&buf, value, ko); err != nil {
+		return "", err
+	}
+	if err := ko.end(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func writeJson(buf *bytes.Buffer, value interface{}, ko *keyOrder) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		sorted := make([]string, 0, len(v))
+		for key := range v {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+		keys, err := ko.keys(sorted)
+		if err != nil {
+			return err
+		}
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJsonScalar(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeJson(buf, v[key], ko); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJson(buf, item, ko); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		return writeJsonScalar(buf, value)
+	}
+	return nil
+}
+
+func writeJsonScalar(buf *bytes.Buffer, value interface{}) error {
+	var scalar bytes.Buffer
+	encoder := json.NewEncoder(&scalar)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimSuffix(scalar.Bytes(), []byte("\n")))
+	return nil
+}
+
+// rfieldToJson converts a parsed JSON body into the values decoded by `FormatBody`.
+func rfieldToJson(value rfield.Value) (interface{}, error) {
+	switch v := value.(type) {
+	case *rfield.Bool:
+		return v.Value, nil
+	case *rfield.String:
+		return v.Value, nil
+	case *rfield.I64:
+		return v.Value, nil
+	case *rfield.F64:
+		return v.Value, nil
+	case *rfield.Struct:
+		object := make(map[string]interface{}, len(v.Fields))
+		for _, field := range v.Fields {
+			item, err := rfieldToJson(field.Value)
+			if err != nil {
+				return nil, err
+			}
+			object[field.Name] = item
+		}
+		return object, nil
+	case *rfield.List:
+		items := make([]interface{}, 0, len(v.Values))
+		for _, value := range v.Values {
+			item, err := rfieldToJson(value)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		// U64 values can't be represented by an OTLP AnyValue.
+		return nil, fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+// jsonToOtlpAnyValue converts the values returned by `rfieldToJson` into an OTLP AnyValue.
+func jsonToOtlpAnyValue(value interface{}) *commonpb.AnyValue {
+	switch v := value.(type) {
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+	case map[string]interface{}:
+		kvs := make([]*commonpb.KeyValue, 0, len(v))
+		for key, item := range v {
+			kvs = append(kvs, &commonpb.KeyValue{Key: key, Value: jsonToOtlpAnyValue(item)})
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: kvs}}}
+	case []interface{}:
+		items := make([]*commonpb.AnyValue, 0, len(v))
+		for _, item := range v {
+			items = append(items, jsonToOtlpAnyValue(item))
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: items}}}
+	default:
+		return nil
+	}
+}
+
+func kvlistToJson(kvs *commonpb.KeyValueList) (map[string]interface{}, error) {
+	object := make(map[string]interface{}, len(kvs.Values))
+	for _, kv := range kvs.Values {
+		item, err := anyValueToJson(kv.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", kv.Key, err)
+		}
+		object[kv.Key] = item
+	}
+	return object, nil
+}
+
+func anyValueToJson(value *commonpb.AnyValue) (interface{}, error) {
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_BoolValue:
+		return v.BoolValue, nil
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue, nil
+	case *commonpb.AnyValue_IntValue:
+		return v.IntValue, nil
+	case *commonpb.AnyValue_DoubleValue:
+		return v.DoubleValue, nil
+	case *commonpb.AnyValue_KvlistValue:
+		return kvlistToJson(v.KvlistValue)
+	case *commonpb.AnyValue_ArrayValue:
+		items := make([]interface{}, 0, len(v.ArrayValue.Values))
+		for _, item := range v.ArrayValue.Values {
+			jsonItem, err := anyValueToJson(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, jsonItem)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unsupported json value type %T", v)
+	}
+}
+
+// parseLogfmt parses a list of space separated `key=value` pairs. The values can be quoted (Go string literal syntax).
+// It returns the pairs in their original order, or false if a token is not a pair, or if a key is empty or duplicated.
+func parseLogfmt(body string) ([]*commonpb.KeyValue, bool) {
+	var pairs []*commonpb.KeyValue
+	keys := make(map[string]bool)
+	for i := 0; i < len(body); {
+		if body[i] == ' ' || body[i] == '\t' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(body) && body[i] != '=' && body[i] != ' ' && body[i] != '\t' && body[i] != '"' {
+			i++
+		}
+		if i == start || i == len(body) || body[i] != '=' {
+			return nil, false
+		}
+		key := body[start:i]
+		i++
+
+		var value string
+		if i < len(body) && body[i] == '"' {
+			end := i + 1
+			for end < len(body) && body[end] != '"' {
+				if body[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(body) {
+				return nil, false
+			}
+			unquoted, err := strconv.Unquote(body[i : end+1])
+			if err != nil {
+				return nil, false
+			}
+			value = unquoted
+			i = end + 1
+			if i < len(body) && body[i] != ' ' && body[i] != '\t' {
+				return nil, false
+			}
+		} else {
+			start = i
+			for i < len(body) && body[i] != ' ' && body[i] != '\t' {
+				if body[i] == '"' || body[i] == '=' {
+					return nil, false
+				}
+				i++
+			}
+			value = body[start:i]
+		}
+
+		if keys[key] {
+			return nil, false
+		}
+		keys[key] = true
+		pairs = append(pairs, &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}})
+	}
+	return pairs, true
+}
+
+// formatLogfmt returns the logfmt representation of a list of string pairs (in the given key order, see `newKeyOrder`,
+// values quoted if needed).
+func formatLogfmt(kvs *commonpb.KeyValueList, order string) (string, error) {
+	ko, err := parseKeyOrder(order)
+	if err != nil {
+		return "", err
+	}
+	pairs := make(map[string]*commonpb.KeyValue, len(kvs.Values))
+	sorted := make([]string, 0, len(kvs.Values))
+	for _, kv := range kvs.Values {
+		if _, ok := pairs[kv.Key]; ok {
+			return "", fmt.Errorf("%s: duplicate key", kv.Key)
+		}
+		pairs[kv.Key] = kv
+		sorted = append(sorted, kv.Key)
+	}
+	sort.Strings(sorted)
+	keys, err := ko.keys(sorted)
+	if err != nil {
+		return "", err
+	}
+	if err := ko.end(); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for i, key := range keys {
+		kv := pairs[key]
+		value, ok := kv.Value.GetValue().(*commonpb.AnyValue_StringValue)
+		if !ok {
+			return "", fmt.Errorf("%s: expected a string value, got %T", kv.Key, kv.Value.GetValue())
+		}
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(kv.Key)
+		sb.WriteByte('=')
+		if value.StringValue == "" || strings.ContainsAny(value.StringValue, " \t\"=\\") || strconv.Quote(value.StringValue) != `"`+value.StringValue+`"` {
+			sb.WriteString(strconv.Quote(value.StringValue))
+		} else {
+			sb.WriteString(value.StringValue)
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,72 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"github.com/apache/arrow/go/v9/arrow"
+	"github.com/apache/arrow/go/v9/arrow/array"
+)
+
+// DerivedMetadataKey is the key of the field metadata marking a derived column, i.e. a column computed from the other
+// columns (e.g. a span duration) that has no OTLP counterpart.
+const DerivedMetadataKey = "derived"
+
+// MarkDerivedColumns returns the record with the top-level columns `names` marked as derived. The columns that are
+// not part of the record are ignored.
+func MarkDerivedColumns(record arrow.Record, names ...string) arrow.Record {
+	derived := make(map[string]bool, len(names))
+	for _, name := range names {
+		derived[name] = true
+	}
+
+	// The fields are copied as the schema shares its slice.
+	fields := append([]arrow.Field(nil), record.Schema().Fields()...)
+	changed := false
+	for i, field := range fields {
+		if derived[field.Name] && !IsDerivedField(field) {
+			fields[i].Metadata = arrow.NewMetadata([]string{DerivedMetadataKey}, []string{"true"})
+			changed = true
+		}
+	}
+	if !changed {
+		return record
+	}
+	metadata := record.Schema().Metadata()
+	return array.NewRecord(arrow.NewSchema(fields, &metadata), record.Columns(), record.NumRows())
+}
+
+// DropDerivedColumns returns the record without its derived columns (see `MarkDerivedColumns`).
+func DropDerivedColumns(record arrow.Record) arrow.Record {
+	var fields []arrow.Field
+	var columns []arrow.Array
+	for i, field := range record.Schema().Fields() {
+		if IsDerivedField(field) {
+			continue
+		}
+		fields = append(fields, field)
+		columns = append(columns, record.Column(i))
+	}
+	if len(fields) == int(record.NumCols()) {
+		return record
+	}
+	metadata := record.Schema().Metadata()
+	return array.NewRecord(arrow.NewSchema(fields, &metadata), columns, record.NumRows())
+}
+
+// IsDerivedField returns true if a field is marked as derived.
+func IsDerivedField(field arrow.Field) bool {
+	i := field.Metadata.FindKey(DerivedMetadataKey)
+	return i >= 0 && field.Metadata.Values()[i] == "true"
+}
@@ -0,0 +1,195 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+var (
+	// ErrUnsupportedValue is returned for an AnyValue (or a data point value) of an unknown type or with a nil wrapper.
+	ErrUnsupportedValue = errors.New("unsupported value type")
+	// ErrUnsupportedMetricType is returned for a metric of an unknown type.
+	ErrUnsupportedMetricType = errors.New("unsupported metric type")
+	// ErrNilItem is returned for a nil item (e.g. a nil log record or a nil attribute) in a request.
+	ErrNilItem = errors.New("nil item")
+	// ErrDuplicateKey is returned for a list of KeyValues (e.g. attributes) containing the same key several times.
+	ErrDuplicateKey = errors.New("duplicate key")
+)
+
+// ItemError is the error of an item (log record, span or metric) rejected by an OTLP to Arrow converter. The indexes
+// locate the item in its request, an index being -1 when the error concerns a whole resource or scope (resp. a whole
+// item).
+type ItemError struct {
+	// Kind of the item, e.g. "log record", "span" or "metric".
+	Kind      string
+	Resource  int
+	Scope     int
+	Item      int
+	DataPoint int
+	Err       error
+}
+
+// PartialSuccess is returned by the OTLP to Arrow converters, along with the records built from the valid items, when
+// some items of a request have been rejected (it is nil otherwise). It is not an error: the error returned by the
+// converters means that no record has been built.
+//
+// `RejectedCount` maps onto the `rejected_log_records` (resp. `rejected_spans`, `rejected_data_points`) field of the
+// OTLP partial success response, and `ErrorMessage()` onto its `error_message` field.
+type PartialSuccess struct {
+	RejectedCount int64
+	Errors        []*ItemError
+}
+
+func (e *ItemError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "resource #%d", e.Resource)
+	if e.Scope >= 0 {
+		fmt.Fprintf(&sb, ", scope #%d", e.Scope)
+	}
+	if e.Item >= 0 {
+		fmt.Fprintf(&sb, ", %s #%d", e.Kind, e.Item)
+	}
+	if e.DataPoint >= 0 {
+		fmt.Fprintf(&sb, ", data point #%d", e.DataPoint)
+	}
+	fmt.Fprintf(&sb, ": %v", e.Err)
+	return sb.String()
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// Reject records the error of a rejected item (or group of items if `count` > 1).
+func (p *PartialSuccess) Reject(err *ItemError, count int) {
+	p.RejectedCount += int64(count)
+	p.Errors = append(p.Errors, err)
+}
+
+// OrNil returns p if at least one item has been rejected, nil otherwise.
+func (p *PartialSuccess) OrNil() *PartialSuccess {
+	if len(p.Errors) == 0 {
+		return nil
+	}
+	return p
+}
+
+// ErrorMessage summarizes the errors of the rejected items (empty if p is nil).
+func (p *PartialSuccess) ErrorMessage() string {
+	switch {
+	case p == nil || len(p.Errors) == 0:
+		return ""
+	case len(p.Errors) == 1:
+		return fmt.Sprintf("%d rejected item(s): %v", p.RejectedCount, p.Errors[0])
+	default:
+		return fmt.Sprintf("%d rejected item(s): %v (and %d more errors)", p.RejectedCount, p.Errors[0], len(p.Errors)-1)
+	}
+}
+
+// CheckAnyValueType returns an error if the value of an AnyValue is of an unknown type or is a nil wrapper (the nested
+// values are not checked). An unset value is valid.
+func CheckAnyValueType(value *commonpb.AnyValue) error {
+	isNil := false
+	switch v := value.GetValue().(type) {
+	case nil:
+		return nil
+	case *commonpb.AnyValue_StringValue:
+		isNil = v == nil
+	case *commonpb.AnyValue_BoolValue:
+		isNil = v == nil
+	case *commonpb.AnyValue_IntValue:
+		isNil = v == nil
+	case *commonpb.AnyValue_DoubleValue:
+		isNil = v == nil
+	case *commonpb.AnyValue_BytesValue:
+		isNil = v == nil
+	case *commonpb.AnyValue_ArrayValue:
+		isNil = v == nil
+	case *commonpb.AnyValue_KvlistValue:
+		isNil = v == nil
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupportedValue, v)
+	}
+	if isNil {
+		return fmt.Errorf("%w: nil %T", ErrUnsupportedValue, value.GetValue())
+	}
+	return nil
+}
+
+// ValidateAnyValue checks the type of an AnyValue and of its nested values (see `CheckAnyValueType`).
+func ValidateAnyValue(value *commonpb.AnyValue) error {
+	if err := CheckAnyValueType(value); err != nil {
+		return err
+	}
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_ArrayValue:
+		for i, item := range v.ArrayValue.GetValues() {
+			if err := ValidateAnyValue(item); err != nil {
+				return fmt.Errorf("item #%d: %w", i, err)
+			}
+		}
+	case *commonpb.AnyValue_KvlistValue:
+		return ValidateKeyValues(v.KvlistValue.GetValues())
+	}
+	return nil
+}
+
+// ValidateKeyValues checks a list of KeyValues (e.g. attributes), see `ValidateAnyValue`. The keys must be unique.
+func ValidateKeyValues(kvs []*commonpb.KeyValue) error {
+	keys := make(map[string]bool, len(kvs))
+	for i, kv := range kvs {
+		if kv == nil {
+			return fmt.Errorf("attribute #%d: %w", i, ErrNilItem)
+		}
+		if keys[kv.Key] {
+			return fmt.Errorf("attribute %q: %w", kv.Key, ErrDuplicateKey)
+		}
+		keys[kv.Key] = true
+		if err := ValidateAnyValue(kv.Value); err != nil {
+			return fmt.Errorf("attribute %q: %w", kv.Key, err)
+		}
+	}
+	return nil
+}
+
+// ValidateResource checks the attributes of a resource and that it can be serialized to identify it (a nil resource is
+// valid).
+func ValidateResource(resource *resourcepb.Resource) error {
+	if err := ValidateKeyValues(resource.GetAttributes()); err != nil {
+		return fmt.Errorf("resource: %w", err)
+	}
+	if _, err := ProtoKey(resource); err != nil {
+		return fmt.Errorf("resource: %w", err)
+	}
+	return nil
+}
+
+// ValidateScope checks the attributes of an instrumentation scope and that it can be serialized to identify it (a nil
+// scope is valid).
+func ValidateScope(scope *commonpb.InstrumentationScope) error {
+	if err := ValidateKeyValues(scope.GetAttributes()); err != nil {
+		return fmt.Errorf("scope: %w", err)
+	}
+	if _, err := ProtoKey(scope); err != nil {
+		return fmt.Errorf("scope: %w", err)
+	}
+	return nil
+}
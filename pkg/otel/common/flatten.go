@@ -0,0 +1,226 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/apache/arrow/go/v9/arrow"
+	"github.com/apache/arrow/go/v9/arrow/array"
+	"github.com/apache/arrow/go/v9/arrow/bitutil"
+	"github.com/apache/arrow/go/v9/arrow/memory"
+
+	"otel-arrow-adapter/pkg/air/rfield"
+	"otel-arrow-adapter/pkg/otel/constants"
+)
+
+// Flattened attributes
+//
+// A flattened `attributes` struct is replaced by one field per attribute named `attributes.<key>` (e.g. the attribute
+// `http.method` is stored in the column `attributes.http.method`). The attributes are flattened at the level of their
+// entity: top-level columns for the logs, spans and data points, fields of the resource and scope structs, and fields
+// of the span events and links. The attribute values themselves (e.g. kvlists) are not flattened.
+//
+// The flattening is selected with `EncodingOptions.FlattenAttributes`, so it only applies to the layouts built with
+// `EncodingOptions` (the `OtlpXXXToArrowRecordsWithOptions` functions). The wide metrics layout always keeps the
+// attributes nested, and the events and links of the normalized trace layout keep their key/value lists.
+//
+// The naming scheme is reversible: the key of an attribute is the name of its field without the `attributes.` prefix
+// (dots included). This prefix is reserved, so a flattened attribute can't collide with the other fields of its entity
+// whatever its key.
+
+// FlattenedAttributesPrefix is the prefix of the names of the flattened attribute fields.
+const FlattenedAttributesPrefix = constants.ATTRIBUTES + "."
+
+// nestedEntities contains the names of the structs (resp. lists of structs) containing nested entities with their own
+// attributes.
+var nestedEntities = map[string]bool{
+	constants.RESOURCE:      true,
+	constants.SCOPE:         true,
+	constants.SCOPE_LOGS:    true,
+	constants.SCOPE_SPANS:   true,
+	constants.SCOPE_METRICS: true,
+	constants.SPAN_EVENTS:   true,
+	constants.SPAN_LINKS:    true,
+}
+
+// FlattenAttributes replaces the `attributes` structs of the fields of an entity record (and of its nested entities)
+// by flattened attribute fields. The nested structs and lists are updated in place.
+func FlattenAttributes(fields []*rfield.Field) []*rfield.Field {
+	return flattenAttributes(fields, true)
+}
+
+func flattenAttributes(fields []*rfield.Field, withNestedEntities bool) []*rfield.Field {
+	result := make([]*rfield.Field, 0, len(fields))
+	for _, field := range fields {
+		switch v := field.Value.(type) {
+		case *rfield.Struct:
+			if field.Name == constants.ATTRIBUTES {
+				for _, attribute := range v.Fields {
+					result = append(result, rfield.NewField(FlattenedAttributesPrefix+attribute.Name, attribute.Value))
+				}
+				continue
+			}
+			if withNestedEntities && nestedEntities[field.Name] {
+				v.Fields = flattenAttributes(v.Fields, false)
+			}
+		case *rfield.List:
+			if withNestedEntities && nestedEntities[field.Name] {
+				for _, value := range v.Values {
+					if s, ok := value.(*rfield.Struct); ok {
+						s.Fields = flattenAttributes(s.Fields, false)
+					}
+				}
+			}
+		}
+		result = append(result, field)
+	}
+	return result
+}
+
+// UnflattenAttributes regroups the flattened attribute fields of a record (and of its nested entities) into
+// `attributes` structs, so the record can be decoded like a record with nested attributes. The record is returned
+// as is if it doesn't contain flattened attributes.
+//
+// In the lists of nested entities, a regrouped `attributes` struct is null when all its fields are null (e.g. for a
+// span event without attributes in a list of events with attributes).
+func UnflattenAttributes(record arrow.Record) arrow.Record {
+	fields := record.Schema().Fields()
+	children := make([]arrow.ArrayData, 0, len(fields))
+	for _, column := range record.Columns() {
+		children = append(children, column.Data())
+	}
+
+	newFields, newChildren, changed := unflattenFields(fields, children, int(record.NumRows()), true, false)
+	if !changed {
+		return record
+	}
+
+	columns := make([]arrow.Array, 0, len(newChildren))
+	for _, data := range newChildren {
+		columns = append(columns, array.MakeFromData(data))
+	}
+	metadata := record.Schema().Metadata()
+	return array.NewRecord(arrow.NewSchema(newFields, &metadata), columns, record.NumRows())
+}
+
+// unflattenFields regroups the flattened attribute fields of a struct (or of a record) of `length` rows. The fields of
+// the nested entities are processed as well if `withNestedEntities` is true. The `attributes` struct is nullable for
+// the items of a list, the other rows sharing the same attributes.
+func unflattenFields(fields []arrow.Field, children []arrow.ArrayData, length int, withNestedEntities bool, nullableAttributes bool) ([]arrow.Field, []arrow.ArrayData, bool) {
+	var newFields, attributeFields []arrow.Field
+	var newChildren, attributeChildren []arrow.ArrayData
+	changed := false
+
+	for i, field := range fields {
+		child := children[i]
+		if strings.HasPrefix(field.Name, FlattenedAttributesPrefix) {
+			attribute := field
+			attribute.Name = strings.TrimPrefix(field.Name, FlattenedAttributesPrefix)
+			attributeFields = append(attributeFields, attribute)
+			attributeChildren = append(attributeChildren, child)
+			continue
+		}
+		if withNestedEntities && nestedEntities[field.Name] {
+			if newChild, ok := unflattenNestedEntity(child, false); ok {
+				field.Type = newChild.DataType()
+				child = newChild
+				changed = true
+			}
+		}
+		newFields = append(newFields, field)
+		newChildren = append(newChildren, child)
+	}
+	if len(attributeFields) == 0 {
+		return newFields, newChildren, changed
+	}
+
+	attributes := attributesData(attributeFields, attributeChildren, length, nullableAttributes)
+	newFields = append(newFields, arrow.Field{Name: constants.ATTRIBUTES, Type: attributes.DataType(), Nullable: true})
+	newChildren = append(newChildren, attributes)
+
+	// The fields of a struct are sorted by name like the fields built from a normalized AIR record.
+	indices := make([]int, len(newFields))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool { return newFields[indices[i]].Name < newFields[indices[j]].Name })
+	sortedFields := make([]arrow.Field, 0, len(newFields))
+	sortedChildren := make([]arrow.ArrayData, 0, len(newChildren))
+	for _, i := range indices {
+		sortedFields = append(sortedFields, newFields[i])
+		sortedChildren = append(sortedChildren, newChildren[i])
+	}
+	return sortedFields, sortedChildren, true
+}
+
+// unflattenNestedEntity regroups the flattened attributes of a struct or of a list of structs (returns false if there
+// is nothing to regroup).
+func unflattenNestedEntity(data arrow.ArrayData, listItems bool) (arrow.ArrayData, bool) {
+	switch t := data.DataType().(type) {
+	case *arrow.StructType:
+		// The children of a struct share the index space of the struct, offset included.
+		fields, children, changed := unflattenFields(t.Fields(), data.Children(), data.Offset()+data.Len(), false, listItems)
+		if !changed {
+			return nil, false
+		}
+		return array.NewData(arrow.StructOf(fields...), data.Len(), data.Buffers(), children, data.NullN(), data.Offset()), true
+	case *arrow.ListType:
+		items, ok := unflattenNestedEntity(data.Children()[0], true)
+		if !ok {
+			return nil, false
+		}
+		return array.NewData(arrow.ListOf(items.DataType()), data.Len(), data.Buffers(), []arrow.ArrayData{items}, data.NullN(), data.Offset()), true
+	default:
+		return nil, false
+	}
+}
+
+// attributesData returns the data of an `attributes` struct of `length` rows containing the given attribute fields. If
+// `nullable` is true, a row is null if all its attributes are null.
+func attributesData(fields []arrow.Field, children []arrow.ArrayData, length int, nullable bool) arrow.ArrayData {
+	if !nullable {
+		return array.NewData(arrow.StructOf(fields...), length, []*memory.Buffer{nil}, children, 0, 0)
+	}
+
+	arrays := make([]arrow.Array, 0, len(children))
+	for _, child := range children {
+		arrays = append(arrays, array.MakeFromData(child))
+	}
+
+	validity := make([]byte, bitutil.CeilByte(length)/8)
+	nulls := 0
+	for row := 0; row < length; row++ {
+		valid := false
+		for _, arr := range arrays {
+			if !IsNull(arr, row) {
+				valid = true
+				break
+			}
+		}
+		if valid {
+			bitutil.SetBit(validity, row)
+		} else {
+			nulls++
+		}
+	}
+
+	buffers := []*memory.Buffer{nil}
+	if nulls > 0 {
+		buffers[0] = memory.NewBufferBytes(validity)
+	}
+	return array.NewData(arrow.StructOf(fields...), length, buffers, children, nulls, 0)
+}
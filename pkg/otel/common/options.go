@@ -0,0 +1,87 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"otel-arrow-adapter/pkg/air"
+)
+
+// EncodingOptions defines the optional encodings of the OTLP to Arrow converters. A nil EncodingOptions selects the
+// default encoding (inline resources and scopes, nested attributes).
+type EncodingOptions struct {
+	// Reference tables deduplicating the resources and scopes of the batch (inline resources and scopes if nil). The
+	// records then only carry a `resource_id` and a `scope_id` column, and the reference tables must be built with
+	// `References.Build()` once all the requests of the batch have been converted.
+	References *ReferenceTables
+
+	// Flattens the attributes into one column per attribute (see `FlattenAttributes`).
+	FlattenAttributes bool
+
+	// Parses the JSON and logfmt string bodies of the log records into structs (see `BodyParserConfig`, disabled if nil).
+	BodyParser *BodyParserConfig
+
+	// Adds the derived span columns (duration, root span flag and depth in the batch), dropped by the decoders.
+	DerivedSpanColumns bool
+}
+
+// Refs returns the reference tables of the options (nil if o is nil).
+func (o *EncodingOptions) Refs() *ReferenceTables {
+	if o == nil {
+		return nil
+	}
+	return o.References
+}
+
+// BodyParserConfig returns the configuration of the log body parser (nil if o is nil or if the parser is disabled).
+func (o *EncodingOptions) BodyParserConfig() *BodyParserConfig {
+	if o == nil {
+		return nil
+	}
+	return o.BodyParser
+}
+
+// DerivedColumns returns true if the derived span columns are requested.
+func (o *EncodingOptions) DerivedColumns() bool {
+	return o != nil && o.DerivedSpanColumns
+}
+
+// AddRecord adds an entity record to a record repository, flattening its attributes if requested. The error of the
+// record repository is returned (see `air.RecordRepository.AddRecord`).
+func (o *EncodingOptions) AddRecord(rr *air.RecordRepository, record *air.Record) error {
+	return o.AddRecords(rr, []*air.Record{record})
+}
+
+// AddRecords is similar to `AddRecord` for the records of a single item (e.g. the data points of a metric). All the
+// records are checked before being added, so either all of them or none of them are added.
+func (o *EncodingOptions) AddRecords(rr *air.RecordRepository, records []*air.Record) error {
+	if o != nil && o.FlattenAttributes {
+		flattened := make([]*air.Record, 0, len(records))
+		for _, record := range records {
+			flattened = append(flattened, air.NewRecordFromFields(FlattenAttributes(record.Fields())))
+		}
+		records = flattened
+	}
+	for _, record := range records {
+		if err := record.Check(); err != nil {
+			return err
+		}
+	}
+	for _, record := range records {
+		if err := rr.AddRecord(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLP/JSON
+//
+// The OTLP/JSON encoding is the `protojson` mapping of the OTLP messages with two exceptions required by the OTLP
+// specification: the trace and span ids are hex-encoded strings (instead of base64), and the enums are encoded as
+// integers. Both the lowerCamelCase and the original field names are accepted on input.
+
+// otlpJsonIdFields contains the names of the trace and span id fields (all the messages, both naming styles).
+var otlpJsonIdFields = map[string]bool{
+	"traceId":        true,
+	"trace_id":       true,
+	"spanId":         true,
+	"span_id":        true,
+	"parentSpanId":   true,
+	"parent_span_id": true,
+}
+
+// UnmarshalOtlpJson decodes an OTLP/JSON document into an OTLP message (e.g. an ExportTraceServiceRequest). The
+// unknown fields are rejected.
+func UnmarshalOtlpJson(data []byte, message proto.Message) error {
+	doc, err := decodeJson(data)
+	if err != nil {
+		return err
+	}
+	if err := convertOtlpJsonIds(doc, hexToBase64); err != nil {
+		return err
+	}
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return protojson.Unmarshal(data, message)
+}
+
+// MarshalOtlpJson encodes an OTLP message into an OTLP/JSON document.
+func MarshalOtlpJson(message proto.Message) ([]byte, error) {
+	data, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeJson(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := convertOtlpJsonIds(doc, base64ToHex); err != nil {
+		return nil, err
+	}
+	result, err := marshalJson(doc, "")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(result), nil
+}
+
+// decodeJson decodes a JSON document, keeping the literal representation of the numbers.
+func decodeJson(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	// `More` doesn't report a trailing closing delimiter, only the end of the input is accepted.
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("json: unexpected data after the top-level value")
+	}
+	return doc, nil
+}
+
+// convertOtlpJsonIds converts in place the string values of the trace and span id fields of a decoded OTLP/JSON
+// document.
+func convertOtlpJsonIds(doc interface{}, convert func(string) (string, error)) error {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for name, value := range v {
+			if id, ok := value.(string); ok && otlpJsonIdFields[name] {
+				converted, err := convert(id)
+				if err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				v[name] = converted
+				continue
+			}
+			if err := convertOtlpJsonIds(value, convert); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := convertOtlpJsonIds(item, convert); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hexToBase64(id string) (string, error) {
+	bytes, err := hex.DecodeString(id)
+	if err != nil {
+		return "", fmt.Errorf("invalid hex id %q", id)
+	}
+	return base64.StdEncoding.EncodeToString(bytes), nil
+}
+
+func base64ToHex(id string) (string, error) {
+	bytes, err := base64.StdEncoding.DecodeString(id)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
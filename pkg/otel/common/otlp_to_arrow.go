@@ -15,6 +15,8 @@
 package common
 
 import (
+	"reflect"
+
 	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
 	resourcepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/resource/v1"
 	"otel-arrow-adapter/pkg/air"
@@ -22,6 +24,25 @@ import (
 	"otel-arrow-adapter/pkg/otel/constants"
 )
 
+// Variants
+//
+// The attributes of the items of a list (span events, span links and exemplars) and the arrays that are not made of
+// scalars of a single type are stored as variants instead of plain values:
+//
+//   - a variant is a struct with a single field named after the type of the value (`bool`, `int`, `double`, `string`,
+//     `bytes`, `kvlist` or `array`), e.g. `{"int": 1}`; an unset value is an empty struct;
+//   - the values of a `kvlist` variant and the items of an `array` variant are variants as well;
+//   - the `attributes` column of a list item is a struct with one variant per attribute key, e.g.
+//     `attributes: {"http.status": {"int": 200}}`. The items of a list are stored with the union of their fields, so
+//     the keys missing from an item are null;
+//   - a list of structs in an attribute or body value is always a list of variants, e.g.
+//     `[{"string": "a"}, {"int": 1}]`;
+//   - the attributes of the events and links of the normalized trace layout are a list of key/value structs, the value
+//     being a variant, e.g. `attributes: [{"key": "http.status", "value": {"int": 200}}]` (see `NewKeyValueList`).
+//
+// The attributes of the resources, scopes, log records, spans and data points are not affected: each row has its own
+// schema, so they are stored as plain values, the arrays of scalars of a single type as plain lists.
+
 func NewAttributes(attributes []*commonpb.KeyValue) *rfield.Field {
 	if attributes == nil || len(attributes) == 0 {
 		return nil
@@ -47,23 +68,29 @@ func NewAttributes(attributes []*commonpb.KeyValue) *rfield.Field {
 	return nil
 }
 
-func AddResource(record *air.Record, resource *resourcepb.Resource) {
-	resourceField := ResourceField(resource)
+// AddResource adds the resource and the schema URL of a ResourceLogs/ResourceMetrics/ResourceSpans to a record.
+func AddResource(record *air.Record, resource *resourcepb.Resource, schemaUrl string) {
+	resourceField := ResourceField(resource, schemaUrl)
 	if resourceField != nil {
 		record.AddField(resourceField)
 	}
 }
 
-func ResourceField(resource *resourcepb.Resource) *rfield.Field {
+// ResourceField returns the struct field representing a resource and its schema URL (nil if both are empty). The
+// schema URL is stored in the `schema_url` field of the struct.
+func ResourceField(resource *resourcepb.Resource, schemaUrl string) *rfield.Field {
 	var resourceFields []*rfield.Field
 
-	attributes := NewAttributes(resource.Attributes)
+	attributes := NewAttributes(resource.GetAttributes())
 	if attributes != nil {
 		resourceFields = append(resourceFields, attributes)
 	}
 
-	if resource.DroppedAttributesCount > 0 {
-		resourceFields = append(resourceFields, rfield.NewU32Field(constants.DROPPED_ATTRIBUTES_COUNT, resource.DroppedAttributesCount))
+	if resource.GetDroppedAttributesCount() > 0 {
+		resourceFields = append(resourceFields, rfield.NewU32Field(constants.DROPPED_ATTRIBUTES_COUNT, resource.GetDroppedAttributesCount()))
+	}
+	if len(schemaUrl) > 0 {
+		resourceFields = append(resourceFields, rfield.NewStringField(constants.SCHEMA_URL, schemaUrl))
 	}
 	if len(resourceFields) > 0 {
 		field := rfield.NewStructField(constants.RESOURCE, rfield.Struct{
@@ -75,25 +102,31 @@ func ResourceField(resource *resourcepb.Resource) *rfield.Field {
 	}
 }
 
-func AddScope(record *air.Record, scopeKey string, scope *commonpb.InstrumentationScope) {
-	scopeField := ScopeField(scopeKey, scope)
+// AddScope adds the instrumentation scope and the schema URL of a ScopeLogs/ScopeMetrics/ScopeSpans to a record.
+func AddScope(record *air.Record, scopeKey string, scope *commonpb.InstrumentationScope, schemaUrl string) {
+	scopeField := ScopeField(scopeKey, scope, schemaUrl)
 	if scopeField != nil {
 		// ToDo check optimization for when fields are always pointers or interfaces instead of structs as today.
 		record.AddField(scopeField)
 	}
 }
 
-func ScopeField(scopeKey string, scope *commonpb.InstrumentationScope) *rfield.Field {
+// ScopeField returns the struct field representing an instrumentation scope and its schema URL. The schema URL is
+// stored in the `schema_url` field of the struct.
+func ScopeField(scopeKey string, scope *commonpb.InstrumentationScope, schemaUrl string) *rfield.Field {
 	var fields []*rfield.Field
 
-	fields = append(fields, rfield.NewStringField(constants.NAME, scope.Name))
-	fields = append(fields, rfield.NewStringField(constants.VERSION, scope.Version))
-	attributes := NewAttributes(scope.Attributes)
+	fields = append(fields, rfield.NewStringField(constants.NAME, scope.GetName()))
+	fields = append(fields, rfield.NewStringField(constants.VERSION, scope.GetVersion()))
+	attributes := NewAttributes(scope.GetAttributes())
 	if attributes != nil {
 		fields = append(fields, attributes)
 	}
-	if scope.DroppedAttributesCount > 0 {
-		fields = append(fields, rfield.NewU32Field(constants.DROPPED_ATTRIBUTES_COUNT, scope.DroppedAttributesCount))
+	if scope.GetDroppedAttributesCount() > 0 {
+		fields = append(fields, rfield.NewU32Field(constants.DROPPED_ATTRIBUTES_COUNT, scope.GetDroppedAttributesCount()))
+	}
+	if len(schemaUrl) > 0 {
+		fields = append(fields, rfield.NewStringField(constants.SCHEMA_URL, schemaUrl))
 	}
 
 	field := rfield.NewStructField(scopeKey, rfield.Struct{
@@ -102,50 +135,142 @@ func ScopeField(scopeKey string, scope *commonpb.InstrumentationScope) *rfield.F
 	return field
 }
 
+// NewItemAttributes converts the attributes of a list item (span event, span link or exemplar) into a struct of
+// variants (see `OtlpAnyValueToVariant`). The items of a list are stored with the union of their fields, the variants
+// keep the type of the values of an attribute key having values of different types from one item to another.
+func NewItemAttributes(attributes []*commonpb.KeyValue) *rfield.Field {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	attributeFields := make([]*rfield.Field, 0, len(attributes))
+	for _, attribute := range attributes {
+		attributeFields = append(attributeFields, rfield.NewStructField(attribute.Key, *OtlpAnyValueToVariant(attribute.Value)))
+	}
+	return rfield.NewStructField(constants.ATTRIBUTES, rfield.Struct{Fields: attributeFields})
+}
+
+// NewKeyValueList converts attributes into a list of key/value structs, the values being variants (see
+// `OtlpAnyValueToVariant`). Unlike `NewItemAttributes`, the data type of the list doesn't depend on the attribute keys,
+// only on the types of the values.
+func NewKeyValueList(attributes []*commonpb.KeyValue) *rfield.Field {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	items := make([]rfield.Value, 0, len(attributes))
+	for _, attribute := range attributes {
+		items = append(items, &rfield.Struct{Fields: []*rfield.Field{
+			rfield.NewStringField(constants.KEY, attribute.Key),
+			rfield.NewStructField(constants.VALUE, *OtlpAnyValueToVariant(attribute.Value)),
+		}})
+	}
+	return rfield.NewListField(constants.ATTRIBUTES, rfield.List{Values: items})
+}
+
+// OtlpAnyValueToValue converts an OTLP AnyValue into an AIR value. Unset values are converted into Null values, empty
+// kvlists into empty structs and empty arrays into empty lists (typed by the RecordRepository with the element type
+// previously seen at the same path).
+//
+// The arrays of scalars of a single type are converted into lists of this type (their unset items are ignored), the
+// other arrays (scalars of different types, kvlists or nested arrays) into lists of variants (see
+// `OtlpAnyValueToVariant`).
 func OtlpAnyValueToValue(value *commonpb.AnyValue) rfield.Value {
-	if value != nil {
-		switch value.Value.(type) {
-		case *commonpb.AnyValue_BoolValue:
-			return &rfield.Bool{Value: value.GetBoolValue()}
-		case *commonpb.AnyValue_IntValue:
-			return &rfield.I64{Value: value.GetIntValue()}
-		case *commonpb.AnyValue_DoubleValue:
-			return &rfield.F64{Value: value.GetDoubleValue()}
-		case *commonpb.AnyValue_StringValue:
-			return &rfield.String{Value: value.GetStringValue()}
-		case *commonpb.AnyValue_BytesValue:
-			return &rfield.Binary{Value: value.GetBytesValue()}
-		case *commonpb.AnyValue_ArrayValue:
-			values := value.GetArrayValue()
-			fieldValues := make([]rfield.Value, 0, len(values.Values))
-			for _, value := range values.Values {
-				v := OtlpAnyValueToValue(value)
-				if v != nil {
-					fieldValues = append(fieldValues, v)
-				}
+	if value == nil {
+		return &rfield.Null{}
+	}
+	switch value.Value.(type) {
+	case *commonpb.AnyValue_BoolValue:
+		return &rfield.Bool{Value: value.GetBoolValue()}
+	case *commonpb.AnyValue_IntValue:
+		return &rfield.I64{Value: value.GetIntValue()}
+	case *commonpb.AnyValue_DoubleValue:
+		return &rfield.F64{Value: value.GetDoubleValue()}
+	case *commonpb.AnyValue_StringValue:
+		return &rfield.String{Value: value.GetStringValue()}
+	case *commonpb.AnyValue_BytesValue:
+		return &rfield.Binary{Value: value.GetBytesValue()}
+	case *commonpb.AnyValue_ArrayValue:
+		values := value.GetArrayValue()
+		if !isScalarArray(values.GetValues()) {
+			variants := make([]rfield.Value, 0, len(values.GetValues()))
+			for _, value := range values.GetValues() {
+				variants = append(variants, OtlpAnyValueToVariant(value))
 			}
-			return &rfield.List{Values: fieldValues}
-		case *commonpb.AnyValue_KvlistValue:
-			values := value.GetKvlistValue()
-			if values == nil || len(values.Values) == 0 {
-				return nil
-			} else {
-				fields := make([]*rfield.Field, 0, len(values.Values))
-				for _, kv := range values.Values {
-					v := OtlpAnyValueToValue(kv.Value)
-					if v != nil {
-						fields = append(fields, &rfield.Field{
-							Name:  kv.Key,
-							Value: v,
-						})
-					}
-				}
-				return &rfield.Struct{Fields: fields}
+			return &rfield.List{Values: variants}
+		}
+		fieldValues := make([]rfield.Value, 0, len(values.GetValues()))
+		for _, value := range values.GetValues() {
+			v := OtlpAnyValueToValue(value)
+			if _, ok := v.(*rfield.Null); !ok {
+				fieldValues = append(fieldValues, v)
 			}
-		default:
-			return nil
 		}
-	} else {
-		return nil
+		return &rfield.List{Values: fieldValues}
+	case *commonpb.AnyValue_KvlistValue:
+		values := value.GetKvlistValue()
+		fields := make([]*rfield.Field, 0, len(values.GetValues()))
+		for _, kv := range values.GetValues() {
+			fields = append(fields, &rfield.Field{
+				Name:  kv.Key,
+				Value: OtlpAnyValueToValue(kv.Value),
+			})
+		}
+		return &rfield.Struct{Fields: fields}
+	default:
+		return &rfield.Null{}
+	}
+}
+
+// OtlpAnyValueToVariant converts an OTLP AnyValue into a variant: a struct with a single field named after the type of
+// the value (see the VARIANT_XXX constants), so values of different types can be stored in the same column. The
+// values of kvlists are variants and the items of arrays are variants. Unset values are converted into empty structs.
+func OtlpAnyValueToVariant(value *commonpb.AnyValue) *rfield.Struct {
+	var field *rfield.Field
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_BoolValue:
+		field = rfield.NewBoolField(constants.VARIANT_BOOL, v.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		field = rfield.NewI64Field(constants.VARIANT_INT, v.IntValue)
+	case *commonpb.AnyValue_DoubleValue:
+		field = rfield.NewF64Field(constants.VARIANT_DOUBLE, v.DoubleValue)
+	case *commonpb.AnyValue_StringValue:
+		field = rfield.NewStringField(constants.VARIANT_STRING, v.StringValue)
+	case *commonpb.AnyValue_BytesValue:
+		field = rfield.NewBinaryField(constants.VARIANT_BYTES, v.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		items := make([]rfield.Value, 0, len(v.ArrayValue.GetValues()))
+		for _, item := range v.ArrayValue.GetValues() {
+			items = append(items, OtlpAnyValueToVariant(item))
+		}
+		field = rfield.NewListField(constants.VARIANT_ARRAY, rfield.List{Values: items})
+	case *commonpb.AnyValue_KvlistValue:
+		fields := make([]*rfield.Field, 0, len(v.KvlistValue.GetValues()))
+		for _, kv := range v.KvlistValue.GetValues() {
+			fields = append(fields, rfield.NewStructField(kv.Key, *OtlpAnyValueToVariant(kv.Value)))
+		}
+		field = rfield.NewStructField(constants.VARIANT_KVLIST, rfield.Struct{Fields: fields})
+	default:
+		return &rfield.Struct{}
+	}
+	return &rfield.Struct{Fields: []*rfield.Field{field}}
+}
+
+// isScalarArray returns true if the set items of an array are scalars of a single type.
+func isScalarArray(values []*commonpb.AnyValue) bool {
+	var itemType reflect.Type
+	for _, value := range values {
+		switch value.GetValue().(type) {
+		case nil:
+			continue
+		case *commonpb.AnyValue_ArrayValue, *commonpb.AnyValue_KvlistValue:
+			return false
+		}
+		if t := reflect.TypeOf(value.GetValue()); itemType == nil {
+			itemType = t
+		} else if t != itemType {
+			return false
+		}
 	}
+	return true
 }
@@ -0,0 +1,304 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+
+	"github.com/apache/arrow/go/v9/arrow"
+	"google.golang.org/protobuf/proto"
+
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/resource/v1"
+	"otel-arrow-adapter/pkg/air"
+	"otel-arrow-adapter/pkg/air/config"
+	"otel-arrow-adapter/pkg/air/rfield"
+	"otel-arrow-adapter/pkg/otel/constants"
+)
+
+// ReferenceTables deduplicates the resources and scopes of a batch. Each distinct resource (resp. scope) is emitted
+// once in the resources (resp. scopes) reference table with an `id` column, and the entity records (logs, spans, data
+// points) only carry a `resource_id` (resp. `scope_id`) column referring to it.
+//
+// The methods of a nil ReferenceTables add the resources and scopes inline, like `AddResource` and `AddScope`.
+type ReferenceTables struct {
+	resources   *air.RecordRepository
+	scopes      *air.RecordRepository
+	resourceIds map[string]uint32
+	scopeIds    map[string]uint32
+}
+
+// ReferenceRecords contains the Arrow records of the resources and scopes reference tables of a batch.
+type ReferenceRecords struct {
+	Resources []arrow.Record
+	Scopes    []arrow.Record
+}
+
+// ReferenceResolver resolves the `resource_id` and `scope_id` columns of the entity records of a batch.
+//
+// The methods of a nil ReferenceResolver (or applied to a record without reference columns) read the inline resource
+// and scope columns.
+type ReferenceResolver struct {
+	resources map[uint32]resourceEntry
+	scopes    map[uint32]scopeEntry
+}
+
+type resourceEntry struct {
+	resource  *resourcepb.Resource
+	schemaUrl string
+}
+
+type scopeEntry struct {
+	scope     *commonpb.InstrumentationScope
+	schemaUrl string
+}
+
+// NewReferenceTables creates empty reference tables. The records of the two tables are built with their own
+// dictionaries, using the given configuration.
+func NewReferenceTables(cfg *config.Config) *ReferenceTables {
+	return &ReferenceTables{
+		resources:   air.NewRecordRepository(cfg),
+		scopes:      air.NewRecordRepository(cfg),
+		resourceIds: make(map[string]uint32),
+		scopeIds:    make(map[string]uint32),
+	}
+}
+
+// AddResource adds the resource of an entity record, i.e. a `resource_id` column or an inline resource column if t is
+// nil.
+func (t *ReferenceTables) AddResource(record *air.Record, resource *resourcepb.Resource, schemaUrl string) error {
+	field, err := t.ResourceField(resource, schemaUrl)
+	if err != nil {
+		return err
+	}
+	if field != nil {
+		record.AddField(field)
+	}
+	return nil
+}
+
+// ResourceField returns the `resource_id` field referring to a resource (registered in the resources table on first
+// use), or the inline resource field if t is nil.
+func (t *ReferenceTables) ResourceField(resource *resourcepb.Resource, schemaUrl string) (*rfield.Field, error) {
+	if t == nil {
+		return ResourceField(resource, schemaUrl), nil
+	}
+	key, err := referenceKey(resource, schemaUrl)
+	if err != nil {
+		return nil, fmt.Errorf("resource: %w", err)
+	}
+	id, ok := t.resourceIds[key]
+	if !ok {
+		id = uint32(len(t.resourceIds))
+		record := air.NewRecord()
+		record.U32Field(constants.ID, id)
+		AddResource(record, resource, schemaUrl)
+		if err := t.resources.AddRecord(record); err != nil {
+			return nil, fmt.Errorf("resource: %w", err)
+		}
+		t.resourceIds[key] = id
+	}
+	return rfield.NewU32Field(constants.RESOURCE_ID, id), nil
+}
+
+// AddScope adds the scope of an entity record, i.e. a `scope_id` column or an inline scope column named `scopeKey` if
+// t is nil.
+func (t *ReferenceTables) AddScope(record *air.Record, scopeKey string, scope *commonpb.InstrumentationScope, schemaUrl string) error {
+	field, err := t.ScopeField(scopeKey, scope, schemaUrl)
+	if err != nil {
+		return err
+	}
+	record.AddField(field)
+	return nil
+}
+
+// ScopeField returns the `scope_id` field referring to a scope (registered in the scopes table on first use), or the
+// inline scope field named `scopeKey` if t is nil.
+func (t *ReferenceTables) ScopeField(scopeKey string, scope *commonpb.InstrumentationScope, schemaUrl string) (*rfield.Field, error) {
+	if t == nil {
+		return ScopeField(scopeKey, scope, schemaUrl), nil
+	}
+	key, err := referenceKey(scope, schemaUrl)
+	if err != nil {
+		return nil, fmt.Errorf("scope: %w", err)
+	}
+	id, ok := t.scopeIds[key]
+	if !ok {
+		id = uint32(len(t.scopeIds))
+		record := air.NewRecord()
+		record.U32Field(constants.ID, id)
+		AddScope(record, constants.SCOPE, scope, schemaUrl)
+		if err := t.scopes.AddRecord(record); err != nil {
+			return nil, fmt.Errorf("scope: %w", err)
+		}
+		t.scopeIds[key] = id
+	}
+	return rfield.NewU32Field(constants.SCOPE_ID, id), nil
+}
+
+// Build builds the records of the two reference tables and resets them, so the ids of the next batch start at 0.
+func (t *ReferenceTables) Build() (*ReferenceRecords, error) {
+	result := &ReferenceRecords{}
+	resources, err := t.resources.Build()
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range resources {
+		result.Resources = append(result.Resources, record)
+	}
+	scopes, err := t.scopes.Build()
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range scopes {
+		result.Scopes = append(result.Scopes, record)
+	}
+
+	t.resourceIds = make(map[string]uint32)
+	t.scopeIds = make(map[string]uint32)
+	return result, nil
+}
+
+// referenceKey returns the key identifying a resource or a scope and its schema URL in a reference table. A nil and an
+// empty message share the same key as they have the same inline representation. It returns an error if the message
+// can't be serialized (e.g. a string with invalid UTF-8, see `ValidateResource` and `ValidateScope`).
+func referenceKey(message proto.Message, schemaUrl string) (string, error) {
+	key, err := ProtoKey(message)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d:%s%s", len(schemaUrl), schemaUrl, key), nil
+}
+
+// NewReferenceResolver decodes the reference tables of a batch.
+func NewReferenceResolver(records *ReferenceRecords) (*ReferenceResolver, error) {
+	resolver := &ReferenceResolver{
+		resources: make(map[uint32]resourceEntry),
+		scopes:    make(map[uint32]scopeEntry),
+	}
+
+	err := forEachReference(records.Resources, func(id uint32, record arrow.Record, row int) error {
+		resource, err := ResourceFromRecord(record, row)
+		if err != nil {
+			return err
+		}
+		schemaUrl, err := SchemaUrlFromRecord(record, constants.RESOURCE, row)
+		if err != nil {
+			return err
+		}
+		if _, ok := resolver.resources[id]; ok {
+			return fmt.Errorf("duplicate id %d", id)
+		}
+		resolver.resources[id] = resourceEntry{resource: resource, schemaUrl: schemaUrl}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("resources: %w", err)
+	}
+
+	err = forEachReference(records.Scopes, func(id uint32, record arrow.Record, row int) error {
+		scope, err := ScopeFromRecord(record, constants.SCOPE, row)
+		if err != nil {
+			return err
+		}
+		schemaUrl, err := SchemaUrlFromRecord(record, constants.SCOPE, row)
+		if err != nil {
+			return err
+		}
+		if _, ok := resolver.scopes[id]; ok {
+			return fmt.Errorf("duplicate id %d", id)
+		}
+		resolver.scopes[id] = scopeEntry{scope: scope, schemaUrl: schemaUrl}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scopes: %w", err)
+	}
+
+	return resolver, nil
+}
+
+// forEachReference calls `fn` for every row of a reference table.
+func forEachReference(records []arrow.Record, fn func(id uint32, record arrow.Record, row int) error) error {
+	for _, record := range records {
+		record = UnflattenAttributes(record)
+		ids := Column(record, constants.ID)
+		if ids == nil {
+			return fmt.Errorf("missing column %q", constants.ID)
+		}
+		for row := 0; row < int(record.NumRows()); row++ {
+			id, err := U32FromArray(ids, row)
+			if err != nil {
+				return fmt.Errorf("%s: %w", constants.ID, err)
+			}
+			if err := fn(id, record, row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Resource returns the resource and the schema URL of the row `row` of an entity record.
+func (r *ReferenceResolver) Resource(record arrow.Record, row int) (*resourcepb.Resource, string, error) {
+	if ids := Column(record, constants.RESOURCE_ID); r != nil && ids != nil {
+		id, err := U32FromArray(ids, row)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", constants.RESOURCE_ID, err)
+		}
+		entry, ok := r.resources[id]
+		if !ok {
+			return nil, "", fmt.Errorf("%s: unknown resource id %d", constants.RESOURCE_ID, id)
+		}
+		// Each row gets its own copy, as the rebuilt messages may be modified by the caller.
+		return proto.Clone(entry.resource).(*resourcepb.Resource), entry.schemaUrl, nil
+	}
+
+	resource, err := ResourceFromRecord(record, row)
+	if err != nil {
+		return nil, "", err
+	}
+	schemaUrl, err := SchemaUrlFromRecord(record, constants.RESOURCE, row)
+	if err != nil {
+		return nil, "", err
+	}
+	return resource, schemaUrl, nil
+}
+
+// Scope returns the instrumentation scope and the schema URL of the row `row` of an entity record (`scopeKey` is the
+// name of the inline scope column).
+func (r *ReferenceResolver) Scope(record arrow.Record, scopeKey string, row int) (*commonpb.InstrumentationScope, string, error) {
+	if ids := Column(record, constants.SCOPE_ID); r != nil && ids != nil {
+		id, err := U32FromArray(ids, row)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", constants.SCOPE_ID, err)
+		}
+		entry, ok := r.scopes[id]
+		if !ok {
+			return nil, "", fmt.Errorf("%s: unknown scope id %d", constants.SCOPE_ID, id)
+		}
+		return proto.Clone(entry.scope).(*commonpb.InstrumentationScope), entry.schemaUrl, nil
+	}
+
+	scope, err := ScopeFromRecord(record, scopeKey, row)
+	if err != nil {
+		return nil, "", err
+	}
+	schemaUrl, err := SchemaUrlFromRecord(record, scopeKey, row)
+	if err != nil {
+		return nil, "", err
+	}
+	return scope, schemaUrl, nil
+}
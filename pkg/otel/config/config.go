@@ -0,0 +1,142 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+
+	config2 "otel-arrow-adapter/pkg/air/config"
+	"otel-arrow-adapter/pkg/otel/common"
+	"otel-arrow-adapter/pkg/otel/metrics"
+)
+
+// Config defines the configuration of the OTLP to OTLP Arrow converters.
+//
+// Example (YAML):
+//
+//	air:
+//	  dictionaries:
+//	    string_columns:
+//	      min_row_count: 10
+//	      max_card: 255
+//	      max_card_ratio: 0.5
+//	      max_sorted_dictionaries: 5
+//	multivariate_metrics:
+//	  system.cpu.time: state
+//	multivariate_rules:
+//	  - glob: "system.network.*"
+//	    attributes: [device, direction]
+//	  - regex: "^process\\.cpu\\..*$"
+//	    attributes: [state]
+//	multivariate_auto:
+//	  max_cardinality: 10
+//	flatten_attributes: true
+//	log_body_parser:
+//	  max_depth: 4
+//	  max_keys: 64
+//	derived_span_columns: true
+type Config struct {
+	// Configuration of the AIR RecordRepository dictionaries. The records are only sorted by their dictionary columns
+	// (see `max_sorted_dictionaries`), the sort order itself is not configurable.
+	Air *config2.Config `json:"air" yaml:"air"`
+
+	// Map of metric names to the attribute used to build multivariate metrics.
+	MultivariateMetrics map[string]string `json:"multivariate_metrics" yaml:"multivariate_metrics"`
+
+	// Rules selecting the multivariate metrics by name pattern (evaluated in order after `multivariate_metrics`).
+	MultivariateRules []*metrics.MultivariateRule `json:"multivariate_rules" yaml:"multivariate_rules"`
+
+	// Automatic detection of the multivariate attribute of the remaining gauges and sums (disabled if null).
+	MultivariateAuto *metrics.MultivariateAutoConfig `json:"multivariate_auto" yaml:"multivariate_auto"`
+
+	// Flattens the attributes into one column per attribute (see `common.FlattenAttributes`).
+	FlattenAttributes bool `json:"flatten_attributes" yaml:"flatten_attributes"`
+
+	// Parser of the JSON and, if enabled, logfmt log bodies (disabled if null).
+	LogBodyParser *common.BodyParserConfig `json:"log_body_parser" yaml:"log_body_parser"`
+
+	// Adds the derived span columns (see `common.EncodingOptions.DerivedSpanColumns`).
+	DerivedSpanColumns bool `json:"derived_span_columns" yaml:"derived_span_columns"`
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		Air:                 config2.NewDefaultConfig(),
+		MultivariateMetrics: map[string]string{},
+	}
+}
+
+// Load parses and validates a Config. The settings not defined in the data keep the values of `NewDefaultConfig`.
+func Load(data []byte, format config2.Format) (*Config, error) {
+	config := NewDefaultConfig()
+	if err := config2.LoadInto(data, format, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// LoadFile parses and validates the Config stored in a JSON or YAML file (see `config2.FormatFromPath`).
+func LoadFile(path string) (*Config, error) {
+	config := NewDefaultConfig()
+	if err := config2.LoadFileInto(path, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate checks the consistency of the configuration.
+func (c *Config) Validate() error {
+	if c.Air == nil {
+		return fmt.Errorf("config: air section must not be null")
+	}
+	if err := c.Air.Validate(); err != nil {
+		return err
+	}
+	for metric, attribute := range c.MultivariateMetrics {
+		if metric == "" {
+			return fmt.Errorf("config: multivariate_metrics: empty metric name")
+		}
+		if attribute == "" {
+			return fmt.Errorf("config: multivariate_metrics.%s: empty attribute name", metric)
+		}
+	}
+	if err := c.MultivariateMetricsConfig().Validate(); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if c.LogBodyParser != nil {
+		if err := c.LogBodyParser.Validate(); err != nil {
+			return fmt.Errorf("config: log_body_parser: %w", err)
+		}
+	}
+	return nil
+}
+
+// EncodingOptions returns the encoding options expected by the `OtlpXXXToArrowRecordsWithOptions` functions.
+func (c *Config) EncodingOptions() *common.EncodingOptions {
+	return &common.EncodingOptions{
+		FlattenAttributes:  c.FlattenAttributes,
+		BodyParser:         c.LogBodyParser,
+		DerivedSpanColumns: c.DerivedSpanColumns,
+	}
+}
+
+// MultivariateMetricsConfig returns the multivariate configuration expected by `metrics.OtlpMetricsToArrowRecords`.
+func (c *Config) MultivariateMetricsConfig() *metrics.MultivariateMetricsConfig {
+	return &metrics.MultivariateMetricsConfig{
+		Metrics: c.MultivariateMetrics,
+		Rules:   c.MultivariateRules,
+		Auto:    c.MultivariateAuto,
+	}
+}
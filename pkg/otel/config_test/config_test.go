@@ -0,0 +1,132 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"testing"
+
+	config2 "otel-arrow-adapter/pkg/air/config"
+	"otel-arrow-adapter/pkg/otel/config"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	doc := `
+air:
+  dictionaries:
+    string_columns:
+      max_card: 100
+multivariate_metrics:
+  system.cpu.time: state
+  system.memory.usage: state
+flatten_attributes: true
+log_body_parser:
+  max_keys: 10
+  logfmt: true
+derived_span_columns: true
+`
+	cfg, err := config.Load([]byte(doc), config2.FormatYAML)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Air.Dictionaries.StringColumns.MaxCard != 100 {
+		t.Errorf("Expected max_card 100, got %d", cfg.Air.Dictionaries.StringColumns.MaxCard)
+	}
+	if cfg.Air.Dictionaries.StringColumns.MinRowCount != 10 {
+		t.Errorf("Expected default min_row_count 10, got %d", cfg.Air.Dictionaries.StringColumns.MinRowCount)
+	}
+	multivariate := cfg.MultivariateMetricsConfig()
+	if len(multivariate.Metrics) != 2 || multivariate.Metrics["system.cpu.time"] != "state" {
+		t.Errorf("Unexpected multivariate metrics: %v", multivariate.Metrics)
+	}
+	if !cfg.EncodingOptions().FlattenAttributes {
+		t.Errorf("Expected flattened attributes")
+	}
+	if !cfg.EncodingOptions().DerivedSpanColumns {
+		t.Errorf("Expected derived span columns")
+	}
+	if cfg.LogBodyParser == nil || cfg.LogBodyParser.MaxKeys != 10 || !cfg.LogBodyParser.Logfmt || cfg.EncodingOptions().BodyParser != cfg.LogBodyParser {
+		t.Errorf("Unexpected log body parser: %+v", cfg.LogBodyParser)
+	}
+
+	cfg, err = config.Load([]byte(`{"multivariate_metrics": {"system.cpu.time": "state"}}`), config2.FormatJSON)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *cfg.Air != *config2.NewDefaultConfig() {
+		t.Errorf("Expected the default AIR config, got %+v", cfg.Air)
+	}
+	if cfg.FlattenAttributes {
+		t.Errorf("Expected nested attributes by default")
+	}
+	if cfg.LogBodyParser != nil {
+		t.Errorf("Expected the log body parser to be disabled by default")
+	}
+}
+
+func TestLoadMultivariateRules(t *testing.T) {
+	t.Parallel()
+
+	doc := `
+multivariate_rules:
+  - glob: "system.network.*"
+    attributes: [device, direction]
+  - regex: "^process\\.cpu\\..*$"
+    attributes: [state]
+multivariate_auto:
+  max_cardinality: 10
+`
+	cfg, err := config.Load([]byte(doc), config2.FormatYAML)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	multivariate := cfg.MultivariateMetricsConfig()
+	if len(multivariate.Rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(multivariate.Rules))
+	}
+	if multivariate.Rules[0].Glob != "system.network.*" || len(multivariate.Rules[0].Attributes) != 2 {
+		t.Errorf("Unexpected rule: %+v", multivariate.Rules[0])
+	}
+	if multivariate.Rules[1].Regex != `^process\.cpu\..*$` {
+		t.Errorf("Unexpected rule: %+v", multivariate.Rules[1])
+	}
+	if multivariate.Auto == nil || multivariate.Auto.MaxCardinality != 10 {
+		t.Errorf("Unexpected auto config: %+v", multivariate.Auto)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+
+	docs := []string{
+		`air: {dictionaries: {string_columns: {max_card_ratio: 2}}}`,
+		`air: null`,
+		`multivariate_metrics: {system.cpu.time: ""}`,
+		`multivariate: {system.cpu.time: state}`,
+		`multivariate_rules: [{regex: "(", attributes: [state]}]`,
+		`multivariate_rules: [{glob: "system.*", regex: "system", attributes: [state]}]`,
+		`multivariate_rules: [{glob: "system.*", attributes: []}]`,
+		`multivariate_rules: [{glob: "system.*", attributes: ["a,b"]}]`,
+		`multivariate_rules: [null]`,
+		`multivariate_auto: {max_cardinality: 1}`,
+		`log_body_parser: {max_depth: -1}`,
+	}
+	for _, doc := range docs {
+		if _, err := config.Load([]byte(doc), config2.FormatYAML); err == nil {
+			t.Errorf("Expected an error for %s", doc)
+		}
+	}
+}
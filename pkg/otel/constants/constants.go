@@ -28,6 +28,9 @@ const TRACE_ID string = "trace_id"
 const TRACE_STATE string = "trace_state"
 const SPAN_ID string = "span_id"
 const PARENT_SPAN_ID string = "parent_span_id"
+const DURATION_TIME_UNIX_NANO string = "duration_time_unix_nano"
+const IS_ROOT string = "is_root"
+const DEPTH string = "depth"
 const ATTRIBUTES string = "attributes"
 const RESOURCE string = "resource"
 const SCOPE_METRICS string = "scope_metrics"
@@ -36,7 +39,13 @@ const SCOPE_SPANS string = "scope_spans"
 const NAME string = "name"
 const KIND string = "kind"
 const VERSION string = "version"
+const SCHEMA_URL string = "schema_url"
+const DESCRIPTION string = "description"
+const UNIT string = "unit"
 const BODY string = "body"
+const BODY_FORMAT string = "body_format"
+const BODY_KEY_ORDER string = "body_key_order"
+const BODY_ORIGINAL string = "body_original"
 const STATUS string = "status"
 const STATUS_MESSAGE string = "status_message"
 const GAUGE_METRICS string = "gauge"
@@ -50,6 +59,13 @@ const SUMMARY_QUANTILE_VALUES string = "quantile"
 const SUMMARY_QUANTILE string = "quantile"
 const SUMMARY_VALUE string = "value"
 const METRIC_VALUE string = "value"
+const MULTIVARIATE_KEY string = "multivariate_key"
+const AGGREGATION_TEMPORALITY string = "aggregation_temporality"
+const IS_MONOTONIC string = "is_monotonic"
+const EXEMPLARS string = "exemplars"
+const FILTERED_ATTRIBUTES string = "filtered_attributes"
+const EXEMPLAR_AS_INT string = "as_int"
+const EXEMPLAR_AS_DOUBLE string = "as_double"
 const HISTOGRAM string = "histogram"
 const HISTOGRAM_COUNT string = "count"
 const HISTOGRAM_SUM string = "sum"
@@ -63,3 +79,21 @@ const EXP_HISTOGRAM_ZERO_COUNT string = "zero_count"
 const EXP_HISTOGRAM_POSITIVE string = "positive"
 const EXP_HISTOGRAM_NEGATIVE string = "negative"
 const EXP_HISTOGRAM_OFFSET string = "offset"
+const ID string = "id"
+const PARENT_ID string = "parent_id"
+const RESOURCE_ID string = "resource_id"
+const SCOPE_ID string = "scope_id"
+const SCOPE string = "scope"
+
+// Fields of the items of the key/value lists (see `common.NewKeyValueList`).
+const KEY string = "key"
+const VALUE string = "value"
+
+// Fields of the variants (see `common.OtlpAnyValueToVariant`).
+const VARIANT_BOOL string = "bool"
+const VARIANT_INT string = "int"
+const VARIANT_DOUBLE string = "double"
+const VARIANT_STRING string = "string"
+const VARIANT_BYTES string = "bytes"
+const VARIANT_KVLIST string = "kvlist"
+const VARIANT_ARRAY string = "array"
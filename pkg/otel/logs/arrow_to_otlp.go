@@ -0,0 +1,158 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"fmt"
+
+	"github.com/apache/arrow/go/v9/arrow"
+
+	collogspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/logs/v1"
+	"otel-arrow-adapter/pkg/otel/common"
+	"otel-arrow-adapter/pkg/otel/constants"
+)
+
+// ArrowRecordsToOtlpLogs converts the Arrow records produced by `OtlpLogsToArrowRecords` back to an OTLP request.
+//
+// The rows are regrouped into ResourceLogs and ScopeLogs by resource, scope and schema URL values (in order of first appearance).
+// The attributes are sorted by key, and the log records are ordered by record then by row. The flattened attributes
+// are supported as well (see `common.FlattenAttributes`), and the parsed bodies are restored as their original strings
+// (see `common.BodyParserConfig`).
+func ArrowRecordsToOtlpLogs(records []arrow.Record) (*collogspb.ExportLogsServiceRequest, error) {
+	return arrowRecordsToOtlpLogs(records, nil)
+}
+
+// ArrowRecordsToOtlpLogsWithReferences converts the Arrow records produced by `OtlpLogsToArrowRecordsWithOptions` with
+// reference tables back to an OTLP request, resolving the resource and scope references with the reference tables of
+// the batch.
+func ArrowRecordsToOtlpLogsWithReferences(records []arrow.Record, refs *common.ReferenceRecords) (*collogspb.ExportLogsServiceRequest, error) {
+	resolver, err := common.NewReferenceResolver(refs)
+	if err != nil {
+		return nil, err
+	}
+	return arrowRecordsToOtlpLogs(records, resolver)
+}
+
+func arrowRecordsToOtlpLogs(records []arrow.Record, resolver *common.ReferenceResolver) (*collogspb.ExportLogsServiceRequest, error) {
+	request := &collogspb.ExportLogsServiceRequest{}
+	resourceLogsByKey := make(map[string]*logspb.ResourceLogs)
+	scopeLogsByKey := make(map[string]*logspb.ScopeLogs)
+
+	for _, record := range records {
+		record = common.UnflattenAttributes(record)
+		for row := 0; row < int(record.NumRows()); row++ {
+			resource, resourceSchemaUrl, err := resolver.Resource(record, row)
+			if err != nil {
+				return nil, err
+			}
+			resourceKey, err := common.ProtoKey(&logspb.ResourceLogs{Resource: resource, SchemaUrl: resourceSchemaUrl})
+			if err != nil {
+				return nil, err
+			}
+			resourceLogs, ok := resourceLogsByKey[resourceKey]
+			if !ok {
+				resourceLogs = &logspb.ResourceLogs{Resource: resource, SchemaUrl: resourceSchemaUrl}
+				resourceLogsByKey[resourceKey] = resourceLogs
+				request.ResourceLogs = append(request.ResourceLogs, resourceLogs)
+			}
+
+			scope, scopeSchemaUrl, err := resolver.Scope(record, constants.SCOPE_LOGS, row)
+			if err != nil {
+				return nil, err
+			}
+			scopeKey, err := common.ProtoKey(&logspb.ScopeLogs{Scope: scope, SchemaUrl: scopeSchemaUrl})
+			if err != nil {
+				return nil, err
+			}
+			scopeKey = resourceKey + scopeKey
+			scopeLogs, ok := scopeLogsByKey[scopeKey]
+			if !ok {
+				scopeLogs = &logspb.ScopeLogs{Scope: scope, SchemaUrl: scopeSchemaUrl}
+				scopeLogsByKey[scopeKey] = scopeLogs
+				resourceLogs.ScopeLogs = append(resourceLogs.ScopeLogs, scopeLogs)
+			}
+
+			log, err := logRecordFromRow(record, row)
+			if err != nil {
+				return nil, fmt.Errorf("log record #%d: %w", row, err)
+			}
+			scopeLogs.LogRecords = append(scopeLogs.LogRecords, log)
+		}
+	}
+
+	return request, nil
+}
+
+func logRecordFromRow(record arrow.Record, row int) (*logspb.LogRecord, error) {
+	var err error
+	log := &logspb.LogRecord{}
+
+	if log.TimeUnixNano, err = common.U64FromArray(common.Column(record, constants.TIME_UNIX_NANO), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.TIME_UNIX_NANO, err)
+	}
+	if log.ObservedTimeUnixNano, err = common.U64FromArray(common.Column(record, constants.OBSERVED_TIME_UNIX_NANO), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.OBSERVED_TIME_UNIX_NANO, err)
+	}
+	severityNumber, err := common.I32FromArray(common.Column(record, constants.SEVERITY_NUMBER), row)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.SEVERITY_NUMBER, err)
+	}
+	log.SeverityNumber = logspb.SeverityNumber(severityNumber)
+	if log.SeverityText, err = common.StringFromArray(common.Column(record, constants.SEVERITY_TEXT), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.SEVERITY_TEXT, err)
+	}
+	if log.Body, err = common.ArrowValueToOtlpAnyValue(common.Column(record, constants.BODY), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.BODY, err)
+	}
+	bodyFormat, err := common.StringFromArray(common.Column(record, constants.BODY_FORMAT), row)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.BODY_FORMAT, err)
+	}
+	if bodyFormat != "" {
+		body, err := common.StringFromArray(common.Column(record, constants.BODY_ORIGINAL), row)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", constants.BODY_ORIGINAL, err)
+		}
+		if body == "" {
+			keyOrder, err := common.StringFromArray(common.Column(record, constants.BODY_KEY_ORDER), row)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", constants.BODY_KEY_ORDER, err)
+			}
+			if body, err = common.FormatBody(bodyFormat, log.Body, keyOrder); err != nil {
+				return nil, fmt.Errorf("%s: %w", constants.BODY, err)
+			}
+		}
+		log.Body = &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: body}}
+	}
+	if log.Attributes, err = common.KeyValuesFromArray(common.Column(record, constants.ATTRIBUTES), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.ATTRIBUTES, err)
+	}
+	if log.DroppedAttributesCount, err = common.U32FromArray(common.Column(record, constants.DROPPED_ATTRIBUTES_COUNT), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.DROPPED_ATTRIBUTES_COUNT, err)
+	}
+	if log.Flags, err = common.U32FromArray(common.Column(record, constants.FLAGS), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.FLAGS, err)
+	}
+	if log.TraceId, err = common.BinaryFromArray(common.Column(record, constants.TRACE_ID), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.TRACE_ID, err)
+	}
+	if log.SpanId, err = common.BinaryFromArray(common.Column(record, constants.SPAN_ID), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.SPAN_ID, err)
+	}
+
+	return log, nil
+}
@@ -0,0 +1,43 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logs
+
+import (
+	"github.com/apache/arrow/go/v9/arrow"
+
+	collogspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"otel-arrow-adapter/pkg/air"
+	"otel-arrow-adapter/pkg/otel/common"
+)
+
+// OtlpJsonLogsToArrowRecords converts an OTLP/JSON ExportLogsServiceRequest (see `common.UnmarshalOtlpJson`) to one or
+// more Arrow records. The rejected log records are reported as in `OtlpLogsToArrowRecords`.
+func OtlpJsonLogsToArrowRecords(rr *air.RecordRepository, data []byte) ([]arrow.Record, *common.PartialSuccess, error) {
+	request := &collogspb.ExportLogsServiceRequest{}
+	if err := common.UnmarshalOtlpJson(data, request); err != nil {
+		return nil, nil, err
+	}
+	return OtlpLogsToArrowRecords(rr, request)
+}
+
+// ArrowRecordsToOtlpJsonLogs converts the Arrow records produced by `OtlpLogsToArrowRecords` to an OTLP/JSON
+// ExportLogsServiceRequest (see `common.MarshalOtlpJson`).
+func ArrowRecordsToOtlpJsonLogs(records []arrow.Record) ([]byte, error) {
+	request, err := ArrowRecordsToOtlpLogs(records)
+	if err != nil {
+		return nil, err
+	}
+	return common.MarshalOtlpJson(request)
+}
@@ -15,19 +15,51 @@
 package logs
 
 import (
+	"fmt"
+
 	"github.com/apache/arrow/go/v9/arrow"
 
 	collogspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/logs/v1"
 	"otel-arrow-adapter/pkg/air"
 	"otel-arrow-adapter/pkg/otel/common"
 	"otel-arrow-adapter/pkg/otel/constants"
 )
 
-// OtlpLogsToArrowRecords converts an OTLP ResourceLogs to one or more Arrow records
-func OtlpLogsToArrowRecords(rr *air.RecordRepository, request *collogspb.ExportLogsServiceRequest) ([]arrow.Record, error) {
-	for _, resourceLogs := range request.ResourceLogs {
-		for _, scopeLogs := range resourceLogs.ScopeLogs {
-			for _, log := range scopeLogs.LogRecords {
+// OtlpLogsToArrowRecords converts an OTLP ResourceLogs to one or more Arrow records.
+//
+// The invalid log records (e.g. with an attribute of an unsupported type) are skipped and reported by the returned
+// `*common.PartialSuccess` (nil if all the log records have been converted), the records of the other log records
+// being returned. The records are only nil if an error is returned (e.g. a failure of the record repository).
+func OtlpLogsToArrowRecords(rr *air.RecordRepository, request *collogspb.ExportLogsServiceRequest) ([]arrow.Record, *common.PartialSuccess, error) {
+	return otlpLogsToArrowRecords(rr, nil, request)
+}
+
+// OtlpLogsToArrowRecordsWithOptions is similar to `OtlpLogsToArrowRecords` but uses the optional encodings selected by
+// `opts` (see `common.EncodingOptions`).
+func OtlpLogsToArrowRecordsWithOptions(rr *air.RecordRepository, opts *common.EncodingOptions, request *collogspb.ExportLogsServiceRequest) ([]arrow.Record, *common.PartialSuccess, error) {
+	return otlpLogsToArrowRecords(rr, opts, request)
+}
+
+func otlpLogsToArrowRecords(rr *air.RecordRepository, opts *common.EncodingOptions, request *collogspb.ExportLogsServiceRequest) ([]arrow.Record, *common.PartialSuccess, error) {
+	rejected := &common.PartialSuccess{}
+
+	for resourceIdx, resourceLogs := range request.ResourceLogs {
+		if err := common.ValidateResource(resourceLogs.GetResource()); err != nil {
+			rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: -1, Item: -1, DataPoint: -1, Err: err}, countLogRecords(resourceLogs.GetScopeLogs()...))
+			continue
+		}
+		for scopeIdx, scopeLogs := range resourceLogs.GetScopeLogs() {
+			if err := common.ValidateScope(scopeLogs.GetScope()); err != nil {
+				rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: scopeIdx, Item: -1, DataPoint: -1, Err: err}, countLogRecords(scopeLogs))
+				continue
+			}
+			for logIdx, log := range scopeLogs.GetLogRecords() {
+				if err := validateLogRecord(log); err != nil {
+					rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: scopeIdx, Item: logIdx, DataPoint: -1, Err: err}, 1)
+					continue
+				}
 				record := air.NewRecord()
 
 				if log.TimeUnixNano > 0 {
@@ -36,15 +68,14 @@ func OtlpLogsToArrowRecords(rr *air.RecordRepository, request *collogspb.ExportL
 				if log.ObservedTimeUnixNano > 0 {
 					record.U64Field(constants.OBSERVED_TIME_UNIX_NANO, log.ObservedTimeUnixNano)
 				}
-				common.AddResource(record, resourceLogs.Resource)
-				common.AddScope(record, constants.SCOPE_LOGS, scopeLogs.Scope)
+				if err := addResourceAndScope(record, opts, resourceLogs, scopeLogs); err != nil {
+					rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: scopeIdx, Item: logIdx, DataPoint: -1, Err: err}, 1)
+					continue
+				}
 
 				record.I32Field(constants.SEVERITY_NUMBER, int32(log.SeverityNumber))
 				record.StringField(constants.SEVERITY_TEXT, log.SeverityText)
-				body := common.OtlpAnyValueToValue(log.Body)
-				if body != nil {
-					record.GenericField(constants.BODY, body)
-				}
+				addBody(record, opts.BodyParserConfig(), log.Body)
 				attributes := common.NewAttributes(log.Attributes)
 				if attributes != nil {
 					record.AddField(attributes)
@@ -63,14 +94,16 @@ func OtlpLogsToArrowRecords(rr *air.RecordRepository, request *collogspb.ExportL
 					record.BinaryField(constants.SPAN_ID, log.SpanId)
 				}
 
-				rr.AddRecord(record)
+				if err := opts.AddRecord(rr, record); err != nil {
+					rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: scopeIdx, Item: logIdx, DataPoint: -1, Err: err}, 1)
+				}
 			}
 		}
 	}
 
 	logsRecords, err := rr.Build()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	result := make([]arrow.Record, 0, len(logsRecords))
@@ -78,5 +111,59 @@ func OtlpLogsToArrowRecords(rr *air.RecordRepository, request *collogspb.ExportL
 		result = append(result, record)
 	}
 
-	return result, nil
+	return result, rejected.OrNil(), nil
+}
+
+// itemKind is the kind of the items reported in the `common.ItemError`s.
+const itemKind = "log record"
+
+// validateLogRecord checks the body and the attributes of a log record.
+func validateLogRecord(log *logspb.LogRecord) error {
+	if log == nil {
+		return common.ErrNilItem
+	}
+	if err := common.ValidateAnyValue(log.Body); err != nil {
+		return fmt.Errorf("%s: %w", constants.BODY, err)
+	}
+	return common.ValidateKeyValues(log.Attributes)
+}
+
+// addResourceAndScope adds the resource and the scope of a log record.
+func addResourceAndScope(record *air.Record, opts *common.EncodingOptions, resourceLogs *logspb.ResourceLogs, scopeLogs *logspb.ScopeLogs) error {
+	if err := opts.Refs().AddResource(record, resourceLogs.Resource, resourceLogs.SchemaUrl); err != nil {
+		return err
+	}
+	return opts.Refs().AddScope(record, constants.SCOPE_LOGS, scopeLogs.Scope, scopeLogs.SchemaUrl)
+}
+
+// countLogRecords returns the number of log records of a list of ScopeLogs.
+func countLogRecords(scopeLogs ...*logspb.ScopeLogs) int {
+	count := 0
+	for _, sl := range scopeLogs {
+		count += len(sl.GetLogRecords())
+	}
+	return count
+}
+
+// addBody adds the body of a log record. A JSON or logfmt string body is stored as a struct with a `body_format` column
+// if the body parser is enabled, and the `body_key_order` and `body_original` columns if needed (see
+// `common.ParsedBody`).
+func addBody(record *air.Record, parser *common.BodyParserConfig, body *commonpb.AnyValue) {
+	if body == nil {
+		return
+	}
+	if str, ok := body.Value.(*commonpb.AnyValue_StringValue); ok && parser != nil {
+		if parsed := parser.ParseBody(str.StringValue); parsed != nil {
+			record.GenericField(constants.BODY, parsed.Value)
+			record.StringField(constants.BODY_FORMAT, parsed.Format)
+			if parsed.KeyOrder != "" {
+				record.StringField(constants.BODY_KEY_ORDER, parsed.KeyOrder)
+			}
+			if parsed.Original != "" {
+				record.StringField(constants.BODY_ORIGINAL, parsed.Original)
+			}
+			return
+		}
+	}
+	record.GenericField(constants.BODY, common.OtlpAnyValueToValue(body))
 }
@@ -15,12 +15,26 @@
 package logs_test
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/apache/arrow/go/v9/arrow"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	collogspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/resource/v1"
 	"otel-arrow-adapter/pkg/air"
 	"otel-arrow-adapter/pkg/air/config"
 	datagen2 "otel-arrow-adapter/pkg/datagen"
+	"otel-arrow-adapter/pkg/otel/common"
+	"otel-arrow-adapter/pkg/otel/constants"
 	"otel-arrow-adapter/pkg/otel/logs"
+	"otel-arrow-adapter/pkg/otel/testutil"
 )
 
 func TestOtlpLogsToArrowEvents(t *testing.T) {
@@ -31,7 +45,7 @@ func TestOtlpLogsToArrowEvents(t *testing.T) {
 	lg := datagen2.NewLogsGenerator(datagen2.DefaultResourceAttributes(), datagen2.DefaultInstrumentationScope())
 
 	request := lg.Generate(10, 100)
-	records, err := logs.OtlpLogsToArrowRecords(rr, request)
+	records, _, err := logs.OtlpLogsToArrowRecords(rr, request)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -39,3 +53,501 @@ func TestOtlpLogsToArrowEvents(t *testing.T) {
 		t.Errorf("Expected 1 record, got %d", len(records))
 	}
 }
+
+func TestOtlpLogsWithEmptyAttributes(t *testing.T) {
+	t.Parallel()
+
+	strValue := func(v string) *commonpb.AnyValue {
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}
+	}
+	attributes := func(tags ...*commonpb.AnyValue) []*commonpb.KeyValue {
+		return []*commonpb.KeyValue{
+			{Key: "unset", Value: nil},
+			{Key: "empty_kvlist", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{}}}},
+			{Key: "tags", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: tags}}}},
+		}
+	}
+
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: &resourcepb.Resource{},
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope: &commonpb.InstrumentationScope{Name: "scope"},
+				LogRecords: []*logspb.LogRecord{
+					{TimeUnixNano: 1, Attributes: attributes(strValue("a"), strValue("b"))},
+					{TimeUnixNano: 2, Attributes: attributes()},
+					{TimeUnixNano: 3, Attributes: attributes(nil)},
+				},
+			}},
+		}},
+	}
+
+	rr := air.NewRecordRepository(config.NewDefaultConfig())
+	records, _, err := logs.OtlpLogsToArrowRecords(rr, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Unset values, empty kvlists and empty arrays must not create new schemas.
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(records))
+	}
+	if records[0].NumRows() != 3 {
+		t.Errorf("Expected 3 rows, got %d", records[0].NumRows())
+	}
+
+	result, err := logs.ArrowRecordsToOtlpLogs(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The unset array item of the third log is not preserved.
+	request.ResourceLogs[0].ScopeLogs[0].LogRecords[2].Attributes = attributes()
+	if diff := cmp.Diff(flattenLogs(t, request), flattenLogs(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected logs (-expected +got):\n%s", diff)
+	}
+}
+
+func TestArrowRecordsToOtlpLogsSchemaUrls(t *testing.T) {
+	t.Parallel()
+
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{
+			{
+				Resource:  &resourcepb.Resource{},
+				SchemaUrl: "https://opentelemetry.io/schemas/1.9.0",
+				ScopeLogs: []*logspb.ScopeLogs{
+					{
+						Scope:      &commonpb.InstrumentationScope{Name: "scope"},
+						SchemaUrl:  "https://opentelemetry.io/schemas/1.8.0",
+						LogRecords: []*logspb.LogRecord{{TimeUnixNano: 1}},
+					},
+					{
+						Scope:      &commonpb.InstrumentationScope{Name: "scope"},
+						LogRecords: []*logspb.LogRecord{{TimeUnixNano: 2}},
+					},
+				},
+			},
+			{
+				Resource: &resourcepb.Resource{},
+				ScopeLogs: []*logspb.ScopeLogs{{
+					Scope:      &commonpb.InstrumentationScope{Name: "scope"},
+					LogRecords: []*logspb.LogRecord{{TimeUnixNano: 3}},
+				}},
+			},
+		},
+	}
+
+	rr := air.NewRecordRepository(config.NewDefaultConfig())
+	records, _, err := logs.OtlpLogsToArrowRecords(rr, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err := logs.ArrowRecordsToOtlpLogs(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The resources and the scopes only differ by their schema URL.
+	if len(result.ResourceLogs) != 2 {
+		t.Fatalf("Expected 2 ResourceLogs, got %d", len(result.ResourceLogs))
+	}
+	scopeLogsCount := len(result.ResourceLogs[0].ScopeLogs) + len(result.ResourceLogs[1].ScopeLogs)
+	if scopeLogsCount != 3 {
+		t.Errorf("Expected 3 ScopeLogs, got %d", scopeLogsCount)
+	}
+	if diff := cmp.Diff(flattenLogs(t, request), flattenLogs(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected logs (-expected +got):\n%s", diff)
+	}
+}
+
+func TestArrowRecordsToOtlpLogsWithReferences(t *testing.T) {
+	t.Parallel()
+
+	resource := &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+		{Key: "service.name", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "svc"}}},
+	}}
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{
+			{
+				Resource: resource,
+				ScopeLogs: []*logspb.ScopeLogs{
+					{
+						Scope:      &commonpb.InstrumentationScope{Name: "scope"},
+						LogRecords: []*logspb.LogRecord{{TimeUnixNano: 1}, {TimeUnixNano: 2}},
+					},
+					{
+						Scope:      &commonpb.InstrumentationScope{Name: "scope"},
+						SchemaUrl:  "https://opentelemetry.io/schemas/1.8.0",
+						LogRecords: []*logspb.LogRecord{{TimeUnixNano: 3}},
+					},
+				},
+			},
+			{
+				Resource:  resource,
+				SchemaUrl: "https://opentelemetry.io/schemas/1.9.0",
+				ScopeLogs: []*logspb.ScopeLogs{{
+					Scope:      &commonpb.InstrumentationScope{Name: "scope"},
+					LogRecords: []*logspb.LogRecord{{TimeUnixNano: 4}},
+				}},
+			},
+		},
+	}
+
+	cfg := config.NewDefaultConfig()
+	refs := common.NewReferenceTables(cfg)
+	records, _, err := logs.OtlpLogsToArrowRecordsWithOptions(air.NewRecordRepository(cfg), &common.EncodingOptions{References: refs}, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tables, err := refs.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, record := range records {
+		if common.Column(record, constants.RESOURCE) != nil || common.Column(record, constants.SCOPE_LOGS) != nil {
+			t.Errorf("Expected no inline resource and scope columns")
+		}
+	}
+	// The resources and the scopes only differ by their schema URL.
+	if rows := countRows(tables.Resources); rows != 2 {
+		t.Errorf("Expected 2 resources, got %d", rows)
+	}
+	if rows := countRows(tables.Scopes); rows != 2 {
+		t.Errorf("Expected 2 scopes, got %d", rows)
+	}
+
+	result, err := logs.ArrowRecordsToOtlpLogsWithReferences(records, tables)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.ResourceLogs) != 2 {
+		t.Fatalf("Expected 2 ResourceLogs, got %d", len(result.ResourceLogs))
+	}
+	if diff := cmp.Diff(flattenLogs(t, request), flattenLogs(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected logs (-expected +got):\n%s", diff)
+	}
+
+	// The references can't be resolved without the reference tables of the batch.
+	if _, err := logs.ArrowRecordsToOtlpLogsWithReferences(records, &common.ReferenceRecords{}); err == nil {
+		t.Errorf("Expected an error for unknown references")
+	}
+}
+
+func TestArrowRecordsToOtlpLogsStructuredBodies(t *testing.T) {
+	t.Parallel()
+
+	bodies := []struct {
+		body string
+		// Format of the parsed body (empty if the body is kept as a string).
+		format string
+		// Canonical form of the body restored if the original body is not kept (empty if identical to the body).
+		canonical string
+	}{
+		{body: ` {"b": 1.5, "a": {"d": [1, 2], "c": true}, "s": "x<y"}`, format: common.BodyFormatJson, canonical: `{"b":1.5,"a":{"d":[1,2],"c":true},"s":"x<y"}`},
+		{body: `{"a":{"c":[1,2]},"b":1,"f":1.5,"s":"x<y"}`, format: common.BodyFormatJson},
+		{body: `{"b":[{"y":1,"x":2},{"z":[1.5]}],"a":"c"}`, format: common.BodyFormatJson},
+		{body: `level=info msg="server \"x\" started" code=200`, format: common.BodyFormatLogfmt},
+		{body: `code=200 level=info msg="server \"x\" started"`, format: common.BodyFormatLogfmt},
+		{body: `ts=1  empty=""`, format: common.BodyFormatLogfmt, canonical: `ts=1 empty=""`},
+		{body: `plain text message`},
+		{body: `user logged in a=b`},
+		{body: `a==b`},
+		{body: `{"a": null}`},
+		{body: `{"a": [1, "x"]}`},
+		{body: `{"a": 1.0}`},
+		{body: `{"a": 1, "a": 2}`},
+		{body: `{"a": {"b": {"c": {"d": {"e": 1}}}}}`},
+		{body: `{"a": 1} {"b": 2}`},
+		{body: `{"a": 1}}`},
+		{body: `{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5, "f": 6}`},
+	}
+
+	var logRecords []*logspb.LogRecord
+	for i, body := range bodies {
+		logRecords = append(logRecords, &logspb.LogRecord{
+			TimeUnixNano: uint64(i + 1),
+			Body:         &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: body.body}},
+		})
+	}
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			ScopeLogs: []*logspb.ScopeLogs{{LogRecords: logRecords}},
+		}},
+	}
+
+	for _, keepOriginal := range []bool{false, true} {
+		for _, logfmt := range []bool{false, true} {
+			opts := &common.EncodingOptions{BodyParser: &common.BodyParserConfig{MaxDepth: 4, MaxKeys: 5, Logfmt: logfmt, KeepOriginal: keepOriginal}}
+			records, _, err := logs.OtlpLogsToArrowRecordsWithOptions(air.NewRecordRepository(config.NewDefaultConfig()), opts, request)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			expectedFormats := make(map[uint64]string)
+			expectedBodies := make(map[uint64]string)
+			expectedOriginals := 0
+			for i, body := range bodies {
+				expectedBodies[uint64(i+1)] = body.body
+				if body.format == common.BodyFormatJson || (body.format == common.BodyFormatLogfmt && logfmt) {
+					expectedFormats[uint64(i+1)] = body.format
+					if body.canonical != "" {
+						if keepOriginal {
+							expectedOriginals++
+						} else {
+							expectedBodies[uint64(i+1)] = body.canonical
+						}
+					}
+				}
+			}
+			formats := make(map[uint64]string)
+			originals := 0
+			for _, record := range records {
+				for row := 0; row < int(record.NumRows()); row++ {
+					timestamp, err := common.U64FromArray(common.Column(record, constants.TIME_UNIX_NANO), row)
+					if err != nil {
+						t.Fatalf("Unexpected error: %v", err)
+					}
+					format, err := common.StringFromArray(common.Column(record, constants.BODY_FORMAT), row)
+					if err != nil {
+						t.Fatalf("Unexpected error: %v", err)
+					}
+					if format != "" {
+						formats[timestamp] = format
+					}
+				}
+				if column := common.Column(record, constants.BODY_ORIGINAL); column != nil {
+					originals += column.Len() - column.NullN()
+				}
+			}
+			if diff := cmp.Diff(expectedFormats, formats); diff != "" {
+				t.Errorf("Unexpected body formats with logfmt=%v (-expected +got):\n%s", logfmt, diff)
+			}
+			if originals != expectedOriginals {
+				t.Errorf("Expected %d original bodies with logfmt=%v and keepOriginal=%v, got %d", expectedOriginals, logfmt, keepOriginal, originals)
+			}
+
+			// The bodies are restored with their original key order, and byte for byte if the original is kept.
+			result, err := logs.ArrowRecordsToOtlpLogs(records)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			restored := make(map[uint64]string)
+			for _, resourceLogs := range result.ResourceLogs {
+				for _, scopeLogs := range resourceLogs.ScopeLogs {
+					for _, log := range scopeLogs.LogRecords {
+						restored[log.TimeUnixNano] = log.Body.GetStringValue()
+					}
+				}
+			}
+			if diff := cmp.Diff(expectedBodies, restored); diff != "" {
+				t.Errorf("Unexpected bodies with logfmt=%v and keepOriginal=%v (-expected +got):\n%s", logfmt, keepOriginal, diff)
+			}
+		}
+	}
+}
+
+func TestOtlpLogsToArrowRecordsPartialSuccess(t *testing.T) {
+	t.Parallel()
+
+	invalidValue := &commonpb.AnyValue{Value: (*commonpb.AnyValue_StringValue)(nil)}
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{
+			{
+				ScopeLogs: []*logspb.ScopeLogs{{LogRecords: []*logspb.LogRecord{
+					{TimeUnixNano: 1},
+					nil,
+					{TimeUnixNano: 3, Attributes: []*commonpb.KeyValue{{Key: "k", Value: invalidValue}}},
+					{TimeUnixNano: 4, Body: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "body"}}},
+				}}},
+			},
+			{
+				Resource:  &resourcepb.Resource{Attributes: []*commonpb.KeyValue{{Key: "k", Value: invalidValue}}},
+				ScopeLogs: []*logspb.ScopeLogs{{LogRecords: []*logspb.LogRecord{{TimeUnixNano: 5}, {TimeUnixNano: 6}}}},
+			},
+		},
+	}
+
+	records, partialSuccess, err := logs.OtlpLogsToArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rows := countRows(records); rows != 2 {
+		t.Errorf("Expected 2 converted log records, got %d", rows)
+	}
+	if partialSuccess == nil {
+		t.Fatalf("Expected a partial success")
+	}
+	if partialSuccess.RejectedCount != 4 {
+		t.Errorf("Expected 4 rejected log records, got %d", partialSuccess.RejectedCount)
+	}
+	expectedMessage := "4 rejected item(s): resource #0, scope #0, log record #1: nil item (and 2 more errors)"
+	if message := partialSuccess.ErrorMessage(); message != expectedMessage {
+		t.Errorf("Expected the error message %q, got %q", expectedMessage, message)
+	}
+	expected := []string{
+		"resource #0, scope #0, log record #1: nil item",
+		`resource #0, scope #0, log record #2: attribute "k": unsupported value type: nil *v1.AnyValue_StringValue`,
+		`resource #1: resource: attribute "k": unsupported value type: nil *v1.AnyValue_StringValue`,
+	}
+	var actual []string
+	for _, itemErr := range partialSuccess.Errors {
+		actual = append(actual, itemErr.Error())
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("Unexpected errors (-expected +got):\n%s", diff)
+	}
+	if !errors.Is(partialSuccess.Errors[1], common.ErrUnsupportedValue) {
+		t.Errorf("Expected an ErrUnsupportedValue error, got %v", partialSuccess.Errors[1])
+	}
+}
+
+func TestOtlpLogsToArrowRecordsWithOptionsInvalidUtf8(t *testing.T) {
+	t.Parallel()
+
+	// Resources and scopes with invalid UTF-8 strings can't be serialized to identify them.
+	invalidString := &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "\xff"}}
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{
+			{
+				Resource:  &resourcepb.Resource{Attributes: []*commonpb.KeyValue{{Key: "k", Value: invalidString}}},
+				ScopeLogs: []*logspb.ScopeLogs{{LogRecords: []*logspb.LogRecord{{TimeUnixNano: 1}}}},
+			},
+			{
+				ScopeLogs: []*logspb.ScopeLogs{
+					{Scope: &commonpb.InstrumentationScope{Name: "\xff"}, LogRecords: []*logspb.LogRecord{{TimeUnixNano: 2}}},
+					{LogRecords: []*logspb.LogRecord{{TimeUnixNano: 3}}},
+				},
+			},
+		},
+	}
+
+	cfg := config.NewDefaultConfig()
+	records, partialSuccess, err := logs.OtlpLogsToArrowRecordsWithOptions(air.NewRecordRepository(cfg), &common.EncodingOptions{References: common.NewReferenceTables(cfg)}, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rows := countRows(records); rows != 1 {
+		t.Errorf("Expected 1 converted log record, got %d", rows)
+	}
+	if partialSuccess == nil || partialSuccess.RejectedCount != 2 {
+		t.Errorf("Expected 2 rejected log records, got %s", partialSuccess.ErrorMessage())
+	}
+}
+
+func TestOtlpJsonLogs(t *testing.T) {
+	t.Parallel()
+
+	doc := `{
+  "resourceLogs": [{
+    "resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "svc"}}]},
+    "scopeLogs": [{
+      "scope": {"name": "scope"},
+      "logRecords": [{
+        "timeUnixNano": "1544712660300000000",
+        "severityNumber": "SEVERITY_NUMBER_INFO",
+        "severityText": "Information",
+        "traceId": "5b8efff798038103d269b633813fc60c",
+        "spanId": "eee19b7ec3c1b174",
+        "body": {"stringValue": "Example log record"},
+        "attributes": [{"key": "payload", "value": {"bytesValue": "AQI="}}]
+      }]
+    }]
+  }]
+}`
+
+	records, _, err := logs.OtlpJsonLogsToArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), []byte(doc))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := logs.ArrowRecordsToOtlpJsonLogs(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The ids are hex-encoded, the other bytes values are base64-encoded.
+	for _, expected := range []string{`"traceId":"5b8efff798038103d269b633813fc60c"`, `"spanId":"eee19b7ec3c1b174"`, `"bytesValue":"AQI="`, `"severityNumber":9`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("Expected %s in %s", expected, data)
+		}
+	}
+
+	expected := &collogspb.ExportLogsServiceRequest{}
+	if err := common.UnmarshalOtlpJson([]byte(doc), expected); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result := &collogspb.ExportLogsServiceRequest{}
+	if err := common.UnmarshalOtlpJson(data, result); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(flattenLogs(t, expected), flattenLogs(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected logs (-expected +got):\n%s", diff)
+	}
+}
+
+func countRows(records []arrow.Record) int64 {
+	var rows int64
+	for _, record := range records {
+		rows += record.NumRows()
+	}
+	return rows
+}
+
+func TestArrowRecordsToOtlpLogs(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefaultConfig()
+	rr := air.NewRecordRepository(cfg)
+	lg := datagen2.NewLogsGenerator(datagen2.DefaultResourceAttributes(), datagen2.DefaultInstrumentationScope())
+
+	request := lg.Generate(10, 100)
+	records, _, err := logs.OtlpLogsToArrowRecords(rr, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result, err := logs.ArrowRecordsToOtlpLogs(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// All the generated logs share the same resource and scope.
+	if len(result.ResourceLogs) != 1 || len(result.ResourceLogs[0].ScopeLogs) != 1 {
+		t.Fatalf("Expected 1 ResourceLogs and 1 ScopeLogs, got %d ResourceLogs", len(result.ResourceLogs))
+	}
+	if diff := cmp.Diff(flattenLogs(t, request), flattenLogs(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected logs (-expected +got):\n%s", diff)
+	}
+}
+
+// flattenLogs returns the log records of a request embedded into a ResourceLogs/ScopeLogs pair (one pair per log
+// record) with the attributes sorted by key and the log records sorted by content, so requests can be compared
+// independently of the grouping and of the order of the log records.
+func flattenLogs(t *testing.T, request *collogspb.ExportLogsServiceRequest) []*logspb.ResourceLogs {
+	t.Helper()
+
+	var result []*logspb.ResourceLogs
+
+	for _, resourceLogs := range request.ResourceLogs {
+		for _, scopeLogs := range resourceLogs.ScopeLogs {
+			for _, log := range scopeLogs.LogRecords {
+				resource := proto.Clone(resourceLogs.Resource).(*resourcepb.Resource)
+				scope := proto.Clone(scopeLogs.Scope).(*commonpb.InstrumentationScope)
+				log := proto.Clone(log).(*logspb.LogRecord)
+
+				flattened := &logspb.ResourceLogs{
+					Resource:  resource,
+					SchemaUrl: resourceLogs.SchemaUrl,
+					ScopeLogs: []*logspb.ScopeLogs{{
+						Scope:      scope,
+						SchemaUrl:  scopeLogs.SchemaUrl,
+						LogRecords: []*logspb.LogRecord{log},
+					}},
+				}
+				testutil.SortAttributes(flattened)
+				result = append(result, flattened)
+			}
+		}
+	}
+
+	testutil.SortByContent(t, result)
+	return result
+}
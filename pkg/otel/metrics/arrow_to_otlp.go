@@ -0,0 +1,748 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apache/arrow/go/v9/arrow"
+	"github.com/apache/arrow/go/v9/arrow/array"
+
+	colmetricspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/metrics/v1"
+	"otel-arrow-adapter/pkg/otel/common"
+	"otel-arrow-adapter/pkg/otel/constants"
+)
+
+// metricColumn is a struct column containing the values of a metric (e.g. `gauge_<name>` or `histogram_<name>`).
+type metricColumn struct {
+	metricType string
+	name       string
+	arr        arrow.Array
+}
+
+// dataPointContext contains the fields shared by all the metric columns of a row.
+type dataPointContext struct {
+	timeUnixNano      uint64
+	startTimeUnixNano uint64
+	attributes        []*commonpb.KeyValue
+	flags             uint32
+	exemplars         []*metricspb.Exemplar
+	// Attributes used to build the multivariate metric columns of the row (empty for univariate metrics, see
+	// `multivariateFieldName`).
+	multivariateKey string
+	// Description and unit of the metric.
+	description string
+	unit        string
+	// Aggregation of the sums and histograms.
+	aggregationTemporality metricspb.AggregationTemporality
+	isMonotonic            bool
+}
+
+// metricsBuilder regroups the decoded data points into ResourceMetrics, ScopeMetrics and Metrics.
+type metricsBuilder struct {
+	request              *colmetricspb.ExportMetricsServiceRequest
+	resourceMetricsByKey map[string]*metricspb.ResourceMetrics
+	scopeMetricsByKey    map[string]*metricspb.ScopeMetrics
+	metricsByKey         map[string]*metricspb.Metric
+	// Resolver of the resource and scope references (nil for the inline resources and scopes).
+	resolver *common.ReferenceResolver
+}
+
+// ArrowRecordsToOtlpMetrics converts the Arrow records produced by `OtlpMetricsToArrowRecords` back to an OTLP
+// request.
+//
+// The metric type and name are extracted from the name of the metric columns (e.g. `gauge_<name>`). Multivariate
+// columns are expanded back into one data point per combination of values of the multivariate attributes. The data points are
+// regrouped into ResourceMetrics, ScopeMetrics and Metrics by resource, scope, schema URL, type, name, description and
+// unit (in order of first appearance). The attributes are sorted by key.
+//
+// The records produced by `OtlpMetricsToWideArrowRecords` are supported as well (see `metricColumnContext`), and so
+// are the flattened attributes of `OtlpMetricsToArrowRecordsWithOptions` (see `common.FlattenAttributes`).
+func ArrowRecordsToOtlpMetrics(records []arrow.Record) (*colmetricspb.ExportMetricsServiceRequest, error) {
+	return arrowRecordsToOtlpMetrics(records, nil)
+}
+
+// ArrowRecordsToOtlpMetricsWithReferences converts the Arrow records produced by
+// `OtlpMetricsToArrowRecordsWithOptions` with reference tables back to an OTLP request, resolving the resource and
+// scope references with the reference tables of the batch.
+func ArrowRecordsToOtlpMetricsWithReferences(records []arrow.Record, refs *common.ReferenceRecords) (*colmetricspb.ExportMetricsServiceRequest, error) {
+	resolver, err := common.NewReferenceResolver(refs)
+	if err != nil {
+		return nil, err
+	}
+	return arrowRecordsToOtlpMetrics(records, resolver)
+}
+
+func arrowRecordsToOtlpMetrics(records []arrow.Record, resolver *common.ReferenceResolver) (*colmetricspb.ExportMetricsServiceRequest, error) {
+	builder := metricsBuilder{
+		resolver:             resolver,
+		request:              &colmetricspb.ExportMetricsServiceRequest{},
+		resourceMetricsByKey: make(map[string]*metricspb.ResourceMetrics),
+		scopeMetricsByKey:    make(map[string]*metricspb.ScopeMetrics),
+		metricsByKey:         make(map[string]*metricspb.Metric),
+	}
+
+	for _, record := range records {
+		record = common.UnflattenAttributes(record)
+		metricColumns := metricColumns(record)
+
+		for row := 0; row < int(record.NumRows()); row++ {
+			scopeMetrics, scopeKey, err := builder.scopeMetrics(record, row)
+			if err != nil {
+				return nil, err
+			}
+			ctx, err := dataPointContextFromRow(record, row)
+			if err != nil {
+				return nil, fmt.Errorf("data point #%d: %w", row, err)
+			}
+
+			for _, column := range metricColumns {
+				if common.IsNull(column.arr, row) {
+					continue
+				}
+				columnCtx, err := metricColumnContext(ctx, column.arr, row)
+				if err != nil {
+					return nil, fmt.Errorf("data point #%d: %s_%s: %w", row, column.metricType, column.name, err)
+				}
+				metric := builder.metric(scopeMetrics, scopeKey, column, columnCtx)
+				if err := addDataPoint(metric, column, columnCtx, row); err != nil {
+					return nil, fmt.Errorf("data point #%d: %s_%s: %w", row, column.metricType, column.name, err)
+				}
+			}
+		}
+	}
+
+	return builder.request, nil
+}
+
+// metricColumns returns the metric columns of a record.
+func metricColumns(record arrow.Record) []metricColumn {
+	var columns []metricColumn
+	for i, field := range record.Schema().Fields() {
+		if field.Type.ID() != arrow.STRUCT {
+			continue
+		}
+		metricType, name, ok := parseMetricColumnName(field.Name)
+		if !ok {
+			continue
+		}
+		columns = append(columns, metricColumn{metricType: metricType, name: name, arr: record.Column(i)})
+	}
+	return columns
+}
+
+// parseMetricColumnName splits the name of a metric column into a metric type and a metric name.
+func parseMetricColumnName(columnName string) (metricType string, name string, ok bool) {
+	metricTypes := []string{constants.GAUGE_METRICS, constants.SUM_METRICS, constants.SUMMARY_METRICS, constants.HISTOGRAM, constants.EXP_HISTOGRAM}
+	for _, metricType := range metricTypes {
+		if strings.HasPrefix(columnName, metricType+"_") {
+			return metricType, columnName[len(metricType)+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// scopeMetrics returns the ScopeMetrics of a row (created on first use) and the key identifying it.
+func (b *metricsBuilder) scopeMetrics(record arrow.Record, row int) (*metricspb.ScopeMetrics, string, error) {
+	resource, resourceSchemaUrl, err := b.resolver.Resource(record, row)
+	if err != nil {
+		return nil, "", err
+	}
+	resourceKey, err := common.ProtoKey(&metricspb.ResourceMetrics{Resource: resource, SchemaUrl: resourceSchemaUrl})
+	if err != nil {
+		return nil, "", err
+	}
+	resourceMetrics, ok := b.resourceMetricsByKey[resourceKey]
+	if !ok {
+		resourceMetrics = &metricspb.ResourceMetrics{Resource: resource, SchemaUrl: resourceSchemaUrl}
+		b.resourceMetricsByKey[resourceKey] = resourceMetrics
+		b.request.ResourceMetrics = append(b.request.ResourceMetrics, resourceMetrics)
+	}
+
+	scope, scopeSchemaUrl, err := b.resolver.Scope(record, constants.SCOPE_METRICS, row)
+	if err != nil {
+		return nil, "", err
+	}
+	scopeKey, err := common.ProtoKey(&metricspb.ScopeMetrics{Scope: scope, SchemaUrl: scopeSchemaUrl})
+	if err != nil {
+		return nil, "", err
+	}
+	scopeKey = resourceKey + scopeKey
+	scopeMetrics, ok := b.scopeMetricsByKey[scopeKey]
+	if !ok {
+		scopeMetrics = &metricspb.ScopeMetrics{Scope: scope, SchemaUrl: scopeSchemaUrl}
+		b.scopeMetricsByKey[scopeKey] = scopeMetrics
+		resourceMetrics.ScopeMetrics = append(resourceMetrics.ScopeMetrics, scopeMetrics)
+	}
+	return scopeMetrics, scopeKey, nil
+}
+
+// metric returns the Metric of a ScopeMetrics corresponding to a metric column (created on first use). Metrics with
+// the same name but a different description, unit or aggregation are kept apart.
+func (b *metricsBuilder) metric(scopeMetrics *metricspb.ScopeMetrics, scopeKey string, column metricColumn, ctx *dataPointContext) *metricspb.Metric {
+	metricKey := fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%s\x00%d\x00%t", scopeKey, column.metricType, column.name, ctx.description, ctx.unit, ctx.aggregationTemporality, ctx.isMonotonic)
+	metric, ok := b.metricsByKey[metricKey]
+	if !ok {
+		metric = &metricspb.Metric{Name: column.name, Description: ctx.description, Unit: ctx.unit}
+		switch column.metricType {
+		case constants.GAUGE_METRICS:
+			metric.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{}}
+		case constants.SUM_METRICS:
+			metric.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				AggregationTemporality: ctx.aggregationTemporality,
+				IsMonotonic:            ctx.isMonotonic,
+			}}
+		case constants.SUMMARY_METRICS:
+			metric.Data = &metricspb.Metric_Summary{Summary: &metricspb.Summary{}}
+		case constants.HISTOGRAM:
+			metric.Data = &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+				AggregationTemporality: ctx.aggregationTemporality,
+			}}
+		case constants.EXP_HISTOGRAM:
+			metric.Data = &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{
+				AggregationTemporality: ctx.aggregationTemporality,
+			}}
+		}
+		b.metricsByKey[metricKey] = metric
+		scopeMetrics.Metrics = append(scopeMetrics.Metrics, metric)
+	}
+	return metric
+}
+
+func dataPointContextFromRow(record arrow.Record, row int) (*dataPointContext, error) {
+	var err error
+	ctx := &dataPointContext{}
+
+	if ctx.timeUnixNano, err = common.U64FromArray(common.Column(record, constants.TIME_UNIX_NANO), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.TIME_UNIX_NANO, err)
+	}
+	if ctx.startTimeUnixNano, err = common.U64FromArray(common.Column(record, constants.START_TIME_UNIX_NANO), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.START_TIME_UNIX_NANO, err)
+	}
+	if ctx.attributes, err = common.KeyValuesFromArray(common.Column(record, constants.ATTRIBUTES), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.ATTRIBUTES, err)
+	}
+	if ctx.flags, err = common.U32FromArray(common.Column(record, constants.FLAGS), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.FLAGS, err)
+	}
+	if ctx.exemplars, err = exemplarsFromRow(record, row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.EXEMPLARS, err)
+	}
+	if ctx.multivariateKey, err = common.StringFromArray(common.Column(record, constants.MULTIVARIATE_KEY), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.MULTIVARIATE_KEY, err)
+	}
+	if ctx.description, err = common.StringFromArray(common.Column(record, constants.DESCRIPTION), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.DESCRIPTION, err)
+	}
+	if ctx.unit, err = common.StringFromArray(common.Column(record, constants.UNIT), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.UNIT, err)
+	}
+	temporality, err := common.I32FromArray(common.Column(record, constants.AGGREGATION_TEMPORALITY), row)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.AGGREGATION_TEMPORALITY, err)
+	}
+	ctx.aggregationTemporality = metricspb.AggregationTemporality(temporality)
+	if ctx.isMonotonic, err = common.BoolFromArray(common.Column(record, constants.IS_MONOTONIC), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.IS_MONOTONIC, err)
+	}
+	return ctx, nil
+}
+
+// metricColumnContext returns the context of the data point stored at position `row` of a univariate metric column.
+// In the wide-table layout (see `OtlpMetricsToWideArrowRecords`), the description, unit, aggregation, flags and
+// exemplars are stored in the metric columns and override the top-level columns of the row.
+func metricColumnContext(ctx *dataPointContext, arr arrow.Array, row int) (*dataPointContext, error) {
+	if ctx.multivariateKey != "" {
+		// The fields of a multivariate column are named after the values of the multivariate attributes.
+		return ctx, nil
+	}
+
+	description := common.StructField(arr, constants.DESCRIPTION)
+	unit := common.StructField(arr, constants.UNIT)
+	temporality := common.StructField(arr, constants.AGGREGATION_TEMPORALITY)
+	isMonotonic := common.StructField(arr, constants.IS_MONOTONIC)
+	flags := common.StructField(arr, constants.FLAGS)
+	exemplars := common.StructField(arr, constants.EXEMPLARS)
+	if description == nil && unit == nil && temporality == nil && isMonotonic == nil && flags == nil && exemplars == nil {
+		return ctx, nil
+	}
+
+	var err error
+	columnCtx := *ctx
+	if description != nil {
+		if columnCtx.description, err = common.StringFromArray(description, row); err != nil {
+			return nil, fmt.Errorf("%s: %w", constants.DESCRIPTION, err)
+		}
+	}
+	if unit != nil {
+		if columnCtx.unit, err = common.StringFromArray(unit, row); err != nil {
+			return nil, fmt.Errorf("%s: %w", constants.UNIT, err)
+		}
+	}
+	if temporality != nil {
+		value, err := common.I32FromArray(temporality, row)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", constants.AGGREGATION_TEMPORALITY, err)
+		}
+		columnCtx.aggregationTemporality = metricspb.AggregationTemporality(value)
+	}
+	if isMonotonic != nil {
+		if columnCtx.isMonotonic, err = common.BoolFromArray(isMonotonic, row); err != nil {
+			return nil, fmt.Errorf("%s: %w", constants.IS_MONOTONIC, err)
+		}
+	}
+	if flags != nil {
+		if columnCtx.flags, err = common.U32FromArray(flags, row); err != nil {
+			return nil, fmt.Errorf("%s: %w", constants.FLAGS, err)
+		}
+	}
+	if exemplars != nil {
+		if columnCtx.exemplars, err = exemplarsFromArray(exemplars, row); err != nil {
+			return nil, fmt.Errorf("%s: %w", constants.EXEMPLARS, err)
+		}
+	}
+	return &columnCtx, nil
+}
+
+// exemplarsFromRow decodes the exemplars of a row (see `AddExemplars`).
+func exemplarsFromRow(record arrow.Record, row int) ([]*metricspb.Exemplar, error) {
+	return exemplarsFromArray(common.Column(record, constants.EXEMPLARS), row)
+}
+
+// exemplarsFromArray decodes the exemplars stored at position `row` of a list of exemplar structs.
+func exemplarsFromArray(arr arrow.Array, row int) ([]*metricspb.Exemplar, error) {
+	exemplars, start, end, err := common.ListValues(arr, row)
+	if err != nil || start == end {
+		return nil, err
+	}
+
+	filteredAttributes := common.StructField(exemplars, constants.FILTERED_ATTRIBUTES)
+	timeUnixNano := common.StructField(exemplars, constants.TIME_UNIX_NANO)
+	asInt := common.StructField(exemplars, constants.EXEMPLAR_AS_INT)
+	asDouble := common.StructField(exemplars, constants.EXEMPLAR_AS_DOUBLE)
+	spanId := common.StructField(exemplars, constants.SPAN_ID)
+	traceId := common.StructField(exemplars, constants.TRACE_ID)
+
+	result := make([]*metricspb.Exemplar, 0, end-start)
+	for i := start; i < end; i++ {
+		exemplar := &metricspb.Exemplar{}
+		if exemplar.FilteredAttributes, err = common.VariantKeyValuesFromArray(filteredAttributes, i); err != nil {
+			return nil, fmt.Errorf("%s: %w", constants.FILTERED_ATTRIBUTES, err)
+		}
+		if exemplar.TimeUnixNano, err = common.U64FromArray(timeUnixNano, i); err != nil {
+			return nil, fmt.Errorf("%s: %w", constants.TIME_UNIX_NANO, err)
+		}
+		if !common.IsNull(asInt, i) {
+			value, err := common.I64FromArray(asInt, i)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", constants.EXEMPLAR_AS_INT, err)
+			}
+			exemplar.Value = &metricspb.Exemplar_AsInt{AsInt: value}
+		} else if !common.IsNull(asDouble, i) {
+			value, err := common.F64FromArray(asDouble, i)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", constants.EXEMPLAR_AS_DOUBLE, err)
+			}
+			exemplar.Value = &metricspb.Exemplar_AsDouble{AsDouble: value}
+		}
+		if exemplar.SpanId, err = common.BinaryFromArray(spanId, i); err != nil {
+			return nil, fmt.Errorf("%s: %w", constants.SPAN_ID, err)
+		}
+		if exemplar.TraceId, err = common.BinaryFromArray(traceId, i); err != nil {
+			return nil, fmt.Errorf("%s: %w", constants.TRACE_ID, err)
+		}
+		result = append(result, exemplar)
+	}
+	return result, nil
+}
+
+// addDataPoint decodes the data point(s) stored at position `row` of a metric column and appends it to the metric.
+func addDataPoint(metric *metricspb.Metric, column metricColumn, ctx *dataPointContext, row int) error {
+	switch data := metric.Data.(type) {
+	case *metricspb.Metric_Gauge:
+		dataPoints, err := numberDataPoints(column.arr, ctx, row)
+		if err != nil {
+			return err
+		}
+		data.Gauge.DataPoints = append(data.Gauge.DataPoints, dataPoints...)
+	case *metricspb.Metric_Sum:
+		dataPoints, err := numberDataPoints(column.arr, ctx, row)
+		if err != nil {
+			return err
+		}
+		data.Sum.DataPoints = append(data.Sum.DataPoints, dataPoints...)
+	case *metricspb.Metric_Summary:
+		dataPoints, err := summaryDataPoints(column.arr, ctx, row)
+		if err != nil {
+			return err
+		}
+		data.Summary.DataPoints = append(data.Summary.DataPoints, dataPoints...)
+	case *metricspb.Metric_Histogram:
+		dataPoints, err := histogramDataPoints(column.arr, ctx, row)
+		if err != nil {
+			return err
+		}
+		data.Histogram.DataPoints = append(data.Histogram.DataPoints, dataPoints...)
+	case *metricspb.Metric_ExponentialHistogram:
+		dataPoints, err := expHistogramDataPoints(column.arr, ctx, row)
+		if err != nil {
+			return err
+		}
+		data.ExponentialHistogram.DataPoints = append(data.ExponentialHistogram.DataPoints, dataPoints...)
+	default:
+		return fmt.Errorf("unsupported metric type %q", column.metricType)
+	}
+	return nil
+}
+
+// numberDataPoints decodes the data points of a gauge or sum column.
+//
+// A univariate column contains a single `value` field. A multivariate column contains one field per combination of
+// values of the multivariate attributes (see `multivariateMetric`), each field is expanded into a data point with the
+// multivariate attributes restored. The type of the fields determines whether the data points are ints or doubles.
+func numberDataPoints(arr arrow.Array, ctx *dataPointContext, row int) ([]*metricspb.NumberDataPoint, error) {
+	if ctx.multivariateKey == "" {
+		value := common.StructField(arr, constants.METRIC_VALUE)
+		if value == nil {
+			return nil, fmt.Errorf("missing field %q", constants.METRIC_VALUE)
+		}
+		dataPoint := newNumberDataPoint(ctx, ctx.attributes)
+		if err := setNumberValue(dataPoint, value, row); err != nil {
+			return nil, err
+		}
+		return []*metricspb.NumberDataPoint{dataPoint}, nil
+	}
+
+	var dataPoints []*metricspb.NumberDataPoint
+	err := multivariateDataPoints(arr, ctx, row, func(value arrow.Array, attributes []*commonpb.KeyValue) error {
+		dataPoint := newNumberDataPoint(ctx, attributes)
+		if err := setNumberValue(dataPoint, value, row); err != nil {
+			return err
+		}
+		dataPoints = append(dataPoints, dataPoint)
+		return nil
+	})
+	return dataPoints, err
+}
+
+// multivariateDataPoints calls `fn` for each non-null field of a multivariate column at position `row`, with the
+// attributes of the row completed by the multivariate attributes decoded from the field name (sorted by key).
+func multivariateDataPoints(arr arrow.Array, ctx *dataPointContext, row int, fn func(value arrow.Array, attributes []*commonpb.KeyValue) error) error {
+	structArr, ok := arr.(*array.Struct)
+	if !ok {
+		return fmt.Errorf("expected a struct array, got %s", arr.DataType())
+	}
+	for i, field := range structArr.DataType().(*arrow.StructType).Fields() {
+		value := structArr.Field(i)
+		if common.IsNull(value, row) {
+			continue
+		}
+		pivotAttributes, err := multivariateAttributes(field.Name, ctx.multivariateKey)
+		if err != nil {
+			return err
+		}
+		attributes := ctx.attributes
+		if len(pivotAttributes) > 0 {
+			attributes = make([]*commonpb.KeyValue, 0, len(ctx.attributes)+len(pivotAttributes))
+			attributes = append(attributes, ctx.attributes...)
+			attributes = append(attributes, pivotAttributes...)
+			sort.Sort(KeyValues(attributes))
+		}
+		if err := fn(value, attributes); err != nil {
+			return fmt.Errorf("%s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+func newNumberDataPoint(ctx *dataPointContext, attributes []*commonpb.KeyValue) *metricspb.NumberDataPoint {
+	return &metricspb.NumberDataPoint{
+		Attributes:        attributes,
+		StartTimeUnixNano: ctx.startTimeUnixNano,
+		TimeUnixNano:      ctx.timeUnixNano,
+		Flags:             ctx.flags,
+		Exemplars:         ctx.exemplars,
+	}
+}
+
+// setNumberValue sets the int or double value of a data point from the value at position `row` of an array.
+func setNumberValue(dataPoint *metricspb.NumberDataPoint, value arrow.Array, row int) error {
+	switch value.DataType().ID() {
+	case arrow.FLOAT32, arrow.FLOAT64:
+		v, err := common.F64FromArray(value, row)
+		if err != nil {
+			return err
+		}
+		dataPoint.Value = &metricspb.NumberDataPoint_AsDouble{AsDouble: v}
+	case arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64:
+		v, err := common.I64FromArray(value, row)
+		if err != nil {
+			return err
+		}
+		dataPoint.Value = &metricspb.NumberDataPoint_AsInt{AsInt: v}
+	default:
+		return fmt.Errorf("unsupported value type %s", value.DataType())
+	}
+	return nil
+}
+
+// summaryDataPoints decodes the data points of a summary column (one per field for a multivariate column).
+func summaryDataPoints(arr arrow.Array, ctx *dataPointContext, row int) ([]*metricspb.SummaryDataPoint, error) {
+	if ctx.multivariateKey == "" {
+		dataPoint, err := summaryDataPoint(arr, ctx, ctx.attributes, row)
+		if err != nil {
+			return nil, err
+		}
+		return []*metricspb.SummaryDataPoint{dataPoint}, nil
+	}
+
+	var dataPoints []*metricspb.SummaryDataPoint
+	err := multivariateDataPoints(arr, ctx, row, func(value arrow.Array, attributes []*commonpb.KeyValue) error {
+		dataPoint, err := summaryDataPoint(value, ctx, attributes, row)
+		if err != nil {
+			return err
+		}
+		dataPoints = append(dataPoints, dataPoint)
+		return nil
+	})
+	return dataPoints, err
+}
+
+// summaryDataPoint decodes the count, sum and quantiles of a summary struct.
+func summaryDataPoint(arr arrow.Array, ctx *dataPointContext, attributes []*commonpb.KeyValue, row int) (*metricspb.SummaryDataPoint, error) {
+	var err error
+	dataPoint := &metricspb.SummaryDataPoint{
+		Attributes:        attributes,
+		StartTimeUnixNano: ctx.startTimeUnixNano,
+		TimeUnixNano:      ctx.timeUnixNano,
+		Flags:             ctx.flags,
+	}
+
+	if dataPoint.Count, err = common.U64FromArray(common.StructField(arr, constants.SUMMARY_COUNT), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.SUMMARY_COUNT, err)
+	}
+	if dataPoint.Sum, err = common.F64FromArray(common.StructField(arr, constants.SUMMARY_SUM), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.SUMMARY_SUM, err)
+	}
+
+	quantiles, start, end, err := common.ListValues(common.StructField(arr, constants.SUMMARY_QUANTILE_VALUES), row)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.SUMMARY_QUANTILE_VALUES, err)
+	}
+	quantile := common.StructField(quantiles, constants.SUMMARY_QUANTILE)
+	value := common.StructField(quantiles, constants.SUMMARY_VALUE)
+	for i := start; i < end; i++ {
+		quantileValue := &metricspb.SummaryDataPoint_ValueAtQuantile{}
+		if quantileValue.Quantile, err = common.F64FromArray(quantile, i); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", constants.SUMMARY_QUANTILE_VALUES, constants.SUMMARY_QUANTILE, err)
+		}
+		if quantileValue.Value, err = common.F64FromArray(value, i); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", constants.SUMMARY_QUANTILE_VALUES, constants.SUMMARY_VALUE, err)
+		}
+		dataPoint.QuantileValues = append(dataPoint.QuantileValues, quantileValue)
+	}
+
+	return dataPoint, nil
+}
+
+// histogramDataPoints decodes the data points of a histogram column (one per field for a multivariate column).
+func histogramDataPoints(arr arrow.Array, ctx *dataPointContext, row int) ([]*metricspb.HistogramDataPoint, error) {
+	if ctx.multivariateKey == "" {
+		dataPoint, err := histogramDataPoint(arr, ctx, ctx.attributes, row)
+		if err != nil {
+			return nil, err
+		}
+		return []*metricspb.HistogramDataPoint{dataPoint}, nil
+	}
+
+	var dataPoints []*metricspb.HistogramDataPoint
+	err := multivariateDataPoints(arr, ctx, row, func(value arrow.Array, attributes []*commonpb.KeyValue) error {
+		dataPoint, err := histogramDataPoint(value, ctx, attributes, row)
+		if err != nil {
+			return err
+		}
+		dataPoints = append(dataPoints, dataPoint)
+		return nil
+	})
+	return dataPoints, err
+}
+
+// histogramDataPoint decodes the count, the optional sum/min/max, the bucket counts and the explicit bounds of a
+// histogram struct.
+func histogramDataPoint(arr arrow.Array, ctx *dataPointContext, attributes []*commonpb.KeyValue, row int) (*metricspb.HistogramDataPoint, error) {
+	var err error
+	dataPoint := &metricspb.HistogramDataPoint{
+		Attributes:        attributes,
+		StartTimeUnixNano: ctx.startTimeUnixNano,
+		TimeUnixNano:      ctx.timeUnixNano,
+		Flags:             ctx.flags,
+		Exemplars:         ctx.exemplars,
+	}
+
+	if dataPoint.Count, err = common.U64FromArray(common.StructField(arr, constants.HISTOGRAM_COUNT), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.HISTOGRAM_COUNT, err)
+	}
+	if dataPoint.Sum, dataPoint.Min, dataPoint.Max, err = histogramSumMinMax(arr, row); err != nil {
+		return nil, err
+	}
+	if dataPoint.BucketCounts, err = u64List(common.StructField(arr, constants.HISTOGRAM_BUCKET_COUNTS), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.HISTOGRAM_BUCKET_COUNTS, err)
+	}
+	if dataPoint.ExplicitBounds, err = f64List(common.StructField(arr, constants.HISTOGRAM_EXPLICIT_BOUNDS), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.HISTOGRAM_EXPLICIT_BOUNDS, err)
+	}
+
+	return dataPoint, nil
+}
+
+// expHistogramDataPoints decodes the data points of an exponential histogram column (one per field for a multivariate
+// column).
+func expHistogramDataPoints(arr arrow.Array, ctx *dataPointContext, row int) ([]*metricspb.ExponentialHistogramDataPoint, error) {
+	if ctx.multivariateKey == "" {
+		dataPoint, err := expHistogramDataPoint(arr, ctx, ctx.attributes, row)
+		if err != nil {
+			return nil, err
+		}
+		return []*metricspb.ExponentialHistogramDataPoint{dataPoint}, nil
+	}
+
+	var dataPoints []*metricspb.ExponentialHistogramDataPoint
+	err := multivariateDataPoints(arr, ctx, row, func(value arrow.Array, attributes []*commonpb.KeyValue) error {
+		dataPoint, err := expHistogramDataPoint(value, ctx, attributes, row)
+		if err != nil {
+			return err
+		}
+		dataPoints = append(dataPoints, dataPoint)
+		return nil
+	})
+	return dataPoints, err
+}
+
+// expHistogramDataPoint decodes the count, the optional sum/min/max, the scale, the zero count and the optional
+// positive and negative buckets of an exponential histogram struct.
+func expHistogramDataPoint(arr arrow.Array, ctx *dataPointContext, attributes []*commonpb.KeyValue, row int) (*metricspb.ExponentialHistogramDataPoint, error) {
+	var err error
+	dataPoint := &metricspb.ExponentialHistogramDataPoint{
+		Attributes:        attributes,
+		StartTimeUnixNano: ctx.startTimeUnixNano,
+		TimeUnixNano:      ctx.timeUnixNano,
+		Flags:             ctx.flags,
+		Exemplars:         ctx.exemplars,
+	}
+
+	if dataPoint.Count, err = common.U64FromArray(common.StructField(arr, constants.HISTOGRAM_COUNT), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.HISTOGRAM_COUNT, err)
+	}
+	if dataPoint.Sum, dataPoint.Min, dataPoint.Max, err = histogramSumMinMax(arr, row); err != nil {
+		return nil, err
+	}
+	if dataPoint.Scale, err = common.I32FromArray(common.StructField(arr, constants.EXP_HISTOGRAM_SCALE), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.EXP_HISTOGRAM_SCALE, err)
+	}
+	if dataPoint.ZeroCount, err = common.U64FromArray(common.StructField(arr, constants.EXP_HISTOGRAM_ZERO_COUNT), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.EXP_HISTOGRAM_ZERO_COUNT, err)
+	}
+	if dataPoint.Positive, err = expHistogramBuckets(common.StructField(arr, constants.EXP_HISTOGRAM_POSITIVE), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.EXP_HISTOGRAM_POSITIVE, err)
+	}
+	if dataPoint.Negative, err = expHistogramBuckets(common.StructField(arr, constants.EXP_HISTOGRAM_NEGATIVE), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.EXP_HISTOGRAM_NEGATIVE, err)
+	}
+
+	return dataPoint, nil
+}
+
+// expHistogramBuckets decodes the offset and the bucket counts of the positive or negative buckets of an exponential
+// histogram. Nil is returned if the buckets are not defined.
+func expHistogramBuckets(arr arrow.Array, row int) (*metricspb.ExponentialHistogramDataPoint_Buckets, error) {
+	if common.IsNull(arr, row) {
+		return nil, nil
+	}
+
+	var err error
+	buckets := &metricspb.ExponentialHistogramDataPoint_Buckets{}
+	if buckets.Offset, err = common.I32FromArray(common.StructField(arr, constants.EXP_HISTOGRAM_OFFSET), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.EXP_HISTOGRAM_OFFSET, err)
+	}
+	if buckets.BucketCounts, err = u64List(common.StructField(arr, constants.HISTOGRAM_BUCKET_COUNTS), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.HISTOGRAM_BUCKET_COUNTS, err)
+	}
+	return buckets, nil
+}
+
+// histogramSumMinMax decodes the optional sum, min and max fields shared by the histograms and the exponential
+// histograms.
+func histogramSumMinMax(arr arrow.Array, row int) (sum *float64, min *float64, max *float64, err error) {
+	if sum, err = optionalF64(common.StructField(arr, constants.HISTOGRAM_SUM), row); err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", constants.HISTOGRAM_SUM, err)
+	}
+	if min, err = optionalF64(common.StructField(arr, constants.HISTOGRAM_MIN), row); err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", constants.HISTOGRAM_MIN, err)
+	}
+	if max, err = optionalF64(common.StructField(arr, constants.HISTOGRAM_MAX), row); err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", constants.HISTOGRAM_MAX, err)
+	}
+	return sum, min, max, nil
+}
+
+// optionalF64 returns nil if the value doesn't exist or is null, and a pointer to the value otherwise.
+func optionalF64(arr arrow.Array, row int) (*float64, error) {
+	if common.IsNull(arr, row) {
+		return nil, nil
+	}
+	value, err := common.F64FromArray(arr, row)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// u64List returns the values of a list of unsigned integers (nil for an empty or null list).
+func u64List(arr arrow.Array, row int) ([]uint64, error) {
+	values, start, end, err := common.ListValues(arr, row)
+	if err != nil || start == end {
+		return nil, err
+	}
+	result := make([]uint64, 0, end-start)
+	for i := start; i < end; i++ {
+		value, err := common.U64FromArray(values, i)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+// f64List returns the values of a list of floating point numbers (nil for an empty or null list).
+func f64List(arr arrow.Array, row int) ([]float64, error) {
+	values, start, end, err := common.ListValues(arr, row)
+	if err != nil || start == end {
+		return nil, err
+	}
+	result := make([]float64, 0, end-start)
+	for i := start; i < end; i++ {
+		value, err := common.F64FromArray(values, i)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
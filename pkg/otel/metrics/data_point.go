@@ -16,11 +16,13 @@ package metrics
 
 import (
 	"encoding/binary"
+	"fmt"
 	"math"
 	"sort"
 
 	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
 	v1 "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/metrics/v1"
+	"otel-arrow-adapter/pkg/otel/common"
 )
 
 type KeyValues []*commonpb.KeyValue
@@ -30,23 +32,73 @@ func (kvs KeyValues) Less(i, j int) bool { return kvs[i].Key < kvs[j].Key }
 func (kvs KeyValues) Len() int           { return len(kvs) }
 func (kvs KeyValues) Swap(i, j int)      { kvs[i], kvs[j] = kvs[j], kvs[i] }
 
-func DataPointSig(dataPoint *v1.NumberDataPoint, multivariateKey string) []byte {
+// DataPoint is the interface shared by the number, histogram, exponential histogram and summary data points.
+type DataPoint interface {
+	GetStartTimeUnixNano() uint64
+	GetTimeUnixNano() uint64
+	GetAttributes() []*commonpb.KeyValue
+	GetFlags() uint32
+}
+
+// DataPointSig returns the signature of the timestamps and attributes (except the multivariate attributes) of a data
+// point. It returns an error wrapping `common.ErrUnsupportedValue` if an attribute value is not supported.
+func DataPointSig(dataPoint DataPoint, multivariateKeys ...string) ([]byte, error) {
 	sig := make([]byte, 16, 128)
 
 	// Serialize times and attributes to build the signature.
-	binary.LittleEndian.PutUint64(sig[0:], dataPoint.StartTimeUnixNano)
-	binary.LittleEndian.PutUint64(sig[8:], dataPoint.TimeUnixNano)
-	KeyValuesSig(&sig, dataPoint.Attributes, multivariateKey)
-	return sig
+	binary.LittleEndian.PutUint64(sig[0:], dataPoint.GetStartTimeUnixNano())
+	binary.LittleEndian.PutUint64(sig[8:], dataPoint.GetTimeUnixNano())
+	if err := KeyValuesSig(&sig, dataPoint.GetAttributes(), multivariateKeys...); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+func fromNumberDataPoints(dataPoints []*v1.NumberDataPoint) []DataPoint {
+	result := make([]DataPoint, len(dataPoints))
+	for i, dataPoint := range dataPoints {
+		result[i] = dataPoint
+	}
+	return result
 }
 
-func KeyValuesSig(sig *[]byte, kvs []*commonpb.KeyValue, multivariateKey string) {
+func fromHistogramDataPoints(dataPoints []*v1.HistogramDataPoint) []DataPoint {
+	result := make([]DataPoint, len(dataPoints))
+	for i, dataPoint := range dataPoints {
+		result[i] = dataPoint
+	}
+	return result
+}
+
+func fromExpHistogramDataPoints(dataPoints []*v1.ExponentialHistogramDataPoint) []DataPoint {
+	result := make([]DataPoint, len(dataPoints))
+	for i, dataPoint := range dataPoints {
+		result[i] = dataPoint
+	}
+	return result
+}
+
+func fromSummaryDataPoints(dataPoints []*v1.SummaryDataPoint) []DataPoint {
+	result := make([]DataPoint, len(dataPoints))
+	for i, dataPoint := range dataPoints {
+		result[i] = dataPoint
+	}
+	return result
+}
+
+func KeyValuesSig(sig *[]byte, kvs []*commonpb.KeyValue, multivariateKeys ...string) error {
+	for i, kv := range kvs {
+		if kv == nil {
+			return fmt.Errorf("attribute #%d: %w", i, common.ErrNilItem)
+		}
+	}
+
 	// Sort KeyValue slice by key to make the signature deterministic.
 	sort.Sort(KeyValues(kvs))
 
 	for _, kv := range kvs {
-		// Skip the multivariate key.
-		if kv.Key == multivariateKey {
+		// Skip the multivariate keys.
+		if isMultivariateAttribute(kv.Key, multivariateKeys) {
 			continue
 		}
 
@@ -54,12 +106,21 @@ func KeyValuesSig(sig *[]byte, kvs []*commonpb.KeyValue, multivariateKey string)
 		*sig = append(*sig, []byte(kv.Key)...)
 
 		// Serialize attribute value
-		ValueSig(sig, kv.Value)
+		if err := ValueSig(sig, kv.Value); err != nil {
+			return fmt.Errorf("attribute %q: %w", kv.Key, err)
+		}
 	}
+	return nil
 }
 
-func ValueSig(sig *[]byte, value *commonpb.AnyValue) {
-	switch value.Value.(type) {
+func ValueSig(sig *[]byte, value *commonpb.AnyValue) error {
+	if err := common.CheckAnyValueType(value); err != nil {
+		return err
+	}
+
+	switch value.GetValue().(type) {
+	case nil:
+		// Unset value.
 	case *commonpb.AnyValue_BoolValue:
 		*sig = append(*sig, BoolToByte(value.GetBoolValue()))
 	case *commonpb.AnyValue_IntValue:
@@ -81,14 +142,15 @@ func ValueSig(sig *[]byte, value *commonpb.AnyValue) {
 	case *commonpb.AnyValue_StringValue:
 		*sig = append(*sig, []byte(value.GetStringValue())...)
 	case *commonpb.AnyValue_KvlistValue:
-		KeyValuesSig(sig, value.GetKvlistValue().Values, "")
+		return KeyValuesSig(sig, value.GetKvlistValue().GetValues())
 	case *commonpb.AnyValue_ArrayValue:
-		for _, item := range value.GetArrayValue().Values {
-			ValueSig(sig, item)
+		for _, item := range value.GetArrayValue().GetValues() {
+			if err := ValueSig(sig, item); err != nil {
+				return err
+			}
 		}
-	default:
-		panic("unsupported value type")
 	}
+	return nil
 }
 
 func BoolToByte(b bool) byte {
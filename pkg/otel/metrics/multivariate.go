@@ -0,0 +1,401 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// Types of the multivariate attributes recorded in the multivariate key (string attributes have no type suffix).
+const (
+	multivariateString = ""
+	multivariateInt    = "int"
+	multivariateBool   = "bool"
+	// The attribute is not defined in any data point of the row.
+	multivariateNone = "none"
+)
+
+// MultivariateMetricsConfig selects the metrics encoded as multivariate metrics and the attributes used to
+// build them. A metric is selected by its exact name (`Metrics`), then by the first matching rule (`Rules`), then by
+// the automatic detection (`Auto`). The metrics with flags or exemplars are always encoded as univariate metrics (both
+// are not preserved by the multivariate encoding).
+type MultivariateMetricsConfig struct {
+	// Map of metric names to the attribute used to build multivariate metrics.
+	Metrics map[string]string
+	// Rules selecting metrics by name pattern, evaluated in order.
+	Rules []*MultivariateRule
+	// Automatic detection of the multivariate attribute of the remaining metrics (disabled if nil).
+	Auto *MultivariateAutoConfig
+}
+
+// MultivariateRule selects the metrics whose name matches a glob pattern (`path.Match` syntax) or a regular expression,
+// and defines the attributes composing their multivariate key.
+type MultivariateRule struct {
+	Glob       string   `json:"glob,omitempty" yaml:"glob,omitempty"`
+	Regex      string   `json:"regex,omitempty" yaml:"regex,omitempty"`
+	Attributes []string `json:"attributes" yaml:"attributes"`
+}
+
+// MultivariateAutoConfig defines the automatic detection of multivariate attributes. For each metric, the string, int
+// or bool attribute defined in all the data points, with at most `MaxCardinality` distinct values, and folding the
+// data points into the smallest number of rows is selected.
+type MultivariateAutoConfig struct {
+	MaxCardinality int `json:"max_cardinality" yaml:"max_cardinality"`
+}
+
+// Validate checks the consistency of the configuration (patterns, attribute names and cardinality).
+func (c *MultivariateMetricsConfig) Validate() error {
+	_, err := newMultivariateSelector(c)
+	return err
+}
+
+// multivariateSelector returns the multivariate attributes of the metrics of a request.
+type multivariateSelector struct {
+	config *MultivariateMetricsConfig
+	// Compiled regular expression of each rule (nil for glob rules).
+	regexes []*regexp.Regexp
+}
+
+func newMultivariateSelector(config *MultivariateMetricsConfig) (*multivariateSelector, error) {
+	selector := &multivariateSelector{config: config}
+	if config == nil {
+		return selector, nil
+	}
+
+	for metric, attribute := range config.Metrics {
+		if metric == "" {
+			return nil, fmt.Errorf("multivariate: empty metric name")
+		}
+		if err := validateMultivariateAttributes([]string{attribute}); err != nil {
+			return nil, fmt.Errorf("multivariate: metric %q: %w", metric, err)
+		}
+	}
+	for i, rule := range config.Rules {
+		if rule == nil {
+			return nil, fmt.Errorf("multivariate: rule #%d must not be nil", i)
+		}
+		var regex *regexp.Regexp
+		switch {
+		case rule.Glob != "" && rule.Regex != "":
+			return nil, fmt.Errorf("multivariate: rule #%d: glob and regex are mutually exclusive", i)
+		case rule.Glob != "":
+			if _, err := path.Match(rule.Glob, ""); err != nil {
+				return nil, fmt.Errorf("multivariate: rule #%d: invalid glob %q: %w", i, rule.Glob, err)
+			}
+		case rule.Regex != "":
+			var err error
+			if regex, err = regexp.Compile(rule.Regex); err != nil {
+				return nil, fmt.Errorf("multivariate: rule #%d: invalid regex: %w", i, err)
+			}
+		default:
+			return nil, fmt.Errorf("multivariate: rule #%d: glob or regex required", i)
+		}
+		if err := validateMultivariateAttributes(rule.Attributes); err != nil {
+			return nil, fmt.Errorf("multivariate: rule #%d: %w", i, err)
+		}
+		selector.regexes = append(selector.regexes, regex)
+	}
+	if config.Auto != nil && config.Auto.MaxCardinality < 2 {
+		return nil, fmt.Errorf("multivariate: auto: max_cardinality must be >= 2 (got %d)", config.Auto.MaxCardinality)
+	}
+	return selector, nil
+}
+
+// validateMultivariateAttributes checks that the attributes can be recorded in a multivariate key.
+func validateMultivariateAttributes(attributes []string) error {
+	if len(attributes) == 0 {
+		return fmt.Errorf("no attributes")
+	}
+	seen := make(map[string]bool, len(attributes))
+	for _, attribute := range attributes {
+		if !isValidMultivariateAttribute(attribute) {
+			return fmt.Errorf("invalid attribute name %q (must be non-empty without ',' or ':')", attribute)
+		}
+		if seen[attribute] {
+			return fmt.Errorf("duplicate attribute %q", attribute)
+		}
+		seen[attribute] = true
+	}
+	return nil
+}
+
+func isValidMultivariateAttribute(attribute string) bool {
+	return attribute != "" && !strings.ContainsAny(attribute, ",:")
+}
+
+// attributes returns the attributes composing the multivariate key of a metric, or nil if the metric must be encoded
+// as a univariate metric.
+func (s *multivariateSelector) attributes(metricName string, dataPoints []DataPoint) []string {
+	if s.config == nil || !multivariateCompatible(dataPoints) {
+		return nil
+	}
+	if attribute, ok := s.config.Metrics[metricName]; ok {
+		return []string{attribute}
+	}
+	for i, rule := range s.config.Rules {
+		var matched bool
+		if s.regexes[i] != nil {
+			matched = s.regexes[i].MatchString(metricName)
+		} else {
+			// The pattern has been validated by newMultivariateSelector.
+			matched, _ = path.Match(rule.Glob, metricName)
+		}
+		if matched {
+			return rule.Attributes
+		}
+	}
+	if s.config.Auto != nil {
+		if attribute := autoMultivariateAttribute(dataPoints, s.config.Auto.MaxCardinality); attribute != "" {
+			return []string{attribute}
+		}
+	}
+	return nil
+}
+
+// multivariateCompatible returns false if a data point has flags or exemplars (not preserved by the multivariate
+// encoding).
+func multivariateCompatible(dataPoints []DataPoint) bool {
+	for _, dataPoint := range dataPoints {
+		if dataPoint.GetFlags() != 0 {
+			return false
+		}
+		// The summary data points have no exemplars.
+		if withExemplars, ok := dataPoint.(interface{ GetExemplars() []*metricspb.Exemplar }); ok && len(withExemplars.GetExemplars()) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// autoMultivariateAttribute returns the attribute worth pivoting on for a set of data points (see
+// `MultivariateAutoConfig`), or an empty string if there is none.
+func autoMultivariateAttribute(dataPoints []DataPoint, maxCardinality int) string {
+	if len(dataPoints) < 2 {
+		return ""
+	}
+
+	// Candidates: string, int or bool attributes defined in all the data points.
+	counts := make(map[string]int)
+	for _, dataPoint := range dataPoints {
+		for _, attribute := range dataPoint.GetAttributes() {
+			if _, _, ok := formatMultivariateValue(attribute.Value); ok && isValidMultivariateAttribute(attribute.Key) {
+				counts[attribute.Key]++
+			}
+		}
+	}
+	candidates := make([]string, 0, len(counts))
+	for key, count := range counts {
+		if count == len(dataPoints) {
+			candidates = append(candidates, key)
+		}
+	}
+	sort.Strings(candidates)
+
+	best := ""
+	bestRows := len(dataPoints)
+	for _, candidate := range candidates {
+		values := make(map[string]bool)
+		rows := make(map[string]bool)
+		for _, dataPoint := range dataPoints {
+			name, types, _ := multivariateFieldName(dataPoint.GetAttributes(), []string{candidate})
+			values[types[0]+"\x00"+name] = true
+			if len(values) > maxCardinality {
+				break
+			}
+			sig, err := DataPointSig(dataPoint, candidate)
+			if err != nil {
+				// Invalid attributes are reported by the converter.
+				return ""
+			}
+			rows[string(sig)] = true
+		}
+		if len(values) > maxCardinality || len(values) < 2 {
+			continue
+		}
+		if len(rows) < bestRows {
+			best = candidate
+			bestRows = len(rows)
+		}
+	}
+	return best
+}
+
+// formatMultivariateValue returns the type and the deterministic string representation of a multivariate attribute
+// value. Only string, int and bool values are supported.
+func formatMultivariateValue(value *commonpb.AnyValue) (valueType string, formatted string, ok bool) {
+	switch t := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return multivariateString, t.StringValue, true
+	case *commonpb.AnyValue_IntValue:
+		return multivariateInt, strconv.FormatInt(t.IntValue, 10), true
+	case *commonpb.AnyValue_BoolValue:
+		return multivariateBool, strconv.FormatBool(t.BoolValue), true
+	default:
+		return "", "", false
+	}
+}
+
+// multivariateFieldName returns the name of the field containing the value of a data point in a multivariate metric
+// column, and the types of the multivariate attributes of the data point (`multivariateNone` for the undefined
+// attributes).
+//
+// The name is the comma-separated list of the formatted attribute values. Commas and backslashes are escaped with a
+// backslash, an undefined attribute is represented by an empty value and an empty string by `\e`.
+func multivariateFieldName(attributes []*commonpb.KeyValue, keys []string) (name string, types []string, err error) {
+	values := make([]string, len(keys))
+	types = make([]string, len(keys))
+	for i, k := range keys {
+		types[i] = multivariateNone
+		for _, attribute := range attributes {
+			if attribute.Key == k && attribute.Value != nil {
+				var value string
+				var ok bool
+				if types[i], value, ok = formatMultivariateValue(attribute.Value); !ok {
+					return "", nil, fmt.Errorf("unsupported multivariate value type: %v", attribute.Value)
+				}
+				values[i] = escapeMultivariateValue(value)
+				break
+			}
+		}
+	}
+	return strings.Join(values, ","), types, nil
+}
+
+// multivariateKey returns the multivariate key recorded in the `multivariate_key` column of a row: the comma-separated
+// list of the multivariate attributes, each followed by `:int`, `:bool` or `:none` for the non-string attributes.
+func multivariateKey(keys []string, types []string) string {
+	specs := make([]string, len(keys))
+	for i, k := range keys {
+		specs[i] = k
+		if types[i] != multivariateString {
+			specs[i] += ":" + types[i]
+		}
+	}
+	return strings.Join(specs, ",")
+}
+
+// mergeMultivariateTypes merges the types of the multivariate attributes of a data point into the types of a row.
+// False is returned if the types are not compatible, i.e. if an attribute has different types.
+func mergeMultivariateTypes(rowTypes []string, types []string) bool {
+	for i := range types {
+		if types[i] != multivariateNone && rowTypes[i] != multivariateNone && types[i] != rowTypes[i] {
+			return false
+		}
+	}
+	for i := range types {
+		if types[i] != multivariateNone {
+			rowTypes[i] = types[i]
+		}
+	}
+	return true
+}
+
+// multivariateAttributes rebuilds the multivariate attributes of a data point from the name of its field and the
+// multivariate key (see `multivariateFieldName` and `multivariateKey`).
+func multivariateAttributes(name string, key string) ([]*commonpb.KeyValue, error) {
+	specs := strings.Split(key, ",")
+	values := splitMultivariateValues(name)
+	if len(values) != len(specs) {
+		return nil, fmt.Errorf("field %q: expected %d values, got %d", name, len(specs), len(values))
+	}
+
+	attributes := make([]*commonpb.KeyValue, 0, len(specs))
+	for i, spec := range specs {
+		if values[i] == "" {
+			// Undefined attribute.
+			continue
+		}
+		value := unescapeMultivariateValue(values[i])
+		k, valueType := spec, multivariateString
+		if idx := strings.IndexByte(spec, ':'); idx >= 0 {
+			k, valueType = spec[:idx], spec[idx+1:]
+		}
+		var anyValue *commonpb.AnyValue
+		switch valueType {
+		case multivariateString:
+			anyValue = &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}
+		case multivariateInt:
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", name, err)
+			}
+			anyValue = &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}
+		case multivariateBool:
+			v, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", name, err)
+			}
+			anyValue = &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+		default:
+			return nil, fmt.Errorf("field %q: unexpected multivariate type %q", name, valueType)
+		}
+		attributes = append(attributes, &commonpb.KeyValue{Key: k, Value: anyValue})
+	}
+	return attributes, nil
+}
+
+func escapeMultivariateValue(value string) string {
+	if value == "" {
+		return `\e`
+	}
+	if !strings.ContainsAny(value, `,\`) {
+		return value
+	}
+	return strings.NewReplacer(`\`, `\\`, `,`, `\,`).Replace(value)
+}
+
+func unescapeMultivariateValue(value string) string {
+	if value == `\e` {
+		return ""
+	}
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	return strings.NewReplacer(`\\`, `\`, `\,`, `,`).Replace(value)
+}
+
+// splitMultivariateValues splits a field name on the unescaped commas (the values stay escaped).
+func splitMultivariateValues(name string) []string {
+	var values []string
+	start := 0
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, name[start:i])
+			start = i + 1
+		}
+	}
+	return append(values, name[start:])
+}
+
+// isMultivariateAttribute returns true if the attribute is one of the multivariate attributes.
+func isMultivariateAttribute(key string, keys []string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
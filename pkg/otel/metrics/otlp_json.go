@@ -0,0 +1,43 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"github.com/apache/arrow/go/v9/arrow"
+
+	colmetricspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"otel-arrow-adapter/pkg/air"
+	"otel-arrow-adapter/pkg/otel/common"
+)
+
+// OtlpJsonMetricsToArrowRecords converts an OTLP/JSON ExportMetricsServiceRequest (see `common.UnmarshalOtlpJson`) to
+// Arrow records (see `OtlpMetricsToArrowRecords`).
+func OtlpJsonMetricsToArrowRecords(rr *air.RecordRepository, data []byte, multivariateConf *MultivariateMetricsConfig) (map[string][]arrow.Record, *common.PartialSuccess, error) {
+	request := &colmetricspb.ExportMetricsServiceRequest{}
+	if err := common.UnmarshalOtlpJson(data, request); err != nil {
+		return nil, nil, err
+	}
+	return OtlpMetricsToArrowRecords(rr, request, multivariateConf)
+}
+
+// ArrowRecordsToOtlpJsonMetrics converts the Arrow records produced by `OtlpMetricsToArrowRecords` to an OTLP/JSON
+// ExportMetricsServiceRequest (see `common.MarshalOtlpJson`).
+func ArrowRecordsToOtlpJsonMetrics(records []arrow.Record) ([]byte, error) {
+	request, err := ArrowRecordsToOtlpMetrics(records)
+	if err != nil {
+		return nil, err
+	}
+	return common.MarshalOtlpJson(request)
+}
@@ -28,56 +28,73 @@ import (
 	"github.com/apache/arrow/go/v9/arrow"
 )
 
-type MultivariateMetricsConfig struct {
-	Metrics map[string]string
-}
-
 type MultivariateRecord struct {
 	fields  []*rfield.Field
 	metrics []*rfield.Field
+	// Types of the multivariate attributes of the row (see `mergeMultivariateTypes`).
+	types []string
 }
 
 // OtlpMetricsToArrowRecords converts an OTLP ResourceMetrics to one or more Arrow records.
-func OtlpMetricsToArrowRecords(rr *air.RecordRepository, request *collogspb.ExportMetricsServiceRequest, multivariateConf *MultivariateMetricsConfig) (map[string][]arrow.Record, error) {
+//
+// The invalid metrics (e.g. of an unknown type or with a data point value of an unsupported type) are skipped and
+// reported by the returned `*common.PartialSuccess` (nil if all the metrics have been converted) with the number of
+// rejected data points, the records of the other metrics being returned. The records are only nil if an error is
+// returned (e.g. an invalid multivariate configuration).
+func OtlpMetricsToArrowRecords(rr *air.RecordRepository, request *collogspb.ExportMetricsServiceRequest, multivariateConf *MultivariateMetricsConfig) (map[string][]arrow.Record, *common.PartialSuccess, error) {
+	return otlpMetricsToArrowRecords(rr, nil, request, multivariateConf)
+}
+
+// OtlpMetricsToArrowRecordsWithOptions is similar to `OtlpMetricsToArrowRecords` but uses the optional encodings
+// selected by `opts` (see `common.EncodingOptions`).
+func OtlpMetricsToArrowRecordsWithOptions(rr *air.RecordRepository, opts *common.EncodingOptions, request *collogspb.ExportMetricsServiceRequest, multivariateConf *MultivariateMetricsConfig) (map[string][]arrow.Record, *common.PartialSuccess, error) {
+	return otlpMetricsToArrowRecords(rr, opts, request, multivariateConf)
+}
+
+func otlpMetricsToArrowRecords(rr *air.RecordRepository, opts *common.EncodingOptions, request *collogspb.ExportMetricsServiceRequest, multivariateConf *MultivariateMetricsConfig) (map[string][]arrow.Record, *common.PartialSuccess, error) {
+	selector, err := newMultivariateSelector(multivariateConf)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	result := make(map[string][]arrow.Record)
-	for _, resourceMetrics := range request.ResourceMetrics {
-		for _, scopeMetrics := range resourceMetrics.ScopeMetrics {
-			for _, metric := range scopeMetrics.Metrics {
-				if metric.Data != nil {
-					switch t := metric.Data.(type) {
-					case *metricspb.Metric_Gauge:
-						err := addGaugeOrSum(rr, resourceMetrics, scopeMetrics, metric.Name, t.Gauge.DataPoints, constants.GAUGE_METRICS, multivariateConf)
-						if err != nil {
-							return nil, err
-						}
-					case *metricspb.Metric_Sum:
-						err := addGaugeOrSum(rr, resourceMetrics, scopeMetrics, metric.Name, t.Sum.DataPoints, constants.SUM_METRICS, multivariateConf)
-						if err != nil {
-							return nil, err
-						}
-					case *metricspb.Metric_Histogram:
-						err := addHistogram(rr, resourceMetrics, scopeMetrics, metric.Name, t.Histogram)
-						if err != nil {
-							return nil, err
-						}
-					case *metricspb.Metric_Summary:
-						err := addSummary(rr, resourceMetrics, scopeMetrics, metric.Name, t.Summary)
-						if err != nil {
-							return nil, err
-						}
-					case *metricspb.Metric_ExponentialHistogram:
-						err := addExpHistogram(rr, resourceMetrics, scopeMetrics, metric.Name, t.ExponentialHistogram)
-						if err != nil {
-							return nil, err
-						}
-					default:
-						panic(fmt.Sprintf("Unsupported metric type: %v", metric.Data))
-					}
+	rejected := &common.PartialSuccess{}
+	for resourceIdx, resourceMetrics := range request.ResourceMetrics {
+		if err := common.ValidateResource(resourceMetrics.GetResource()); err != nil {
+			rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: -1, Item: -1, DataPoint: -1, Err: err}, countDataPoints(resourceMetrics.GetScopeMetrics()...))
+			continue
+		}
+		for scopeIdx, scopeMetrics := range resourceMetrics.GetScopeMetrics() {
+			if err := common.ValidateScope(scopeMetrics.GetScope()); err != nil {
+				rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: scopeIdx, Item: -1, DataPoint: -1, Err: err}, countDataPoints(scopeMetrics))
+				continue
+			}
+			for metricIdx, metric := range scopeMetrics.GetMetrics() {
+				if dataPointIdx, err := validateMetric(metric); err != nil {
+					rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: scopeIdx, Item: metricIdx, DataPoint: dataPointIdx, Err: err}, metricDataPointCount(metric))
+					continue
+				}
+
+				var err error
+				switch t := metric.Data.(type) {
+				case *metricspb.Metric_Gauge:
+					err = addGaugeOrSum(rr, opts, resourceMetrics, scopeMetrics, metric, t.Gauge.DataPoints, constants.GAUGE_METRICS, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED, false, selector)
+				case *metricspb.Metric_Sum:
+					err = addGaugeOrSum(rr, opts, resourceMetrics, scopeMetrics, metric, t.Sum.DataPoints, constants.SUM_METRICS, t.Sum.AggregationTemporality, t.Sum.IsMonotonic, selector)
+				case *metricspb.Metric_Histogram:
+					err = addHistogram(rr, opts, resourceMetrics, scopeMetrics, metric, t.Histogram, selector)
+				case *metricspb.Metric_Summary:
+					err = addSummary(rr, opts, resourceMetrics, scopeMetrics, metric, t.Summary, selector)
+				case *metricspb.Metric_ExponentialHistogram:
+					err = addExpHistogram(rr, opts, resourceMetrics, scopeMetrics, metric, t.ExponentialHistogram, selector)
+				}
+				if err != nil {
+					rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: scopeIdx, Item: metricIdx, DataPoint: -1, Err: err}, metricDataPointCount(metric))
 				}
 			}
 			records, err := rr.Build()
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			for schemaId, record := range records {
 				allRecords := result[schemaId]
@@ -85,99 +102,339 @@ func OtlpMetricsToArrowRecords(rr *air.RecordRepository, request *collogspb.Expo
 			}
 		}
 	}
-	return result, nil
+	return result, rejected.OrNil(), nil
+}
+
+// itemKind is the kind of the items reported in the `common.ItemError`s.
+const itemKind = "metric"
+
+// validateMetric checks the type of a metric and the values and attributes of its data points. A metric without data
+// is valid (it is ignored by the converters). The index of the invalid data point is returned with the error (-1 if the
+// error concerns the whole metric).
+func validateMetric(metric *metricspb.Metric) (int, error) {
+	if metric == nil {
+		return -1, common.ErrNilItem
+	}
+
+	var dataPoints []DataPoint
+	switch t := metric.Data.(type) {
+	case nil:
+		return -1, nil
+	case *metricspb.Metric_Gauge:
+		if t == nil || t.Gauge == nil {
+			return -1, fmt.Errorf("%w: nil gauge", common.ErrUnsupportedMetricType)
+		}
+		for i, dataPoint := range t.Gauge.DataPoints {
+			if err := validateNumberDataPoint(dataPoint); err != nil {
+				return i, err
+			}
+		}
+		return -1, nil
+	case *metricspb.Metric_Sum:
+		if t == nil || t.Sum == nil {
+			return -1, fmt.Errorf("%w: nil sum", common.ErrUnsupportedMetricType)
+		}
+		for i, dataPoint := range t.Sum.DataPoints {
+			if err := validateNumberDataPoint(dataPoint); err != nil {
+				return i, err
+			}
+		}
+		return -1, nil
+	case *metricspb.Metric_Histogram:
+		if t == nil || t.Histogram == nil {
+			return -1, fmt.Errorf("%w: nil histogram", common.ErrUnsupportedMetricType)
+		}
+		for i, dataPoint := range t.Histogram.DataPoints {
+			if dataPoint == nil {
+				return i, common.ErrNilItem
+			}
+			if err := validateExemplars(dataPoint.Exemplars); err != nil {
+				return i, err
+			}
+		}
+		dataPoints = fromHistogramDataPoints(t.Histogram.DataPoints)
+	case *metricspb.Metric_ExponentialHistogram:
+		if t == nil || t.ExponentialHistogram == nil {
+			return -1, fmt.Errorf("%w: nil exponential histogram", common.ErrUnsupportedMetricType)
+		}
+		for i, dataPoint := range t.ExponentialHistogram.DataPoints {
+			if dataPoint == nil {
+				return i, common.ErrNilItem
+			}
+			if err := validateExemplars(dataPoint.Exemplars); err != nil {
+				return i, err
+			}
+		}
+		dataPoints = fromExpHistogramDataPoints(t.ExponentialHistogram.DataPoints)
+	case *metricspb.Metric_Summary:
+		if t == nil || t.Summary == nil {
+			return -1, fmt.Errorf("%w: nil summary", common.ErrUnsupportedMetricType)
+		}
+		for i, dataPoint := range t.Summary.DataPoints {
+			if dataPoint == nil {
+				return i, common.ErrNilItem
+			}
+			for j, quantile := range dataPoint.QuantileValues {
+				if quantile == nil {
+					return i, fmt.Errorf("quantile #%d: %w", j, common.ErrNilItem)
+				}
+			}
+		}
+		dataPoints = fromSummaryDataPoints(t.Summary.DataPoints)
+	default:
+		return -1, fmt.Errorf("%w: %T", common.ErrUnsupportedMetricType, t)
+	}
+
+	for i, dataPoint := range dataPoints {
+		if err := common.ValidateKeyValues(dataPoint.GetAttributes()); err != nil {
+			return i, err
+		}
+	}
+	return -1, nil
+}
+
+// validateNumberDataPoint checks the value, the attributes and the exemplars of a number data point (a data point
+// without value is valid).
+func validateNumberDataPoint(dataPoint *metricspb.NumberDataPoint) error {
+	if dataPoint == nil {
+		return common.ErrNilItem
+	}
+	switch t := dataPoint.Value.(type) {
+	case nil:
+	case *metricspb.NumberDataPoint_AsDouble:
+		if t == nil {
+			return fmt.Errorf("%w: nil %T", common.ErrUnsupportedValue, t)
+		}
+	case *metricspb.NumberDataPoint_AsInt:
+		if t == nil {
+			return fmt.Errorf("%w: nil %T", common.ErrUnsupportedValue, t)
+		}
+	default:
+		return fmt.Errorf("%w: %T", common.ErrUnsupportedValue, t)
+	}
+	if err := common.ValidateKeyValues(dataPoint.Attributes); err != nil {
+		return err
+	}
+	return validateExemplars(dataPoint.Exemplars)
 }
 
-func addGaugeOrSum(rr *air.RecordRepository, resMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, metricName string, dataPoints []*metricspb.NumberDataPoint, metric_type string, config *MultivariateMetricsConfig) error {
-	if mvKey, ok := config.Metrics[metricName]; ok {
-		return multivariateMetric(rr, resMetrics, scopeMetrics, metricName, dataPoints, metric_type, mvKey)
+// validateExemplars checks the values and the filtered attributes of the exemplars of a data point.
+func validateExemplars(exemplars []*metricspb.Exemplar) error {
+	for i, exemplar := range exemplars {
+		if exemplar == nil {
+			return fmt.Errorf("exemplar #%d: %w", i, common.ErrNilItem)
+		}
+		isNil := false
+		switch t := exemplar.Value.(type) {
+		case *metricspb.Exemplar_AsDouble:
+			isNil = t == nil
+		case *metricspb.Exemplar_AsInt:
+			isNil = t == nil
+		}
+		if isNil {
+			return fmt.Errorf("exemplar #%d: %w: nil %T", i, common.ErrUnsupportedValue, exemplar.Value)
+		}
+		if err := common.ValidateKeyValues(exemplar.FilteredAttributes); err != nil {
+			return fmt.Errorf("exemplar #%d: %w", i, err)
+		}
 	}
-	univariateMetric(rr, resMetrics, scopeMetrics, metricName, dataPoints, metric_type)
 	return nil
 }
 
-// ToDo initial metric name is lost, it should be recorded as metadata or constant column
-func multivariateMetric(rr *air.RecordRepository, resMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, metricName string, dataPoints []*metricspb.NumberDataPoint, metric_type string, multivariateKey string) error {
-	records := make(map[string]*MultivariateRecord)
+// metricDataPointCount returns the number of data points of a metric, a metric without data points (e.g. of an
+// unknown type) counting as one.
+func metricDataPointCount(metric *metricspb.Metric) int {
+	count := 0
+	switch t := metric.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		if t != nil {
+			count = len(t.Gauge.GetDataPoints())
+		}
+	case *metricspb.Metric_Sum:
+		if t != nil {
+			count = len(t.Sum.GetDataPoints())
+		}
+	case *metricspb.Metric_Histogram:
+		if t != nil {
+			count = len(t.Histogram.GetDataPoints())
+		}
+	case *metricspb.Metric_ExponentialHistogram:
+		if t != nil {
+			count = len(t.ExponentialHistogram.GetDataPoints())
+		}
+	case *metricspb.Metric_Summary:
+		if t != nil {
+			count = len(t.Summary.GetDataPoints())
+		}
+	}
+	if count == 0 {
+		return 1
+	}
+	return count
+}
+
+// countDataPoints returns the number of data points of a list of ScopeMetrics.
+func countDataPoints(scopeMetrics ...*metricspb.ScopeMetrics) int {
+	count := 0
+	for _, sm := range scopeMetrics {
+		for _, metric := range sm.GetMetrics() {
+			count += metricDataPointCount(metric)
+		}
+	}
+	return count
+}
+
+func addGaugeOrSum(rr *air.RecordRepository, opts *common.EncodingOptions, resMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, metric *metricspb.Metric, dataPoints []*metricspb.NumberDataPoint, metric_type string, temporality metricspb.AggregationTemporality, isMonotonic bool, selector *multivariateSelector) error {
+	if multivariateKeys := selector.attributes(metric.Name, fromNumberDataPoints(dataPoints)); multivariateKeys != nil {
+		return multivariateMetric(rr, opts, resMetrics, scopeMetrics, metric, fromNumberDataPoints(dataPoints), metric_type, temporality, isMonotonic, multivariateKeys, numberValueField)
+	}
+	return univariateMetric(rr, opts, resMetrics, scopeMetrics, metric, dataPoints, metric_type, temporality, isMonotonic)
+}
+
+// numberValueField returns the field containing the int or double value of a number data point.
+func numberValueField(dataPoint DataPoint, name string) (*rfield.Field, error) {
+	switch t := dataPoint.(*metricspb.NumberDataPoint).Value.(type) {
+	case *metricspb.NumberDataPoint_AsDouble:
+		if t != nil {
+			return rfield.NewF64Field(name, t.AsDouble), nil
+		}
+	case *metricspb.NumberDataPoint_AsInt:
+		if t != nil {
+			return rfield.NewI64Field(name, t.AsInt), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %T", common.ErrUnsupportedValue, dataPoint.(*metricspb.NumberDataPoint).Value)
+}
+
+// metricMetadataFields returns the fields describing a metric, i.e. its description and unit (omitted when empty).
+// These string columns are dictionary-encoded like the other string columns.
+func metricMetadataFields(metric *metricspb.Metric) []*rfield.Field {
+	var fields []*rfield.Field
+	if len(metric.Description) > 0 {
+		fields = append(fields, rfield.NewStringField(constants.DESCRIPTION, metric.Description))
+	}
+	if len(metric.Unit) > 0 {
+		fields = append(fields, rfield.NewStringField(constants.UNIT, metric.Unit))
+	}
+	return fields
+}
+
+// aggregationFields returns the fields describing the aggregation of a sum or a histogram. The aggregation temporality
+// and the monotonicity are omitted when they have their default value (i.e. unspecified and false).
+func aggregationFields(temporality metricspb.AggregationTemporality, isMonotonic bool) []*rfield.Field {
+	var fields []*rfield.Field
+	if temporality != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED {
+		fields = append(fields, rfield.NewI32Field(constants.AGGREGATION_TEMPORALITY, int32(temporality)))
+	}
+	if isMonotonic {
+		fields = append(fields, rfield.NewBoolField(constants.IS_MONOTONIC, isMonotonic))
+	}
+	return fields
+}
+
+// multivariateMetric folds the data points sharing the same timestamps and attributes (except the multivariate
+// attributes) into a single row. The metric name is kept in the name of the metric column, each combination of values
+// of the multivariate attributes becomes a field of this column (built by `valueField`, e.g. a number for the gauges
+// and sums, a struct for the histograms and summaries), and the multivariate attributes are recorded in the
+// constant column `multivariate_key` so `ArrowRecordsToOtlpMetrics` can rebuild the original data points (see
+// `multivariateFieldName`).
+//
+// Note: the flags and the exemplars of the data points are not preserved.
+func multivariateMetric(rr *air.RecordRepository, opts *common.EncodingOptions, resMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, metric *metricspb.Metric, dataPoints []DataPoint, metric_type string, temporality metricspb.AggregationTemporality, isMonotonic bool, multivariateKeys []string, valueField func(dataPoint DataPoint, name string) (*rfield.Field, error)) error {
+	records := make(map[string][]*MultivariateRecord)
+	var rows []*MultivariateRecord
 
 	for _, ndp := range dataPoints {
-		sig := DataPointSig(ndp, multivariateKey)
+		multivariateMetricName, types, err := multivariateFieldName(ndp.GetAttributes(), multivariateKeys)
+		if err != nil {
+			return err
+		}
+		dataPointSig, err := DataPointSig(ndp, multivariateKeys...)
+		if err != nil {
+			return err
+		}
+		sig := string(dataPointSig)
 		newEntry := false
-		stringSig := string(sig)
-		record := records[stringSig]
-
-		var multivariateMetricName *string
+		var record *MultivariateRecord
+
+		// The data points of a row must have compatible multivariate attribute types (e.g. an attribute can't be an
+		// int in a data point and a string in another one).
+		for _, candidate := range records[sig] {
+			if mergeMultivariateTypes(candidate.types, types) {
+				record = candidate
+				break
+			}
+		}
 
 		if record == nil {
 			newEntry = true
 			record = &MultivariateRecord{
 				fields:  []*rfield.Field{},
 				metrics: []*rfield.Field{},
+				types:   types,
 			}
-			records[stringSig] = record
+			records[sig] = append(records[sig], record)
+			rows = append(rows, record)
 		}
 
 		if newEntry {
-			if resMetrics.Resource != nil {
-				record.fields = append(record.fields, common.ResourceField(resMetrics.Resource))
+			resourceField, err := opts.Refs().ResourceField(resMetrics.Resource, resMetrics.SchemaUrl)
+			if err != nil {
+				return err
 			}
-			if scopeMetrics.Scope != nil {
-				record.fields = append(record.fields, common.ScopeField(constants.SCOPE_METRICS, scopeMetrics.Scope))
+			if resourceField != nil {
+				record.fields = append(record.fields, resourceField)
 			}
-			timeUnixNanoField := rfield.NewU64Field(constants.TIME_UNIX_NANO, ndp.TimeUnixNano)
+			if scopeMetrics.Scope != nil || scopeMetrics.SchemaUrl != "" {
+				scopeField, err := opts.Refs().ScopeField(constants.SCOPE_METRICS, scopeMetrics.Scope, scopeMetrics.SchemaUrl)
+				if err != nil {
+					return err
+				}
+				record.fields = append(record.fields, scopeField)
+			}
+			record.fields = append(record.fields, metricMetadataFields(metric)...)
+			timeUnixNanoField := rfield.NewU64Field(constants.TIME_UNIX_NANO, ndp.GetTimeUnixNano())
 			record.fields = append(record.fields, timeUnixNanoField)
-			if ndp.StartTimeUnixNano > 0 {
-				startTimeUnixNano := rfield.NewU64Field(constants.START_TIME_UNIX_NANO, ndp.StartTimeUnixNano)
+			record.fields = append(record.fields, aggregationFields(temporality, isMonotonic)...)
+			if ndp.GetStartTimeUnixNano() > 0 {
+				startTimeUnixNano := rfield.NewU64Field(constants.START_TIME_UNIX_NANO, ndp.GetStartTimeUnixNano())
 				record.fields = append(record.fields, startTimeUnixNano)
 			}
-			ma, err := AddMultivariateValue(ndp.Attributes, multivariateKey, &record.fields)
-			if err != nil {
-				return err
-			}
-			multivariateMetricName = ma
-		} else {
-			ma, err := ExtractMultivariateValue(ndp.Attributes, multivariateKey)
-			if err != nil {
-				return err
-			}
-			multivariateMetricName = ma
+			addMultivariateAttributes(ndp.GetAttributes(), multivariateKeys, &record.fields)
 		}
 
-		if multivariateMetricName == nil {
-			multivariateMetricName = new(string)
-		}
-
-		switch t := ndp.Value.(type) {
-		case *metricspb.NumberDataPoint_AsDouble:
-			record.metrics = append(record.metrics, rfield.NewF64Field(*multivariateMetricName, t.AsDouble))
-		case *metricspb.NumberDataPoint_AsInt:
-			record.metrics = append(record.metrics, rfield.NewI64Field(*multivariateMetricName, t.AsInt))
-		default:
-			panic("Unsupported number data point value type")
+		field, err := valueField(ndp, multivariateMetricName)
+		if err != nil {
+			return err
 		}
+		record.metrics = append(record.metrics, field)
 	}
 
-	for _, record := range records {
+	var rowRecords []*air.Record
+	for _, record := range rows {
 		if len(record.fields) == 0 && len(record.metrics) == 0 {
 			continue
 		}
-		record.fields = append(record.fields, rfield.NewStructField(fmt.Sprintf("%s_%s", metric_type, metricName), rfield.Struct{
+		record.fields = append(record.fields, rfield.NewStringField(constants.MULTIVARIATE_KEY, multivariateKey(multivariateKeys, record.types)))
+		record.fields = append(record.fields, rfield.NewStructField(fmt.Sprintf("%s_%s", metric_type, metric.Name), rfield.Struct{
 			Fields: record.metrics,
 		}))
-		rr.AddRecord(air.NewRecordFromFields(record.fields))
+		rowRecords = append(rowRecords, air.NewRecordFromFields(record.fields))
 	}
-	return nil
+	return opts.AddRecords(rr, rowRecords)
 }
 
-func univariateMetric(rr *air.RecordRepository, resMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, metricName string, dataPoints []*metricspb.NumberDataPoint, metric_type string) {
+func univariateMetric(rr *air.RecordRepository, opts *common.EncodingOptions, resMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, metric *metricspb.Metric, dataPoints []*metricspb.NumberDataPoint, metric_type string, temporality metricspb.AggregationTemporality, isMonotonic bool) error {
+	var records []*air.Record
 	for _, ndp := range dataPoints {
 		record := air.NewRecord()
 
-		if resMetrics.Resource != nil {
-			common.AddResource(record, resMetrics.Resource)
+		// Same resource and scope for all the data points, an error is returned before adding any record.
+		if err := addResourceAndScope(record, opts, resMetrics, scopeMetrics); err != nil {
+			return err
 		}
-		if scopeMetrics.Scope != nil {
-			common.AddScope(record, constants.SCOPE_METRICS, scopeMetrics.Scope)
+		for _, field := range metricMetadataFields(metric) {
+			record.AddField(field)
 		}
 
 		record.U64Field(constants.TIME_UNIX_NANO, ndp.TimeUnixNano)
@@ -190,43 +447,47 @@ func univariateMetric(rr *air.RecordRepository, resMetrics *metricspb.ResourceMe
 		}
 
 		if ndp.Value != nil {
-			switch t := ndp.Value.(type) {
-			case *metricspb.NumberDataPoint_AsDouble:
-				record.StructField(fmt.Sprintf("%s_%s", metric_type, metricName), rfield.Struct{
-					Fields: []*rfield.Field{
-						rfield.NewF64Field(constants.METRIC_VALUE, t.AsDouble),
-					},
-				})
-			case *metricspb.NumberDataPoint_AsInt:
-				record.StructField(fmt.Sprintf("%s_%s", metric_type, metricName), rfield.Struct{
-					Fields: []*rfield.Field{
-						rfield.NewI64Field(constants.METRIC_VALUE, t.AsInt),
-					},
-				})
-			default:
-				panic("Unsupported number data point value type")
+			field, err := numberValueField(ndp, constants.METRIC_VALUE)
+			if err != nil {
+				return err
 			}
+			record.StructField(fmt.Sprintf("%s_%s", metric_type, metric.Name), rfield.Struct{
+				Fields: []*rfield.Field{field},
+			})
 		}
 
-		// ToDo Exemplar
+		AddExemplars(record, ndp.Exemplars)
 
 		if ndp.Flags > 0 {
 			record.U32Field(constants.FLAGS, ndp.Flags)
 		}
 
-		rr.AddRecord(record)
+		for _, field := range aggregationFields(temporality, isMonotonic) {
+			record.AddField(field)
+		}
+
+		records = append(records, record)
 	}
+	return opts.AddRecords(rr, records)
 }
 
-func addSummary(rr *air.RecordRepository, resMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, metricName string, summary *metricspb.Summary) error {
+func addSummary(rr *air.RecordRepository, opts *common.EncodingOptions, resMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, metric *metricspb.Metric, summary *metricspb.Summary, selector *multivariateSelector) error {
+	if multivariateKeys := selector.attributes(metric.Name, fromSummaryDataPoints(summary.DataPoints)); multivariateKeys != nil {
+		return multivariateMetric(rr, opts, resMetrics, scopeMetrics, metric, fromSummaryDataPoints(summary.DataPoints), constants.SUMMARY_METRICS, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED, false, multivariateKeys, func(dataPoint DataPoint, name string) (*rfield.Field, error) {
+			return rfield.NewStructField(name, rfield.Struct{Fields: summaryFields(dataPoint.(*metricspb.SummaryDataPoint))}), nil
+		})
+	}
+
+	var records []*air.Record
 	for _, sdp := range summary.DataPoints {
 		record := air.NewRecord()
 
-		if resMetrics.Resource != nil {
-			common.AddResource(record, resMetrics.Resource)
+		// Same resource and scope for all the data points, an error is returned before adding any record.
+		if err := addResourceAndScope(record, opts, resMetrics, scopeMetrics); err != nil {
+			return err
 		}
-		if scopeMetrics.Scope != nil {
-			common.AddScope(record, constants.SCOPE_METRICS, scopeMetrics.Scope)
+		for _, field := range metricMetadataFields(metric) {
+			record.AddField(field)
 		}
 
 		record.U64Field(constants.TIME_UNIX_NANO, sdp.TimeUnixNano)
@@ -238,42 +499,54 @@ func addSummary(rr *air.RecordRepository, resMetrics *metricspb.ResourceMetrics,
 			record.AddField(attributes)
 		}
 
-		var summaryFields []*rfield.Field
-
-		summaryFields = append(summaryFields, rfield.NewU64Field(constants.SUMMARY_COUNT, sdp.Count))
-		summaryFields = append(summaryFields, rfield.NewF64Field(constants.SUMMARY_SUM, sdp.Sum))
-
-		var items []rfield.Value
-		for _, quantile := range sdp.QuantileValues {
-			items = append(items, &rfield.Struct{
-				Fields: []*rfield.Field{
-					rfield.NewF64Field(constants.SUMMARY_QUANTILE, quantile.Quantile),
-					rfield.NewF64Field(constants.SUMMARY_VALUE, quantile.Value),
-				},
-			})
-		}
-		summaryFields = append(summaryFields, rfield.NewListField(constants.SUMMARY_QUANTILE_VALUES, rfield.List{Values: items}))
-
-		record.StructField(fmt.Sprintf("%s_%s", constants.SUMMARY_METRICS, metricName), rfield.Struct{Fields: summaryFields})
+		record.StructField(fmt.Sprintf("%s_%s", constants.SUMMARY_METRICS, metric.Name), rfield.Struct{Fields: summaryFields(sdp)})
 
 		if sdp.Flags > 0 {
 			record.U32Field(constants.FLAGS, sdp.Flags)
 		}
 
-		rr.AddRecord(record)
+		records = append(records, record)
 	}
-	return nil
+	return opts.AddRecords(rr, records)
+}
+
+// summaryFields returns the fields of the summary struct (count, sum and quantiles).
+func summaryFields(sdp *metricspb.SummaryDataPoint) []*rfield.Field {
+	var summaryFields []*rfield.Field
+
+	summaryFields = append(summaryFields, rfield.NewU64Field(constants.SUMMARY_COUNT, sdp.Count))
+	summaryFields = append(summaryFields, rfield.NewF64Field(constants.SUMMARY_SUM, sdp.Sum))
+
+	var items []rfield.Value
+	for _, quantile := range sdp.QuantileValues {
+		items = append(items, &rfield.Struct{
+			Fields: []*rfield.Field{
+				rfield.NewF64Field(constants.SUMMARY_QUANTILE, quantile.Quantile),
+				rfield.NewF64Field(constants.SUMMARY_VALUE, quantile.Value),
+			},
+		})
+	}
+	summaryFields = append(summaryFields, rfield.NewListField(constants.SUMMARY_QUANTILE_VALUES, rfield.List{Values: items}))
+	return summaryFields
 }
 
-func addHistogram(rr *air.RecordRepository, resMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, metricName string, histogram *metricspb.Histogram) error {
+func addHistogram(rr *air.RecordRepository, opts *common.EncodingOptions, resMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, metric *metricspb.Metric, histogram *metricspb.Histogram, selector *multivariateSelector) error {
+	if multivariateKeys := selector.attributes(metric.Name, fromHistogramDataPoints(histogram.DataPoints)); multivariateKeys != nil {
+		return multivariateMetric(rr, opts, resMetrics, scopeMetrics, metric, fromHistogramDataPoints(histogram.DataPoints), constants.HISTOGRAM, histogram.AggregationTemporality, false, multivariateKeys, func(dataPoint DataPoint, name string) (*rfield.Field, error) {
+			return rfield.NewStructField(name, rfield.Struct{Fields: histogramFields(dataPoint.(*metricspb.HistogramDataPoint))}), nil
+		})
+	}
+
+	var records []*air.Record
 	for _, sdp := range histogram.DataPoints {
 		record := air.NewRecord()
 
-		if resMetrics.Resource != nil {
-			common.AddResource(record, resMetrics.Resource)
+		// Same resource and scope for all the data points, an error is returned before adding any record.
+		if err := addResourceAndScope(record, opts, resMetrics, scopeMetrics); err != nil {
+			return err
 		}
-		if scopeMetrics.Scope != nil {
-			common.AddScope(record, constants.SCOPE_METRICS, scopeMetrics.Scope)
+		for _, field := range metricMetadataFields(metric) {
+			record.AddField(field)
 		}
 
 		record.U64Field(constants.TIME_UNIX_NANO, sdp.TimeUnixNano)
@@ -285,57 +558,71 @@ func addHistogram(rr *air.RecordRepository, resMetrics *metricspb.ResourceMetric
 			record.AddField(attributes)
 		}
 
-		// Builds fields of the histogram struct
-		var histoFields []*rfield.Field
+		record.StructField(fmt.Sprintf("%s_%s", constants.HISTOGRAM, metric.Name), rfield.Struct{Fields: histogramFields(sdp)})
+		AddExemplars(record, sdp.Exemplars)
 
-		histoFields = append(histoFields, rfield.NewU64Field(constants.HISTOGRAM_COUNT, sdp.Count))
-		if sdp.Sum != nil {
-			histoFields = append(histoFields, rfield.NewF64Field(constants.HISTOGRAM_SUM, *sdp.Sum))
-		}
-		if sdp.Min != nil {
-			histoFields = append(histoFields, rfield.NewF64Field(constants.HISTOGRAM_MIN, *sdp.Min))
-		}
-		if sdp.Max != nil {
-			histoFields = append(histoFields, rfield.NewF64Field(constants.HISTOGRAM_MAX, *sdp.Max))
-		}
-		var bucketCounts []rfield.Value
-		for _, count := range sdp.BucketCounts {
-			bucketCounts = append(bucketCounts, &rfield.U64{Value: count})
-		}
-		if bucketCounts != nil {
-			histoFields = append(histoFields, rfield.NewListField(constants.HISTOGRAM_BUCKET_COUNTS, rfield.List{Values: bucketCounts}))
+		for _, field := range aggregationFields(histogram.AggregationTemporality, false) {
+			record.AddField(field)
 		}
-		var explicitBounds []rfield.Value
-		for _, count := range sdp.ExplicitBounds {
-			explicitBounds = append(explicitBounds, &rfield.F64{Value: count})
-		}
-		if explicitBounds != nil {
-			histoFields = append(histoFields, rfield.NewListField(constants.HISTOGRAM_EXPLICIT_BOUNDS, rfield.List{Values: explicitBounds}))
-		}
-
-		record.StructField(fmt.Sprintf("%s_%s", constants.HISTOGRAM, metricName), rfield.Struct{Fields: histoFields})
 
 		if sdp.Flags > 0 {
 			record.U32Field(constants.FLAGS, sdp.Flags)
 		}
 
-		rr.AddRecord(record)
+		records = append(records, record)
 	}
+	return opts.AddRecords(rr, records)
+}
 
-	// ToDo aggregation temporality
-	// ToDo Exemplar
-	return nil
+// histogramFields returns the fields of the histogram struct (count, optional sum/min/max, bucket counts and explicit
+// bounds).
+func histogramFields(sdp *metricspb.HistogramDataPoint) []*rfield.Field {
+	var histoFields []*rfield.Field
+
+	histoFields = append(histoFields, rfield.NewU64Field(constants.HISTOGRAM_COUNT, sdp.Count))
+	if sdp.Sum != nil {
+		histoFields = append(histoFields, rfield.NewF64Field(constants.HISTOGRAM_SUM, *sdp.Sum))
+	}
+	if sdp.Min != nil {
+		histoFields = append(histoFields, rfield.NewF64Field(constants.HISTOGRAM_MIN, *sdp.Min))
+	}
+	if sdp.Max != nil {
+		histoFields = append(histoFields, rfield.NewF64Field(constants.HISTOGRAM_MAX, *sdp.Max))
+	}
+	var bucketCounts []rfield.Value
+	for _, count := range sdp.BucketCounts {
+		bucketCounts = append(bucketCounts, &rfield.U64{Value: count})
+	}
+	if bucketCounts != nil {
+		histoFields = append(histoFields, rfield.NewListField(constants.HISTOGRAM_BUCKET_COUNTS, rfield.List{Values: bucketCounts}))
+	}
+	var explicitBounds []rfield.Value
+	for _, count := range sdp.ExplicitBounds {
+		explicitBounds = append(explicitBounds, &rfield.F64{Value: count})
+	}
+	if explicitBounds != nil {
+		histoFields = append(histoFields, rfield.NewListField(constants.HISTOGRAM_EXPLICIT_BOUNDS, rfield.List{Values: explicitBounds}))
+	}
+	return histoFields
 }
 
-func addExpHistogram(rr *air.RecordRepository, resMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, metricName string, histogram *metricspb.ExponentialHistogram) error {
+func addExpHistogram(rr *air.RecordRepository, opts *common.EncodingOptions, resMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, metric *metricspb.Metric, histogram *metricspb.ExponentialHistogram, selector *multivariateSelector) error {
+	if multivariateKeys := selector.attributes(metric.Name, fromExpHistogramDataPoints(histogram.DataPoints)); multivariateKeys != nil {
+		return multivariateMetric(rr, opts, resMetrics, scopeMetrics, metric, fromExpHistogramDataPoints(histogram.DataPoints), constants.EXP_HISTOGRAM, histogram.AggregationTemporality, false, multivariateKeys, func(dataPoint DataPoint, name string) (*rfield.Field, error) {
+			return rfield.NewStructField(name, rfield.Struct{Fields: expHistogramFields(dataPoint.(*metricspb.ExponentialHistogramDataPoint))}), nil
+		})
+	}
+
+	var records []*air.Record
 	for _, sdp := range histogram.DataPoints {
 		record := air.NewRecord()
 
-		if resMetrics.Resource != nil {
-			common.AddResource(record, resMetrics.Resource)
+		// Same resource and scope for all the data points, an error is returned before adding any record.
+		if err := addResourceAndScope(record, opts, resMetrics, scopeMetrics); err != nil {
+			return err
 		}
-		if scopeMetrics.Scope != nil {
-			common.AddScope(record, constants.SCOPE_METRICS, scopeMetrics.Scope)
+		for _, field := range metricMetadataFields(metric) {
+			record.AddField(field)
 		}
 
 		record.U64Field(constants.TIME_UNIX_NANO, sdp.TimeUnixNano)
@@ -347,109 +634,136 @@ func addExpHistogram(rr *air.RecordRepository, resMetrics *metricspb.ResourceMet
 			record.AddField(attributes)
 		}
 
-		// Builds fields of the histogram struct
-		var histoFields []*rfield.Field
+		record.StructField(fmt.Sprintf("%s_%s", constants.EXP_HISTOGRAM, metric.Name), rfield.Struct{Fields: expHistogramFields(sdp)})
+		AddExemplars(record, sdp.Exemplars)
 
-		histoFields = append(histoFields, rfield.NewU64Field(constants.HISTOGRAM_COUNT, sdp.Count))
-		if sdp.Sum != nil {
-			histoFields = append(histoFields, rfield.NewF64Field(constants.HISTOGRAM_SUM, *sdp.Sum))
-		}
-		if sdp.Min != nil {
-			histoFields = append(histoFields, rfield.NewF64Field(constants.HISTOGRAM_MIN, *sdp.Min))
-		}
-		if sdp.Max != nil {
-			histoFields = append(histoFields, rfield.NewF64Field(constants.HISTOGRAM_MAX, *sdp.Max))
+		for _, field := range aggregationFields(histogram.AggregationTemporality, false) {
+			record.AddField(field)
 		}
-		histoFields = append(histoFields, rfield.NewI32Field(constants.EXP_HISTOGRAM_SCALE, sdp.Scale))
-		histoFields = append(histoFields, rfield.NewU64Field(constants.EXP_HISTOGRAM_ZERO_COUNT, sdp.ZeroCount))
 
-		if sdp.Positive != nil {
-			var bucketCounts []rfield.Value
-			for _, count := range sdp.Positive.BucketCounts {
-				bucketCounts = append(bucketCounts, &rfield.U64{Value: count})
-			}
-			if bucketCounts != nil {
-				histoFields = append(histoFields, rfield.NewStructField(constants.EXP_HISTOGRAM_POSITIVE, rfield.Struct{Fields: []*rfield.Field{
-					rfield.NewI32Field(constants.EXP_HISTOGRAM_OFFSET, sdp.Positive.Offset),
-					rfield.NewListField(constants.HISTOGRAM_BUCKET_COUNTS, rfield.List{Values: bucketCounts}),
-				}}))
-			} else {
-				histoFields = append(histoFields, rfield.NewStructField(constants.EXP_HISTOGRAM_POSITIVE, rfield.Struct{Fields: []*rfield.Field{
-					rfield.NewI32Field(constants.EXP_HISTOGRAM_OFFSET, sdp.Positive.Offset),
-				}}))
-			}
+		if sdp.Flags > 0 {
+			record.U32Field(constants.FLAGS, sdp.Flags)
 		}
 
-		if sdp.Negative != nil {
-			var bucketCounts []rfield.Value
-			for _, count := range sdp.Negative.BucketCounts {
-				bucketCounts = append(bucketCounts, &rfield.U64{Value: count})
-			}
-			if bucketCounts != nil {
-				histoFields = append(histoFields, rfield.NewStructField(constants.EXP_HISTOGRAM_NEGATIVE, rfield.Struct{Fields: []*rfield.Field{
-					rfield.NewI32Field(constants.EXP_HISTOGRAM_OFFSET, sdp.Negative.Offset),
-					rfield.NewListField(constants.HISTOGRAM_BUCKET_COUNTS, rfield.List{Values: bucketCounts}),
-				}}))
-			} else {
-				histoFields = append(histoFields, rfield.NewStructField(constants.EXP_HISTOGRAM_NEGATIVE, rfield.Struct{Fields: []*rfield.Field{
-					rfield.NewI32Field(constants.EXP_HISTOGRAM_OFFSET, sdp.Negative.Offset),
-				}}))
-			}
-		}
+		records = append(records, record)
+	}
+	return opts.AddRecords(rr, records)
+}
 
-		record.StructField(fmt.Sprintf("%s_%s", constants.EXP_HISTOGRAM, metricName), rfield.Struct{Fields: histoFields})
+// expHistogramFields returns the fields of the exponential histogram struct (count, optional sum/min/max, scale, zero
+// count and optional positive and negative buckets).
+func expHistogramFields(sdp *metricspb.ExponentialHistogramDataPoint) []*rfield.Field {
+	var histoFields []*rfield.Field
 
-		if sdp.Flags > 0 {
-			record.U32Field(constants.FLAGS, sdp.Flags)
-		}
+	histoFields = append(histoFields, rfield.NewU64Field(constants.HISTOGRAM_COUNT, sdp.Count))
+	if sdp.Sum != nil {
+		histoFields = append(histoFields, rfield.NewF64Field(constants.HISTOGRAM_SUM, *sdp.Sum))
+	}
+	if sdp.Min != nil {
+		histoFields = append(histoFields, rfield.NewF64Field(constants.HISTOGRAM_MIN, *sdp.Min))
+	}
+	if sdp.Max != nil {
+		histoFields = append(histoFields, rfield.NewF64Field(constants.HISTOGRAM_MAX, *sdp.Max))
+	}
+	histoFields = append(histoFields, rfield.NewI32Field(constants.EXP_HISTOGRAM_SCALE, sdp.Scale))
+	histoFields = append(histoFields, rfield.NewU64Field(constants.EXP_HISTOGRAM_ZERO_COUNT, sdp.ZeroCount))
 
-		rr.AddRecord(record)
+	if sdp.Positive != nil {
+		histoFields = append(histoFields, expHistogramBucketsField(constants.EXP_HISTOGRAM_POSITIVE, sdp.Positive))
+	}
+	if sdp.Negative != nil {
+		histoFields = append(histoFields, expHistogramBucketsField(constants.EXP_HISTOGRAM_NEGATIVE, sdp.Negative))
 	}
+	return histoFields
+}
+
+// expHistogramBucketsField returns the struct field containing the offset and the bucket counts (omitted if empty) of
+// the positive or negative buckets of an exponential histogram.
+func expHistogramBucketsField(name string, buckets *metricspb.ExponentialHistogramDataPoint_Buckets) *rfield.Field {
+	fields := []*rfield.Field{rfield.NewI32Field(constants.EXP_HISTOGRAM_OFFSET, buckets.Offset)}
+	var bucketCounts []rfield.Value
+	for _, count := range buckets.BucketCounts {
+		bucketCounts = append(bucketCounts, &rfield.U64{Value: count})
+	}
+	if bucketCounts != nil {
+		fields = append(fields, rfield.NewListField(constants.HISTOGRAM_BUCKET_COUNTS, rfield.List{Values: bucketCounts}))
+	}
+	return rfield.NewStructField(name, rfield.Struct{Fields: fields})
+}
 
-	// ToDo aggregation temporality
-	// ToDo Exemplar
+// addResourceAndScope adds the resource and the scope (if defined) of a data point record.
+func addResourceAndScope(record *air.Record, opts *common.EncodingOptions, resMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics) error {
+	if err := opts.Refs().AddResource(record, resMetrics.Resource, resMetrics.SchemaUrl); err != nil {
+		return err
+	}
+	if scopeMetrics.Scope != nil || scopeMetrics.SchemaUrl != "" {
+		return opts.Refs().AddScope(record, constants.SCOPE_METRICS, scopeMetrics.Scope, scopeMetrics.SchemaUrl)
+	}
 	return nil
 }
 
-/*
-	Positive *ExponentialHistogramDataPoint_Buckets `protobuf:"bytes,8,opt,name=positive,proto3" json:"positive,omitempty"`
-	Negative *ExponentialHistogramDataPoint_Buckets `protobuf:"bytes,9,opt,name=negative,proto3" json:"negative,omitempty"`
-*/
+// AddExemplars adds the exemplars of a data point to a record as a list of structs. As for the span events and links,
+// the exemplars don't need to share the same shape: the list items are stored with the union of their fields and the
+// fields missing from an exemplar (e.g. its trace context) are null.
+func AddExemplars(record *air.Record, exemplars []*metricspb.Exemplar) {
+	if field := exemplarsField(exemplars); field != nil {
+		record.AddField(field)
+	}
+}
 
-func ExtractMultivariateValue(attributes []*commonpb.KeyValue, multivariateKey string) (*string, error) {
-	for _, attribute := range attributes {
-		if attribute.GetKey() == multivariateKey {
-			value := attribute.GetValue().Value
-			switch t := value.(type) {
-			case *commonpb.AnyValue_StringValue:
-				return &t.StringValue, nil
-			default:
-				return nil, fmt.Errorf("Unsupported multivariate value type: %v", value)
+// exemplarsField returns the list field containing the exemplars of a data point (nil if there are no exemplars).
+func exemplarsField(exemplars []*metricspb.Exemplar) *rfield.Field {
+	if len(exemplars) == 0 {
+		return nil
+	}
+
+	convertedExemplars := make([]rfield.Value, 0, len(exemplars))
+
+	for _, exemplar := range exemplars {
+		fields := make([]*rfield.Field, 0, 5)
+
+		if exemplar.FilteredAttributes != nil {
+			attributes := common.NewItemAttributes(exemplar.FilteredAttributes)
+			if attributes != nil {
+				attributes.Name = constants.FILTERED_ATTRIBUTES
+				fields = append(fields, attributes)
 			}
 		}
+		if exemplar.TimeUnixNano > 0 {
+			fields = append(fields, rfield.NewU64Field(constants.TIME_UNIX_NANO, exemplar.TimeUnixNano))
+		}
+		switch t := exemplar.Value.(type) {
+		case *metricspb.Exemplar_AsDouble:
+			fields = append(fields, rfield.NewF64Field(constants.EXEMPLAR_AS_DOUBLE, t.AsDouble))
+		case *metricspb.Exemplar_AsInt:
+			fields = append(fields, rfield.NewI64Field(constants.EXEMPLAR_AS_INT, t.AsInt))
+		}
+		if len(exemplar.SpanId) > 0 {
+			fields = append(fields, rfield.NewBinaryField(constants.SPAN_ID, exemplar.SpanId))
+		}
+		if len(exemplar.TraceId) > 0 {
+			fields = append(fields, rfield.NewBinaryField(constants.TRACE_ID, exemplar.TraceId))
+		}
+		convertedExemplars = append(convertedExemplars, &rfield.Struct{
+			Fields: fields,
+		})
 	}
-	return nil, nil
+	return rfield.NewListField(constants.EXEMPLARS, rfield.List{
+		Values: convertedExemplars,
+	})
 }
 
-func AddMultivariateValue(attributes []*commonpb.KeyValue, multivariateKey string, fields *[]*rfield.Field) (*string, error) {
-	var multivariateValue *string
+// addMultivariateAttributes adds the attributes (except the multivariate attributes) to the fields of a multivariate
+// record.
+func addMultivariateAttributes(attributes []*commonpb.KeyValue, multivariateKeys []string, fields *[]*rfield.Field) {
 	attributeFields := make([]*rfield.Field, 0, len(attributes))
 	for _, attribute := range attributes {
-		if attribute.Value != nil {
-			if attribute.GetKey() == multivariateKey {
-				value := attribute.GetValue().Value
-				switch t := value.(type) {
-				case *commonpb.AnyValue_StringValue:
-					multivariateValue = &t.StringValue
-				default:
-					return nil, fmt.Errorf("Unsupported multivariate value type: %v", value)
-				}
-			}
+		if isMultivariateAttribute(attribute.GetKey(), multivariateKeys) {
+			continue
 		}
 		attributeFields = append(attributeFields, rfield.NewField(attribute.GetKey(), common.OtlpAnyValueToValue(attribute.GetValue())))
 	}
 	if len(attributeFields) > 0 {
 		*fields = append(*fields, rfield.NewStructField(constants.ATTRIBUTES, rfield.Struct{Fields: attributeFields}))
 	}
-	return multivariateValue, nil
 }
@@ -0,0 +1,223 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+
+	"github.com/apache/arrow/go/v9/arrow"
+
+	colmetricspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/metrics/v1"
+	"otel-arrow-adapter/pkg/air"
+	"otel-arrow-adapter/pkg/air/rfield"
+	"otel-arrow-adapter/pkg/otel/common"
+	"otel-arrow-adapter/pkg/otel/constants"
+)
+
+// wideRow is a row of the wide-table layout.
+type wideRow struct {
+	fields []*rfield.Field
+	// Names of the metric columns already defined in the row.
+	columns map[string]bool
+}
+
+// wideTable joins the data points sharing the same resource, scope, timestamps and attributes into rows.
+type wideTable struct {
+	rowsByKey map[string][]*wideRow
+	// Rows in order of creation.
+	rows []*wideRow
+}
+
+// OtlpMetricsToWideArrowRecords converts an OTLP request to Arrow records using the wide-table layout: the data points
+// sharing the same resource, scope, timestamps and attributes are joined into a single row containing one struct
+// column per metric (e.g. `gauge_<name>` or `histogram_<name>`). A scrape-style set of metrics (e.g. host metrics) is
+// stored as a single dense table.
+//
+// As several metrics share a row, the description, unit, aggregation temporality, monotonicity, flags and exemplars
+// of a data point are stored in its metric column instead of top-level columns. A data point whose metric column is
+// already defined in the row (e.g. two metrics with the same name and type but different units) starts a new row.
+// Multivariate metrics are not supported by this layout.
+//
+// The records can be converted back with `ArrowRecordsToOtlpMetrics`. The invalid metrics are skipped as in
+// `OtlpMetricsToArrowRecords` and reported by the returned `*common.PartialSuccess`.
+func OtlpMetricsToWideArrowRecords(rr *air.RecordRepository, request *colmetricspb.ExportMetricsServiceRequest) (map[string][]arrow.Record, *common.PartialSuccess, error) {
+	table := wideTable{rowsByKey: make(map[string][]*wideRow)}
+
+	rejected := &common.PartialSuccess{}
+
+	for resourceIdx, resourceMetrics := range request.ResourceMetrics {
+		if err := common.ValidateResource(resourceMetrics.GetResource()); err != nil {
+			rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: -1, Item: -1, DataPoint: -1, Err: err}, countDataPoints(resourceMetrics.GetScopeMetrics()...))
+			continue
+		}
+		resourceKey, err := common.ProtoKey(&metricspb.ResourceMetrics{Resource: resourceMetrics.Resource, SchemaUrl: resourceMetrics.SchemaUrl})
+		if err != nil {
+			return nil, nil, err
+		}
+		for scopeIdx, scopeMetrics := range resourceMetrics.GetScopeMetrics() {
+			if err := common.ValidateScope(scopeMetrics.GetScope()); err != nil {
+				rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: scopeIdx, Item: -1, DataPoint: -1, Err: err}, countDataPoints(scopeMetrics))
+				continue
+			}
+			scopeKey, err := common.ProtoKey(&metricspb.ScopeMetrics{Scope: scopeMetrics.Scope, SchemaUrl: scopeMetrics.SchemaUrl})
+			if err != nil {
+				return nil, nil, err
+			}
+			key := resourceKey + scopeKey
+
+			for metricIdx, metric := range scopeMetrics.GetMetrics() {
+				if dataPointIdx, err := validateMetric(metric); err != nil {
+					rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: scopeIdx, Item: metricIdx, DataPoint: dataPointIdx, Err: err}, metricDataPointCount(metric))
+					continue
+				}
+				if dataPointIdx, err := table.addMetric(key, resourceMetrics, scopeMetrics, metric); err != nil {
+					rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: scopeIdx, Item: metricIdx, DataPoint: dataPointIdx, Err: err}, metricDataPointCount(metric))
+				}
+			}
+		}
+	}
+
+	// The rows mix the data points of several metrics, so they are all checked before adding any of them.
+	rows := make([]*air.Record, 0, len(table.rows))
+	for _, row := range table.rows {
+		rows = append(rows, air.NewRecordFromFields(row.fields))
+	}
+	for _, row := range rows {
+		if err := row.Check(); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, row := range rows {
+		if err := rr.AddRecord(row); err != nil {
+			return nil, nil, err
+		}
+	}
+	records, err := rr.Build()
+	if err != nil {
+		return nil, nil, err
+	}
+	result := make(map[string][]arrow.Record)
+	for schemaId, record := range records {
+		result[schemaId] = append(result[schemaId], record)
+	}
+	return result, rejected.OrNil(), nil
+}
+
+// addMetric adds the data points of a metric to the table. The index of the failing data point is returned with the
+// error.
+func (t *wideTable) addMetric(key string, resourceMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, metric *metricspb.Metric) (int, error) {
+	switch m := metric.Data.(type) {
+	case *metricspb.Metric_Gauge:
+		return t.addNumberDataPoints(key, resourceMetrics, scopeMetrics, metric, m.Gauge.DataPoints, constants.GAUGE_METRICS, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED, false)
+	case *metricspb.Metric_Sum:
+		return t.addNumberDataPoints(key, resourceMetrics, scopeMetrics, metric, m.Sum.DataPoints, constants.SUM_METRICS, m.Sum.AggregationTemporality, m.Sum.IsMonotonic)
+	case *metricspb.Metric_Histogram:
+		for i, dataPoint := range m.Histogram.DataPoints {
+			fields := append(histogramFields(dataPoint), wideMetricFields(metric, m.Histogram.AggregationTemporality, false, dataPoint.Flags, dataPoint.Exemplars)...)
+			if err := t.add(key, resourceMetrics, scopeMetrics, dataPoint, fmt.Sprintf("%s_%s", constants.HISTOGRAM, metric.Name), fields); err != nil {
+				return i, err
+			}
+		}
+	case *metricspb.Metric_ExponentialHistogram:
+		for i, dataPoint := range m.ExponentialHistogram.DataPoints {
+			fields := append(expHistogramFields(dataPoint), wideMetricFields(metric, m.ExponentialHistogram.AggregationTemporality, false, dataPoint.Flags, dataPoint.Exemplars)...)
+			if err := t.add(key, resourceMetrics, scopeMetrics, dataPoint, fmt.Sprintf("%s_%s", constants.EXP_HISTOGRAM, metric.Name), fields); err != nil {
+				return i, err
+			}
+		}
+	case *metricspb.Metric_Summary:
+		for i, dataPoint := range m.Summary.DataPoints {
+			fields := append(summaryFields(dataPoint), wideMetricFields(metric, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED, false, dataPoint.Flags, nil)...)
+			if err := t.add(key, resourceMetrics, scopeMetrics, dataPoint, fmt.Sprintf("%s_%s", constants.SUMMARY_METRICS, metric.Name), fields); err != nil {
+				return i, err
+			}
+		}
+	}
+	return -1, nil
+}
+
+// addNumberDataPoints adds the data points of a gauge or a sum to the table. The data points without value are
+// ignored.
+func (t *wideTable) addNumberDataPoints(key string, resourceMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, metric *metricspb.Metric, dataPoints []*metricspb.NumberDataPoint, metricType string, temporality metricspb.AggregationTemporality, isMonotonic bool) (int, error) {
+	for i, dataPoint := range dataPoints {
+		if dataPoint.Value == nil {
+			continue
+		}
+		valueField, err := numberValueField(dataPoint, constants.METRIC_VALUE)
+		if err != nil {
+			return i, err
+		}
+		fields := append([]*rfield.Field{valueField}, wideMetricFields(metric, temporality, isMonotonic, dataPoint.Flags, dataPoint.Exemplars)...)
+		if err := t.add(key, resourceMetrics, scopeMetrics, dataPoint, fmt.Sprintf("%s_%s", metricType, metric.Name), fields); err != nil {
+			return i, err
+		}
+	}
+	return -1, nil
+}
+
+// add adds the metric column of a data point to the first row sharing the same resource, scope, timestamps and
+// attributes that doesn't already define this column (a new row is created if there is none).
+func (t *wideTable) add(key string, resourceMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, dataPoint DataPoint, columnName string, fields []*rfield.Field) error {
+	sig, err := DataPointSig(dataPoint)
+	if err != nil {
+		return err
+	}
+	key += string(sig)
+
+	var row *wideRow
+	for _, candidate := range t.rowsByKey[key] {
+		if !candidate.columns[columnName] {
+			row = candidate
+			break
+		}
+	}
+	if row == nil {
+		row = &wideRow{columns: make(map[string]bool)}
+		if resourceField := common.ResourceField(resourceMetrics.Resource, resourceMetrics.SchemaUrl); resourceField != nil {
+			row.fields = append(row.fields, resourceField)
+		}
+		if scopeMetrics.Scope != nil || scopeMetrics.SchemaUrl != "" {
+			row.fields = append(row.fields, common.ScopeField(constants.SCOPE_METRICS, scopeMetrics.Scope, scopeMetrics.SchemaUrl))
+		}
+		row.fields = append(row.fields, rfield.NewU64Field(constants.TIME_UNIX_NANO, dataPoint.GetTimeUnixNano()))
+		if dataPoint.GetStartTimeUnixNano() > 0 {
+			row.fields = append(row.fields, rfield.NewU64Field(constants.START_TIME_UNIX_NANO, dataPoint.GetStartTimeUnixNano()))
+		}
+		if attributes := common.NewAttributes(dataPoint.GetAttributes()); attributes != nil {
+			row.fields = append(row.fields, attributes)
+		}
+		t.rowsByKey[key] = append(t.rowsByKey[key], row)
+		t.rows = append(t.rows, row)
+	}
+
+	row.columns[columnName] = true
+	row.fields = append(row.fields, rfield.NewStructField(columnName, rfield.Struct{Fields: fields}))
+	return nil
+}
+
+// wideMetricFields returns the fields stored in the metric column of a data point in the wide-table layout (see
+// `OtlpMetricsToWideArrowRecords`). The fields having their default value are omitted.
+func wideMetricFields(metric *metricspb.Metric, temporality metricspb.AggregationTemporality, isMonotonic bool, flags uint32, exemplars []*metricspb.Exemplar) []*rfield.Field {
+	fields := metricMetadataFields(metric)
+	fields = append(fields, aggregationFields(temporality, isMonotonic)...)
+	if flags > 0 {
+		fields = append(fields, rfield.NewU32Field(constants.FLAGS, flags))
+	}
+	if field := exemplarsField(exemplars); field != nil {
+		fields = append(fields, field)
+	}
+	return fields
+}
@@ -15,11 +15,13 @@
 package metrics
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
 	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
 	v1 "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/metrics/v1"
+	"otel-arrow-adapter/pkg/otel/common"
 	"otel-arrow-adapter/pkg/otel/metrics"
 )
 
@@ -105,10 +107,27 @@ func TestDataPointSig(t *testing.T) {
 		},
 	}
 
-	sig := metrics.DataPointSig(&ndp, "k5")
+	sig, err := metrics.DataPointSig(&ndp, "k5")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
 	expected := "[1 0 0 0 0 0 0 0 2 0 0 0 0 0 0 0 107 49 2 0 0 0 0 0 0 0 107 50 1 2 3 107 51 0 107 52 0 0 0 0 0 0 240 63 107 55 107 49 2 0 0 0 0 0 0 0 107 52 0 0 0 0 0 0 240 63 107 56 107 49 2 0 0 0 0 0 0 0 107 52 0 0 0 0 0 0 240 63]"
 	observed := fmt.Sprintf("%v", sig)
 	if expected != observed {
 		t.Errorf("expected %v, observed %v", expected, observed)
 	}
 }
+
+func TestDataPointSigUnsupportedValue(t *testing.T) {
+	t.Parallel()
+
+	ndp := v1.NumberDataPoint{
+		Attributes: []*commonpb.KeyValue{
+			{Key: "k1", Value: &commonpb.AnyValue{Value: (*commonpb.AnyValue_StringValue)(nil)}},
+		},
+	}
+
+	if _, err := metrics.DataPointSig(&ndp); !errors.Is(err, common.ErrUnsupportedValue) {
+		t.Errorf("Expected an ErrUnsupportedValue error, got %v", err)
+	}
+}
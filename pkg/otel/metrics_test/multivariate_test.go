@@ -0,0 +1,284 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	colmetricspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/resource/v1"
+	"otel-arrow-adapter/pkg/air"
+	"otel-arrow-adapter/pkg/air/config"
+	"otel-arrow-adapter/pkg/otel/metrics"
+)
+
+func TestMultivariateRules(t *testing.T) {
+	t.Parallel()
+
+	cpuTime := []*metricspb.NumberDataPoint{
+		// Composite key (cpu, state) with an int attribute, a comma, a backslash and an empty string.
+		numberDataPoint(1, 10, keyValue("cpu", intValue(0)), keyValue("state", stringValue("user")), keyValue("host", stringValue("h1"))),
+		numberDataPoint(1, 11, keyValue("cpu", intValue(0)), keyValue("state", stringValue("sys,tem")), keyValue("host", stringValue("h1"))),
+		numberDataPoint(1, 12, keyValue("cpu", intValue(1)), keyValue("state", stringValue(`i\o`)), keyValue("host", stringValue("h1"))),
+		numberDataPoint(1, 13, keyValue("cpu", intValue(1)), keyValue("state", stringValue("")), keyValue("host", stringValue("h1"))),
+		// Data point without the state attribute.
+		numberDataPoint(1, 14, keyValue("cpu", intValue(2)), keyValue("host", stringValue("h1"))),
+	}
+	// The cpu attribute is a string in this data point, so it can't share the row of the other data points.
+	cpuTime = append(cpuTime, numberDataPoint(1, 15, keyValue("cpu", stringValue("0")), keyValue("state", stringValue("user")), keyValue("host", stringValue("h1"))))
+	diskIo := []*metricspb.NumberDataPoint{
+		numberDataPoint(1, 20, keyValue("read", boolValue(true))),
+		numberDataPoint(1, 21, keyValue("read", boolValue(false))),
+	}
+	request := metricsRequest(
+		&metricspb.Metric{Name: "system.cpu.time", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: cpuTime}}},
+		&metricspb.Metric{Name: "system.disk.io", Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{DataPoints: diskIo}}},
+	)
+
+	multivariateConf := &metrics.MultivariateMetricsConfig{
+		Rules: []*metrics.MultivariateRule{
+			{Glob: "system.cpu.*", Attributes: []string{"cpu", "state"}},
+			{Regex: `^system\.disk\.`, Attributes: []string{"read"}},
+		},
+	}
+	// cpu.time: 1 row for the int cpu attributes and 1 row for the string cpu attribute, disk.io: 1 row.
+	if rows := countRows(t, request, multivariateConf); rows != 3 {
+		t.Errorf("Expected 3 rows, got %d", rows)
+	}
+
+	result := roundTripMetrics(t, air.NewRecordRepository(config.NewDefaultConfig()), request, multivariateConf)
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+}
+
+func TestMultivariateWithFlagsOrExemplars(t *testing.T) {
+	t.Parallel()
+
+	flagsDataPoints := []*metricspb.NumberDataPoint{
+		numberDataPoint(1, 1, keyValue("state", stringValue("running"))),
+		numberDataPoint(1, 2, keyValue("state", stringValue("sleeping"))),
+	}
+	flagsDataPoints[1].Flags = 1
+	exemplarDataPoints := []*metricspb.HistogramDataPoint{
+		{TimeUnixNano: 1, Count: 1, Attributes: []*commonpb.KeyValue{keyValue("state", stringValue("running"))}},
+		{TimeUnixNano: 1, Count: 2, Attributes: []*commonpb.KeyValue{keyValue("state", stringValue("sleeping"))},
+			Exemplars: []*metricspb.Exemplar{{TimeUnixNano: 1, Value: &metricspb.Exemplar_AsDouble{AsDouble: 1.5}}}},
+	}
+	request := metricsRequest(
+		&metricspb.Metric{Name: "processes", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: flagsDataPoints}}},
+		&metricspb.Metric{Name: "threads", Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{DataPoints: exemplarDataPoints}}},
+	)
+
+	multivariateConf := &metrics.MultivariateMetricsConfig{
+		Metrics: map[string]string{"processes": "state"},
+		Rules:   []*metrics.MultivariateRule{{Glob: "thr*", Attributes: []string{"state"}}},
+	}
+	// Both metrics stay univariate (2 rows each).
+	if rows := countRows(t, request, multivariateConf); rows != 4 {
+		t.Errorf("Expected 4 rows, got %d", rows)
+	}
+
+	result := roundTripMetrics(t, air.NewRecordRepository(config.NewDefaultConfig()), request, multivariateConf)
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+}
+
+func TestMultivariateAuto(t *testing.T) {
+	t.Parallel()
+
+	var dataPoints []*metricspb.NumberDataPoint
+	for pid := int64(0); pid < 4; pid++ {
+		for _, state := range []string{"running", "sleeping"} {
+			dataPoints = append(dataPoints, numberDataPoint(1, pid, keyValue("pid", intValue(pid)), keyValue("state", stringValue(state))))
+		}
+	}
+	exemplarDataPoints := []*metricspb.NumberDataPoint{
+		numberDataPoint(1, 1, keyValue("state", stringValue("running"))),
+		numberDataPoint(1, 2, keyValue("state", stringValue("sleeping"))),
+	}
+	exemplarDataPoints[0].Exemplars = []*metricspb.Exemplar{{TimeUnixNano: 1, Value: &metricspb.Exemplar_AsInt{AsInt: 1}}}
+	request := metricsRequest(
+		&metricspb.Metric{Name: "processes", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: dataPoints}}},
+		&metricspb.Metric{Name: "threads", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: exemplarDataPoints}}},
+	)
+
+	multivariateConf := &metrics.MultivariateMetricsConfig{Auto: &metrics.MultivariateAutoConfig{MaxCardinality: 3}}
+	// processes: the pid attribute exceeds the max cardinality, the state attribute folds the data points into 4 rows.
+	// threads: the metric has exemplars and stays univariate (2 rows).
+	if rows := countRows(t, request, multivariateConf); rows != 6 {
+		t.Errorf("Expected 6 rows, got %d", rows)
+	}
+
+	result := roundTripMetrics(t, air.NewRecordRepository(config.NewDefaultConfig()), request, multivariateConf)
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+}
+
+func TestMultivariateHistogramsAndSummaries(t *testing.T) {
+	t.Parallel()
+
+	sum := 10.5
+	var histogramDataPoints []*metricspb.HistogramDataPoint
+	var expHistogramDataPoints []*metricspb.ExponentialHistogramDataPoint
+	var summaryDataPoints []*metricspb.SummaryDataPoint
+	for i, route := range []string{"/a", "/b", "/c"} {
+		attributes := []*commonpb.KeyValue{keyValue("route", stringValue(route)), keyValue("host", stringValue("h1"))}
+		histogramDataPoints = append(histogramDataPoints, &metricspb.HistogramDataPoint{
+			TimeUnixNano:   1,
+			Attributes:     attributes,
+			Count:          uint64(i + 1),
+			Sum:            &sum,
+			BucketCounts:   []uint64{uint64(i), 1},
+			ExplicitBounds: []float64{5},
+		})
+		expHistogramDataPoints = append(expHistogramDataPoints, &metricspb.ExponentialHistogramDataPoint{
+			TimeUnixNano: 1,
+			Attributes:   attributes,
+			Count:        uint64(i + 1),
+			Scale:        int32(i),
+			Positive:     &metricspb.ExponentialHistogramDataPoint_Buckets{Offset: 1, BucketCounts: []uint64{uint64(i)}},
+		})
+		summaryDataPoints = append(summaryDataPoints, &metricspb.SummaryDataPoint{
+			TimeUnixNano:   1,
+			Attributes:     attributes,
+			Count:          uint64(i + 1),
+			Sum:            sum,
+			QuantileValues: []*metricspb.SummaryDataPoint_ValueAtQuantile{{Quantile: 0.5, Value: float64(i)}},
+		})
+	}
+	// Data point without the multivariate attribute.
+	histogramDataPoints = append(histogramDataPoints, &metricspb.HistogramDataPoint{TimeUnixNano: 1, Attributes: []*commonpb.KeyValue{keyValue("host", stringValue("h1"))}, Count: 4})
+	request := metricsRequest(
+		&metricspb.Metric{Name: "http.latency", Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+			DataPoints:             histogramDataPoints,
+		}}},
+		&metricspb.Metric{Name: "http.latency.exp", Data: &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			DataPoints:             expHistogramDataPoints,
+		}}},
+		&metricspb.Metric{Name: "http.latency.summary", Data: &metricspb.Metric_Summary{Summary: &metricspb.Summary{DataPoints: summaryDataPoints}}},
+	)
+
+	multivariateConf := &metrics.MultivariateMetricsConfig{
+		Rules: []*metrics.MultivariateRule{{Glob: "http.latency*", Attributes: []string{"route"}}},
+	}
+	// The data points of each metric are folded into a single row.
+	if rows := countRows(t, request, multivariateConf); rows != 3 {
+		t.Errorf("Expected 3 rows, got %d", rows)
+	}
+
+	result := roundTripMetrics(t, air.NewRecordRepository(config.NewDefaultConfig()), request, multivariateConf)
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+
+	// Automatic detection.
+	multivariateConf = &metrics.MultivariateMetricsConfig{Auto: &metrics.MultivariateAutoConfig{MaxCardinality: 5}}
+	result = roundTripMetrics(t, air.NewRecordRepository(config.NewDefaultConfig()), request, multivariateConf)
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+}
+
+func TestMultivariateConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := &metrics.MultivariateMetricsConfig{
+		Metrics: map[string]string{"system.cpu.time": "state"},
+		Rules:   []*metrics.MultivariateRule{{Glob: "system.*", Attributes: []string{"cpu", "state"}}},
+		Auto:    &metrics.MultivariateAutoConfig{MaxCardinality: 2},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	configs := []*metrics.MultivariateMetricsConfig{
+		{Metrics: map[string]string{"": "state"}},
+		{Metrics: map[string]string{"system.cpu.time": "cpu:state"}},
+		{Rules: []*metrics.MultivariateRule{{Attributes: []string{"state"}}}},
+		{Rules: []*metrics.MultivariateRule{{Glob: "[", Attributes: []string{"state"}}}},
+		{Rules: []*metrics.MultivariateRule{{Regex: "(", Attributes: []string{"state"}}}},
+		{Rules: []*metrics.MultivariateRule{{Glob: "system.*", Regex: "system", Attributes: []string{"state"}}}},
+		{Rules: []*metrics.MultivariateRule{{Glob: "system.*", Attributes: []string{"state", "state"}}}},
+		{Rules: []*metrics.MultivariateRule{nil}},
+		{Auto: &metrics.MultivariateAutoConfig{MaxCardinality: 1}},
+	}
+	for _, conf := range configs {
+		if err := conf.Validate(); err == nil {
+			t.Errorf("Expected an error for %+v", conf)
+		}
+		if _, _, err := metrics.OtlpMetricsToArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), metricsRequest(), conf); err == nil {
+			t.Errorf("Expected an error for %+v", conf)
+		}
+	}
+}
+
+// countRows returns the number of rows of the Arrow records converted from a request.
+func countRows(t *testing.T, request *colmetricspb.ExportMetricsServiceRequest, multivariateConf *metrics.MultivariateMetricsConfig) int64 {
+	t.Helper()
+
+	multiSchemaRecords, _, err := metrics.OtlpMetricsToArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), request, multivariateConf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var rows int64
+	for _, records := range multiSchemaRecords {
+		for _, record := range records {
+			rows += record.NumRows()
+		}
+	}
+	return rows
+}
+
+func metricsRequest(metrics ...*metricspb.Metric) *colmetricspb.ExportMetricsServiceRequest {
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:   &commonpb.InstrumentationScope{Name: "scope"},
+				Metrics: metrics,
+			}},
+		}},
+	}
+}
+
+func numberDataPoint(timeUnixNano uint64, value int64, attributes ...*commonpb.KeyValue) *metricspb.NumberDataPoint {
+	return &metricspb.NumberDataPoint{TimeUnixNano: timeUnixNano, Attributes: attributes, Value: &metricspb.NumberDataPoint_AsInt{AsInt: value}}
+}
+
+func keyValue(key string, value *commonpb.AnyValue) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: value}
+}
+
+func stringValue(v string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}
+}
+
+func intValue(v int64) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}
+}
+
+func boolValue(v bool) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+}
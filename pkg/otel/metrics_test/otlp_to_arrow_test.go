@@ -15,12 +15,25 @@
 package metrics_test
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/apache/arrow/go/v9/arrow"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	colmetricspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/resource/v1"
 	"otel-arrow-adapter/pkg/air"
 	"otel-arrow-adapter/pkg/air/config"
 	datagen2 "otel-arrow-adapter/pkg/datagen"
+	"otel-arrow-adapter/pkg/otel/common"
 	"otel-arrow-adapter/pkg/otel/metrics"
+	"otel-arrow-adapter/pkg/otel/testutil"
 )
 
 func TestOtlpMetricsToArrowEvents(t *testing.T) {
@@ -37,7 +50,7 @@ func TestOtlpMetricsToArrowEvents(t *testing.T) {
 	multivariateConf.Metrics["system.memory.usage"] = "state"
 
 	request := lg.Generate(10, 100)
-	multiSchemaRecords, err := metrics.OtlpMetricsToArrowRecords(rr, request, &multivariateConf)
+	multiSchemaRecords, _, err := metrics.OtlpMetricsToArrowRecords(rr, request, &multivariateConf)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -46,28 +59,28 @@ func TestOtlpMetricsToArrowEvents(t *testing.T) {
 	}
 	for schemaId, records := range multiSchemaRecords {
 		switch schemaId {
-		case "resource:{attributes:{hostname:Str,ip:Str,status:I64,up:Bol,version:F64}},scope_metrics:{name:Str,version:Str},start_time_unix_nano:U64,sum_system.cpu.load_average.1m:{value:F64},time_unix_nano:U64":
+		case "aggregation_temporality:I32,description:Str,resource:{attributes:{hostname:Str,ip:Str,status:I64,up:Bol,version:F64}},scope_metrics:{name:Str,version:Str},start_time_unix_nano:U64,sum_system.cpu.load_average.1m:{value:F64},time_unix_nano:U64,unit:Str":
 			for _, record := range records {
-				if record.NumCols() != 5 {
-					t.Errorf("Expected 6 fields, got %d", record.NumCols())
+				if record.NumCols() != 8 {
+					t.Errorf("Expected 8 fields, got %d", record.NumCols())
 				}
 				if record.NumRows() != 10 {
 					t.Errorf("Expected 10 rows, got %d", record.NumRows())
 				}
 			}
-		case "attributes:{cpu:I64,state:Str},resource:{attributes:{hostname:Str,ip:Str,status:I64,up:Bol,version:F64}},scope_metrics:{name:Str,version:Str},start_time_unix_nano:U64,sum_system.cpu.time:{idle:F64,interrupt:F64,iowait:F64,system:F64,user:F64},time_unix_nano:U64":
+		case "aggregation_temporality:I32,attributes:{cpu:I64},multivariate_key:Str,resource:{attributes:{hostname:Str,ip:Str,status:I64,up:Bol,version:F64}},scope_metrics:{name:Str,version:Str},start_time_unix_nano:U64,sum_system.cpu.time:{idle:F64,interrupt:F64,iowait:F64,system:F64,user:F64},time_unix_nano:U64,unit:Str":
 			for _, record := range records {
-				if record.NumCols() != 6 {
-					t.Errorf("Expected 5 fields, got %d", record.NumCols())
+				if record.NumCols() != 9 {
+					t.Errorf("Expected 9 fields, got %d", record.NumCols())
 				}
 				if record.NumRows() != 10 {
 					t.Errorf("Expected 10 rows, got %d", record.NumRows())
 				}
 			}
-		case "attributes:{state:Str},resource:{attributes:{hostname:Str,ip:Str,status:I64,up:Bol,version:F64}},scope_metrics:{name:Str,version:Str},start_time_unix_nano:U64,sum_system.memory.usage:{free:I64,inactive:I64,used:I64},time_unix_nano:U64":
+		case "aggregation_temporality:I32,description:Str,multivariate_key:Str,resource:{attributes:{hostname:Str,ip:Str,status:I64,up:Bol,version:F64}},scope_metrics:{name:Str,version:Str},start_time_unix_nano:U64,sum_system.memory.usage:{free:I64,inactive:I64,used:I64},time_unix_nano:U64,unit:Str":
 			for _, record := range records {
-				if record.NumCols() != 6 {
-					t.Errorf("Expected 5 fields, got %d", record.NumCols())
+				if record.NumCols() != 9 {
+					t.Errorf("Expected 9 fields, got %d", record.NumCols())
 				}
 				if record.NumRows() != 10 {
 					t.Errorf("Expected 10 rows, got %d", record.NumRows())
@@ -78,3 +91,727 @@ func TestOtlpMetricsToArrowEvents(t *testing.T) {
 		}
 	}
 }
+
+func TestArrowRecordsToOtlpMetrics(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefaultConfig()
+	rr := air.NewRecordRepository(cfg)
+	lg := datagen2.NewMetricsGenerator(datagen2.DefaultResourceAttributes(), datagen2.DefaultInstrumentationScope())
+
+	request := lg.Generate(10, 100)
+	result := roundTripMetrics(t, rr, request, &metrics.MultivariateMetricsConfig{})
+
+	// All the generated metrics share the same resource and scope.
+	if len(result.ResourceMetrics) != 1 || len(result.ResourceMetrics[0].ScopeMetrics) != 1 {
+		t.Fatalf("Expected 1 ResourceMetrics and 1 ScopeMetrics, got %d ResourceMetrics", len(result.ResourceMetrics))
+	}
+	if len(result.ResourceMetrics[0].ScopeMetrics[0].Metrics) != 3 {
+		t.Errorf("Expected 3 metrics, got %d", len(result.ResourceMetrics[0].ScopeMetrics[0].Metrics))
+	}
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+}
+
+func TestArrowRecordsToOtlpMultivariateMetrics(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefaultConfig()
+	rr := air.NewRecordRepository(cfg)
+	lg := datagen2.NewMetricsGenerator(datagen2.DefaultResourceAttributes(), datagen2.DefaultInstrumentationScope())
+	multivariateConf := &metrics.MultivariateMetricsConfig{
+		Metrics: map[string]string{
+			"system.cpu.time":     "state",
+			"system.memory.usage": "state",
+		},
+	}
+
+	request := lg.Generate(10, 100)
+	result := roundTripMetrics(t, rr, request, multivariateConf)
+
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+}
+
+func TestArrowRecordsToOtlpMultivariateGauge(t *testing.T) {
+	t.Parallel()
+
+	dataPoint := func(state string, value int64) *metricspb.NumberDataPoint {
+		attributes := []*commonpb.KeyValue{
+			{Key: "host", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "h1"}}},
+		}
+		if state != "" {
+			attributes = append(attributes, &commonpb.KeyValue{Key: "state", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: state}}})
+		}
+		return &metricspb.NumberDataPoint{TimeUnixNano: 1, Attributes: attributes, Value: &metricspb.NumberDataPoint_AsInt{AsInt: value}}
+	}
+	request := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope: &commonpb.InstrumentationScope{Name: "scope"},
+				Metrics: []*metricspb.Metric{{
+					Name: "processes",
+					Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{
+						dataPoint("running", 3),
+						dataPoint("sleeping", 120),
+						// Data point without the multivariate attribute.
+						dataPoint("", 1),
+					}}},
+				}},
+			}},
+		}},
+	}
+
+	rr := air.NewRecordRepository(config.NewDefaultConfig())
+	multivariateConf := &metrics.MultivariateMetricsConfig{Metrics: map[string]string{"processes": "state"}}
+	multiSchemaRecords, _, err := metrics.OtlpMetricsToArrowRecords(rr, request, multivariateConf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The 3 data points are folded into a single row.
+	if len(multiSchemaRecords) != 1 {
+		t.Fatalf("Expected 1 schema, got %d", len(multiSchemaRecords))
+	}
+	for _, records := range multiSchemaRecords {
+		if len(records) != 1 || records[0].NumRows() != 1 {
+			t.Fatalf("Expected 1 record with 1 row")
+		}
+	}
+
+	result := roundTripMetrics(t, air.NewRecordRepository(config.NewDefaultConfig()), request, multivariateConf)
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+}
+
+func TestArrowRecordsToOtlpGauges(t *testing.T) {
+	t.Parallel()
+
+	attributes := []*commonpb.KeyValue{
+		{Key: "host", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "h1"}}},
+	}
+	request := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope: &commonpb.InstrumentationScope{Name: "scope"},
+				Metrics: []*metricspb.Metric{
+					{
+						Name: "requests",
+						Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{
+							{TimeUnixNano: 1, Attributes: attributes, Value: &metricspb.NumberDataPoint_AsInt{AsInt: 10}},
+							{TimeUnixNano: 2, Attributes: attributes, Value: &metricspb.NumberDataPoint_AsInt{AsInt: 12}, Flags: 1},
+						}}},
+					},
+					{
+						Name: "temperature",
+						Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{
+							{StartTimeUnixNano: 1, TimeUnixNano: 2, Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 21.5}},
+						}}},
+					},
+					{
+						Name: "requests",
+						Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{DataPoints: []*metricspb.NumberDataPoint{
+							{TimeUnixNano: 3, Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 1.5}},
+						}}},
+					},
+				},
+			}},
+		}},
+	}
+
+	rr := air.NewRecordRepository(config.NewDefaultConfig())
+	result := roundTripMetrics(t, rr, request, &metrics.MultivariateMetricsConfig{})
+
+	// The gauge and the sum named `requests` are different metrics.
+	if len(result.ResourceMetrics[0].ScopeMetrics[0].Metrics) != 3 {
+		t.Errorf("Expected 3 metrics, got %d", len(result.ResourceMetrics[0].ScopeMetrics[0].Metrics))
+	}
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+}
+
+func TestArrowRecordsToOtlpHistogramsAndSummaries(t *testing.T) {
+	t.Parallel()
+
+	f64 := func(v float64) *float64 { return &v }
+	attributes := []*commonpb.KeyValue{
+		{Key: "host", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "h1"}}},
+	}
+	request := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope: &commonpb.InstrumentationScope{Name: "scope"},
+				Metrics: []*metricspb.Metric{
+					{
+						Name: "latency",
+						Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{DataPoints: []*metricspb.HistogramDataPoint{
+							{
+								TimeUnixNano:   1,
+								Attributes:     attributes,
+								Count:          6,
+								Sum:            f64(12.5),
+								Min:            f64(0.5),
+								Max:            f64(5),
+								BucketCounts:   []uint64{1, 2, 3},
+								ExplicitBounds: []float64{1, 2.5},
+							},
+							{StartTimeUnixNano: 1, TimeUnixNano: 2, Count: 0, Flags: 1},
+						}}},
+					},
+					{
+						Name: "size",
+						Data: &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{DataPoints: []*metricspb.ExponentialHistogramDataPoint{
+							{
+								TimeUnixNano: 1,
+								Attributes:   attributes,
+								Count:        10,
+								Sum:          f64(100),
+								Scale:        -2,
+								ZeroCount:    1,
+								Positive:     &metricspb.ExponentialHistogramDataPoint_Buckets{Offset: 3, BucketCounts: []uint64{4, 5}},
+								Negative:     &metricspb.ExponentialHistogramDataPoint_Buckets{Offset: -1},
+							},
+							{TimeUnixNano: 2, Count: 1, Scale: 1},
+						}}},
+					},
+					{
+						Name: "duration",
+						Data: &metricspb.Metric_Summary{Summary: &metricspb.Summary{DataPoints: []*metricspb.SummaryDataPoint{
+							{
+								TimeUnixNano: 1,
+								Attributes:   attributes,
+								Count:        3,
+								Sum:          7.5,
+								QuantileValues: []*metricspb.SummaryDataPoint_ValueAtQuantile{
+									{Quantile: 0.5, Value: 2},
+									{Quantile: 0.99, Value: 4},
+								},
+							},
+							{TimeUnixNano: 2, Count: 1, Sum: 1},
+						}}},
+					},
+				},
+			}},
+		}},
+	}
+
+	rr := air.NewRecordRepository(config.NewDefaultConfig())
+	result := roundTripMetrics(t, rr, request, &metrics.MultivariateMetricsConfig{})
+
+	if len(result.ResourceMetrics[0].ScopeMetrics[0].Metrics) != 3 {
+		t.Errorf("Expected 3 metrics, got %d", len(result.ResourceMetrics[0].ScopeMetrics[0].Metrics))
+	}
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+}
+
+func TestArrowRecordsToOtlpAggregationTemporality(t *testing.T) {
+	t.Parallel()
+
+	request := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope: &commonpb.InstrumentationScope{Name: "scope"},
+				Metrics: []*metricspb.Metric{
+					{
+						Name: "requests",
+						Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+							AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+							IsMonotonic:            true,
+							DataPoints: []*metricspb.NumberDataPoint{
+								{TimeUnixNano: 1, Value: &metricspb.NumberDataPoint_AsInt{AsInt: 10}},
+							},
+						}},
+					},
+					{
+						Name: "requests",
+						Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+							AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+							DataPoints: []*metricspb.NumberDataPoint{
+								{TimeUnixNano: 2, Value: &metricspb.NumberDataPoint_AsInt{AsInt: -2}},
+							},
+						}},
+					},
+					{
+						Name: "latency",
+						Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+							AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+							DataPoints: []*metricspb.HistogramDataPoint{
+								{TimeUnixNano: 1, Count: 2, BucketCounts: []uint64{1, 1}, ExplicitBounds: []float64{1}},
+							},
+						}},
+					},
+					{
+						Name: "size",
+						Data: &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{
+							AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+							DataPoints: []*metricspb.ExponentialHistogramDataPoint{
+								{TimeUnixNano: 1, Count: 1, Scale: 1},
+							},
+						}},
+					},
+				},
+			}},
+		}},
+	}
+
+	rr := air.NewRecordRepository(config.NewDefaultConfig())
+	result := roundTripMetrics(t, rr, request, &metrics.MultivariateMetricsConfig{})
+
+	// The cumulative monotonic sum and the delta sum named `requests` are different metrics.
+	if len(result.ResourceMetrics[0].ScopeMetrics[0].Metrics) != 4 {
+		t.Errorf("Expected 4 metrics, got %d", len(result.ResourceMetrics[0].ScopeMetrics[0].Metrics))
+	}
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+}
+
+func TestArrowRecordsToOtlpExemplars(t *testing.T) {
+	t.Parallel()
+
+	traceId := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	spanId := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	filteredAttributes := func(userId string) []*commonpb.KeyValue {
+		return []*commonpb.KeyValue{
+			{Key: "user_id", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: userId}}},
+		}
+	}
+	request := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope: &commonpb.InstrumentationScope{Name: "scope"},
+				Metrics: []*metricspb.Metric{
+					{
+						Name: "requests",
+						Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{DataPoints: []*metricspb.NumberDataPoint{
+							{
+								TimeUnixNano: 2,
+								Value:        &metricspb.NumberDataPoint_AsInt{AsInt: 10},
+								Exemplars: []*metricspb.Exemplar{
+									{TimeUnixNano: 1, Value: &metricspb.Exemplar_AsInt{AsInt: 3}, TraceId: traceId, SpanId: spanId},
+									{TimeUnixNano: 2, Value: &metricspb.Exemplar_AsInt{AsInt: 0}, TraceId: traceId, SpanId: spanId},
+								},
+							},
+							{TimeUnixNano: 3, Value: &metricspb.NumberDataPoint_AsInt{AsInt: 12}},
+						}}},
+					},
+					{
+						Name: "temperature",
+						Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{
+							{
+								TimeUnixNano: 2,
+								Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: 21.5},
+								Exemplars: []*metricspb.Exemplar{
+									{TimeUnixNano: 1, Value: &metricspb.Exemplar_AsDouble{AsDouble: 21.2}},
+								},
+							},
+						}}},
+					},
+					{
+						Name: "latency",
+						Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{DataPoints: []*metricspb.HistogramDataPoint{
+							{
+								TimeUnixNano:   2,
+								Count:          2,
+								BucketCounts:   []uint64{1, 1},
+								ExplicitBounds: []float64{1},
+								Exemplars: []*metricspb.Exemplar{
+									{FilteredAttributes: filteredAttributes("u1"), TimeUnixNano: 1, Value: &metricspb.Exemplar_AsDouble{AsDouble: 0.5}, TraceId: traceId, SpanId: spanId},
+									{FilteredAttributes: filteredAttributes("u2"), TimeUnixNano: 2, Value: &metricspb.Exemplar_AsDouble{AsDouble: 1.5}, TraceId: traceId, SpanId: spanId},
+								},
+							},
+						}}},
+					},
+					{
+						Name: "size",
+						Data: &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{DataPoints: []*metricspb.ExponentialHistogramDataPoint{
+							{
+								TimeUnixNano: 2,
+								Count:        1,
+								Scale:        1,
+								Exemplars: []*metricspb.Exemplar{
+									{FilteredAttributes: filteredAttributes("u3"), TimeUnixNano: 1, Value: &metricspb.Exemplar_AsInt{AsInt: 7}},
+								},
+							},
+						}}},
+					},
+				},
+			}},
+		}},
+	}
+
+	rr := air.NewRecordRepository(config.NewDefaultConfig())
+	result := roundTripMetrics(t, rr, request, &metrics.MultivariateMetricsConfig{})
+
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+}
+
+func TestArrowRecordsToOtlpMixedExemplars(t *testing.T) {
+	t.Parallel()
+
+	traceId := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	spanId := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	// The exemplars of a data point don't share the same shape (value type, trace context, filtered attributes).
+	exemplars := []*metricspb.Exemplar{
+		{
+			FilteredAttributes: []*commonpb.KeyValue{{Key: "user_id", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "u1"}}}},
+			TimeUnixNano:       1,
+			Value:              &metricspb.Exemplar_AsInt{AsInt: 3},
+			TraceId:            traceId,
+			SpanId:             spanId,
+		},
+		{TimeUnixNano: 2, Value: &metricspb.Exemplar_AsDouble{AsDouble: 1.5}},
+		{Value: &metricspb.Exemplar_AsInt{AsInt: 0}, SpanId: spanId},
+	}
+	request := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope: &commonpb.InstrumentationScope{Name: "scope"},
+				Metrics: []*metricspb.Metric{
+					{
+						Name: "requests",
+						Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{DataPoints: []*metricspb.NumberDataPoint{
+							{TimeUnixNano: 2, Value: &metricspb.NumberDataPoint_AsInt{AsInt: 10}, Exemplars: exemplars},
+						}}},
+					},
+					{
+						Name: "latency",
+						Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{DataPoints: []*metricspb.HistogramDataPoint{
+							{TimeUnixNano: 2, Count: 3, BucketCounts: []uint64{2, 1}, ExplicitBounds: []float64{1}, Exemplars: exemplars},
+						}}},
+					},
+				},
+			}},
+		}},
+	}
+
+	rr := air.NewRecordRepository(config.NewDefaultConfig())
+	result := roundTripMetrics(t, rr, request, &metrics.MultivariateMetricsConfig{})
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+
+	// The wide layout encodes the exemplars the same way.
+	multiSchemaRecords, _, err := metrics.OtlpMetricsToWideArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var records []arrow.Record
+	for _, schemaRecords := range multiSchemaRecords {
+		records = append(records, schemaRecords...)
+	}
+	result, err = metrics.ArrowRecordsToOtlpMetrics(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected wide metrics (-expected +got):\n%s", diff)
+	}
+}
+
+func TestArrowRecordsToOtlpMetricMetadata(t *testing.T) {
+	t.Parallel()
+
+	gauge := func(timeUnixNano uint64) *metricspb.Metric_Gauge {
+		return &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{
+			{TimeUnixNano: timeUnixNano, Value: &metricspb.NumberDataPoint_AsInt{AsInt: 10}},
+		}}}
+	}
+	request := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{
+			{
+				Resource:  &resourcepb.Resource{},
+				SchemaUrl: "https://opentelemetry.io/schemas/1.9.0",
+				ScopeMetrics: []*metricspb.ScopeMetrics{{
+					Scope:     &commonpb.InstrumentationScope{Name: "scope"},
+					SchemaUrl: "https://opentelemetry.io/schemas/1.8.0",
+					Metrics: []*metricspb.Metric{
+						{Name: "memory", Description: "Bytes of memory in use.", Unit: "By", Data: gauge(1)},
+						{Name: "memory", Description: "Bytes of memory in use.", Unit: "KiBy", Data: gauge(2)},
+					},
+				}},
+			},
+			{
+				Resource: &resourcepb.Resource{},
+				ScopeMetrics: []*metricspb.ScopeMetrics{{
+					Scope:   &commonpb.InstrumentationScope{Name: "scope"},
+					Metrics: []*metricspb.Metric{{Name: "memory", Data: gauge(3)}},
+				}},
+			},
+		},
+	}
+
+	rr := air.NewRecordRepository(config.NewDefaultConfig())
+	result := roundTripMetrics(t, rr, request, &metrics.MultivariateMetricsConfig{})
+
+	// The resources only differ by their schema URL.
+	if len(result.ResourceMetrics) != 2 {
+		t.Fatalf("Expected 2 ResourceMetrics, got %d", len(result.ResourceMetrics))
+	}
+	// The metrics named `memory` only differ by their unit.
+	for _, resourceMetrics := range result.ResourceMetrics {
+		if resourceMetrics.SchemaUrl != "" && len(resourceMetrics.ScopeMetrics[0].Metrics) != 2 {
+			t.Errorf("Expected 2 metrics, got %d", len(resourceMetrics.ScopeMetrics[0].Metrics))
+		}
+	}
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+}
+
+func TestOtlpMetricsToArrowRecordsPartialSuccess(t *testing.T) {
+	t.Parallel()
+
+	gauge := func(name string, dataPoints ...*metricspb.NumberDataPoint) *metricspb.Metric {
+		return &metricspb.Metric{Name: name, Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: dataPoints}}}
+	}
+	request := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Metrics: []*metricspb.Metric{
+					gauge("valid",
+						&metricspb.NumberDataPoint{TimeUnixNano: 1, Value: &metricspb.NumberDataPoint_AsInt{AsInt: 1}},
+						&metricspb.NumberDataPoint{TimeUnixNano: 2, Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 2}},
+					),
+					gauge("invalid_value",
+						&metricspb.NumberDataPoint{TimeUnixNano: 1, Value: &metricspb.NumberDataPoint_AsInt{AsInt: 1}},
+						&metricspb.NumberDataPoint{TimeUnixNano: 2, Value: (*metricspb.NumberDataPoint_AsInt)(nil)},
+						&metricspb.NumberDataPoint{TimeUnixNano: 3, Value: &metricspb.NumberDataPoint_AsInt{AsInt: 3}},
+					),
+					{Name: "invalid_sum", Data: (*metricspb.Metric_Sum)(nil)},
+					nil,
+				},
+			}},
+		}},
+	}
+	expected := []string{
+		"resource #0, scope #0, metric #1, data point #1: unsupported value type: nil *v1.NumberDataPoint_AsInt",
+		"resource #0, scope #0, metric #2: unsupported metric type: nil sum",
+		"resource #0, scope #0, metric #3: nil item",
+	}
+
+	converters := map[string]func() (map[string][]arrow.Record, *common.PartialSuccess, error){
+		"default": func() (map[string][]arrow.Record, *common.PartialSuccess, error) {
+			return metrics.OtlpMetricsToArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), request, nil)
+		},
+		"wide": func() (map[string][]arrow.Record, *common.PartialSuccess, error) {
+			return metrics.OtlpMetricsToWideArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), request)
+		},
+	}
+	for name, convert := range converters {
+		records, partialSuccess, err := convert()
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", name, err)
+		}
+		rows := int64(0)
+		for _, schemaRecords := range records {
+			for _, record := range schemaRecords {
+				rows += record.NumRows()
+			}
+		}
+		if rows != 2 {
+			t.Errorf("%s: Expected 2 converted data points, got %d", name, rows)
+		}
+		if partialSuccess == nil {
+			t.Fatalf("%s: Expected a partial success", name)
+		}
+		if partialSuccess.RejectedCount != 5 {
+			t.Errorf("%s: Expected 5 rejected data points, got %d", name, partialSuccess.RejectedCount)
+		}
+		var actual []string
+		for _, itemErr := range partialSuccess.Errors {
+			actual = append(actual, itemErr.Error())
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Errorf("%s: Unexpected errors (-expected +got):\n%s", name, diff)
+		}
+		if !errors.Is(partialSuccess.Errors[1], common.ErrUnsupportedMetricType) {
+			t.Errorf("%s: Expected an ErrUnsupportedMetricType error, got %v", name, partialSuccess.Errors[1])
+		}
+	}
+}
+
+func TestOtlpJsonMetrics(t *testing.T) {
+	t.Parallel()
+
+	doc := `{
+  "resourceMetrics": [{
+    "resource": {},
+    "scopeMetrics": [{
+      "scope": {"name": "scope"},
+      "metrics": [{
+        "name": "requests",
+        "sum": {
+          "aggregationTemporality": 2,
+          "isMonotonic": true,
+          "dataPoints": [{
+            "timeUnixNano": "1544712660000000000",
+            "asInt": "10",
+            "exemplars": [{"timeUnixNano": "1544712660000000000", "asDouble": 1.5, "traceId": "5b8efff798038103d269b633813fc60c", "spanId": "eee19b7ec3c1b174"}]
+          }]
+        }
+      }]
+    }]
+  }]
+}`
+
+	multiSchemaRecords, _, err := metrics.OtlpJsonMetricsToArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), []byte(doc), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var records []arrow.Record
+	for _, schemaRecords := range multiSchemaRecords {
+		records = append(records, schemaRecords...)
+	}
+	data, err := metrics.ArrowRecordsToOtlpJsonMetrics(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{`"traceId":"5b8efff798038103d269b633813fc60c"`, `"spanId":"eee19b7ec3c1b174"`, `"aggregationTemporality":2`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("Expected %s in %s", expected, data)
+		}
+	}
+
+	expected := &colmetricspb.ExportMetricsServiceRequest{}
+	if err := common.UnmarshalOtlpJson([]byte(doc), expected); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result := &colmetricspb.ExportMetricsServiceRequest{}
+	if err := common.UnmarshalOtlpJson(data, result); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(flattenMetrics(t, expected), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+}
+
+// roundTripMetrics converts a request to Arrow records and back to OTLP.
+func roundTripMetrics(t *testing.T, rr *air.RecordRepository, request *colmetricspb.ExportMetricsServiceRequest, multivariateConf *metrics.MultivariateMetricsConfig) *colmetricspb.ExportMetricsServiceRequest {
+	t.Helper()
+
+	multiSchemaRecords, _, err := metrics.OtlpMetricsToArrowRecords(rr, request, multivariateConf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var records []arrow.Record
+	for _, schemaRecords := range multiSchemaRecords {
+		records = append(records, schemaRecords...)
+	}
+	result, err := metrics.ArrowRecordsToOtlpMetrics(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return result
+}
+
+// flattenMetrics returns the data points of a request embedded into a ResourceMetrics/ScopeMetrics/Metric (one per
+// data point) with the attributes sorted by key and the data points sorted by content, so requests can be compared
+// independently of the grouping and of the order of the data points.
+func flattenMetrics(t *testing.T, request *colmetricspb.ExportMetricsServiceRequest) []*metricspb.ResourceMetrics {
+	t.Helper()
+
+	var result []*metricspb.ResourceMetrics
+
+	add := func(resourceMetrics *metricspb.ResourceMetrics, scopeMetrics *metricspb.ScopeMetrics, metric *metricspb.Metric) {
+		resource := proto.Clone(resourceMetrics.Resource).(*resourcepb.Resource)
+		scope := proto.Clone(scopeMetrics.Scope).(*commonpb.InstrumentationScope)
+
+		flattened := &metricspb.ResourceMetrics{
+			Resource:  resource,
+			SchemaUrl: resourceMetrics.SchemaUrl,
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:     scope,
+				SchemaUrl: scopeMetrics.SchemaUrl,
+				Metrics:   []*metricspb.Metric{metric},
+			}},
+		}
+		testutil.SortAttributes(flattened)
+		result = append(result, flattened)
+	}
+
+	for _, resourceMetrics := range request.ResourceMetrics {
+		for _, scopeMetrics := range resourceMetrics.ScopeMetrics {
+			for _, metric := range scopeMetrics.Metrics {
+				switch data := metric.Data.(type) {
+				case *metricspb.Metric_Gauge:
+					for _, dataPoint := range data.Gauge.DataPoints {
+						dataPoint := proto.Clone(dataPoint).(*metricspb.NumberDataPoint)
+						add(resourceMetrics, scopeMetrics, &metricspb.Metric{
+							Name:        metric.Name,
+							Description: metric.Description,
+							Unit:        metric.Unit,
+							Data:        &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{dataPoint}}},
+						})
+					}
+				case *metricspb.Metric_Sum:
+					for _, dataPoint := range data.Sum.DataPoints {
+						dataPoint := proto.Clone(dataPoint).(*metricspb.NumberDataPoint)
+						add(resourceMetrics, scopeMetrics, &metricspb.Metric{
+							Name:        metric.Name,
+							Description: metric.Description,
+							Unit:        metric.Unit,
+							Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+								AggregationTemporality: data.Sum.AggregationTemporality,
+								IsMonotonic:            data.Sum.IsMonotonic,
+								DataPoints:             []*metricspb.NumberDataPoint{dataPoint},
+							}},
+						})
+					}
+				case *metricspb.Metric_Summary:
+					for _, dataPoint := range data.Summary.DataPoints {
+						dataPoint := proto.Clone(dataPoint).(*metricspb.SummaryDataPoint)
+						add(resourceMetrics, scopeMetrics, &metricspb.Metric{
+							Name:        metric.Name,
+							Description: metric.Description,
+							Unit:        metric.Unit,
+							Data:        &metricspb.Metric_Summary{Summary: &metricspb.Summary{DataPoints: []*metricspb.SummaryDataPoint{dataPoint}}},
+						})
+					}
+				case *metricspb.Metric_Histogram:
+					for _, dataPoint := range data.Histogram.DataPoints {
+						dataPoint := proto.Clone(dataPoint).(*metricspb.HistogramDataPoint)
+						add(resourceMetrics, scopeMetrics, &metricspb.Metric{
+							Name:        metric.Name,
+							Description: metric.Description,
+							Unit:        metric.Unit,
+							Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+								AggregationTemporality: data.Histogram.AggregationTemporality,
+								DataPoints:             []*metricspb.HistogramDataPoint{dataPoint},
+							}},
+						})
+					}
+				case *metricspb.Metric_ExponentialHistogram:
+					for _, dataPoint := range data.ExponentialHistogram.DataPoints {
+						dataPoint := proto.Clone(dataPoint).(*metricspb.ExponentialHistogramDataPoint)
+						add(resourceMetrics, scopeMetrics, &metricspb.Metric{
+							Name:        metric.Name,
+							Description: metric.Description,
+							Unit:        metric.Unit,
+							Data: &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{
+								AggregationTemporality: data.ExponentialHistogram.AggregationTemporality,
+								DataPoints:             []*metricspb.ExponentialHistogramDataPoint{dataPoint},
+							}},
+						})
+					}
+				}
+			}
+		}
+	}
+
+	testutil.SortByContent(t, result)
+	return result
+}
@@ -0,0 +1,100 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/apache/arrow/go/v9/arrow"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/metrics/v1"
+	"otel-arrow-adapter/pkg/air"
+	"otel-arrow-adapter/pkg/air/config"
+	"otel-arrow-adapter/pkg/otel/metrics"
+)
+
+func TestOtlpMetricsToWideArrowRecords(t *testing.T) {
+	t.Parallel()
+
+	h1 := []*commonpb.KeyValue{keyValue("host", stringValue("h1"))}
+	h2 := []*commonpb.KeyValue{keyValue("host", stringValue("h2"))}
+	sum := 3.5
+	request := metricsRequest(
+		&metricspb.Metric{Name: "cpu.utilization", Unit: "1", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{
+			{TimeUnixNano: 1, Attributes: h1, Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 0.5}},
+			{TimeUnixNano: 1, Attributes: h2, Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 0.7}},
+		}}}},
+		&metricspb.Metric{Name: "memory.usage", Description: "Memory in use", Unit: "By", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{
+			{TimeUnixNano: 1, Attributes: h1, Value: &metricspb.NumberDataPoint_AsInt{AsInt: 1024}, Flags: 1},
+		}}}},
+		&metricspb.Metric{Name: "requests", Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            true,
+			DataPoints: []*metricspb.NumberDataPoint{
+				{TimeUnixNano: 1, Attributes: h1, Value: &metricspb.NumberDataPoint_AsInt{AsInt: 42}, Exemplars: []*metricspb.Exemplar{
+					{TimeUnixNano: 1, Value: &metricspb.Exemplar_AsInt{AsInt: 7}},
+				}},
+			},
+		}}},
+		&metricspb.Metric{Name: "latency", Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+			DataPoints: []*metricspb.HistogramDataPoint{
+				{TimeUnixNano: 1, Attributes: h1, Count: 2, Sum: &sum, BucketCounts: []uint64{1, 1}, ExplicitBounds: []float64{2}},
+			},
+		}}},
+		&metricspb.Metric{Name: "latency", Data: &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{
+			DataPoints: []*metricspb.ExponentialHistogramDataPoint{
+				{TimeUnixNano: 1, Attributes: h1, Count: 2, Scale: 1, ZeroCount: 1},
+			},
+		}}},
+		&metricspb.Metric{Name: "latency", Data: &metricspb.Metric_Summary{Summary: &metricspb.Summary{
+			DataPoints: []*metricspb.SummaryDataPoint{
+				{TimeUnixNano: 1, Attributes: h1, Count: 2, Sum: sum},
+			},
+		}}},
+		// Same name and type as the first metric but a different unit.
+		&metricspb.Metric{Name: "cpu.utilization", Unit: "%", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{
+			{TimeUnixNano: 1, Attributes: h1, Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 50}},
+		}}}},
+	)
+
+	multiSchemaRecords, _, err := metrics.OtlpMetricsToWideArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// h1: 1 row for all the metrics and 1 row for the second `cpu.utilization` metric, h2: 1 row.
+	var records []arrow.Record
+	var rows int64
+	for _, schemaRecords := range multiSchemaRecords {
+		for _, record := range schemaRecords {
+			records = append(records, record)
+			rows += record.NumRows()
+		}
+	}
+	if rows != 3 {
+		t.Errorf("Expected 3 rows, got %d", rows)
+	}
+
+	result, err := metrics.ArrowRecordsToOtlpMetrics(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(flattenMetrics(t, request), flattenMetrics(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testutil contains the helpers shared by the tests to normalize OTLP messages before comparing them (the
+// OTLP -> Arrow -> OTLP conversions don't preserve the order of the attributes nor the order of the items). It must
+// only be imported by tests, the helpers report their failures with `testing.TB`.
+package testutil
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// SortAttributes sorts by key all the lists of KeyValues (attributes and kvlists) of a message, recursively.
+func SortAttributes(message proto.Message) {
+	sortAttributes(message.ProtoReflect())
+}
+
+func sortAttributes(message protoreflect.Message) {
+	keyValueDescriptor := (&commonpb.KeyValue{}).ProtoReflect().Descriptor()
+	keyDescriptor := keyValueDescriptor.Fields().ByName("key")
+
+	message.Range(func(fd protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := value.List()
+			items := make([]protoreflect.Value, list.Len())
+			for i := range items {
+				items[i] = list.Get(i)
+				sortAttributes(items[i].Message())
+			}
+			if fd.Message().FullName() == keyValueDescriptor.FullName() {
+				sort.SliceStable(items, func(i, j int) bool {
+					return items[i].Message().Get(keyDescriptor).String() < items[j].Message().Get(keyDescriptor).String()
+				})
+				for i, item := range items {
+					list.Set(i, item)
+				}
+			}
+		case fd.Message() != nil && !fd.IsMap():
+			sortAttributes(value.Message())
+		}
+		return true
+	})
+}
+
+// SortByContent sorts a slice of messages (e.g. []proto.Message or []*logspb.ResourceLogs) by their deterministic
+// binary representation. The test fails if messages is not a slice of messages or if a message can't be marshaled.
+func SortByContent(t testing.TB, messages interface{}) {
+	t.Helper()
+
+	slice := reflect.ValueOf(messages)
+	if slice.Kind() != reflect.Slice {
+		t.Fatalf("Expected a slice of messages, got %T", messages)
+	}
+	keys := make([]string, slice.Len())
+	for i := range keys {
+		message, ok := slice.Index(i).Interface().(proto.Message)
+		if !ok {
+			t.Fatalf("Expected a slice of messages, got %T", messages)
+		}
+		key, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		keys[i] = string(key)
+	}
+	sort.Sort(byContent{swap: reflect.Swapper(messages), keys: keys})
+}
+
+type byContent struct {
+	swap func(i, j int)
+	keys []string
+}
+
+func (b byContent) Len() int           { return len(b.keys) }
+func (b byContent) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
+func (b byContent) Swap(i, j int) {
+	b.swap(i, j)
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
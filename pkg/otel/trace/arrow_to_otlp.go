@@ -0,0 +1,291 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"fmt"
+
+	"github.com/apache/arrow/go/v9/arrow"
+	"github.com/apache/arrow/go/v9/arrow/array"
+
+	coltracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	v1 "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/trace/v1"
+	"otel-arrow-adapter/pkg/otel/common"
+	"otel-arrow-adapter/pkg/otel/constants"
+)
+
+// traceBuilder regroups the decoded spans into ResourceSpans and ScopeSpans.
+type traceBuilder struct {
+	request            *coltracepb.ExportTraceServiceRequest
+	resourceSpansByKey map[string]*v1.ResourceSpans
+	scopeSpansByKey    map[string]*v1.ScopeSpans
+	resolver           *common.ReferenceResolver
+}
+
+// newTraceBuilder creates a traceBuilder reading the inline resources and scopes if `resolver` is nil.
+func newTraceBuilder(resolver *common.ReferenceResolver) *traceBuilder {
+	return &traceBuilder{
+		resolver:           resolver,
+		request:            &coltracepb.ExportTraceServiceRequest{},
+		resourceSpansByKey: make(map[string]*v1.ResourceSpans),
+		scopeSpansByKey:    make(map[string]*v1.ScopeSpans),
+	}
+}
+
+// ArrowRecordsToOtlpTrace converts the Arrow records produced by `OtlpTraceToArrowRecords` back to an OTLP request.
+//
+// The rows are regrouped into ResourceSpans and ScopeSpans by resource, scope and schema URL values (in order of first
+// appearance). The attributes are sorted by key, and the spans are ordered by record then by row. The flattened
+// attributes are supported as well (see `common.FlattenAttributes`), and the derived span columns are dropped.
+func ArrowRecordsToOtlpTrace(records []arrow.Record) (*coltracepb.ExportTraceServiceRequest, error) {
+	return arrowRecordsToOtlpTrace(records, nil)
+}
+
+// ArrowRecordsToOtlpTraceWithReferences converts the Arrow records produced by `OtlpTraceToArrowRecordsWithOptions`
+// with reference tables back to an OTLP request, resolving the resource and scope references with the reference tables
+// of the batch.
+func ArrowRecordsToOtlpTraceWithReferences(records []arrow.Record, refs *common.ReferenceRecords) (*coltracepb.ExportTraceServiceRequest, error) {
+	resolver, err := common.NewReferenceResolver(refs)
+	if err != nil {
+		return nil, err
+	}
+	return arrowRecordsToOtlpTrace(records, resolver)
+}
+
+func arrowRecordsToOtlpTrace(records []arrow.Record, resolver *common.ReferenceResolver) (*coltracepb.ExportTraceServiceRequest, error) {
+	builder := newTraceBuilder(resolver)
+
+	for _, record := range records {
+		record = common.UnflattenAttributes(common.DropDerivedColumns(record))
+		for row := 0; row < int(record.NumRows()); row++ {
+			scopeSpans, err := builder.scopeSpans(record, row)
+			if err != nil {
+				return nil, err
+			}
+			span, err := spanFromRow(record, row)
+			if err != nil {
+				return nil, fmt.Errorf("span #%d: %w", row, err)
+			}
+			scopeSpans.Spans = append(scopeSpans.Spans, span)
+		}
+	}
+
+	return builder.request, nil
+}
+
+// scopeSpans returns the ScopeSpans of a row (created on first use).
+func (b *traceBuilder) scopeSpans(record arrow.Record, row int) (*v1.ScopeSpans, error) {
+	resource, resourceSchemaUrl, err := b.resolver.Resource(record, row)
+	if err != nil {
+		return nil, err
+	}
+	resourceKey, err := common.ProtoKey(&v1.ResourceSpans{Resource: resource, SchemaUrl: resourceSchemaUrl})
+	if err != nil {
+		return nil, err
+	}
+	resourceSpans, ok := b.resourceSpansByKey[resourceKey]
+	if !ok {
+		resourceSpans = &v1.ResourceSpans{Resource: resource, SchemaUrl: resourceSchemaUrl}
+		b.resourceSpansByKey[resourceKey] = resourceSpans
+		b.request.ResourceSpans = append(b.request.ResourceSpans, resourceSpans)
+	}
+
+	scope, scopeSchemaUrl, err := b.resolver.Scope(record, constants.SCOPE_SPANS, row)
+	if err != nil {
+		return nil, err
+	}
+	scopeKey, err := common.ProtoKey(&v1.ScopeSpans{Scope: scope, SchemaUrl: scopeSchemaUrl})
+	if err != nil {
+		return nil, err
+	}
+	scopeKey = resourceKey + scopeKey
+	scopeSpans, ok := b.scopeSpansByKey[scopeKey]
+	if !ok {
+		scopeSpans = &v1.ScopeSpans{Scope: scope, SchemaUrl: scopeSchemaUrl}
+		b.scopeSpansByKey[scopeKey] = scopeSpans
+		resourceSpans.ScopeSpans = append(resourceSpans.ScopeSpans, scopeSpans)
+	}
+	return scopeSpans, nil
+}
+
+func spanFromRow(record arrow.Record, row int) (*v1.Span, error) {
+	var err error
+	span := &v1.Span{}
+
+	if span.StartTimeUnixNano, err = common.U64FromArray(common.Column(record, constants.START_TIME_UNIX_NANO), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.START_TIME_UNIX_NANO, err)
+	}
+	if span.EndTimeUnixNano, err = common.U64FromArray(common.Column(record, constants.END_TIME_UNIX_NANO), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.END_TIME_UNIX_NANO, err)
+	}
+	if span.TraceId, err = common.BinaryFromArray(common.Column(record, constants.TRACE_ID), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.TRACE_ID, err)
+	}
+	if span.SpanId, err = common.BinaryFromArray(common.Column(record, constants.SPAN_ID), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.SPAN_ID, err)
+	}
+	if span.TraceState, err = common.StringFromArray(common.Column(record, constants.TRACE_STATE), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.TRACE_STATE, err)
+	}
+	if span.ParentSpanId, err = common.BinaryFromArray(common.Column(record, constants.PARENT_SPAN_ID), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.PARENT_SPAN_ID, err)
+	}
+	if span.Name, err = common.StringFromArray(common.Column(record, constants.NAME), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.NAME, err)
+	}
+	kind, err := common.I32FromArray(common.Column(record, constants.KIND), row)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.KIND, err)
+	}
+	span.Kind = v1.Span_SpanKind(kind)
+	if span.Attributes, err = common.KeyValuesFromArray(common.Column(record, constants.ATTRIBUTES), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.ATTRIBUTES, err)
+	}
+	if span.DroppedAttributesCount, err = common.U32FromArray(common.Column(record, constants.DROPPED_ATTRIBUTES_COUNT), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.DROPPED_ATTRIBUTES_COUNT, err)
+	}
+	if span.Events, err = eventsFromRow(record, row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.SPAN_EVENTS, err)
+	}
+	if span.DroppedEventsCount, err = common.U32FromArray(common.Column(record, constants.DROPPED_EVENTS_COUNT), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.DROPPED_EVENTS_COUNT, err)
+	}
+	if span.Links, err = linksFromRow(record, row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.SPAN_LINKS, err)
+	}
+	if span.DroppedLinksCount, err = common.U32FromArray(common.Column(record, constants.DROPPED_LINKS_COUNT), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.DROPPED_LINKS_COUNT, err)
+	}
+	if span.Status, err = statusFromRow(record, row); err != nil {
+		return nil, err
+	}
+
+	return span, nil
+}
+
+func eventsFromRow(record arrow.Record, row int) ([]*v1.Span_Event, error) {
+	events, start, end, err := common.ListValues(common.Column(record, constants.SPAN_EVENTS), row)
+	if err != nil || start == end {
+		return nil, err
+	}
+
+	timeUnixNano := common.StructField(events, constants.TIME_UNIX_NANO)
+	name := common.StructField(events, constants.NAME)
+	attributes := common.StructField(events, constants.ATTRIBUTES)
+	droppedAttributesCount := common.StructField(events, constants.DROPPED_ATTRIBUTES_COUNT)
+
+	result := make([]*v1.Span_Event, 0, end-start)
+	for i := start; i < end; i++ {
+		event, err := eventAt(timeUnixNano, name, attributes, droppedAttributesCount, i)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, event)
+	}
+	return result, nil
+}
+
+// eventAt decodes the span event stored at position `i` of the arrays containing the fields of the events.
+func eventAt(timeUnixNano, name, attributes, droppedAttributesCount arrow.Array, i int) (*v1.Span_Event, error) {
+	var err error
+	event := &v1.Span_Event{}
+	if event.TimeUnixNano, err = common.U64FromArray(timeUnixNano, i); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.TIME_UNIX_NANO, err)
+	}
+	if event.Name, err = common.StringFromArray(name, i); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.NAME, err)
+	}
+	if event.Attributes, err = itemKeyValues(attributes, i); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.ATTRIBUTES, err)
+	}
+	if event.DroppedAttributesCount, err = common.U32FromArray(droppedAttributesCount, i); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.DROPPED_ATTRIBUTES_COUNT, err)
+	}
+	return event, nil
+}
+
+func linksFromRow(record arrow.Record, row int) ([]*v1.Span_Link, error) {
+	links, start, end, err := common.ListValues(common.Column(record, constants.SPAN_LINKS), row)
+	if err != nil || start == end {
+		return nil, err
+	}
+
+	traceId := common.StructField(links, constants.TRACE_ID)
+	spanId := common.StructField(links, constants.SPAN_ID)
+	traceState := common.StructField(links, constants.TRACE_STATE)
+	attributes := common.StructField(links, constants.ATTRIBUTES)
+	droppedAttributesCount := common.StructField(links, constants.DROPPED_ATTRIBUTES_COUNT)
+
+	result := make([]*v1.Span_Link, 0, end-start)
+	for i := start; i < end; i++ {
+		link, err := linkAt(traceId, spanId, traceState, attributes, droppedAttributesCount, i)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, link)
+	}
+	return result, nil
+}
+
+// linkAt decodes the span link stored at position `i` of the arrays containing the fields of the links.
+func linkAt(traceId, spanId, traceState, attributes, droppedAttributesCount arrow.Array, i int) (*v1.Span_Link, error) {
+	var err error
+	link := &v1.Span_Link{}
+	if link.TraceId, err = common.BinaryFromArray(traceId, i); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.TRACE_ID, err)
+	}
+	if link.SpanId, err = common.BinaryFromArray(spanId, i); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.SPAN_ID, err)
+	}
+	if link.TraceState, err = common.StringFromArray(traceState, i); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.TRACE_STATE, err)
+	}
+	if link.Attributes, err = itemKeyValues(attributes, i); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.ATTRIBUTES, err)
+	}
+	if link.DroppedAttributesCount, err = common.U32FromArray(droppedAttributesCount, i); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.DROPPED_ATTRIBUTES_COUNT, err)
+	}
+	return link, nil
+}
+
+// itemKeyValues decodes the attributes of a span event or link, stored as a struct of variants in the events and links
+// lists (see `common.NewItemAttributes`) or as a key/value list in the normalized layout (see `common.NewKeyValueList`).
+func itemKeyValues(attributes arrow.Array, i int) ([]*commonpb.KeyValue, error) {
+	if _, ok := attributes.(*array.List); ok {
+		return common.KeyValueListFromArray(attributes, i)
+	}
+	return common.VariantKeyValuesFromArray(attributes, i)
+}
+
+// statusFromRow returns the status of a span, or nil if the span has no status.
+func statusFromRow(record arrow.Record, row int) (*v1.Status, error) {
+	code := common.Column(record, constants.STATUS)
+	if code == nil {
+		return nil, nil
+	}
+
+	status := &v1.Status{}
+	statusCode, err := common.I32FromArray(code, row)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.STATUS, err)
+	}
+	status.Code = v1.Status_StatusCode(statusCode)
+	if status.Message, err = common.StringFromArray(common.Column(record, constants.STATUS_MESSAGE), row); err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.STATUS_MESSAGE, err)
+	}
+	return status, nil
+}
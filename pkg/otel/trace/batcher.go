@@ -0,0 +1,245 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"fmt"
+	"time"
+
+	coltracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	v1 "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/trace/v1"
+	"otel-arrow-adapter/pkg/otel/common"
+)
+
+// TraceBatcherConfig defines the time and size budgets of a TraceBatcher.
+type TraceBatcherConfig struct {
+	// Maximum number of buffered spans. When this budget is exceeded, the oldest traces are emitted.
+	MaxSpans int
+
+	// Maximum time a trace stays buffered after the arrival of its first span.
+	MaxDelay time.Duration
+
+	// A trace whose root span has been received is emitted once it hasn't received any span for this delay, i.e. when
+	// it is most likely complete (disabled if zero).
+	CompletionDelay time.Duration
+}
+
+// TraceBatcher buffers spans by trace id and emits the spans of a trace together, so the spans of a trace are
+// converted in the same batch (e.g. for tail sampling or to improve compression).
+//
+// The batcher doesn't start any goroutine: the caller provides the current time and is expected to call `Expired`
+// periodically, and `Flush` on shutdown. A TraceBatcher is not safe for concurrent use.
+type TraceBatcher struct {
+	config    *TraceBatcherConfig
+	traces    map[string]*bufferedTrace
+	order     []*bufferedTrace // By arrival time of the first span.
+	spanCount int
+}
+
+type bufferedTrace struct {
+	traceId   string
+	firstSeen time.Time
+	lastSeen  time.Time
+	hasRoot   bool
+	spans     []*bufferedSpan
+}
+
+type bufferedSpan struct {
+	resourceSpans *v1.ResourceSpans
+	scopeSpans    *v1.ScopeSpans
+	resourceKey   string
+	scopeKey      string
+	span          *v1.Span
+}
+
+func NewDefaultTraceBatcherConfig() *TraceBatcherConfig {
+	return &TraceBatcherConfig{
+		MaxSpans:        10000,
+		MaxDelay:        10 * time.Second,
+		CompletionDelay: 2 * time.Second,
+	}
+}
+
+// Validate checks the consistency of the configuration.
+func (c *TraceBatcherConfig) Validate() error {
+	if c.MaxSpans <= 0 {
+		return fmt.Errorf("trace batcher: MaxSpans must be strictly positive (got %d)", c.MaxSpans)
+	}
+	if c.MaxDelay <= 0 {
+		return fmt.Errorf("trace batcher: MaxDelay must be strictly positive (got %s)", c.MaxDelay)
+	}
+	if c.CompletionDelay < 0 {
+		return fmt.Errorf("trace batcher: CompletionDelay must be positive (got %s)", c.CompletionDelay)
+	}
+	return nil
+}
+
+// NewTraceBatcher creates an empty TraceBatcher.
+func NewTraceBatcher(config *TraceBatcherConfig) (*TraceBatcher, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &TraceBatcher{
+		config: config,
+		traces: make(map[string]*bufferedTrace),
+	}, nil
+}
+
+// SpanCount returns the number of buffered spans.
+func (b *TraceBatcher) SpanCount() int {
+	return b.spanCount
+}
+
+// Add buffers the spans of a request received at time `now`, and returns the traces to emit (nil if none), i.e. the
+// expired traces (see `Expired`) and the oldest traces exceeding the size budget.
+//
+// The nil spans (resp. ResourceSpans and ScopeSpans) are ignored. If a resource or a scope can't be serialized, an
+// error is returned and none of the spans of the request are buffered.
+func (b *TraceBatcher) Add(request *coltracepb.ExportTraceServiceRequest, now time.Time) (*coltracepb.ExportTraceServiceRequest, error) {
+	var spans []*bufferedSpan
+	for _, resourceSpans := range request.GetResourceSpans() {
+		if resourceSpans == nil {
+			continue
+		}
+		resourceKey, err := common.ProtoKey(&v1.ResourceSpans{Resource: resourceSpans.Resource, SchemaUrl: resourceSpans.SchemaUrl})
+		if err != nil {
+			return nil, err
+		}
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			if scopeSpans == nil {
+				continue
+			}
+			scopeKey, err := common.ProtoKey(&v1.ScopeSpans{Scope: scopeSpans.Scope, SchemaUrl: scopeSpans.SchemaUrl})
+			if err != nil {
+				return nil, err
+			}
+			for _, span := range scopeSpans.Spans {
+				if span == nil {
+					continue
+				}
+				spans = append(spans, &bufferedSpan{
+					resourceSpans: resourceSpans,
+					scopeSpans:    scopeSpans,
+					resourceKey:   resourceKey,
+					scopeKey:      resourceKey + scopeKey,
+					span:          span,
+				})
+			}
+		}
+	}
+	for _, span := range spans {
+		b.addSpan(span, now)
+	}
+
+	ready := b.expired(now)
+	for _, trace := range b.order {
+		if b.spanCount <= b.config.MaxSpans {
+			break
+		}
+		if !ready[trace] {
+			ready[trace] = true
+			b.spanCount -= len(trace.spans)
+		}
+	}
+	return b.emit(ready), nil
+}
+
+// Expired returns the traces to emit at time `now` (nil if none), i.e. the traces buffered for more than `MaxDelay`
+// and the traces with a root span that haven't received any span for `CompletionDelay`.
+func (b *TraceBatcher) Expired(now time.Time) *coltracepb.ExportTraceServiceRequest {
+	return b.emit(b.expired(now))
+}
+
+// Flush returns all the buffered traces (nil if none).
+func (b *TraceBatcher) Flush() *coltracepb.ExportTraceServiceRequest {
+	ready := make(map[*bufferedTrace]bool, len(b.order))
+	for _, trace := range b.order {
+		ready[trace] = true
+	}
+	b.spanCount = 0
+	return b.emit(ready)
+}
+
+func (b *TraceBatcher) addSpan(span *bufferedSpan, now time.Time) {
+	traceId := string(span.span.TraceId)
+	trace, ok := b.traces[traceId]
+	if !ok {
+		trace = &bufferedTrace{traceId: traceId, firstSeen: now}
+		b.traces[traceId] = trace
+		b.order = append(b.order, trace)
+	}
+	trace.lastSeen = now
+	trace.hasRoot = trace.hasRoot || len(span.span.ParentSpanId) == 0
+	trace.spans = append(trace.spans, span)
+	b.spanCount++
+}
+
+// expired returns the traces to emit at time `now`. The returned traces are no longer counted in the size budget.
+func (b *TraceBatcher) expired(now time.Time) map[*bufferedTrace]bool {
+	ready := make(map[*bufferedTrace]bool)
+	for _, trace := range b.order {
+		if now.Sub(trace.firstSeen) >= b.config.MaxDelay ||
+			(b.config.CompletionDelay > 0 && trace.hasRoot && now.Sub(trace.lastSeen) >= b.config.CompletionDelay) {
+			ready[trace] = true
+			b.spanCount -= len(trace.spans)
+		}
+	}
+	return ready
+}
+
+// emit removes the `ready` traces from the buffer and returns them as a request. The traces are ordered by arrival
+// time of their first span, and the spans of a trace are kept in arrival order. The spans are regrouped into
+// ResourceSpans and ScopeSpans by resource, scope and schema URL.
+func (b *TraceBatcher) emit(ready map[*bufferedTrace]bool) *coltracepb.ExportTraceServiceRequest {
+	if len(ready) == 0 {
+		return nil
+	}
+
+	request := &coltracepb.ExportTraceServiceRequest{}
+	resourceSpansByKey := make(map[string]*v1.ResourceSpans)
+	scopeSpansByKey := make(map[string]*v1.ScopeSpans)
+	remaining := b.order[:0]
+
+	for _, trace := range b.order {
+		if !ready[trace] {
+			remaining = append(remaining, trace)
+			continue
+		}
+		delete(b.traces, trace.traceId)
+
+		for _, span := range trace.spans {
+			resourceSpans, ok := resourceSpansByKey[span.resourceKey]
+			if !ok {
+				resourceSpans = &v1.ResourceSpans{Resource: span.resourceSpans.Resource, SchemaUrl: span.resourceSpans.SchemaUrl}
+				resourceSpansByKey[span.resourceKey] = resourceSpans
+				request.ResourceSpans = append(request.ResourceSpans, resourceSpans)
+			}
+			scopeSpans, ok := scopeSpansByKey[span.scopeKey]
+			if !ok {
+				scopeSpans = &v1.ScopeSpans{Scope: span.scopeSpans.Scope, SchemaUrl: span.scopeSpans.SchemaUrl}
+				scopeSpansByKey[span.scopeKey] = scopeSpans
+				resourceSpans.ScopeSpans = append(resourceSpans.ScopeSpans, scopeSpans)
+			}
+			scopeSpans.Spans = append(scopeSpans.Spans, span.span)
+		}
+	}
+
+	// Clears the tail of the slice so the emitted traces can be garbage collected.
+	for i := len(remaining); i < len(b.order); i++ {
+		b.order[i] = nil
+	}
+	b.order = remaining
+	return request
+}
@@ -0,0 +1,104 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"github.com/apache/arrow/go/v9/arrow"
+
+	coltracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	v1 "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/trace/v1"
+	"otel-arrow-adapter/pkg/air"
+	"otel-arrow-adapter/pkg/otel/common"
+	"otel-arrow-adapter/pkg/otel/constants"
+)
+
+// derivedSpanColumns contains the names of the derived span columns (see `common.EncodingOptions.DerivedSpanColumns`).
+var derivedSpanColumns = []string{constants.DURATION_TIME_UNIX_NANO, constants.IS_ROOT, constants.DEPTH}
+
+// spanDepths computes the depth of the spans of a batch, i.e. the number of ancestors of a span found in the batch by
+// following the parent chain.
+type spanDepths struct {
+	parents map[string]string
+	depths  map[string]uint32
+}
+
+func newSpanDepths(request *coltracepb.ExportTraceServiceRequest) *spanDepths {
+	parents := make(map[string]string)
+	for _, resourceSpans := range request.ResourceSpans {
+		for _, scopeSpans := range resourceSpans.GetScopeSpans() {
+			for _, span := range scopeSpans.GetSpans() {
+				if len(span.GetSpanId()) > 0 {
+					parents[spanKey(span.TraceId, span.SpanId)] = spanKey(span.TraceId, span.ParentSpanId)
+				}
+			}
+		}
+	}
+	return &spanDepths{parents: parents, depths: make(map[string]uint32)}
+}
+
+// depth returns the depth of a span. A span whose parent is not part of the batch has a depth of 0, and the parent
+// chain is cut at the first span seen twice (invalid cycle).
+func (d *spanDepths) depth(span *v1.Span) uint32 {
+	key := spanKey(span.TraceId, span.SpanId)
+	if depth, ok := d.depths[key]; ok {
+		return depth
+	}
+
+	// The chain contains the span and its ancestors with an unknown depth, the depth of the last one being `base`.
+	var chain []string
+	onChain := make(map[string]bool)
+	base := uint32(0)
+	for {
+		chain = append(chain, key)
+		onChain[key] = true
+		parent := d.parents[key]
+		if _, ok := d.parents[parent]; !ok || onChain[parent] {
+			break
+		}
+		if depth, ok := d.depths[parent]; ok {
+			base = depth + 1
+			break
+		}
+		key = parent
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		d.depths[chain[i]] = base
+		base++
+	}
+	return d.depths[chain[0]]
+}
+
+func spanKey(traceId []byte, spanId []byte) string {
+	return string(traceId) + ":" + string(spanId)
+}
+
+// addDerivedColumns adds the derived columns of a span: its duration (if the start and end times are defined), a flag
+// set for the root spans (no parent span id), and its depth in the batch.
+func addDerivedColumns(record *air.Record, depths *spanDepths, span *v1.Span) {
+	if span.StartTimeUnixNano > 0 && span.EndTimeUnixNano >= span.StartTimeUnixNano {
+		record.U64Field(constants.DURATION_TIME_UNIX_NANO, span.EndTimeUnixNano-span.StartTimeUnixNano)
+	}
+	record.BoolField(constants.IS_ROOT, len(span.ParentSpanId) == 0)
+	record.U32Field(constants.DEPTH, depths.depth(span))
+}
+
+// markDerivedColumns marks the derived span columns of the records so the decoders drop them.
+func markDerivedColumns(records []arrow.Record) []arrow.Record {
+	for i, record := range records {
+		records[i] = common.MarkDerivedColumns(record, derivedSpanColumns...)
+	}
+	return records
+}
@@ -0,0 +1,294 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/apache/arrow/go/v9/arrow"
+
+	coltracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	v1 "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/trace/v1"
+	"otel-arrow-adapter/pkg/air"
+	"otel-arrow-adapter/pkg/air/config"
+	"otel-arrow-adapter/pkg/air/rfield"
+	"otel-arrow-adapter/pkg/otel/common"
+	"otel-arrow-adapter/pkg/otel/constants"
+)
+
+// NormalizedRecordRepository contains the record repositories of the spans, events and links batches of the
+// normalized trace layout. Each batch has its own schemas and dictionaries.
+type NormalizedRecordRepository struct {
+	Spans  *air.RecordRepository
+	Events *air.RecordRepository
+	Links  *air.RecordRepository
+}
+
+// NormalizedRecords contains the related Arrow records of the normalized trace layout (see
+// `OtlpTraceToNormalizedArrowRecords`).
+type NormalizedRecords struct {
+	Spans  []arrow.Record
+	Events []arrow.Record
+	Links  []arrow.Record
+}
+
+// idRow is a row of one of the batches, identified by its `id` column.
+type idRow struct {
+	id     uint32
+	record arrow.Record
+	row    int
+}
+
+// spanRow is a row of the spans batch.
+type spanRow struct {
+	idRow
+	span *v1.Span
+}
+
+// NewNormalizedRecordRepository creates the record repositories of the three batches with the same configuration.
+func NewNormalizedRecordRepository(cfg *config.Config) *NormalizedRecordRepository {
+	return &NormalizedRecordRepository{
+		Spans:  air.NewRecordRepository(cfg),
+		Events: air.NewRecordRepository(cfg),
+		Links:  air.NewRecordRepository(cfg),
+	}
+}
+
+// OtlpTraceToNormalizedArrowRecords converts an OTLP trace to three related batches of Arrow records: the spans
+// (without their events and links), the span events and the span links. Unlike `OtlpTraceToArrowRecords`, the schema
+// of the spans doesn't depend on the shape of their events and links.
+//
+// Every row has an `id` column (sequential within a request). The events and links refer to their span with a
+// `parent_id` column containing the id of the span. The ids also preserve the order of the spans, events and links
+// (the rows of a batch may be reordered by the optimizations of the record repositories). The invalid spans are
+// skipped and reported as in `OtlpTraceToArrowRecords`.
+//
+// The attributes of the events and links are stored as key/value lists (see `common.NewKeyValueList`), so the schemas
+// of the events and links don't depend on their attribute keys.
+func OtlpTraceToNormalizedArrowRecords(rr *NormalizedRecordRepository, request *coltracepb.ExportTraceServiceRequest) (*NormalizedRecords, *common.PartialSuccess, error) {
+	return OtlpTraceToNormalizedArrowRecordsWithOptions(rr, nil, request)
+}
+
+// OtlpTraceToNormalizedArrowRecordsWithOptions is similar to `OtlpTraceToNormalizedArrowRecords` but uses the optional
+// encodings selected by `opts` (see `common.EncodingOptions`). The reference tables, the flattened attributes and the
+// derived span columns only apply to the spans batch, the events and links keeping their key/value lists.
+func OtlpTraceToNormalizedArrowRecordsWithOptions(rr *NormalizedRecordRepository, opts *common.EncodingOptions, request *coltracepb.ExportTraceServiceRequest) (*NormalizedRecords, *common.PartialSuccess, error) {
+	var spanId, eventId, linkId uint32
+	var depths *spanDepths
+	if opts.DerivedColumns() {
+		depths = newSpanDepths(request)
+	}
+
+	rejected := forEachValidSpan(request, func(resourceSpans *v1.ResourceSpans, scopeSpans *v1.ScopeSpans, span *v1.Span) error {
+		record, err := newSpanRecord(opts.Refs(), resourceSpans, scopeSpans, span)
+		if err != nil {
+			return err
+		}
+		record.U32Field(constants.ID, spanId)
+		if depths != nil {
+			addDerivedColumns(record, depths, span)
+		}
+
+		events := make([]*air.Record, 0, len(span.Events))
+		for i, event := range span.Events {
+			fields := append(eventFields(event, common.NewKeyValueList), rfield.NewU32Field(constants.ID, eventId+uint32(i)), rfield.NewU32Field(constants.PARENT_ID, spanId))
+			events = append(events, air.NewRecordFromFields(fields))
+		}
+		links := make([]*air.Record, 0, len(span.Links))
+		for i, link := range span.Links {
+			fields := append(linkFields(link, common.NewKeyValueList), rfield.NewU32Field(constants.ID, linkId+uint32(i)), rfield.NewU32Field(constants.PARENT_ID, spanId))
+			links = append(links, air.NewRecordFromFields(fields))
+		}
+
+		// The events and links are checked before adding the span (checked by `AddRecord`), so either all of them or
+		// none of them are added to the repositories.
+		for _, r := range append(events, links...) {
+			if err := r.Check(); err != nil {
+				return err
+			}
+		}
+		if err := opts.AddRecord(rr.Spans, record); err != nil {
+			return err
+		}
+		for _, event := range events {
+			if err := rr.Events.AddRecord(event); err != nil {
+				return err
+			}
+		}
+		for _, link := range links {
+			if err := rr.Links.AddRecord(link); err != nil {
+				return err
+			}
+		}
+		spanId++
+		eventId += uint32(len(events))
+		linkId += uint32(len(links))
+		return nil
+	})
+
+	var err error
+	result := &NormalizedRecords{}
+	if result.Spans, err = buildRecords(rr.Spans); err != nil {
+		return nil, nil, err
+	}
+	if depths != nil {
+		result.Spans = markDerivedColumns(result.Spans)
+	}
+	if result.Events, err = buildRecords(rr.Events); err != nil {
+		return nil, nil, err
+	}
+	if result.Links, err = buildRecords(rr.Links); err != nil {
+		return nil, nil, err
+	}
+	return result, rejected.OrNil(), nil
+}
+
+// NormalizedArrowRecordsToOtlpTrace converts the Arrow records produced by `OtlpTraceToNormalizedArrowRecords` back
+// to an OTLP request.
+//
+// The spans, events and links are ordered by id, and the events and links are attached to the span referenced by
+// their `parent_id`. The rows are regrouped into ResourceSpans and ScopeSpans as in `ArrowRecordsToOtlpTrace`, and the
+// flattened attributes and derived columns of the spans are supported as well.
+func NormalizedArrowRecordsToOtlpTrace(records *NormalizedRecords) (*coltracepb.ExportTraceServiceRequest, error) {
+	return normalizedArrowRecordsToOtlpTrace(records, nil)
+}
+
+// NormalizedArrowRecordsToOtlpTraceWithReferences converts the Arrow records produced by
+// `OtlpTraceToNormalizedArrowRecordsWithOptions` with reference tables back to an OTLP request, resolving the resource
+// and scope references with the reference tables of the batch.
+func NormalizedArrowRecordsToOtlpTraceWithReferences(records *NormalizedRecords, refs *common.ReferenceRecords) (*coltracepb.ExportTraceServiceRequest, error) {
+	resolver, err := common.NewReferenceResolver(refs)
+	if err != nil {
+		return nil, err
+	}
+	return normalizedArrowRecordsToOtlpTrace(records, resolver)
+}
+
+func normalizedArrowRecordsToOtlpTrace(records *NormalizedRecords, resolver *common.ReferenceResolver) (*coltracepb.ExportTraceServiceRequest, error) {
+	spanRecords := make([]arrow.Record, 0, len(records.Spans))
+	for _, record := range records.Spans {
+		spanRecords = append(spanRecords, common.UnflattenAttributes(common.DropDerivedColumns(record)))
+	}
+	spans, err := idRows(spanRecords)
+	if err != nil {
+		return nil, fmt.Errorf("spans: %w", err)
+	}
+	spanRows := make([]spanRow, 0, len(spans))
+	spansById := make(map[uint32]*v1.Span, len(spans))
+	for _, r := range spans {
+		span, err := spanFromRow(r.record, r.row)
+		if err != nil {
+			return nil, fmt.Errorf("span #%d: %w", r.id, err)
+		}
+		if _, ok := spansById[r.id]; ok {
+			return nil, fmt.Errorf("span #%d: duplicate id", r.id)
+		}
+		spansById[r.id] = span
+		spanRows = append(spanRows, spanRow{idRow: r, span: span})
+	}
+
+	events, err := idRows(records.Events)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.SPAN_EVENTS, err)
+	}
+	for _, r := range events {
+		span, err := parentSpan(r, spansById)
+		if err != nil {
+			return nil, fmt.Errorf("event #%d: %w", r.id, err)
+		}
+		event, err := eventAt(
+			common.Column(r.record, constants.TIME_UNIX_NANO),
+			common.Column(r.record, constants.NAME),
+			common.Column(r.record, constants.ATTRIBUTES),
+			common.Column(r.record, constants.DROPPED_ATTRIBUTES_COUNT),
+			r.row,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("event #%d: %w", r.id, err)
+		}
+		span.Events = append(span.Events, event)
+	}
+
+	links, err := idRows(records.Links)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.SPAN_LINKS, err)
+	}
+	for _, r := range links {
+		span, err := parentSpan(r, spansById)
+		if err != nil {
+			return nil, fmt.Errorf("link #%d: %w", r.id, err)
+		}
+		link, err := linkAt(
+			common.Column(r.record, constants.TRACE_ID),
+			common.Column(r.record, constants.SPAN_ID),
+			common.Column(r.record, constants.TRACE_STATE),
+			common.Column(r.record, constants.ATTRIBUTES),
+			common.Column(r.record, constants.DROPPED_ATTRIBUTES_COUNT),
+			r.row,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("link #%d: %w", r.id, err)
+		}
+		span.Links = append(span.Links, link)
+	}
+
+	builder := newTraceBuilder(resolver)
+	for _, r := range spanRows {
+		scopeSpans, err := builder.scopeSpans(r.record, r.row)
+		if err != nil {
+			return nil, err
+		}
+		scopeSpans.Spans = append(scopeSpans.Spans, r.span)
+	}
+	return builder.request, nil
+}
+
+// idRows returns the rows of a batch sorted by id.
+func idRows(records []arrow.Record) ([]idRow, error) {
+	var rows []idRow
+	for _, record := range records {
+		ids := common.Column(record, constants.ID)
+		if ids == nil {
+			return nil, fmt.Errorf("missing column %q", constants.ID)
+		}
+		for row := 0; row < int(record.NumRows()); row++ {
+			id, err := common.U32FromArray(ids, row)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", constants.ID, err)
+			}
+			rows = append(rows, idRow{id: id, record: record, row: row})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].id < rows[j].id })
+	return rows, nil
+}
+
+// parentSpan returns the span referenced by the `parent_id` column of an event or a link.
+func parentSpan(r idRow, spansById map[uint32]*v1.Span) (*v1.Span, error) {
+	parentIds := common.Column(r.record, constants.PARENT_ID)
+	if parentIds == nil {
+		return nil, fmt.Errorf("missing column %q", constants.PARENT_ID)
+	}
+	parentId, err := common.U32FromArray(parentIds, r.row)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.PARENT_ID, err)
+	}
+	span, ok := spansById[parentId]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown span id %d", constants.PARENT_ID, parentId)
+	}
+	return span, nil
+}
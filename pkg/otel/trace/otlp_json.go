@@ -0,0 +1,43 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"github.com/apache/arrow/go/v9/arrow"
+
+	coltracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"otel-arrow-adapter/pkg/air"
+	"otel-arrow-adapter/pkg/otel/common"
+)
+
+// OtlpJsonTraceToArrowRecords converts an OTLP/JSON ExportTraceServiceRequest (see `common.UnmarshalOtlpJson`) to one
+// or more Arrow records. The rejected spans are reported as in `OtlpTraceToArrowRecords`.
+func OtlpJsonTraceToArrowRecords(rr *air.RecordRepository, data []byte) ([]arrow.Record, *common.PartialSuccess, error) {
+	request := &coltracepb.ExportTraceServiceRequest{}
+	if err := common.UnmarshalOtlpJson(data, request); err != nil {
+		return nil, nil, err
+	}
+	return OtlpTraceToArrowRecords(rr, request)
+}
+
+// ArrowRecordsToOtlpJsonTrace converts the Arrow records produced by `OtlpTraceToArrowRecords` to an OTLP/JSON
+// ExportTraceServiceRequest (see `common.MarshalOtlpJson`).
+func ArrowRecordsToOtlpJsonTrace(records []arrow.Record) ([]byte, error) {
+	request, err := ArrowRecordsToOtlpTrace(records)
+	if err != nil {
+		return nil, err
+	}
+	return common.MarshalOtlpJson(request)
+}
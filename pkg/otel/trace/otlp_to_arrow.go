@@ -15,9 +15,12 @@
 package trace
 
 import (
+	"fmt"
+
 	"github.com/apache/arrow/go/v9/arrow"
 
 	coltracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
 	v1 "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/trace/v1"
 	"otel-arrow-adapter/pkg/air"
 	"otel-arrow-adapter/pkg/air/rfield"
@@ -26,109 +29,198 @@ import (
 )
 
 // OtlpTraceToArrowRecords converts an OTLP trace to one or more Arrow records.
-func OtlpTraceToArrowRecords(rr *air.RecordRepository, request *coltracepb.ExportTraceServiceRequest) ([]arrow.Record, error) {
-	for _, resourceSpans := range request.ResourceSpans {
-		for _, scopeSpans := range resourceSpans.ScopeSpans {
-			for _, span := range scopeSpans.Spans {
-				record := air.NewRecord()
-
-				if span.StartTimeUnixNano > 0 {
-					record.U64Field(constants.START_TIME_UNIX_NANO, span.StartTimeUnixNano)
-				}
-				if span.EndTimeUnixNano > 0 {
-					record.U64Field(constants.END_TIME_UNIX_NANO, span.EndTimeUnixNano)
-				}
-				common.AddResource(record, resourceSpans.Resource)
-				common.AddScope(record, constants.SCOPE_SPANS, scopeSpans.Scope)
+//
+// The invalid spans (e.g. with an attribute of an unsupported type) are skipped and reported by the returned
+// `*common.PartialSuccess` (nil if all the spans have been converted), the records of the other spans being returned.
+// An error means that no record has been built.
+func OtlpTraceToArrowRecords(rr *air.RecordRepository, request *coltracepb.ExportTraceServiceRequest) ([]arrow.Record, *common.PartialSuccess, error) {
+	return otlpTraceToArrowRecords(rr, nil, request)
+}
 
-				if span.TraceId != nil && len(span.TraceId) > 0 {
-					record.BinaryField(constants.TRACE_ID, span.TraceId)
-				}
-				if span.SpanId != nil && len(span.SpanId) > 0 {
-					record.BinaryField(constants.SPAN_ID, span.SpanId)
-				}
-				if len(span.TraceState) > 0 {
-					record.StringField(constants.TRACE_STATE, span.TraceState)
-				}
-				if span.ParentSpanId != nil && len(span.ParentSpanId) > 0 {
-					record.BinaryField(constants.PARENT_SPAN_ID, span.SpanId)
-				}
-				if len(span.Name) > 0 {
-					record.StringField(constants.NAME, span.Name)
-				}
-				record.I32Field(constants.KIND, int32(span.Kind))
-				attributes := common.NewAttributes(span.Attributes)
-				if attributes != nil {
-					record.AddField(attributes)
-				}
+// OtlpTraceToArrowRecordsWithOptions is similar to `OtlpTraceToArrowRecords` but uses the optional encodings selected by
+// `opts` (see `common.EncodingOptions`).
+func OtlpTraceToArrowRecordsWithOptions(rr *air.RecordRepository, opts *common.EncodingOptions, request *coltracepb.ExportTraceServiceRequest) ([]arrow.Record, *common.PartialSuccess, error) {
+	return otlpTraceToArrowRecords(rr, opts, request)
+}
 
-				if span.DroppedAttributesCount > 0 {
-					record.U32Field(constants.DROPPED_ATTRIBUTES_COUNT, uint32(span.DroppedAttributesCount))
-				}
+func otlpTraceToArrowRecords(rr *air.RecordRepository, opts *common.EncodingOptions, request *coltracepb.ExportTraceServiceRequest) ([]arrow.Record, *common.PartialSuccess, error) {
+	var depths *spanDepths
+	if opts.DerivedColumns() {
+		depths = newSpanDepths(request)
+	}
 
-				// Events
-				AddEvents(record, span.Events)
-				if span.DroppedEventsCount > 0 {
-					record.U32Field(constants.DROPPED_EVENTS_COUNT, uint32(span.DroppedEventsCount))
-				}
+	rejected := forEachValidSpan(request, func(resourceSpans *v1.ResourceSpans, scopeSpans *v1.ScopeSpans, span *v1.Span) error {
+		record, err := newSpanRecord(opts.Refs(), resourceSpans, scopeSpans, span)
+		if err != nil {
+			return err
+		}
+		AddEvents(record, span.Events)
+		AddLinks(record, span.Links)
+		if depths != nil {
+			addDerivedColumns(record, depths, span)
+		}
+		return opts.AddRecord(rr, record)
+	})
 
-				// Links
-				AddLinks(record, span.Links)
-				if span.DroppedLinksCount > 0 {
-					record.U32Field(constants.DROPPED_LINKS_COUNT, uint32(span.DroppedLinksCount))
-				}
+	records, err := buildRecords(rr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if depths != nil {
+		records = markDerivedColumns(records)
+	}
+	return records, rejected.OrNil(), nil
+}
 
-				// Status
-				if span.Status != nil {
-					record.I32Field(constants.STATUS, int32(span.Status.Code))
-					record.StringField(constants.STATUS_MESSAGE, span.Status.Message)
-				}
+// itemKind is the kind of the items reported in the `common.ItemError`s.
+const itemKind = "span"
+
+// forEachValidSpan calls `fn` for every valid span of a request, and returns the errors of the rejected spans.
+func forEachValidSpan(request *coltracepb.ExportTraceServiceRequest, fn func(resourceSpans *v1.ResourceSpans, scopeSpans *v1.ScopeSpans, span *v1.Span) error) *common.PartialSuccess {
+	rejected := &common.PartialSuccess{}
 
-				rr.AddRecord(record)
+	for resourceIdx, resourceSpans := range request.ResourceSpans {
+		if err := common.ValidateResource(resourceSpans.GetResource()); err != nil {
+			rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: -1, Item: -1, DataPoint: -1, Err: err}, countSpans(resourceSpans.GetScopeSpans()...))
+			continue
+		}
+		for scopeIdx, scopeSpans := range resourceSpans.GetScopeSpans() {
+			if err := common.ValidateScope(scopeSpans.GetScope()); err != nil {
+				rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: scopeIdx, Item: -1, DataPoint: -1, Err: err}, countSpans(scopeSpans))
+				continue
+			}
+			for spanIdx, span := range scopeSpans.GetSpans() {
+				if err := validateSpan(span); err != nil {
+					rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: scopeIdx, Item: spanIdx, DataPoint: -1, Err: err}, 1)
+					continue
+				}
+				if err := fn(resourceSpans, scopeSpans, span); err != nil {
+					rejected.Reject(&common.ItemError{Kind: itemKind, Resource: resourceIdx, Scope: scopeIdx, Item: spanIdx, DataPoint: -1, Err: err}, 1)
+				}
 			}
 		}
 	}
+	return rejected
+}
+
+// validateSpan checks the attributes of a span and of its events and links.
+func validateSpan(span *v1.Span) error {
+	if span == nil {
+		return common.ErrNilItem
+	}
+	if err := common.ValidateKeyValues(span.Attributes); err != nil {
+		return err
+	}
+	for i, event := range span.Events {
+		if event == nil {
+			return fmt.Errorf("event #%d: %w", i, common.ErrNilItem)
+		}
+		if err := common.ValidateKeyValues(event.Attributes); err != nil {
+			return fmt.Errorf("event #%d: %w", i, err)
+		}
+	}
+	for i, link := range span.Links {
+		if link == nil {
+			return fmt.Errorf("link #%d: %w", i, common.ErrNilItem)
+		}
+		if err := common.ValidateKeyValues(link.Attributes); err != nil {
+			return fmt.Errorf("link #%d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// countSpans returns the number of spans of a list of ScopeSpans.
+func countSpans(scopeSpans ...*v1.ScopeSpans) int {
+	count := 0
+	for _, ss := range scopeSpans {
+		count += len(ss.GetSpans())
+	}
+	return count
+}
 
-	logsRecords, err := rr.Build()
+// buildRecords builds the Arrow records of a record repository.
+func buildRecords(rr *air.RecordRepository) ([]arrow.Record, error) {
+	records, err := rr.Build()
 	if err != nil {
 		return nil, err
 	}
 
-	result := make([]arrow.Record, 0, len(logsRecords))
-	for _, record := range logsRecords {
+	result := make([]arrow.Record, 0, len(records))
+	for _, record := range records {
 		result = append(result, record)
 	}
 
 	return result, nil
 }
 
+// newSpanRecord returns a record containing the fields of a span, except its events and links. The resource and the
+// scope are inlined if `refs` is nil.
+func newSpanRecord(refs *common.ReferenceTables, resourceSpans *v1.ResourceSpans, scopeSpans *v1.ScopeSpans, span *v1.Span) (*air.Record, error) {
+	record := air.NewRecord()
+
+	if span.StartTimeUnixNano > 0 {
+		record.U64Field(constants.START_TIME_UNIX_NANO, span.StartTimeUnixNano)
+	}
+	if span.EndTimeUnixNano > 0 {
+		record.U64Field(constants.END_TIME_UNIX_NANO, span.EndTimeUnixNano)
+	}
+	if err := refs.AddResource(record, resourceSpans.Resource, resourceSpans.SchemaUrl); err != nil {
+		return nil, err
+	}
+	if err := refs.AddScope(record, constants.SCOPE_SPANS, scopeSpans.Scope, scopeSpans.SchemaUrl); err != nil {
+		return nil, err
+	}
+
+	if span.TraceId != nil && len(span.TraceId) > 0 {
+		record.BinaryField(constants.TRACE_ID, span.TraceId)
+	}
+	if span.SpanId != nil && len(span.SpanId) > 0 {
+		record.BinaryField(constants.SPAN_ID, span.SpanId)
+	}
+	if len(span.TraceState) > 0 {
+		record.StringField(constants.TRACE_STATE, span.TraceState)
+	}
+	if span.ParentSpanId != nil && len(span.ParentSpanId) > 0 {
+		record.BinaryField(constants.PARENT_SPAN_ID, span.ParentSpanId)
+	}
+	if len(span.Name) > 0 {
+		record.StringField(constants.NAME, span.Name)
+	}
+	record.I32Field(constants.KIND, int32(span.Kind))
+	attributes := common.NewAttributes(span.Attributes)
+	if attributes != nil {
+		record.AddField(attributes)
+	}
+
+	if span.DroppedAttributesCount > 0 {
+		record.U32Field(constants.DROPPED_ATTRIBUTES_COUNT, uint32(span.DroppedAttributesCount))
+	}
+	if span.DroppedEventsCount > 0 {
+		record.U32Field(constants.DROPPED_EVENTS_COUNT, uint32(span.DroppedEventsCount))
+	}
+	if span.DroppedLinksCount > 0 {
+		record.U32Field(constants.DROPPED_LINKS_COUNT, uint32(span.DroppedLinksCount))
+	}
+
+	// Status
+	if span.Status != nil {
+		record.I32Field(constants.STATUS, int32(span.Status.Code))
+		record.StringField(constants.STATUS_MESSAGE, span.Status.Message)
+	}
+
+	return record, nil
+}
+
 func AddEvents(record *air.Record, events []*v1.Span_Event) {
 	if events == nil {
 		return
 	}
 
 	convertedEvents := make([]rfield.Value, 0, len(events))
-
 	for _, event := range events {
-		fields := make([]*rfield.Field, 0, 4)
-
-		if event.TimeUnixNano > 0 {
-			fields = append(fields, rfield.NewU64Field(constants.TIME_UNIX_NANO, event.TimeUnixNano))
-		}
-		if len(event.Name) > 0 {
-			fields = append(fields, rfield.NewStringField(constants.NAME, event.Name))
-		}
-		if event.Attributes != nil {
-			attributes := common.NewAttributes(event.Attributes)
-			if attributes != nil {
-				fields = append(fields, attributes)
-			}
-		}
-		if event.DroppedAttributesCount > 0 {
-			fields = append(fields, rfield.NewU32Field(constants.DROPPED_ATTRIBUTES_COUNT, uint32(event.DroppedAttributesCount)))
-		}
 		convertedEvents = append(convertedEvents, &rfield.Struct{
-			Fields: fields,
+			Fields: eventFields(event, common.NewItemAttributes),
 		})
 	}
 	record.ListField(constants.SPAN_EVENTS, rfield.List{
@@ -136,39 +228,67 @@ func AddEvents(record *air.Record, events []*v1.Span_Event) {
 	})
 }
 
+// eventFields returns the fields of a span event (the fields having their default value are omitted), the attributes
+// being converted by `newAttributes`.
+func eventFields(event *v1.Span_Event, newAttributes func([]*commonpb.KeyValue) *rfield.Field) []*rfield.Field {
+	fields := make([]*rfield.Field, 0, 4)
+
+	if event.TimeUnixNano > 0 {
+		fields = append(fields, rfield.NewU64Field(constants.TIME_UNIX_NANO, event.TimeUnixNano))
+	}
+	if len(event.Name) > 0 {
+		fields = append(fields, rfield.NewStringField(constants.NAME, event.Name))
+	}
+	if event.Attributes != nil {
+		attributes := newAttributes(event.Attributes)
+		if attributes != nil {
+			fields = append(fields, attributes)
+		}
+	}
+	if event.DroppedAttributesCount > 0 {
+		fields = append(fields, rfield.NewU32Field(constants.DROPPED_ATTRIBUTES_COUNT, uint32(event.DroppedAttributesCount)))
+	}
+	return fields
+}
+
 func AddLinks(record *air.Record, links []*v1.Span_Link) {
 	if links == nil {
 		return
 	}
 
 	convertedLinks := make([]rfield.Value, 0, len(links))
-
 	for _, link := range links {
-		fields := make([]*rfield.Field, 0, 4)
-
-		if link.TraceId != nil && len(link.TraceId) > 0 {
-			fields = append(fields, rfield.NewBinaryField(constants.TRACE_ID, link.TraceId))
-		}
-		if link.SpanId != nil && len(link.SpanId) > 0 {
-			fields = append(fields, rfield.NewBinaryField(constants.SPAN_ID, link.SpanId))
-		}
-		if len(link.TraceState) > 0 {
-			fields = append(fields, rfield.NewStringField(constants.TRACE_STATE, link.TraceState))
-		}
-		if link.Attributes != nil {
-			attributes := common.NewAttributes(link.Attributes)
-			if attributes != nil {
-				fields = append(fields, attributes)
-			}
-		}
-		if link.DroppedAttributesCount > 0 {
-			fields = append(fields, rfield.NewU32Field(constants.DROPPED_ATTRIBUTES_COUNT, uint32(link.DroppedAttributesCount)))
-		}
 		convertedLinks = append(convertedLinks, &rfield.Struct{
-			Fields: fields,
+			Fields: linkFields(link, common.NewItemAttributes),
 		})
 	}
 	record.ListField(constants.SPAN_LINKS, rfield.List{
 		Values: convertedLinks,
 	})
 }
+
+// linkFields returns the fields of a span link (the fields having their default value are omitted), the attributes
+// being converted by `newAttributes`.
+func linkFields(link *v1.Span_Link, newAttributes func([]*commonpb.KeyValue) *rfield.Field) []*rfield.Field {
+	fields := make([]*rfield.Field, 0, 4)
+
+	if link.TraceId != nil && len(link.TraceId) > 0 {
+		fields = append(fields, rfield.NewBinaryField(constants.TRACE_ID, link.TraceId))
+	}
+	if link.SpanId != nil && len(link.SpanId) > 0 {
+		fields = append(fields, rfield.NewBinaryField(constants.SPAN_ID, link.SpanId))
+	}
+	if len(link.TraceState) > 0 {
+		fields = append(fields, rfield.NewStringField(constants.TRACE_STATE, link.TraceState))
+	}
+	if link.Attributes != nil {
+		attributes := newAttributes(link.Attributes)
+		if attributes != nil {
+			fields = append(fields, attributes)
+		}
+	}
+	if link.DroppedAttributesCount > 0 {
+		fields = append(fields, rfield.NewU32Field(constants.DROPPED_ATTRIBUTES_COUNT, uint32(link.DroppedAttributesCount)))
+	}
+	return fields
+}
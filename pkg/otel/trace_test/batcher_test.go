@@ -0,0 +1,186 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	coltracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/trace/v1"
+	"otel-arrow-adapter/pkg/otel/trace"
+)
+
+func TestTraceBatcherSizeBudget(t *testing.T) {
+	t.Parallel()
+
+	batcher, err := trace.NewTraceBatcher(&trace.TraceBatcherConfig{MaxSpans: 3, MaxDelay: time.Hour})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	now := time.Unix(0, 0)
+
+	emitted, err := batcher.Add(batcherRequest("svc", batcherSpan("a", 1, 0), batcherSpan("b", 1, 0), batcherSpan("a", 2, 1)), now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if emitted != nil {
+		t.Errorf("Expected no emitted traces, got %v", spanNames(emitted))
+	}
+
+	// The oldest trace is emitted with all its spans once the budget is exceeded.
+	emitted, err = batcher.Add(batcherRequest("svc", batcherSpan("c", 1, 0), batcherSpan("a", 3, 1)), now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"a1", "a2", "a3"}, spanNames(emitted)); diff != "" {
+		t.Errorf("Unexpected emitted spans (-expected +got):\n%s", diff)
+	}
+	if batcher.SpanCount() != 2 {
+		t.Errorf("Expected 2 buffered spans, got %d", batcher.SpanCount())
+	}
+
+	if diff := cmp.Diff([]string{"b1", "c1"}, spanNames(batcher.Flush())); diff != "" {
+		t.Errorf("Unexpected flushed spans (-expected +got):\n%s", diff)
+	}
+	if batcher.SpanCount() != 0 || batcher.Flush() != nil {
+		t.Errorf("Expected an empty batcher after a flush")
+	}
+}
+
+func TestTraceBatcherTimeBudget(t *testing.T) {
+	t.Parallel()
+
+	batcher, err := trace.NewTraceBatcher(&trace.TraceBatcherConfig{MaxSpans: 100, MaxDelay: 10 * time.Second, CompletionDelay: 2 * time.Second})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	start := time.Unix(0, 0)
+
+	// Trace a has a root span, trace b doesn't.
+	if _, err := batcher.Add(batcherRequest("svc1", batcherSpan("a", 2, 1), batcherSpan("b", 2, 1)), start); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := batcher.Add(batcherRequest("svc2", batcherSpan("a", 1, 0)), start.Add(time.Second)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if emitted := batcher.Expired(start.Add(2 * time.Second)); emitted != nil {
+		t.Errorf("Expected no emitted traces, got %v", spanNames(emitted))
+	}
+	emitted := batcher.Expired(start.Add(3 * time.Second))
+	if diff := cmp.Diff([]string{"a2", "a1"}, spanNames(emitted)); diff != "" {
+		t.Errorf("Unexpected emitted spans (-expected +got):\n%s", diff)
+	}
+	// The spans are regrouped by resource.
+	if len(emitted.ResourceSpans) != 2 {
+		t.Errorf("Expected 2 ResourceSpans, got %d", len(emitted.ResourceSpans))
+	}
+
+	if emitted := batcher.Expired(start.Add(9 * time.Second)); emitted != nil {
+		t.Errorf("Expected no emitted traces, got %v", spanNames(emitted))
+	}
+	if diff := cmp.Diff([]string{"b2"}, spanNames(batcher.Expired(start.Add(10*time.Second)))); diff != "" {
+		t.Errorf("Unexpected emitted spans (-expected +got):\n%s", diff)
+	}
+}
+
+func TestTraceBatcherInvalidRequests(t *testing.T) {
+	t.Parallel()
+
+	batcher, err := trace.NewTraceBatcher(&trace.TraceBatcherConfig{MaxSpans: 100, MaxDelay: time.Hour})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	now := time.Unix(0, 0)
+
+	// The nil spans, ScopeSpans and ResourceSpans are ignored.
+	request := batcherRequest("svc", batcherSpan("a", 1, 0), nil)
+	request.ResourceSpans[0].ScopeSpans = append(request.ResourceSpans[0].ScopeSpans, nil)
+	request.ResourceSpans = append(request.ResourceSpans, nil)
+	if _, err := batcher.Add(request, now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if batcher.SpanCount() != 1 {
+		t.Errorf("Expected 1 buffered span, got %d", batcher.SpanCount())
+	}
+
+	// A resource that can't be serialized (invalid UTF-8 string) rejects the whole request.
+	request = batcherRequest("svc", batcherSpan("b", 1, 0))
+	request.ResourceSpans = append(request.ResourceSpans, batcherRequest("\xff", batcherSpan("c", 1, 0)).ResourceSpans...)
+	if _, err := batcher.Add(request, now); err == nil {
+		t.Errorf("Expected an error for an invalid resource")
+	}
+	if diff := cmp.Diff([]string{"a1"}, spanNames(batcher.Flush())); diff != "" {
+		t.Errorf("Unexpected flushed spans (-expected +got):\n%s", diff)
+	}
+}
+
+func TestTraceBatcherConfigErrors(t *testing.T) {
+	t.Parallel()
+
+	configs := []*trace.TraceBatcherConfig{
+		{MaxSpans: 0, MaxDelay: time.Second},
+		{MaxSpans: 1, MaxDelay: 0},
+		{MaxSpans: 1, MaxDelay: time.Second, CompletionDelay: -time.Second},
+	}
+	for _, cfg := range configs {
+		if _, err := trace.NewTraceBatcher(cfg); err == nil {
+			t.Errorf("Expected an error for %+v", cfg)
+		}
+	}
+}
+
+// batcherSpan returns a span of the trace `traceId` named after its trace id and span id (e.g. "a1"). The span is a
+// root span if `parentId` is 0.
+func batcherSpan(traceId string, spanId byte, parentId byte) *tracepb.Span {
+	span := &tracepb.Span{
+		TraceId: []byte(traceId),
+		SpanId:  []byte{spanId},
+		Name:    traceId + string('0'+spanId),
+	}
+	if parentId > 0 {
+		span.ParentSpanId = []byte{parentId}
+	}
+	return span
+}
+
+func batcherRequest(service string, spans ...*tracepb.Span) *coltracepb.ExportTraceServiceRequest {
+	return &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+				{Key: "service.name", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: service}}},
+			}},
+			ScopeSpans: []*tracepb.ScopeSpans{{Spans: spans}},
+		}},
+	}
+}
+
+// spanNames returns the names of the spans of a request (in order).
+func spanNames(request *coltracepb.ExportTraceServiceRequest) []string {
+	var names []string
+	for _, resourceSpans := range request.GetResourceSpans() {
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			for _, span := range scopeSpans.Spans {
+				names = append(names, span.Name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,210 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	coltracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/trace/v1"
+	"otel-arrow-adapter/pkg/air/config"
+	datagen2 "otel-arrow-adapter/pkg/datagen"
+	"otel-arrow-adapter/pkg/otel/common"
+	"otel-arrow-adapter/pkg/otel/constants"
+	"otel-arrow-adapter/pkg/otel/trace"
+)
+
+func TestNormalizedTrace(t *testing.T) {
+	t.Parallel()
+
+	attribute := func(key string, value string) *commonpb.KeyValue {
+		return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+	}
+	request := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{attribute("service.name", "svc")}},
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Scope: &commonpb.InstrumentationScope{Name: "scope"},
+				Spans: []*tracepb.Span{
+					{
+						TraceId: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+						SpanId:  []byte{1, 2, 3, 4, 5, 6, 7, 8},
+						Name:    "span-1",
+						// Events with different shapes, in a non-chronological order.
+						Events: []*tracepb.Span_Event{
+							{TimeUnixNano: 3, Name: "retry", Attributes: []*commonpb.KeyValue{attribute("attempt", "2")}},
+							{TimeUnixNano: 1, Name: "exception", Attributes: []*commonpb.KeyValue{attribute("exception.message", "boom"), attribute("exception.type", "Error")}},
+							{TimeUnixNano: 2},
+						},
+						Links: []*tracepb.Span_Link{
+							{TraceId: []byte{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}, SpanId: []byte{8, 7, 6, 5, 4, 3, 2, 1}},
+						},
+					},
+					{
+						TraceId: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+						SpanId:  []byte{2, 2, 3, 4, 5, 6, 7, 8},
+						Name:    "span-2",
+						Events: []*tracepb.Span_Event{
+							{TimeUnixNano: 4, Name: "log", Attributes: []*commonpb.KeyValue{attribute("message", "hello")}, DroppedAttributesCount: 1},
+						},
+					},
+					{
+						TraceId: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+						SpanId:  []byte{3, 2, 3, 4, 5, 6, 7, 8},
+						Name:    "span-3",
+						Links: []*tracepb.Span_Link{
+							{TraceId: []byte{1}, TraceState: "a=b", Attributes: []*commonpb.KeyValue{attribute("kind", "follows")}},
+							{SpanId: []byte{2}, DroppedAttributesCount: 2},
+						},
+					},
+				},
+			}},
+		}},
+	}
+
+	rr := trace.NewNormalizedRecordRepository(config.NewDefaultConfig())
+	records, _, err := trace.OtlpTraceToNormalizedArrowRecords(rr, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The schema of the spans doesn't depend on their events and links.
+	if len(records.Spans) != 1 {
+		t.Errorf("Expected 1 span record, got %d", len(records.Spans))
+	}
+	if len(records.Events) == 0 || len(records.Links) == 0 {
+		t.Fatalf("Expected event and link records")
+	}
+
+	result, err := trace.NormalizedArrowRecordsToOtlpTrace(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The order of the spans, events and links is preserved.
+	if diff := cmp.Diff(request, result, protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected trace (-expected +got):\n%s", diff)
+	}
+}
+
+func TestNormalizedTraceWithOptions(t *testing.T) {
+	t.Parallel()
+
+	attribute := func(key string, value string) *commonpb.KeyValue {
+		return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+	}
+	request := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{attribute("service.name", "svc")}},
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Scope: &commonpb.InstrumentationScope{Name: "scope"},
+				Spans: []*tracepb.Span{
+					{
+						TraceId:           []byte{1},
+						SpanId:            []byte{1},
+						Name:              "root",
+						StartTimeUnixNano: 1,
+						EndTimeUnixNano:   3,
+						Attributes:        []*commonpb.KeyValue{attribute("http.method", "GET")},
+						// Events with different attribute keys.
+						Events: []*tracepb.Span_Event{
+							{TimeUnixNano: 1, Name: "a", Attributes: []*commonpb.KeyValue{attribute("k1", "v1")}},
+							{TimeUnixNano: 2, Name: "b", Attributes: []*commonpb.KeyValue{attribute("k2", "v2"), attribute("k3", "v3")}},
+						},
+					},
+					{TraceId: []byte{1}, SpanId: []byte{2}, ParentSpanId: []byte{1}, Name: "child"},
+				},
+			}},
+		}},
+	}
+
+	cfg := config.NewDefaultConfig()
+	refs := common.NewReferenceTables(cfg)
+	opts := &common.EncodingOptions{References: refs, FlattenAttributes: true, DerivedSpanColumns: true}
+	records, _, err := trace.OtlpTraceToNormalizedArrowRecordsWithOptions(trace.NewNormalizedRecordRepository(cfg), opts, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The schema of the events doesn't depend on their attribute keys.
+	if len(records.Events) != 1 {
+		t.Errorf("Expected 1 event record, got %d", len(records.Events))
+	}
+	for _, name := range []string{constants.RESOURCE_ID, common.FlattenedAttributesPrefix + "http.method", constants.DEPTH} {
+		found := false
+		for _, record := range records.Spans {
+			found = found || common.Column(record, name) != nil
+		}
+		if !found {
+			t.Errorf("Expected a %q span column", name)
+		}
+	}
+
+	refRecords, err := refs.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err := trace.NormalizedArrowRecordsToOtlpTraceWithReferences(records, refRecords)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(request, result, protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected trace (-expected +got):\n%s", diff)
+	}
+}
+
+func TestNormalizedTraceGenerated(t *testing.T) {
+	t.Parallel()
+
+	lg := datagen2.NewTraceGenerator(datagen2.DefaultResourceAttributes(), datagen2.DefaultInstrumentationScope())
+	request := lg.Generate(10, 100)
+
+	rr := trace.NewNormalizedRecordRepository(config.NewDefaultConfig())
+	records, _, err := trace.OtlpTraceToNormalizedArrowRecords(rr, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err := trace.NormalizedArrowRecordsToOtlpTrace(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(flattenSpans(t, request), flattenSpans(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected spans (-expected +got):\n%s", diff)
+	}
+}
+
+func TestNormalizedTraceUnknownParent(t *testing.T) {
+	t.Parallel()
+
+	request := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Spans: []*tracepb.Span{{Name: "span", Events: []*tracepb.Span_Event{{Name: "event"}}}},
+			}},
+		}},
+	}
+	records, _, err := trace.OtlpTraceToNormalizedArrowRecords(trace.NewNormalizedRecordRepository(config.NewDefaultConfig()), request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Events without the spans batch.
+	records.Spans = nil
+	if _, err := trace.NormalizedArrowRecordsToOtlpTrace(records); err == nil {
+		t.Errorf("Expected an error for an event referencing an unknown span")
+	}
+}
@@ -15,16 +15,27 @@
 package trace_test
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	coltracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/trace/v1"
 	"otel-arrow-adapter/pkg/air"
 	"otel-arrow-adapter/pkg/air/config"
 	datagen2 "otel-arrow-adapter/pkg/datagen"
+	"otel-arrow-adapter/pkg/otel/common"
+	"otel-arrow-adapter/pkg/otel/constants"
+	"otel-arrow-adapter/pkg/otel/testutil"
 	"otel-arrow-adapter/pkg/otel/trace"
 )
 
 func TestOtlpTraceToArrowEvents(t *testing.T) {
-	t.Skip("TODO: implement")
 	t.Parallel()
 
 	cfg := config.NewDefaultConfig()
@@ -32,7 +43,7 @@ func TestOtlpTraceToArrowEvents(t *testing.T) {
 	lg := datagen2.NewTraceGenerator(datagen2.DefaultResourceAttributes(), datagen2.DefaultInstrumentationScope())
 
 	request := lg.Generate(10, 100)
-	records, err := trace.OtlpTraceToArrowRecords(rr, request)
+	records, _, err := trace.OtlpTraceToArrowRecords(rr, request)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -40,3 +51,433 @@ func TestOtlpTraceToArrowEvents(t *testing.T) {
 		t.Errorf("Expected 1 record, got %d", len(records))
 	}
 }
+
+func TestArrowRecordsToOtlpTrace(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefaultConfig()
+	rr := air.NewRecordRepository(cfg)
+	lg := datagen2.NewTraceGenerator(datagen2.DefaultResourceAttributes(), datagen2.DefaultInstrumentationScope())
+
+	request := lg.Generate(10, 100)
+	records, _, err := trace.OtlpTraceToArrowRecords(rr, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result, err := trace.ArrowRecordsToOtlpTrace(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// All the generated spans share the same resource and scope.
+	if len(result.ResourceSpans) != 1 || len(result.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("Expected 1 ResourceSpans and 1 ScopeSpans, got %d ResourceSpans", len(result.ResourceSpans))
+	}
+	if diff := cmp.Diff(flattenSpans(t, request), flattenSpans(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected spans (-expected +got):\n%s", diff)
+	}
+}
+
+func TestArrowRecordsToOtlpTraceWithoutStatus(t *testing.T) {
+	t.Parallel()
+
+	request := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{},
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Scope: &commonpb.InstrumentationScope{Name: "scope"},
+				Spans: []*tracepb.Span{{
+					StartTimeUnixNano: 1,
+					EndTimeUnixNano:   2,
+					TraceId:           []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+					SpanId:            []byte{1, 2, 3, 4, 5, 6, 7, 8},
+					ParentSpanId:      []byte{8, 7, 6, 5, 4, 3, 2, 1},
+					Name:              "span",
+					Kind:              tracepb.Span_SPAN_KIND_CLIENT,
+				}},
+			}},
+		}},
+	}
+
+	rr := air.NewRecordRepository(config.NewDefaultConfig())
+	records, _, err := trace.OtlpTraceToArrowRecords(rr, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err := trace.ArrowRecordsToOtlpTrace(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(flattenSpans(t, request), flattenSpans(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected spans (-expected +got):\n%s", diff)
+	}
+}
+
+func TestArrowRecordsToOtlpTraceEventsWithDifferentAttributes(t *testing.T) {
+	t.Parallel()
+
+	str := func(value string) *commonpb.AnyValue {
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}
+	}
+	i64 := func(value int64) *commonpb.AnyValue {
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: value}}
+	}
+	array := func(values ...*commonpb.AnyValue) *commonpb.AnyValue {
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+	}
+	kvlist := func(kvs ...*commonpb.KeyValue) *commonpb.AnyValue {
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: kvs}}}
+	}
+
+	request := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{},
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Scope: &commonpb.InstrumentationScope{Name: "scope"},
+				Spans: []*tracepb.Span{{
+					Name: "span",
+					// Same keys with different types, different keys, unset values and mixed arrays.
+					Events: []*tracepb.Span_Event{
+						{Name: "a", Attributes: []*commonpb.KeyValue{{Key: "k", Value: str("v")}, {Key: "x", Value: i64(1)}}},
+						{Name: "b", Attributes: []*commonpb.KeyValue{{Key: "k", Value: i64(2)}, {Key: "u", Value: nil}}},
+						{Name: "c", Attributes: []*commonpb.KeyValue{{Key: "k", Value: array(str("v"), i64(3), kvlist(&commonpb.KeyValue{Key: "n", Value: str("w")}))}}},
+						{Name: "d"},
+					},
+					Links: []*tracepb.Span_Link{
+						{TraceState: "a", Attributes: []*commonpb.KeyValue{{Key: "k", Value: kvlist(&commonpb.KeyValue{Key: "n", Value: i64(4)})}}},
+						{TraceState: "b", Attributes: []*commonpb.KeyValue{{Key: "k", Value: array(i64(5), array(str("v")))}}},
+					},
+				}},
+			}},
+		}},
+	}
+
+	records, _, err := trace.OtlpTraceToArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err := trace.ArrowRecordsToOtlpTrace(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(flattenSpans(t, request), flattenSpans(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected spans (-expected +got):\n%s", diff)
+	}
+}
+
+func TestArrowRecordsToOtlpTraceFlattenedAttributes(t *testing.T) {
+	t.Parallel()
+
+	attribute := func(key string, value string) *commonpb.KeyValue {
+		return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+	}
+	request := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{attribute("service.name", "svc")}},
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Scope: &commonpb.InstrumentationScope{Name: "scope", Attributes: []*commonpb.KeyValue{attribute("library", "lib")}},
+				Spans: []*tracepb.Span{{
+					Name: "span",
+					// Keys colliding with the reserved prefix or containing dots.
+					Attributes: []*commonpb.KeyValue{
+						attribute("http.method", "GET"),
+						attribute("attributes", "a"),
+						attribute("attributes.http.method", "b"),
+						{Key: "kv", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{
+							Values: []*commonpb.KeyValue{attribute("attributes", "c")},
+						}}}},
+					},
+					Events: []*tracepb.Span_Event{
+						{Name: "event-1", Attributes: []*commonpb.KeyValue{attribute("exception.type", "Error")}},
+						{Name: "event-2", Attributes: []*commonpb.KeyValue{attribute("exception.type", "Timeout")}},
+					},
+					Links: []*tracepb.Span_Link{
+						{TraceId: []byte{1}, Attributes: []*commonpb.KeyValue{attribute("kind", "follows")}},
+					},
+				}},
+			}},
+		}},
+	}
+
+	opts := &common.EncodingOptions{FlattenAttributes: true}
+	records, _, err := trace.OtlpTraceToArrowRecordsWithOptions(air.NewRecordRepository(config.NewDefaultConfig()), opts, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(records))
+	}
+	record := records[0]
+	if common.Column(record, constants.ATTRIBUTES) != nil {
+		t.Errorf("Expected no nested attributes column")
+	}
+	for _, name := range []string{"attributes.http.method", "attributes.attributes", "attributes.attributes.http.method", "attributes.kv"} {
+		if common.Column(record, name) == nil {
+			t.Errorf("Expected a column %q", name)
+		}
+	}
+	if common.StructField(common.Column(record, constants.RESOURCE), "attributes.service.name") == nil {
+		t.Errorf("Expected a flattened resource attribute")
+	}
+
+	result, err := trace.ArrowRecordsToOtlpTrace(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(flattenSpans(t, request), flattenSpans(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected spans (-expected +got):\n%s", diff)
+	}
+}
+
+func TestOtlpTraceToArrowRecordsDerivedColumns(t *testing.T) {
+	t.Parallel()
+
+	traceId, otherTraceId := []byte{1}, []byte{2}
+	request := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{},
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Scope: &commonpb.InstrumentationScope{Name: "scope"},
+				Spans: []*tracepb.Span{
+					// The descendants are listed before their ancestors.
+					{Name: "grandchild", TraceId: traceId, SpanId: []byte{3}, ParentSpanId: []byte{2}, StartTimeUnixNano: 14, EndTimeUnixNano: 15},
+					{Name: "child", TraceId: traceId, SpanId: []byte{2}, ParentSpanId: []byte{1}, StartTimeUnixNano: 12, EndTimeUnixNano: 20},
+					{Name: "root", TraceId: traceId, SpanId: []byte{1}, StartTimeUnixNano: 10, EndTimeUnixNano: 30},
+					{Name: "orphan", TraceId: traceId, SpanId: []byte{4}, ParentSpanId: []byte{9}, StartTimeUnixNano: 10},
+					{Name: "other", TraceId: otherTraceId, SpanId: []byte{5}, ParentSpanId: []byte{1}, StartTimeUnixNano: 10, EndTimeUnixNano: 10},
+				},
+			}},
+		}},
+	}
+
+	opts := &common.EncodingOptions{DerivedSpanColumns: true}
+	records, _, err := trace.OtlpTraceToArrowRecordsWithOptions(air.NewRecordRepository(config.NewDefaultConfig()), opts, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	type derived struct {
+		duration uint64
+		isRoot   bool
+		depth    uint32
+	}
+	expected := map[string]derived{
+		"grandchild": {duration: 1, depth: 2},
+		"child":      {duration: 8, depth: 1},
+		"root":       {duration: 20, isRoot: true},
+		"orphan":     {},
+		"other":      {},
+	}
+	actual := make(map[string]derived)
+	for _, record := range records {
+		for i, field := range record.Schema().Fields() {
+			isDerived := field.Name == constants.DURATION_TIME_UNIX_NANO || field.Name == constants.IS_ROOT || field.Name == constants.DEPTH
+			if common.IsDerivedField(field) != isDerived {
+				t.Errorf("Unexpected derived flag for the column %q", field.Name)
+			}
+			if isDerived && record.Column(i).NullN() > 0 && field.Name != constants.DURATION_TIME_UNIX_NANO {
+				t.Errorf("Unexpected null values in the column %q", field.Name)
+			}
+		}
+		for row := 0; row < int(record.NumRows()); row++ {
+			name, err := common.StringFromArray(common.Column(record, constants.NAME), row)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var values derived
+			if values.duration, err = common.U64FromArray(common.Column(record, constants.DURATION_TIME_UNIX_NANO), row); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if values.isRoot, err = common.BoolFromArray(common.Column(record, constants.IS_ROOT), row); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if values.depth, err = common.U32FromArray(common.Column(record, constants.DEPTH), row); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			actual[name] = values
+		}
+	}
+	if diff := cmp.Diff(expected, actual, cmp.AllowUnexported(derived{})); diff != "" {
+		t.Errorf("Unexpected derived columns (-expected +got):\n%s", diff)
+	}
+
+	// The derived columns are dropped by the decoder.
+	result, err := trace.ArrowRecordsToOtlpTrace(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(flattenSpans(t, request), flattenSpans(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected spans (-expected +got):\n%s", diff)
+	}
+}
+
+func TestOtlpTraceToArrowRecordsPartialSuccess(t *testing.T) {
+	t.Parallel()
+
+	invalidAttributes := []*commonpb.KeyValue{{Key: "k", Value: &commonpb.AnyValue{Value: (*commonpb.AnyValue_IntValue)(nil)}}}
+	duplicateAttributes := []*commonpb.KeyValue{
+		{Key: "k", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 1}}},
+		{Key: "k", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "v"}}},
+	}
+	request := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{},
+			ScopeSpans: []*tracepb.ScopeSpans{
+				{
+					Scope: &commonpb.InstrumentationScope{Name: "scope"},
+					Spans: []*tracepb.Span{
+						{Name: "a", SpanId: []byte{1}},
+						{Name: "b", SpanId: []byte{2}, Events: []*tracepb.Span_Event{{Name: "event", Attributes: invalidAttributes}}},
+						nil,
+						{Name: "c", SpanId: []byte{3}, Links: []*tracepb.Span_Link{nil}},
+						{Name: "d", SpanId: []byte{4}},
+						{Name: "e", SpanId: []byte{5}, Attributes: duplicateAttributes},
+					},
+				},
+				{
+					Scope: &commonpb.InstrumentationScope{Name: "invalid", Attributes: invalidAttributes},
+					Spans: []*tracepb.Span{{Name: "f"}, {Name: "g"}},
+				},
+			},
+		}},
+	}
+
+	records, partialSuccess, err := trace.OtlpTraceToArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rows := int64(0)
+	for _, record := range records {
+		rows += record.NumRows()
+	}
+	if rows != 2 {
+		t.Errorf("Expected 2 converted spans, got %d", rows)
+	}
+	if partialSuccess == nil {
+		t.Fatalf("Expected a partial success")
+	}
+	if partialSuccess.RejectedCount != 6 {
+		t.Errorf("Expected 6 rejected spans, got %d", partialSuccess.RejectedCount)
+	}
+	expected := []string{
+		`resource #0, scope #0, span #1: event #0: attribute "k": unsupported value type: nil *v1.AnyValue_IntValue`,
+		"resource #0, scope #0, span #2: nil item",
+		"resource #0, scope #0, span #3: link #0: nil item",
+		`resource #0, scope #0, span #5: attribute "k": duplicate key`,
+		`resource #0, scope #1: scope: attribute "k": unsupported value type: nil *v1.AnyValue_IntValue`,
+	}
+	var actual []string
+	for _, itemErr := range partialSuccess.Errors {
+		actual = append(actual, itemErr.Error())
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("Unexpected errors (-expected +got):\n%s", diff)
+	}
+
+	// The normalized layout rejects the same spans.
+	normalized, partialSuccess, err := trace.OtlpTraceToNormalizedArrowRecords(trace.NewNormalizedRecordRepository(config.NewDefaultConfig()), request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if partialSuccess == nil || partialSuccess.RejectedCount != 6 {
+		t.Errorf("Expected 6 rejected spans, got %v", partialSuccess)
+	}
+	if normalized == nil {
+		t.Errorf("Expected the records of the valid spans")
+	}
+}
+
+func TestOtlpJsonTrace(t *testing.T) {
+	t.Parallel()
+
+	doc := `{
+  "resourceSpans": [{
+    "resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "svc"}}]},
+    "scopeSpans": [{
+      "scope": {"name": "scope"},
+      "spans": [{
+        "traceId": "5b8efff798038103d269b633813fc60c",
+        "spanId": "eee19b7ec3c1b174",
+        "parent_span_id": "eee19b7ec3c1b173",
+        "name": "span",
+        "kind": "SPAN_KIND_SERVER",
+        "startTimeUnixNano": "1544712660000000000",
+        "endTimeUnixNano": 1544712661000000000,
+        "links": [{"traceId": "5b8efff798038103d269b633813fc60d", "spanId": "eee19b7ec3c1b175"}],
+        "status": {"code": 2}
+      }]
+    }]
+  }]
+}`
+
+	records, _, err := trace.OtlpJsonTraceToArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), []byte(doc))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := trace.ArrowRecordsToOtlpJsonTrace(records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{`"traceId":"5b8efff798038103d269b633813fc60c"`, `"parentSpanId":"eee19b7ec3c1b173"`, `"spanId":"eee19b7ec3c1b175"`, `"kind":2`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("Expected %s in %s", expected, data)
+		}
+	}
+
+	expected := &coltracepb.ExportTraceServiceRequest{}
+	if err := common.UnmarshalOtlpJson([]byte(doc), expected); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	span := expected.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if len(span.TraceId) != 16 || span.TraceId[0] != 0x5b || span.Kind != tracepb.Span_SPAN_KIND_SERVER || span.EndTimeUnixNano != 1544712661000000000 {
+		t.Errorf("Unexpected span: %v", span)
+	}
+	result := &coltracepb.ExportTraceServiceRequest{}
+	if err := common.UnmarshalOtlpJson(data, result); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(flattenSpans(t, expected), flattenSpans(t, result), protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected spans (-expected +got):\n%s", diff)
+	}
+
+	for _, invalid := range []string{`{"resourceSpans": [{"scopeSpans": [{"spans": [{"traceId": "W47/95gDgQPSabYzgT/GDA=="}]}]}]}`, `{"resourceSpans": 1}`, `{} {}`, `{}}`} {
+		if _, _, err := trace.OtlpJsonTraceToArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), []byte(invalid)); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
+
+// flattenSpans returns the spans of a request embedded into a ResourceSpans/ScopeSpans pair (one pair per span) with
+// the attributes sorted by key and the spans sorted by content, so requests can be compared independently of the
+// grouping and of the order of the spans.
+func flattenSpans(t *testing.T, request *coltracepb.ExportTraceServiceRequest) []*tracepb.ResourceSpans {
+	t.Helper()
+
+	var result []*tracepb.ResourceSpans
+
+	for _, resourceSpans := range request.ResourceSpans {
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			for _, span := range scopeSpans.Spans {
+				resource := proto.Clone(resourceSpans.Resource).(*resourcepb.Resource)
+				scope := proto.Clone(scopeSpans.Scope).(*commonpb.InstrumentationScope)
+				span := proto.Clone(span).(*tracepb.Span)
+
+				flattened := &tracepb.ResourceSpans{
+					Resource:  resource,
+					SchemaUrl: resourceSpans.SchemaUrl,
+					ScopeSpans: []*tracepb.ScopeSpans{{
+						Scope:     scope,
+						SchemaUrl: scopeSpans.SchemaUrl,
+						Spans:     []*tracepb.Span{span},
+					}},
+				}
+				testutil.SortAttributes(flattened)
+				result = append(result, flattened)
+			}
+		}
+	}
+
+	testutil.SortByContent(t, result)
+	return result
+}
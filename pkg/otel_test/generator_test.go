@@ -0,0 +1,484 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otel_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	collogspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/logs/v1"
+	metricspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// requestGenerator builds random but valid OTLP requests from a fuzzer input. Every decision consumes bytes of the
+// input (zeros once the input is exhausted), so the fuzzer can minimize a failing input into a minimal request.
+//
+// The shape of every list item (e.g. the events of a span, the exemplars of a data point or the items of an array
+// attribute) is generated independently. The fields not encoded in Arrow yet are left unset.
+type requestGenerator struct {
+	data []byte
+	pos  int
+}
+
+func newRequestGenerator(data []byte) *requestGenerator {
+	return &requestGenerator{data: data}
+}
+
+func (g *requestGenerator) byte() byte {
+	if g.pos >= len(g.data) {
+		return 0
+	}
+	b := g.data[g.pos]
+	g.pos++
+	return b
+}
+
+// intn returns an int in [0, n).
+func (g *requestGenerator) intn(n int) int {
+	return int(g.byte()) % n
+}
+
+func (g *requestGenerator) bool() bool {
+	return g.byte()&1 == 1
+}
+
+func (g *requestGenerator) u64() uint64 {
+	var buf [8]byte
+	for i := range buf {
+		buf[i] = g.byte()
+	}
+	return binary.LittleEndian.Uint64(buf[:])
+}
+
+func (g *requestGenerator) u32() uint32 {
+	return uint32(g.u64())
+}
+
+func (g *requestGenerator) i64() int64 {
+	return int64(g.u64())
+}
+
+// f64 returns a finite float64 (NaN is not equal to itself and would break the comparisons).
+func (g *requestGenerator) f64() float64 {
+	value := math.Float64frombits(g.u64())
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return float64(g.i64())
+	}
+	return value
+}
+
+// str returns a short string picked from a small alphabet so that values repeat across rows.
+func (g *requestGenerator) str() string {
+	return fmt.Sprintf("s%d", g.intn(8))
+}
+
+func (g *requestGenerator) bytes(n int) []byte {
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = g.byte()
+	}
+	return buf
+}
+
+// id returns a trace or span id, or nil.
+func (g *requestGenerator) id(n int) []byte {
+	if !g.bool() {
+		return nil
+	}
+	id := g.bytes(n)
+	// An id made of zeros is indistinguishable from an unset id once minimized.
+	id[0] |= 1
+	return id
+}
+
+// scalarValue returns a random string, int, double, bool or bytes value.
+func (g *requestGenerator) scalarValue() *commonpb.AnyValue {
+	switch g.intn(5) {
+	case 0:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: g.str()}}
+	case 1:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: g.i64()}}
+	case 2:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: g.f64()}}
+	case 3:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: g.bool()}}
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: g.bytes(1 + g.intn(4))}}
+	}
+}
+
+// anyValue returns a scalar, a kvlist of scalars or an array.
+func (g *requestGenerator) anyValue() *commonpb.AnyValue {
+	switch g.intn(4) {
+	case 0:
+		return g.kvlistValue()
+	case 1:
+		return g.arrayValue()
+	default:
+		return g.scalarValue()
+	}
+}
+
+func (g *requestGenerator) kvlistValue() *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{
+		Values: g.scalarAttributes(),
+	}}}
+}
+
+// arrayValue returns an array of scalars of a single type or an array mixing scalars of different types and kvlists.
+func (g *requestGenerator) arrayValue() *commonpb.AnyValue {
+	values := make([]*commonpb.AnyValue, g.intn(4))
+	if g.bool() {
+		for i := range values {
+			values[i] = &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: g.str()}}
+		}
+	} else {
+		for i := range values {
+			if g.intn(4) == 0 {
+				values[i] = g.kvlistValue()
+			} else {
+				values[i] = g.scalarValue()
+			}
+		}
+	}
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+}
+
+// attributes returns up to 4 attributes with distinct keys.
+func (g *requestGenerator) attributes() []*commonpb.KeyValue {
+	count := g.intn(5)
+	attributes := make([]*commonpb.KeyValue, 0, count)
+	for i := 0; i < count; i++ {
+		attributes = append(attributes, &commonpb.KeyValue{Key: fmt.Sprintf("k%d", i), Value: g.anyValue()})
+	}
+	return attributes
+}
+
+func (g *requestGenerator) scalarAttributes() []*commonpb.KeyValue {
+	count := g.intn(3)
+	attributes := make([]*commonpb.KeyValue, 0, count)
+	for i := 0; i < count; i++ {
+		attributes = append(attributes, &commonpb.KeyValue{Key: fmt.Sprintf("k%d", i), Value: g.scalarValue()})
+	}
+	return attributes
+}
+
+func (g *requestGenerator) resource() *resourcepb.Resource {
+	return &resourcepb.Resource{Attributes: g.attributes(), DroppedAttributesCount: uint32(g.intn(3))}
+}
+
+func (g *requestGenerator) scope() *commonpb.InstrumentationScope {
+	return &commonpb.InstrumentationScope{Name: g.str(), Version: g.str()}
+}
+
+// schemaUrl returns an empty or a random schema URL.
+func (g *requestGenerator) schemaUrl() string {
+	if !g.bool() {
+		return ""
+	}
+	return fmt.Sprintf("https://opentelemetry.io/schemas/1.%d.0", g.intn(4))
+}
+
+// logsRequest returns a random ExportLogsServiceRequest.
+func (g *requestGenerator) logsRequest() *collogspb.ExportLogsServiceRequest {
+	request := &collogspb.ExportLogsServiceRequest{}
+	for i := g.intn(3); i >= 0; i-- {
+		resourceLogs := &logspb.ResourceLogs{Resource: g.resource(), SchemaUrl: g.schemaUrl()}
+		for j := g.intn(3); j >= 0; j-- {
+			scopeLogs := &logspb.ScopeLogs{Scope: g.scope(), SchemaUrl: g.schemaUrl()}
+			for k := g.intn(4); k >= 0; k-- {
+				log := &logspb.LogRecord{
+					TimeUnixNano:           g.u64(),
+					ObservedTimeUnixNano:   g.u64(),
+					SeverityNumber:         logspb.SeverityNumber(g.intn(25)),
+					Attributes:             g.attributes(),
+					DroppedAttributesCount: uint32(g.intn(3)),
+					Flags:                  uint32(g.intn(3)),
+					TraceId:                g.id(16),
+					SpanId:                 g.id(8),
+				}
+				if g.bool() {
+					log.SeverityText = g.str()
+				}
+				if g.bool() {
+					log.Body = g.anyValue()
+				}
+				scopeLogs.LogRecords = append(scopeLogs.LogRecords, log)
+			}
+			resourceLogs.ScopeLogs = append(resourceLogs.ScopeLogs, scopeLogs)
+		}
+		request.ResourceLogs = append(request.ResourceLogs, resourceLogs)
+	}
+	return request
+}
+
+// traceRequest returns a random ExportTraceServiceRequest.
+func (g *requestGenerator) traceRequest() *coltracepb.ExportTraceServiceRequest {
+	request := &coltracepb.ExportTraceServiceRequest{}
+	for i := g.intn(3); i >= 0; i-- {
+		resourceSpans := &tracepb.ResourceSpans{Resource: g.resource(), SchemaUrl: g.schemaUrl()}
+		for j := g.intn(3); j >= 0; j-- {
+			scopeSpans := &tracepb.ScopeSpans{Scope: g.scope(), SchemaUrl: g.schemaUrl()}
+			for k := g.intn(4); k >= 0; k-- {
+				span := &tracepb.Span{
+					TraceId:                g.id(16),
+					SpanId:                 g.id(8),
+					ParentSpanId:           g.id(8),
+					StartTimeUnixNano:      g.u64(),
+					EndTimeUnixNano:        g.u64(),
+					Kind:                   tracepb.Span_SpanKind(g.intn(6)),
+					Attributes:             g.attributes(),
+					DroppedAttributesCount: uint32(g.intn(3)),
+					DroppedEventsCount:     uint32(g.intn(3)),
+					DroppedLinksCount:      uint32(g.intn(3)),
+				}
+				if g.bool() {
+					span.Name = g.str()
+				}
+				if g.bool() {
+					span.TraceState = g.str()
+				}
+				if g.bool() {
+					span.Status = &tracepb.Status{Code: tracepb.Status_StatusCode(g.intn(3)), Message: g.str()}
+				}
+				span.Events = g.events()
+				span.Links = g.links()
+				scopeSpans.Spans = append(scopeSpans.Spans, span)
+			}
+			resourceSpans.ScopeSpans = append(resourceSpans.ScopeSpans, scopeSpans)
+		}
+		request.ResourceSpans = append(request.ResourceSpans, resourceSpans)
+	}
+	return request
+}
+
+// events returns the events of a span, each event has its own shape.
+func (g *requestGenerator) events() []*tracepb.Span_Event {
+	events := make([]*tracepb.Span_Event, g.intn(3))
+	for i := range events {
+		events[i] = &tracepb.Span_Event{
+			TimeUnixNano:           g.u64(),
+			Attributes:             g.attributes(),
+			DroppedAttributesCount: uint32(g.intn(3)),
+		}
+		if g.bool() {
+			events[i].Name = g.str()
+		}
+	}
+	return events
+}
+
+// links returns the links of a span, each link has its own shape.
+func (g *requestGenerator) links() []*tracepb.Span_Link {
+	links := make([]*tracepb.Span_Link, g.intn(3))
+	for i := range links {
+		links[i] = &tracepb.Span_Link{
+			TraceId:                g.id(16),
+			SpanId:                 g.id(8),
+			Attributes:             g.attributes(),
+			DroppedAttributesCount: uint32(g.intn(3)),
+		}
+		if g.bool() {
+			links[i].TraceState = g.str()
+		}
+	}
+	return links
+}
+
+// metricsRequest returns a random ExportMetricsServiceRequest.
+func (g *requestGenerator) metricsRequest() *colmetricspb.ExportMetricsServiceRequest {
+	request := &colmetricspb.ExportMetricsServiceRequest{}
+	for i := g.intn(3); i >= 0; i-- {
+		resourceMetrics := &metricspb.ResourceMetrics{Resource: g.resource(), SchemaUrl: g.schemaUrl()}
+		for j := g.intn(3); j >= 0; j-- {
+			scopeMetrics := &metricspb.ScopeMetrics{Scope: g.scope(), SchemaUrl: g.schemaUrl()}
+			for k := g.intn(4); k >= 0; k-- {
+				scopeMetrics.Metrics = append(scopeMetrics.Metrics, g.metric())
+			}
+			resourceMetrics.ScopeMetrics = append(resourceMetrics.ScopeMetrics, scopeMetrics)
+		}
+		request.ResourceMetrics = append(request.ResourceMetrics, resourceMetrics)
+	}
+	return request
+}
+
+// metric returns a metric of a random type with at least one data point.
+func (g *requestGenerator) metric() *metricspb.Metric {
+	metric := &metricspb.Metric{Name: fmt.Sprintf("metric%d", g.intn(4))}
+	if g.bool() {
+		metric.Description = g.str()
+	}
+	if g.bool() {
+		metric.Unit = []string{"1", "s", "By"}[g.intn(3)]
+	}
+	count := 1 + g.intn(3)
+	switch g.intn(5) {
+	case 0:
+		gauge := &metricspb.Gauge{}
+		for i := 0; i < count; i++ {
+			gauge.DataPoints = append(gauge.DataPoints, g.numberDataPoint())
+		}
+		metric.Data = &metricspb.Metric_Gauge{Gauge: gauge}
+	case 1:
+		sum := &metricspb.Sum{AggregationTemporality: g.temporality(), IsMonotonic: g.bool()}
+		for i := 0; i < count; i++ {
+			sum.DataPoints = append(sum.DataPoints, g.numberDataPoint())
+		}
+		metric.Data = &metricspb.Metric_Sum{Sum: sum}
+	case 2:
+		histogram := &metricspb.Histogram{AggregationTemporality: g.temporality()}
+		for i := 0; i < count; i++ {
+			histogram.DataPoints = append(histogram.DataPoints, g.histogramDataPoint())
+		}
+		metric.Data = &metricspb.Metric_Histogram{Histogram: histogram}
+	case 3:
+		histogram := &metricspb.ExponentialHistogram{AggregationTemporality: g.temporality()}
+		for i := 0; i < count; i++ {
+			histogram.DataPoints = append(histogram.DataPoints, g.expHistogramDataPoint())
+		}
+		metric.Data = &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: histogram}
+	default:
+		summary := &metricspb.Summary{}
+		for i := 0; i < count; i++ {
+			summary.DataPoints = append(summary.DataPoints, g.summaryDataPoint())
+		}
+		metric.Data = &metricspb.Metric_Summary{Summary: summary}
+	}
+	return metric
+}
+
+func (g *requestGenerator) temporality() metricspb.AggregationTemporality {
+	return metricspb.AggregationTemporality(g.intn(3))
+}
+
+func (g *requestGenerator) numberDataPoint() *metricspb.NumberDataPoint {
+	dataPoint := &metricspb.NumberDataPoint{
+		Attributes:        g.attributes(),
+		StartTimeUnixNano: g.u64(),
+		TimeUnixNano:      g.u64(),
+		Flags:             uint32(g.intn(2)),
+		Exemplars:         g.exemplars(),
+	}
+	if g.bool() {
+		dataPoint.Value = &metricspb.NumberDataPoint_AsInt{AsInt: g.i64()}
+	} else {
+		dataPoint.Value = &metricspb.NumberDataPoint_AsDouble{AsDouble: g.f64()}
+	}
+	return dataPoint
+}
+
+func (g *requestGenerator) histogramDataPoint() *metricspb.HistogramDataPoint {
+	dataPoint := &metricspb.HistogramDataPoint{
+		Attributes:        g.attributes(),
+		StartTimeUnixNano: g.u64(),
+		TimeUnixNano:      g.u64(),
+		Count:             g.u64(),
+		Sum:               g.optionalF64(),
+		Min:               g.optionalF64(),
+		Max:               g.optionalF64(),
+		Flags:             uint32(g.intn(2)),
+		Exemplars:         g.exemplars(),
+	}
+	for i := g.intn(4); i > 0; i-- {
+		dataPoint.BucketCounts = append(dataPoint.BucketCounts, g.u64())
+	}
+	for i := g.intn(4); i > 0; i-- {
+		dataPoint.ExplicitBounds = append(dataPoint.ExplicitBounds, g.f64())
+	}
+	return dataPoint
+}
+
+func (g *requestGenerator) expHistogramDataPoint() *metricspb.ExponentialHistogramDataPoint {
+	dataPoint := &metricspb.ExponentialHistogramDataPoint{
+		Attributes:        g.attributes(),
+		StartTimeUnixNano: g.u64(),
+		TimeUnixNano:      g.u64(),
+		Count:             g.u64(),
+		Sum:               g.optionalF64(),
+		Min:               g.optionalF64(),
+		Max:               g.optionalF64(),
+		Scale:             int32(g.intn(20)) - 10,
+		ZeroCount:         g.u64(),
+		Positive:          g.expHistogramBuckets(),
+		Negative:          g.expHistogramBuckets(),
+		Flags:             uint32(g.intn(2)),
+		Exemplars:         g.exemplars(),
+	}
+	return dataPoint
+}
+
+// exemplars returns the exemplars of a data point, each exemplar has its own shape.
+func (g *requestGenerator) exemplars() []*metricspb.Exemplar {
+	exemplars := make([]*metricspb.Exemplar, g.intn(3))
+	for i := range exemplars {
+		exemplars[i] = &metricspb.Exemplar{
+			FilteredAttributes: g.attributes(),
+			TimeUnixNano:       g.u64(),
+			TraceId:            g.id(16),
+			SpanId:             g.id(8),
+		}
+		switch g.intn(3) {
+		case 0:
+			exemplars[i].Value = &metricspb.Exemplar_AsInt{AsInt: g.i64()}
+		case 1:
+			exemplars[i].Value = &metricspb.Exemplar_AsDouble{AsDouble: g.f64()}
+		}
+	}
+	return exemplars
+}
+
+func (g *requestGenerator) expHistogramBuckets() *metricspb.ExponentialHistogramDataPoint_Buckets {
+	if !g.bool() {
+		return nil
+	}
+	buckets := &metricspb.ExponentialHistogramDataPoint_Buckets{Offset: int32(g.intn(20)) - 10}
+	for i := g.intn(4); i > 0; i-- {
+		buckets.BucketCounts = append(buckets.BucketCounts, g.u64())
+	}
+	return buckets
+}
+
+func (g *requestGenerator) summaryDataPoint() *metricspb.SummaryDataPoint {
+	dataPoint := &metricspb.SummaryDataPoint{
+		Attributes:        g.attributes(),
+		StartTimeUnixNano: g.u64(),
+		TimeUnixNano:      g.u64(),
+		Count:             g.u64(),
+		Sum:               g.f64(),
+		Flags:             uint32(g.intn(2)),
+	}
+	for i := g.intn(4); i > 0; i-- {
+		dataPoint.QuantileValues = append(dataPoint.QuantileValues, &metricspb.SummaryDataPoint_ValueAtQuantile{
+			Quantile: g.f64(),
+			Value:    g.f64(),
+		})
+	}
+	return dataPoint
+}
+
+func (g *requestGenerator) optionalF64() *float64 {
+	if !g.bool() {
+		return nil
+	}
+	value := g.f64()
+	return &value
+}
@@ -0,0 +1,474 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otel_test
+
+// Round-trip properties OTLP -> Arrow -> OTLP. The requests are generated from the fuzzer input (see
+// `requestGenerator`) and compared after normalization (see `normalizeXXX`).
+//
+// The seed corpus is run by `go test`. To fuzz a signal:
+//
+//	go test ./pkg/otel_test -run '^$' -fuzz FuzzLogsRoundTrip -fuzzminimizetime 200x
+//
+// Every new interesting input is minimized before fuzzing resumes (for up to 60s by default, without counting these
+// executions), `-fuzzminimizetime` bounds this minimization.
+//
+// A failing input is minimized by the fuzzer and stored in testdata/fuzz/<FuzzTarget>; the error message contains the
+// corresponding request.
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/apache/arrow/go/v9/arrow"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	collogspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	logspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/logs/v1"
+	metricspb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "otel-arrow-adapter/api/go.opentelemetry.io/proto/otlp/trace/v1"
+	"otel-arrow-adapter/pkg/air"
+	"otel-arrow-adapter/pkg/air/config"
+	"otel-arrow-adapter/pkg/otel/common"
+	"otel-arrow-adapter/pkg/otel/logs"
+	"otel-arrow-adapter/pkg/otel/metrics"
+	"otel-arrow-adapter/pkg/otel/testutil"
+	"otel-arrow-adapter/pkg/otel/trace"
+)
+
+func FuzzLogsRoundTrip(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		request := newRequestGenerator(data).logsRequest()
+
+		records, rejected, err := logs.OtlpLogsToArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), request)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(request))
+		}
+		if rejected != nil {
+			t.Fatalf("Unexpected rejected items: %s\nrequest:\n%s", rejected.ErrorMessage(), prototext.Format(request))
+		}
+		result, err := logs.ArrowRecordsToOtlpLogs(records)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(request))
+		}
+		checkLogsRoundTrip(t, request, result)
+	})
+}
+
+// checkLogsRoundTrip compares the log records of the original request and of the converted request.
+func checkLogsRoundTrip(t *testing.T, request *collogspb.ExportLogsServiceRequest, result *collogspb.ExportLogsServiceRequest) {
+	t.Helper()
+
+	var expected, got []proto.Message
+	for _, resourceLogs := range request.ResourceLogs {
+		expected = append(expected, normalizeLogs(resourceLogs)...)
+	}
+	for _, resourceLogs := range result.ResourceLogs {
+		got = append(got, normalizeLogs(resourceLogs)...)
+	}
+	checkRoundTrip(t, request, expected, got)
+}
+
+func FuzzTraceRoundTrip(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		request := newRequestGenerator(data).traceRequest()
+
+		records, rejected, err := trace.OtlpTraceToArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), request)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(request))
+		}
+		if rejected != nil {
+			t.Fatalf("Unexpected rejected items: %s\nrequest:\n%s", rejected.ErrorMessage(), prototext.Format(request))
+		}
+		result, err := trace.ArrowRecordsToOtlpTrace(records)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(request))
+		}
+		checkTraceRoundTrip(t, request, result)
+	})
+}
+
+func FuzzNormalizedTraceRoundTrip(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		request := newRequestGenerator(data).traceRequest()
+
+		records, rejected, err := trace.OtlpTraceToNormalizedArrowRecords(trace.NewNormalizedRecordRepository(config.NewDefaultConfig()), request)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(request))
+		}
+		if rejected != nil {
+			t.Fatalf("Unexpected rejected items: %s\nrequest:\n%s", rejected.ErrorMessage(), prototext.Format(request))
+		}
+		result, err := trace.NormalizedArrowRecordsToOtlpTrace(records)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(request))
+		}
+		checkTraceRoundTrip(t, request, result)
+	})
+}
+
+// checkTraceRoundTrip compares the spans of the original request and of the converted request.
+func checkTraceRoundTrip(t *testing.T, request *coltracepb.ExportTraceServiceRequest, result *coltracepb.ExportTraceServiceRequest) {
+	t.Helper()
+
+	var expected, got []proto.Message
+	for _, resourceSpans := range request.ResourceSpans {
+		expected = append(expected, normalizeSpans(resourceSpans)...)
+	}
+	for _, resourceSpans := range result.ResourceSpans {
+		got = append(got, normalizeSpans(resourceSpans)...)
+	}
+	checkRoundTrip(t, request, expected, got)
+}
+
+func FuzzMetricsRoundTrip(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		request := newRequestGenerator(data).metricsRequest()
+
+		multiSchemaRecords, rejected, err := metrics.OtlpMetricsToArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), request, &metrics.MultivariateMetricsConfig{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(request))
+		}
+		if rejected != nil {
+			t.Fatalf("Unexpected rejected items: %s\nrequest:\n%s", rejected.ErrorMessage(), prototext.Format(request))
+		}
+		result, err := metrics.ArrowRecordsToOtlpMetrics(metricsRecords(multiSchemaRecords))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(request))
+		}
+		checkMetricsRoundTrip(t, request, result)
+	})
+}
+
+func FuzzWideMetricsRoundTrip(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		request := newRequestGenerator(data).metricsRequest()
+
+		multiSchemaRecords, rejected, err := metrics.OtlpMetricsToWideArrowRecords(air.NewRecordRepository(config.NewDefaultConfig()), request)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(request))
+		}
+		if rejected != nil {
+			t.Fatalf("Unexpected rejected items: %s\nrequest:\n%s", rejected.ErrorMessage(), prototext.Format(request))
+		}
+		result, err := metrics.ArrowRecordsToOtlpMetrics(metricsRecords(multiSchemaRecords))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(request))
+		}
+		checkMetricsRoundTrip(t, request, result)
+	})
+}
+
+// FuzzReferencesRoundTrip converts the three signals with the resources and scopes deduplicated into reference tables.
+// The same reference tables are used for the three batches.
+func FuzzReferencesRoundTrip(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		generator := newRequestGenerator(data)
+		cfg := config.NewDefaultConfig()
+		refs := common.NewReferenceTables(cfg)
+		opts := &common.EncodingOptions{References: refs}
+
+		logsRequest := generator.logsRequest()
+		logsRecords, rejected, err := logs.OtlpLogsToArrowRecordsWithOptions(air.NewRecordRepository(cfg), opts, logsRequest)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(logsRequest))
+		}
+		if rejected != nil {
+			t.Fatalf("Unexpected rejected items: %s\nrequest:\n%s", rejected.ErrorMessage(), prototext.Format(logsRequest))
+		}
+		tables := buildReferences(t, refs)
+		logsResult, err := logs.ArrowRecordsToOtlpLogsWithReferences(logsRecords, tables)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(logsRequest))
+		}
+		checkLogsRoundTrip(t, logsRequest, logsResult)
+
+		traceRequest := generator.traceRequest()
+		traceRecords, rejected, err := trace.OtlpTraceToArrowRecordsWithOptions(air.NewRecordRepository(cfg), opts, traceRequest)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(traceRequest))
+		}
+		if rejected != nil {
+			t.Fatalf("Unexpected rejected items: %s\nrequest:\n%s", rejected.ErrorMessage(), prototext.Format(traceRequest))
+		}
+		tables = buildReferences(t, refs)
+		traceResult, err := trace.ArrowRecordsToOtlpTraceWithReferences(traceRecords, tables)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(traceRequest))
+		}
+		checkTraceRoundTrip(t, traceRequest, traceResult)
+
+		metricsRequest := generator.metricsRequest()
+		multiSchemaRecords, rejected, err := metrics.OtlpMetricsToArrowRecordsWithOptions(air.NewRecordRepository(cfg), opts, metricsRequest, &metrics.MultivariateMetricsConfig{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(metricsRequest))
+		}
+		if rejected != nil {
+			t.Fatalf("Unexpected rejected items: %s\nrequest:\n%s", rejected.ErrorMessage(), prototext.Format(metricsRequest))
+		}
+		tables = buildReferences(t, refs)
+		metricsResult, err := metrics.ArrowRecordsToOtlpMetricsWithReferences(metricsRecords(multiSchemaRecords), tables)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(metricsRequest))
+		}
+		checkMetricsRoundTrip(t, metricsRequest, metricsResult)
+	})
+}
+
+// FuzzFlattenedAttributesRoundTrip converts the three signals with the attributes flattened into one column per
+// attribute.
+func FuzzFlattenedAttributesRoundTrip(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		generator := newRequestGenerator(data)
+		cfg := config.NewDefaultConfig()
+		opts := &common.EncodingOptions{FlattenAttributes: true}
+
+		logsRequest := generator.logsRequest()
+		logsRecords, rejected, err := logs.OtlpLogsToArrowRecordsWithOptions(air.NewRecordRepository(cfg), opts, logsRequest)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(logsRequest))
+		}
+		if rejected != nil {
+			t.Fatalf("Unexpected rejected items: %s\nrequest:\n%s", rejected.ErrorMessage(), prototext.Format(logsRequest))
+		}
+		logsResult, err := logs.ArrowRecordsToOtlpLogs(logsRecords)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(logsRequest))
+		}
+		checkLogsRoundTrip(t, logsRequest, logsResult)
+
+		traceRequest := generator.traceRequest()
+		traceRecords, rejected, err := trace.OtlpTraceToArrowRecordsWithOptions(air.NewRecordRepository(cfg), opts, traceRequest)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(traceRequest))
+		}
+		if rejected != nil {
+			t.Fatalf("Unexpected rejected items: %s\nrequest:\n%s", rejected.ErrorMessage(), prototext.Format(traceRequest))
+		}
+		traceResult, err := trace.ArrowRecordsToOtlpTrace(traceRecords)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(traceRequest))
+		}
+		checkTraceRoundTrip(t, traceRequest, traceResult)
+
+		metricsRequest := generator.metricsRequest()
+		multiSchemaRecords, rejected, err := metrics.OtlpMetricsToArrowRecordsWithOptions(air.NewRecordRepository(cfg), opts, metricsRequest, &metrics.MultivariateMetricsConfig{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(metricsRequest))
+		}
+		if rejected != nil {
+			t.Fatalf("Unexpected rejected items: %s\nrequest:\n%s", rejected.ErrorMessage(), prototext.Format(metricsRequest))
+		}
+		metricsResult, err := metrics.ArrowRecordsToOtlpMetrics(metricsRecords(multiSchemaRecords))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v\nrequest:\n%s", err, prototext.Format(metricsRequest))
+		}
+		checkMetricsRoundTrip(t, metricsRequest, metricsResult)
+	})
+}
+
+func buildReferences(t *testing.T, refs *common.ReferenceTables) *common.ReferenceRecords {
+	t.Helper()
+
+	tables, err := refs.Build()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return tables
+}
+
+// metricsRecords returns the records of all the schemas.
+func metricsRecords(multiSchemaRecords map[string][]arrow.Record) []arrow.Record {
+	var records []arrow.Record
+	for _, schemaRecords := range multiSchemaRecords {
+		records = append(records, schemaRecords...)
+	}
+	return records
+}
+
+// checkMetricsRoundTrip compares the metrics of the original request and of the converted request.
+func checkMetricsRoundTrip(t *testing.T, request *colmetricspb.ExportMetricsServiceRequest, result *colmetricspb.ExportMetricsServiceRequest) {
+	t.Helper()
+
+	var expected, got []proto.Message
+	for _, resourceMetrics := range request.ResourceMetrics {
+		expected = append(expected, normalizeMetrics(resourceMetrics)...)
+	}
+	for _, resourceMetrics := range result.ResourceMetrics {
+		got = append(got, normalizeMetrics(resourceMetrics)...)
+	}
+	checkRoundTrip(t, request, expected, got)
+}
+
+// addSeeds adds a deterministic set of random inputs to the seed corpus.
+func addSeeds(f *testing.F) {
+	rng := rand.New(rand.NewSource(42))
+	f.Add([]byte{})
+	for i := 0; i < 50; i++ {
+		seed := make([]byte, 64+rng.Intn(1024))
+		rng.Read(seed)
+		f.Add(seed)
+	}
+}
+
+// checkRoundTrip compares the normalized messages independently of their order.
+func checkRoundTrip(t *testing.T, request proto.Message, expected []proto.Message, got []proto.Message) {
+	t.Helper()
+	testutil.SortByContent(t, expected)
+	testutil.SortByContent(t, got)
+	// cmp.Diff is slow (it dominated the fuzzing time), it only runs to report a difference.
+	if equalMessages(expected, got) {
+		return
+	}
+	if diff := cmp.Diff(expected, got, protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected round trip (-expected +got):\n%s\nrequest:\n%s", diff, prototext.Format(request))
+	}
+}
+
+// equalMessages returns true if both lists contain equal messages in the same order.
+func equalMessages(expected []proto.Message, got []proto.Message) bool {
+	if len(expected) != len(got) {
+		return false
+	}
+	for i := range expected {
+		if !proto.Equal(expected[i], got[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeLogs returns the log records of a ResourceLogs embedded into a ResourceLogs/ScopeLogs pair (one pair per
+// log record) with the attributes sorted by key.
+func normalizeLogs(resourceLogs *logspb.ResourceLogs) []proto.Message {
+	var result []proto.Message
+	for _, scopeLogs := range resourceLogs.ScopeLogs {
+		for _, log := range scopeLogs.LogRecords {
+			normalized := &logspb.ResourceLogs{
+				Resource:  resourceLogs.Resource,
+				SchemaUrl: resourceLogs.SchemaUrl,
+				ScopeLogs: []*logspb.ScopeLogs{{
+					Scope:      scopeLogs.Scope,
+					SchemaUrl:  scopeLogs.SchemaUrl,
+					LogRecords: []*logspb.LogRecord{log},
+				}},
+			}
+			normalized = proto.Clone(normalized).(*logspb.ResourceLogs)
+			testutil.SortAttributes(normalized)
+			result = append(result, normalized)
+		}
+	}
+	return result
+}
+
+// normalizeSpans returns the spans of a ResourceSpans embedded into a ResourceSpans/ScopeSpans pair (one pair per
+// span) with the attributes sorted by key.
+func normalizeSpans(resourceSpans *tracepb.ResourceSpans) []proto.Message {
+	var result []proto.Message
+	for _, scopeSpans := range resourceSpans.ScopeSpans {
+		for _, span := range scopeSpans.Spans {
+			normalized := &tracepb.ResourceSpans{
+				Resource:  resourceSpans.Resource,
+				SchemaUrl: resourceSpans.SchemaUrl,
+				ScopeSpans: []*tracepb.ScopeSpans{{
+					Scope:     scopeSpans.Scope,
+					SchemaUrl: scopeSpans.SchemaUrl,
+					Spans:     []*tracepb.Span{span},
+				}},
+			}
+			normalized = proto.Clone(normalized).(*tracepb.ResourceSpans)
+			testutil.SortAttributes(normalized)
+			result = append(result, normalized)
+		}
+	}
+	return result
+}
+
+// normalizeMetrics returns the data points of a ResourceMetrics embedded into a ResourceMetrics/ScopeMetrics/Metric
+// (one per data point) with the attributes sorted by key.
+func normalizeMetrics(resourceMetrics *metricspb.ResourceMetrics) []proto.Message {
+	var result []proto.Message
+	for _, scopeMetrics := range resourceMetrics.ScopeMetrics {
+		for _, metric := range scopeMetrics.Metrics {
+			for _, single := range splitDataPoints(metric) {
+				normalized := &metricspb.ResourceMetrics{
+					Resource:  resourceMetrics.Resource,
+					SchemaUrl: resourceMetrics.SchemaUrl,
+					ScopeMetrics: []*metricspb.ScopeMetrics{{
+						Scope:     scopeMetrics.Scope,
+						SchemaUrl: scopeMetrics.SchemaUrl,
+						Metrics:   []*metricspb.Metric{single},
+					}},
+				}
+				normalized = proto.Clone(normalized).(*metricspb.ResourceMetrics)
+				testutil.SortAttributes(normalized)
+				result = append(result, normalized)
+			}
+		}
+	}
+	return result
+}
+
+// splitDataPoints returns one copy of the metric per data point.
+func splitDataPoints(metric *metricspb.Metric) []*metricspb.Metric {
+	var result []*metricspb.Metric
+	single := func(data func(m *metricspb.Metric)) {
+		m := proto.Clone(metric).(*metricspb.Metric)
+		data(m)
+		result = append(result, m)
+	}
+
+	switch data := metric.Data.(type) {
+	case *metricspb.Metric_Gauge:
+		for _, dataPoint := range data.Gauge.DataPoints {
+			dataPoint := dataPoint
+			single(func(m *metricspb.Metric) { m.GetGauge().DataPoints = []*metricspb.NumberDataPoint{dataPoint} })
+		}
+	case *metricspb.Metric_Sum:
+		for _, dataPoint := range data.Sum.DataPoints {
+			dataPoint := dataPoint
+			single(func(m *metricspb.Metric) { m.GetSum().DataPoints = []*metricspb.NumberDataPoint{dataPoint} })
+		}
+	case *metricspb.Metric_Histogram:
+		for _, dataPoint := range data.Histogram.DataPoints {
+			dataPoint := dataPoint
+			single(func(m *metricspb.Metric) { m.GetHistogram().DataPoints = []*metricspb.HistogramDataPoint{dataPoint} })
+		}
+	case *metricspb.Metric_ExponentialHistogram:
+		for _, dataPoint := range data.ExponentialHistogram.DataPoints {
+			dataPoint := dataPoint
+			single(func(m *metricspb.Metric) {
+				m.GetExponentialHistogram().DataPoints = []*metricspb.ExponentialHistogramDataPoint{dataPoint}
+			})
+		}
+	case *metricspb.Metric_Summary:
+		for _, dataPoint := range data.Summary.DataPoints {
+			dataPoint := dataPoint
+			single(func(m *metricspb.Metric) { m.GetSummary().DataPoints = []*metricspb.SummaryDataPoint{dataPoint} })
+		}
+	}
+	return result
+}